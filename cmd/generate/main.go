@@ -0,0 +1,157 @@
+// Command generate produces synthetic work packages for demos and load tests of the Gantt and
+// resource views, and writes them as NDJSON or straight into the work package collection.
+//
+// Usage:
+//
+//	go run ./cmd/generate [flags]
+//
+// The output only depends on the flags: the same -seed, -start, -end and -now always produce
+// the same work packages. -start, -end and -now default to dates around today, so give them
+// explicitly to reproduce a data set on another day. The NDJSON output can be loaded with
+// cmd/import.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/database"
+	"stationMonitor/internal/generator"
+	"stationMonitor/internal/importer"
+	"stationMonitor/internal/models"
+)
+
+func main() {
+	configPath := flag.String("config", "config/config.yaml", "config file with the MongoDB URL (MONGODB_URL overrides it)")
+	seed := flag.Int64("seed", 1, "random seed")
+	aircraft := flag.Int("aircraft", 20, "number of aircraft")
+	stations := flag.String("stations", "AMS,LHR,JFK", "comma-separated LocationCodes; the first two are the home bases")
+	start := flag.String("start", "", "first day of the period, YYYY-MM-DD (default 60 days before -now)")
+	end := flag.String("end", "", "last day of the period, YYYY-MM-DD (default 30 days after -now)")
+	now := flag.String("now", "", "date and time that separates actuals from plans, YYYY-MM-DD or RFC 3339 (default today)")
+	firstId := flag.Int("first-id", 100000, "AircraftWorkPackageIds are numbered after this one")
+	output := flag.String("output", "-", "NDJSON file to write, \"-\" for stdout")
+	toMongo := flag.Bool("mongo", false, "upsert into the database instead of writing NDJSON")
+	collectionName := flag.String("collection", database.WorkPackageCollection, "collection to write to with -mongo")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: generate [flags]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	opts := generator.Options{
+		Seed:               *seed,
+		Aircraft:           *aircraft,
+		FirstWorkPackageId: *firstId,
+	}
+	for _, station := range strings.Split(*stations, ",") {
+		if station = strings.TrimSpace(station); station != "" {
+			opts.Stations = append(opts.Stations, station)
+		}
+	}
+
+	var err error
+	opts.Now = time.Now().UTC().Truncate(24 * time.Hour)
+	if *now != "" {
+		if opts.Now, err = parseTime(*now); err != nil {
+			log.Fatalf("Invalid -now: %v", err)
+		}
+	}
+	opts.Start = opts.Now.Truncate(24*time.Hour).AddDate(0, 0, -60)
+	if *start != "" {
+		if opts.Start, err = time.Parse("2006-01-02", *start); err != nil {
+			log.Fatalf("Invalid -start: %v", err)
+		}
+	}
+	opts.End = opts.Now.Truncate(24*time.Hour).AddDate(0, 0, 30)
+	if *end != "" {
+		if opts.End, err = time.Parse("2006-01-02", *end); err != nil {
+			log.Fatalf("Invalid -end: %v", err)
+		}
+	}
+	opts.End = opts.End.AddDate(0, 0, 1)
+
+	var emit func(models.AircraftWorkPackage) error
+	var done func() error
+	if *toMongo {
+		mongoURL := os.Getenv("MONGODB_URL")
+		if mongoURL == "" {
+			cfg, err := config.LoadConfig(*configPath)
+			if err != nil {
+				log.Fatalf("Failed to load config: %v", err)
+			}
+			mongoURL = cfg.MongoDB.URL
+		}
+
+		db, err := database.Connect(mongoURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to MongoDB: %v", err)
+		}
+		defer database.Disconnect()
+		collection := db.Collection(*collectionName)
+
+		counts := map[string]int{}
+		emit = func(wp models.AircraftWorkPackage) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			outcome, err := importer.Upsert(ctx, collection, wp)
+			if err != nil {
+				return fmt.Errorf("work package %d: %w", wp.AircraftWorkPackageId, err)
+			}
+			counts[outcome]++
+			return nil
+		}
+		done = func() error {
+			fmt.Printf("Generated into %s: %d inserted, %d updated, %d unchanged\n", *collectionName,
+				counts[importer.Inserted], counts[importer.Updated], counts[importer.Unchanged])
+			return nil
+		}
+	} else {
+		file := os.Stdout
+		if *output != "-" {
+			if file, err = os.Create(*output); err != nil {
+				log.Fatalf("Failed to create %s: %v", *output, err)
+			}
+			defer file.Close()
+		}
+		writer := bufio.NewWriter(file)
+		encoder := json.NewEncoder(writer)
+		count := 0
+		emit = func(wp models.AircraftWorkPackage) error {
+			count++
+			return encoder.Encode(wp)
+		}
+		done = func() error {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if *output != "-" {
+				fmt.Fprintf(os.Stderr, "Wrote %d work packages to %s\n", count, *output)
+			}
+			return nil
+		}
+	}
+
+	if err := generator.Generate(opts, emit); err != nil {
+		log.Fatalf("Failed to generate work packages: %v", err)
+	}
+	if err := done(); err != nil {
+		log.Fatalf("Failed to write work packages: %v", err)
+	}
+}
+
+// parseTime accepts a date, taken as midnight UTC, or an RFC 3339 date and time
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,156 @@
+// Command import loads work package exports into the work package collection.
+//
+// Usage:
+//
+//	go run ./cmd/import [flags] file...
+//
+// Files may be JSON (a work package or an array of them), NDJSON (one per line) or an
+// OData collection response ({"value": [...]}); use "-" for stdin. Work packages are
+// upserted by AircraftWorkPackageId, so importing the same file twice is harmless.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/database"
+	"stationMonitor/internal/importer"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// options are the command line flags of an import run
+type options struct {
+	configPath     string
+	collectionName string
+	format         string
+	strict         bool
+	dryRun         bool
+	paths          []string
+}
+
+func main() {
+	configPath := flag.String("config", "config/config.yaml", "config file with the MongoDB URL (MONGODB_URL overrides it)")
+	collectionName := flag.String("collection", database.WorkPackageCollection, "collection to import into")
+	format := flag.String("format", importer.FormatAuto, "input format: auto, json, ndjson or odata")
+	strict := flag.Bool("strict", false, "reject fields that are not part of the AircraftWorkPackage model")
+	dryRun := flag.Bool("dry-run", false, "validate only, do not write to the database")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: import [flags] file...\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	os.Exit(run(options{
+		configPath:     *configPath,
+		collectionName: *collectionName,
+		format:         *format,
+		strict:         *strict,
+		dryRun:         *dryRun,
+		paths:          flag.Args(),
+	}))
+}
+
+// run imports the files and returns the exit code, 1 if any file or record failed. It returns
+// instead of exiting so the deferred disconnect runs
+func run(opts options) int {
+	var collection *mongo.Collection
+	if !opts.dryRun {
+		mongoURL := os.Getenv("MONGODB_URL")
+		if mongoURL == "" {
+			cfg, err := config.LoadConfig(opts.configPath)
+			if err != nil {
+				log.Printf("Failed to load config: %v", err)
+				return 1
+			}
+			mongoURL = cfg.MongoDB.URL
+		}
+
+		db, err := database.Connect(mongoURL)
+		if err != nil {
+			log.Printf("Failed to connect to MongoDB: %v", err)
+			return 1
+		}
+		defer database.Disconnect()
+		collection = db.Collection(opts.collectionName)
+	}
+
+	counts := map[string]int{}
+	failed := 0
+	seen := map[int]string{}
+
+	for _, path := range opts.paths {
+		records, err := readFile(path, opts.format, opts.strict)
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			failed++
+			continue
+		}
+
+		for _, record := range records {
+			if record.Err != nil {
+				fmt.Printf("%s: %s: %v\n", path, record.Location(), record.Err)
+				failed++
+				continue
+			}
+
+			wpId := record.WorkPackage.AircraftWorkPackageId
+			if previous, duplicate := seen[wpId]; duplicate {
+				fmt.Printf("%s: %s: warning: also in %s, the later record wins\n", path, record.Location(), previous)
+			}
+			seen[wpId] = fmt.Sprintf("%s %s", path, record.Location())
+
+			if opts.dryRun {
+				counts["valid"]++
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			outcome, err := importer.Upsert(ctx, collection, record.WorkPackage)
+			cancel()
+			if err != nil {
+				fmt.Printf("%s: %s: failed to store: %v\n", path, record.Location(), err)
+				failed++
+				continue
+			}
+			counts[outcome]++
+		}
+	}
+
+	if opts.dryRun {
+		fmt.Printf("Dry run: %d valid, %d invalid\n", counts["valid"], failed)
+	} else {
+		fmt.Printf("Imported into %s: %d inserted, %d updated, %d unchanged, %d failed\n", opts.collectionName,
+			counts[importer.Inserted], counts[importer.Updated], counts[importer.Unchanged], failed)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readFile reads the records of one input file, "-" being stdin
+func readFile(path, format string, strict bool) ([]importer.Record, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	return importer.Read(r, format, strict)
+}
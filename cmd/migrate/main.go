@@ -0,0 +1,85 @@
+// Command migrate applies the database schema migrations or shows their status.
+//
+// Usage:
+//
+//	go run ./cmd/migrate [flags] [up|status]
+//
+// The server applies pending migrations at startup unless mongodb.skip_migrations is set.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/database"
+)
+
+func main() {
+	configPath := flag.String("config", "config/config.yaml", "config file with the MongoDB URL (MONGODB_URL overrides it)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: migrate [flags] [up|status]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command := "up"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+	if command != "up" && command != "status" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	mongoURL := os.Getenv("MONGODB_URL")
+	if mongoURL == "" {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		mongoURL = cfg.MongoDB.URL
+	}
+
+	db, err := database.Connect(mongoURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer database.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	if command == "up" {
+		applied, err := database.Migrate(ctx, db)
+		for _, record := range applied {
+			fmt.Printf("Applied %d: %s (%.1fs)\n", record.Version, record.Name, record.Duration)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Nothing to migrate")
+		}
+		return
+	}
+
+	states, err := database.MigrationStatus(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to read migration status: %v", err)
+	}
+	pending := 0
+	for _, state := range states {
+		if state.Applied {
+			fmt.Printf("  applied  %3d  %s  (%s)\n", state.Version, state.Name, state.AppliedAt.Format(time.RFC3339))
+		} else {
+			fmt.Printf("  pending  %3d  %s\n", state.Version, state.Name)
+			pending++
+		}
+	}
+	fmt.Printf("%d applied, %d pending\n", len(states)-pending, pending)
+}
@@ -0,0 +1,67 @@
+// Command mockidp runs a local OpenID Connect provider that signs in one configured user
+// without a login page, to try OIDC login without Azure AD, Okta or Keycloak.
+//
+// Usage:
+//
+//	go run ./cmd/mockidp [flags]
+//
+// Add it to config/config.yaml as a provider:
+//
+//	oidc:
+//	  providers:
+//	    - name: mock
+//	      issuer: http://localhost:9000
+//	      client_id: station-monitor
+//	      client_secret: mock-secret
+//	      role_claim: groups
+//	      role_mapping:
+//	        planners: [planner]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"stationMonitor/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9000", "address to listen on")
+	clientID := flag.String("client-id", "station-monitor", "client ID the server uses")
+	clientSecret := flag.String("client-secret", "mock-secret", "client secret the server uses")
+	subject := flag.String("sub", "mock-user-1", "subject of the signed-in user")
+	email := flag.String("email", "mock.user@example.com", "email of the signed-in user")
+	name := flag.String("name", "Mock User", "name of the signed-in user")
+	groups := flag.String("groups", "planners", "comma-separated groups claim of the signed-in user")
+	unverified := flag.Bool("unverified", false, "report the email as not verified")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: mockidp [flags]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	issuer := "http://" + *addr
+	idp, err := oidctest.New(issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("Failed to create provider: %v", err)
+	}
+
+	user := map[string]interface{}{
+		"sub":            *subject,
+		"email":          *email,
+		"email_verified": !*unverified,
+		"name":           *name,
+	}
+	if *groups != "" {
+		user["groups"] = strings.Split(*groups, ",")
+	}
+	idp.SetUser(user)
+
+	log.Printf("Mock OIDC provider %s signs in %s (client %s)", issuer, *email, *clientID)
+	if err := http.ListenAndServe(*addr, idp); err != nil {
+		log.Fatalf("Failed to start provider: %v", err)
+	}
+}
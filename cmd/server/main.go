@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
+	"stationMonitor/internal/archive"
+	"stationMonitor/internal/baseline"
 	"stationMonitor/internal/config"
 	"stationMonitor/internal/database"
+	"stationMonitor/internal/ifs"
+	"stationMonitor/internal/oidc"
+	"stationMonitor/internal/rbac"
 	"stationMonitor/internal/routes"
+	"stationMonitor/internal/stationtime"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +24,30 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Token lifetimes are parsed on every login, fail early on typos
+	if _, err := time.ParseDuration(cfg.JWT.AccessTTL); err != nil {
+		log.Fatalf("Invalid jwt.access_ttl: %v", err)
+	}
+	if _, err := time.ParseDuration(cfg.JWT.RefreshTTL); err != nil {
+		log.Fatalf("Invalid jwt.refresh_ttl: %v", err)
+	}
+
+	// Roles given to new users must exist
+	if err := rbac.ValidateRoles(cfg.Auth.DefaultRoles); err != nil {
+		log.Fatalf("Invalid auth.default_roles: %v", err)
+	}
+	if err := rbac.ValidateRoles(cfg.GoogleOAuth.DefaultRoles); err != nil {
+		log.Fatalf("Invalid google_oauth.default_roles: %v", err)
+	}
+	for domain, roles := range cfg.GoogleOAuth.DomainRoles {
+		if err := rbac.ValidateRoles(roles); err != nil {
+			log.Fatalf("Invalid google_oauth.domain_roles for %s: %v", domain, err)
+		}
+	}
+	if err := oidc.Validate(cfg.OIDC.Providers); err != nil {
+		log.Fatalf("Invalid oidc config: %v", err)
+	}
+
 	// Connect to MongoDB
 	db, err := database.Connect(cfg.MongoDB.URL)
 	if err != nil {
@@ -23,11 +55,116 @@ func main() {
 	}
 	defer database.Disconnect()
 
+	// Apply pending schema migrations
+	if !cfg.MongoDB.SkipMigrations {
+		migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), 10*time.Minute)
+		applied, err := database.Migrate(migrateCtx, db)
+		cancelMigrate()
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		log.Printf("Database schema up to date (%d migrations applied)", len(applied))
+	}
+
+	// Start the baseline scheduler
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if cfg.Baseline.Interval != "" {
+		interval, err := time.ParseDuration(cfg.Baseline.Interval)
+		if err != nil {
+			log.Fatalf("Invalid baseline interval: %v", err)
+		}
+		scheduler := &baseline.Scheduler{
+			WorkPackages: db.Collection(database.WorkPackageCollection),
+			Baselines:    db.Collection(baseline.CollectionName),
+			Interval:     interval,
+			States:       cfg.Baseline.States,
+		}
+		go scheduler.Run(ctx)
+	}
+
+	// Start the archiver
+	if cfg.Archive.Interval != "" {
+		interval, err := time.ParseDuration(cfg.Archive.Interval)
+		if err != nil {
+			log.Fatalf("Invalid archive interval: %v", err)
+		}
+		if cfg.Archive.RetentionDays > 0 && cfg.Archive.RetentionDays < cfg.Archive.AgeDays {
+			log.Fatalf("archive.retention_days must not be less than archive.age_days")
+		}
+		archiver := &archive.Archiver{
+			Store: &archive.MongoStore{
+				WorkPackages: db.Collection(database.WorkPackageCollection),
+				Archive:      db.Collection(database.ArchiveCollection),
+				Baselines:    db.Collection(baseline.CollectionName),
+			},
+			Interval:  interval,
+			Age:       time.Duration(cfg.Archive.AgeDays) * 24 * time.Hour,
+			States:    cfg.Archive.States,
+			Compress:  cfg.Archive.Compress,
+			Retention: time.Duration(cfg.Archive.RetentionDays) * 24 * time.Hour,
+		}
+		go archiver.Run(ctx)
+	}
+
+	// Start the IFS sync
+	if cfg.IFS.BaseURL != "" {
+		interval, err := time.ParseDuration(cfg.IFS.SyncInterval)
+		if err != nil {
+			log.Fatalf("Invalid IFS sync interval: %v", err)
+		}
+		ifsClient := ifs.NewClient(ifs.ClientConfig{
+			BaseURL:      cfg.IFS.BaseURL,
+			EntitySet:    cfg.IFS.EntitySet,
+			PageSize:     cfg.IFS.PageSize,
+			TokenURL:     cfg.IFS.TokenURL,
+			ClientID:     cfg.IFS.ClientID,
+			ClientSecret: cfg.IFS.ClientSecret,
+		})
+		syncer := &ifs.Syncer{
+			Client: ifsClient,
+			Store: &ifs.MongoSyncStore{
+				WorkPackages: db.Collection(database.WorkPackageCollection),
+				Watermarks:   db.Collection(ifs.WatermarkCollection),
+				Queue:        db.Collection(ifs.WriteBackCollection),
+			},
+			Interval: interval,
+		}
+		go syncer.Run(ctx)
+
+		// Start the IFS write-back
+		writeBackInterval, err := time.ParseDuration(cfg.IFS.WriteBack.Interval)
+		if err != nil {
+			log.Fatalf("Invalid IFS write-back interval: %v", err)
+		}
+		backoff, err := time.ParseDuration(cfg.IFS.WriteBack.Backoff)
+		if err != nil {
+			log.Fatalf("Invalid IFS write-back backoff: %v", err)
+		}
+		writeBack := &ifs.WriteBackWorker{
+			Client: ifsClient,
+			Store: &ifs.MongoWriteBackStore{
+				WorkPackages: db.Collection(database.WorkPackageCollection),
+				Queue:        db.Collection(ifs.WriteBackCollection),
+			},
+			Interval:    writeBackInterval,
+			MaxAttempts: cfg.IFS.WriteBack.MaxAttempts,
+			Backoff:     backoff,
+		}
+		go writeBack.Run(ctx)
+	}
+
+	// Time zones of the stations for the tz query parameter
+	stations, err := stationtime.NewRegistry(cfg.Stations.Zones, cfg.Stations.DefaultTZ)
+	if err != nil {
+		log.Fatalf("Invalid stations config: %v", err)
+	}
+
 	// Initialize Gin router
 	router := gin.Default()
 
 	// Setup routes
-	routes.SetupRoutes(router, db)
+	routes.SetupRoutes(router, db, cfg, stations)
 
 	// Start server
 	log.Printf("Server starting on port %s", cfg.Server.Port)
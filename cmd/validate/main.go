@@ -0,0 +1,143 @@
+// Command validate checks the stored work packages against the data quality rules and prints
+// the issues, so they can be fixed in IFS.
+//
+// Usage:
+//
+//	go run ./cmd/validate [flags]
+//
+// The exit status is 1 if any error-severity issue is found.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/database"
+	"stationMonitor/internal/repository"
+	"stationMonitor/internal/validation"
+)
+
+func main() {
+	configPath := flag.String("config", "config/config.yaml", "config file with the MongoDB URL (MONGODB_URL overrides it)")
+	severityName := flag.String("severity", "info", "least severe level to report: error, warning or info")
+	rules := flag.String("rule", "", "comma-separated rule IDs to report (default all)")
+	ids := flag.String("id", "", "comma-separated AircraftWorkPackageIds to check (default all)")
+	locationCode := flag.String("location", "", "only check work packages at this LocationCode")
+	historic := flag.Bool("historic", false, "include historic work packages")
+	output := flag.String("output", "text", "output format: text or json")
+	listRules := flag.Bool("rules", false, "list the rules and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: validate [flags]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *listRules {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, rule := range validation.Rules {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", rule.ID, rule.Severity, rule.Description)
+		}
+		w.Flush()
+		return
+	}
+
+	severity, err := validation.ParseSeverity(*severityName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output != "text" && *output != "json" {
+		log.Fatalf("output must be text or json")
+	}
+
+	report := validation.NewReport(severity)
+	for _, rule := range splitList(*rules) {
+		if !validation.KnownRule(rule) {
+			log.Fatalf("Unknown rule: %s", rule)
+		}
+		report.Rules = append(report.Rules, rule)
+	}
+
+	filter := repository.WorkPackageFilter{}
+	for _, id := range splitList(*ids) {
+		wpId, err := strconv.Atoi(id)
+		if err != nil {
+			log.Fatalf("Invalid work package ID: %s", id)
+		}
+		filter.WorkPackageIds = append(filter.WorkPackageIds, wpId)
+	}
+	if *locationCode != "" {
+		filter.LocationCodes = []string{*locationCode}
+	}
+	if !*historic {
+		current := false
+		filter.IsHistoric = &current
+	}
+
+	mongoURL := os.Getenv("MONGODB_URL")
+	if mongoURL == "" {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		mongoURL = cfg.MongoDB.URL
+	}
+
+	db, err := database.Connect(mongoURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer database.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	workPackages, err := repository.NewMongoWorkPackages(db).Find(ctx, filter, repository.FindOptions{})
+	if err != nil {
+		log.Fatalf("Failed to load work packages: %v", err)
+	}
+	for _, wp := range workPackages {
+		report.Add(wp)
+	}
+	report.Sort()
+
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, issue := range report.Issues {
+			fmt.Fprintf(w, "%s\tWP %d\t%s\t%s\n", issue.Severity, issue.AircraftWorkPackageId, issue.Rule, issue)
+		}
+		w.Flush()
+		fmt.Printf("%d work packages checked, %d with issues: %d errors, %d warnings, %d info\n",
+			report.Checked, report.WorkPackagesWithIssues,
+			report.Counts[validation.Error], report.Counts[validation.Warning], report.Counts[validation.Info])
+	}
+
+	if report.Counts[validation.Error] > 0 {
+		os.Exit(1)
+	}
+}
+
+// splitList splits a comma-separated flag value, ignoring empty entries
+func splitList(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
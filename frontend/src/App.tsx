@@ -53,6 +53,8 @@ function App() {
         <Routes>
           <Route path="/login" element={<Login />} />
           <Route path="/auth/google/callback" element={<Login />} />
+          <Route path="/auth/oidc/callback" element={<Login />} />
+          <Route path="/auth/oidc/:provider/callback" element={<Login />} />
           <Route
             path="/dashboard"
             element={
@@ -4,6 +4,7 @@ import HomeIcon from '@mui/icons-material/Home';
 import { useNavigate } from 'react-router-dom';
 import LogoutIcon from '@mui/icons-material/Logout';
 import Button from '@mui/material/Button';
+import { authService } from '../services/api';
 
 const Header = () => {
   const navigate = useNavigate();
@@ -20,9 +21,12 @@ const Header = () => {
     navigate('/dashboard');
   };
 
-  const handleLogout = () => {
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
+  const handleLogout = async () => {
+    try {
+      await authService.logout();
+    } catch {
+      // The local session is cleared even if the server could not be reached
+    }
     navigate('/login');
   };
 
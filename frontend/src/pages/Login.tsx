@@ -1,5 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import { useNavigate, useSearchParams } from 'react-router-dom';
+import { useNavigate, useParams, useSearchParams } from 'react-router-dom';
 import {
   Container,
   Paper,
@@ -13,7 +13,7 @@ import {
   Tab,
   Divider,
 } from '@mui/material';
-import { authService } from '../services/api';
+import { authService, storeTokens, AuthProvider } from '../services/api';
 
 const Login: React.FC = () => {
   const [tabValue, setTabValue] = useState(0);
@@ -25,8 +25,36 @@ const Login: React.FC = () => {
   const [success, setSuccess] = useState('');
   const [loading, setLoading] = useState(false);
   const [googleOAuthAvailable, setGoogleOAuthAvailable] = useState(true);
+  const [oidcProviders, setOidcProviders] = useState<AuthProvider[]>([]);
   const navigate = useNavigate();
   const [searchParams] = useSearchParams();
+  const { provider } = useParams<{ provider: string }>();
+
+  // Load the identity providers configured on the server
+  useEffect(() => {
+    authService
+      .getAuthProviders()
+      .then((response) => {
+        setOidcProviders(response.providers);
+        setGoogleOAuthAvailable(response.google);
+      })
+      .catch(() => setOidcProviders([]));
+  }, []);
+
+  // Function to handle an OpenID Connect provider callback
+  const handleOIDCCallback = async (providerName: string, code: string, state: string) => {
+    try {
+      setLoading(true);
+      setError('');
+      const response = await authService.handleOIDCCallback(providerName, code, state);
+      storeTokens(response);
+      localStorage.setItem('user', JSON.stringify(response.user));
+      navigate('/dashboard', { replace: true });
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Login failed');
+      setLoading(false);
+    }
+  };
 
   // Function to handle Google OAuth callback
   const handleGoogleCallback = async (code: string, state: string) => {
@@ -34,7 +62,7 @@ const Login: React.FC = () => {
       setLoading(true);
       setError('');
       const response = await authService.handleGoogleCallback(code, state);
-      localStorage.setItem('token', response.token);
+      storeTokens(response);
       localStorage.setItem('user', JSON.stringify(response.user));
       // Redirect to dashboard
       navigate('/dashboard', { replace: true });
@@ -44,35 +72,44 @@ const Login: React.FC = () => {
     }
   };
 
-  // Handle Google OAuth callback - can receive either token (from backend redirect) or code/state (from Google)
+  // Function to exchange the login code of a backend redirect
+  const handleLoginCode = async (loginCode: string) => {
+    try {
+      setLoading(true);
+      setError('');
+      const response = await authService.exchangeLoginCode(loginCode);
+      storeTokens(response);
+      localStorage.setItem('user', JSON.stringify(response.user));
+      navigate('/dashboard', { replace: true });
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Login failed');
+      setLoading(false);
+    }
+  };
+
+  // Handle the login callback - can receive either a login code (from a backend redirect) or code/state (from the provider)
   useEffect(() => {
-    const token = searchParams.get('token');
+    const loginCode = searchParams.get('login_code');
     const code = searchParams.get('code');
     const state = searchParams.get('state');
-    const email = searchParams.get('email');
-    const name = searchParams.get('name');
     
-    // If we have a token directly (backend redirected here), use it
-    if (token) {
-      setLoading(true);
-      localStorage.setItem('token', token);
-      
-      const user = {
-        email: email || '',
-        name: name || '',
-        provider: 'google'
-      };
-      localStorage.setItem('user', JSON.stringify(user));
-      
-      setTimeout(() => {
-        navigate('/dashboard', { replace: true });
-      }, 100);
+    // If we have a login code (backend redirected here), exchange it for the tokens
+    if (loginCode) {
+      handleLoginCode(loginCode);
     } 
+    // If the provider reported an error, show it
+    else if (searchParams.get('error')) {
+      setError(searchParams.get('error_description') || `Login failed: ${searchParams.get('error')}`);
+    }
+    // If we have code and state from an OpenID Connect provider, exchange them for token
+    else if (code && state && provider) {
+      handleOIDCCallback(provider, code, state);
+    }
     // If we have code and state (Google redirected here), exchange them for token
     else if (code && state) {
       handleGoogleCallback(code, state);
     }
-  }, [searchParams, navigate]);
+  }, [searchParams, navigate, provider]);
 
   const handleTabChange = (_event: React.SyntheticEvent, newValue: number) => {
     setTabValue(newValue);
@@ -92,7 +129,7 @@ const Login: React.FC = () => {
 
     try {
       const response = await authService.login({ username, password });
-      localStorage.setItem('token', response.token);
+      storeTokens(response);
       localStorage.setItem('user', JSON.stringify(response.user));
       navigate('/dashboard');
     } catch (err: any) {
@@ -125,7 +162,7 @@ const Login: React.FC = () => {
     try {
       const response = await authService.register({ email, password });
       setSuccess('Registration successful! Redirecting...');
-      localStorage.setItem('token', response.token);
+      storeTokens(response);
       localStorage.setItem('user', JSON.stringify(response.user));
       setTimeout(() => {
         navigate('/dashboard');
@@ -157,6 +194,18 @@ const Login: React.FC = () => {
     }
   };
 
+  const handleOIDCLogin = async (providerName: string) => {
+    try {
+      setLoading(true);
+      setError('');
+      const authUrl = await authService.getOIDCAuthUrl(providerName);
+      window.location.href = authUrl;
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to start login');
+      setLoading(false);
+    }
+  };
+
   return (
     <Container component="main" maxWidth="xs">
       <Box
@@ -236,10 +285,12 @@ const Login: React.FC = () => {
                 {loading ? <CircularProgress size={24} /> : 'Sign In'}
               </Button>
               
+              {(googleOAuthAvailable || oidcProviders.length > 0) && (
+                <Divider sx={{ my: 2 }}>OR</Divider>
+              )}
+
               {googleOAuthAvailable && (
                 <>
-                  <Divider sx={{ my: 2 }}>OR</Divider>
-                  
                   <Button
                     fullWidth
                     variant="outlined"
@@ -267,6 +318,19 @@ const Login: React.FC = () => {
                   </Button>
                 </>
               )}
+
+              {oidcProviders.map((oidcProvider) => (
+                <Button
+                  key={oidcProvider.name}
+                  fullWidth
+                  variant="outlined"
+                  onClick={() => handleOIDCLogin(oidcProvider.name)}
+                  disabled={loading}
+                  sx={{ mb: 2 }}
+                >
+                  Continue with {oidcProvider.displayName}
+                </Button>
+              ))}
             </Box>
           )}
 
@@ -26,13 +26,56 @@ api.interceptors.request.use(
   }
 );
 
-// Add response interceptor to handle errors
+// Store the tokens of a login, registration or refresh
+export const storeTokens = (data: { token: string; refresh_token?: string }) => {
+  localStorage.setItem('token', data.token);
+  if (data.refresh_token) {
+    localStorage.setItem('refresh_token', data.refresh_token);
+  }
+};
+
+const clearSession = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+  localStorage.removeItem('user');
+};
+
+// Concurrent requests that fail with 401 share one refresh, since a refresh token can only be used once
+let refreshing: Promise<string> | null = null;
+
+const refreshAccessToken = (): Promise<string> => {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refresh_token');
+    refreshing = (refreshToken
+      ? axios.post(`${API_URL}/api/token/refresh`, { refresh_token: refreshToken }).then((response) => {
+          storeTokens(response.data);
+          return response.data.token as string;
+        })
+      : Promise.reject(new Error('No refresh token'))
+    ).finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+};
+
+// Add response interceptor to handle errors; an expired access token is refreshed once
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
+    const request = error.config;
+    if (error.response?.status === 401 && request && !request._retried && !request.url?.startsWith('/api/login')) {
+      request._retried = true;
+      try {
+        const token = await refreshAccessToken();
+        request.headers.Authorization = `Bearer ${token}`;
+        return api(request);
+      } catch {
+        // fall through to the login page
+      }
+    }
     if (error.response?.status === 401) {
-      localStorage.removeItem('token');
-      localStorage.removeItem('user');
+      clearSession();
       window.location.href = '/login';
     }
     return Promise.reject(error);
@@ -46,6 +89,8 @@ export interface LoginRequest {
 
 export interface LoginResponse {
   token: string;
+  refresh_token?: string;
+  expires_in?: number;
   user: {
     id: string;
     username?: string;
@@ -53,6 +98,17 @@ export interface LoginResponse {
   };
 }
 
+export interface AuthProvider {
+  name: string;
+  displayName: string;
+  loginUrl: string;
+}
+
+export interface AuthProvidersResponse {
+  providers: AuthProvider[];
+  google: boolean;
+}
+
 export interface RegisterRequest {
   email: string;
   password: string;
@@ -60,6 +116,8 @@ export interface RegisterRequest {
 
 export interface RegisterResponse {
   token: string;
+  refresh_token?: string;
+  expires_in?: number;
   user: {
     id: string;
     email: string;
@@ -81,9 +139,12 @@ export const authService = {
     const response = await api.post<RegisterResponse>('/api/register', credentials);
     return response.data;
   },
-  logout: async () => {
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
+  logout: async (all = false) => {
+    try {
+      await api.post('/api/logout', { all });
+    } finally {
+      clearSession();
+    }
   },
   getGoogleAuthUrl: async () => {
     const response = await api.get('/api/auth/google', {
@@ -101,6 +162,32 @@ export const authService = {
     const response = await api.get(`/api/auth/google/callback?code=${code}&state=${state}&redirect_uri=${encodeURIComponent(redirectUri)}`);
     return response.data;
   },
+  // Browser logins redirect back with a one-time code instead of the tokens
+  exchangeLoginCode: async (code: string): Promise<LoginResponse> => {
+    const response = await api.post<LoginResponse>('/api/auth/exchange', { code });
+    return response.data;
+  },
+  getAuthProviders: async (): Promise<AuthProvidersResponse> => {
+    const response = await api.get<AuthProvidersResponse>('/api/auth/providers');
+    return response.data;
+  },
+  // The login sets a cookie the callback must send back, so both requests carry credentials
+  getOIDCAuthUrl: async (provider: string) => {
+    const response = await api.get(`/api/auth/oidc/${encodeURIComponent(provider)}`, {
+      params: {
+        redirect_url: `${window.location.origin}/auth/oidc/${encodeURIComponent(provider)}/callback`
+      },
+      withCredentials: true,
+    });
+    return response.data.auth_url;
+  },
+  handleOIDCCallback: async (provider: string, code: string, state: string): Promise<LoginResponse> => {
+    const response = await api.get<LoginResponse>(`/api/auth/oidc/${encodeURIComponent(provider)}/callback`, {
+      params: { code, state },
+      withCredentials: true,
+    });
+    return response.data;
+  },
 };
 
 export const dashboardService = {
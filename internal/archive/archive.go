@@ -0,0 +1,280 @@
+// Package archive moves old historic and completed work packages out of the work package
+// collection, so they no longer slow down its queries, and deletes archived packages once
+// their retention period has passed
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// EndDate returns the date a work package ended: ActualEndDateTime, else SchedEndDateTime, else nil
+func EndDate(wp models.AircraftWorkPackage) *time.Time {
+	if wp.ActualEndDateTime != nil {
+		return wp.ActualEndDateTime
+	}
+	return wp.SchedEndDateTime
+}
+
+// Sites returns the distinct Site and Company pairs of the tasks of a work package, in task order
+func Sites(wp models.AircraftWorkPackage) []models.ArchivedSite {
+	sites := []models.ArchivedSite{}
+	seen := map[models.ArchivedSite]bool{}
+	for _, task := range wp.Avexetask {
+		site := models.ArchivedSite{Site: task.Site, Company: task.Company}
+		if !seen[site] {
+			seen[site] = true
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+// Pack copies a work package into an archive document, compressed if asked
+func Pack(wp models.AircraftWorkPackage, compress bool, now time.Time) (models.ArchivedWorkPackage, error) {
+	end := EndDate(wp)
+	if end == nil {
+		return models.ArchivedWorkPackage{}, fmt.Errorf("work package %d has no end date", wp.AircraftWorkPackageId)
+	}
+
+	doc := models.ArchivedWorkPackage{
+		ID:                    wp.ID,
+		AircraftWorkPackageId: wp.AircraftWorkPackageId,
+		AircraftId:            wp.AircraftId,
+		LocationCode:          wp.LocationCode,
+		Objstate:              wp.Objstate,
+		IsHistoric:            wp.IsHistoric,
+		CustomerNo:            wp.CustomerNo,
+		Sites:                 Sites(wp),
+		SchedStartDateTime:    wp.SchedStartDateTime,
+		SchedEndDateTime:      wp.SchedEndDateTime,
+		EndDateTime:           end.UTC(),
+		ArchivedDate:          now.UTC(),
+	}
+
+	if !compress {
+		doc.WorkPackage = &wp
+		return doc, nil
+	}
+
+	data, err := bson.Marshal(wp)
+	if err != nil {
+		return doc, err
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return doc, err
+	}
+	if err := writer.Close(); err != nil {
+		return doc, err
+	}
+	doc.Compressed = buf.Bytes()
+	return doc, nil
+}
+
+// Unpack returns the work package stored in an archive document, with ArchivedDate set
+func Unpack(doc models.ArchivedWorkPackage) (models.AircraftWorkPackage, error) {
+	var wp models.AircraftWorkPackage
+	switch {
+	case doc.WorkPackage != nil:
+		wp = *doc.WorkPackage
+	case len(doc.Compressed) > 0:
+		reader, err := gzip.NewReader(bytes.NewReader(doc.Compressed))
+		if err != nil {
+			return wp, fmt.Errorf("archived work package %d: %w", doc.AircraftWorkPackageId, err)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return wp, fmt.Errorf("archived work package %d: %w", doc.AircraftWorkPackageId, err)
+		}
+		if err := bson.Unmarshal(data, &wp); err != nil {
+			return wp, fmt.Errorf("archived work package %d: %w", doc.AircraftWorkPackageId, err)
+		}
+	default:
+		return wp, fmt.Errorf("archived work package %d holds no data", doc.AircraftWorkPackageId)
+	}
+
+	archivedDate := doc.ArchivedDate
+	wp.ArchivedDate = &archivedDate
+	return wp, nil
+}
+
+// Store holds the work packages due for archiving and the archive they are moved to
+type Store interface {
+	// Due calls fn for each work package that is historic or in one of states and whose
+	// EndDate lies before cutoff, stopping at the first error fn returns
+	Due(ctx context.Context, cutoff time.Time, states []string, fn func(models.AircraftWorkPackage) error) error
+	// Put writes doc to the archive, replacing an older copy of the package
+	Put(ctx context.Context, doc models.ArchivedWorkPackage) error
+	// Remove deletes wp from the work package collection unless its ChangedDate moved since it
+	// was read, and reports whether it was deleted
+	Remove(ctx context.Context, wp models.AircraftWorkPackage) (bool, error)
+	// Purge deletes the archived packages whose EndDateTime lies before cutoff, together with
+	// their baselines, and returns how many
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// MongoStore keeps the work packages and the archive in MongoDB
+type MongoStore struct {
+	WorkPackages *mongo.Collection
+	Archive      *mongo.Collection
+	Baselines    *mongo.Collection // nil keeps the baselines of deleted packages
+}
+
+// Due calls fn for each work package due for archiving
+func (s *MongoStore) Due(ctx context.Context, cutoff time.Time, states []string, fn func(models.AircraftWorkPackage) error) error {
+	eligible := bson.A{bson.M{"IsHistoric": true}}
+	if len(states) > 0 {
+		eligible = append(eligible, bson.M{"Objstate": bson.M{"$in": states}})
+	}
+	cursor, err := s.WorkPackages.Find(ctx, bson.M{"$and": bson.A{
+		bson.M{"$or": eligible},
+		bson.M{"$or": bson.A{
+			bson.M{"ActualEndDateTime": bson.M{"$lt": cutoff}},
+			bson.M{"ActualEndDateTime": nil, "SchedEndDateTime": bson.M{"$lt": cutoff}},
+		}},
+	}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var wp models.AircraftWorkPackage
+		if err := cursor.Decode(&wp); err != nil {
+			return err
+		}
+		if err := fn(wp); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Put writes doc to the archive. An older copy keeps its _id, which a replace cannot
+// change, so it is removed first
+func (s *MongoStore) Put(ctx context.Context, doc models.ArchivedWorkPackage) error {
+	if _, err := s.Archive.DeleteOne(ctx, bson.M{"AircraftWorkPackageId": doc.AircraftWorkPackageId}); err != nil {
+		return err
+	}
+	_, err := s.Archive.InsertOne(ctx, doc)
+	return err
+}
+
+// Remove deletes wp from the work package collection if its ChangedDate did not move
+func (s *MongoStore) Remove(ctx context.Context, wp models.AircraftWorkPackage) (bool, error) {
+	result, err := s.WorkPackages.DeleteOne(ctx, bson.M{"_id": wp.ID, "ChangedDate": wp.ChangedDate})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// Purge deletes the archived packages that ended before cutoff and their baselines
+func (s *MongoStore) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	expired := bson.M{"EndDateTime": bson.M{"$lt": cutoff}}
+
+	if s.Baselines != nil {
+		ids, err := s.Archive.Distinct(ctx, "AircraftWorkPackageId", expired)
+		if err != nil {
+			return 0, err
+		}
+		if len(ids) > 0 {
+			if _, err := s.Baselines.DeleteMany(ctx, bson.M{"AircraftWorkPackageId": bson.M{"$in": ids}}); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	result, err := s.Archive.DeleteMany(ctx, expired)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// Archiver moves work packages that are historic or in one of States, and whose EndDate lies
+// more than Age back, into the archive. Archived packages whose EndDate lies more than
+// Retention back are deleted, together with their baselines
+type Archiver struct {
+	Store     Store
+	Interval  time.Duration
+	Age       time.Duration
+	States    []string
+	Compress  bool
+	Retention time.Duration // 0 keeps archived packages forever
+}
+
+// Run archives and purges every Interval until the context is cancelled
+func (a *Archiver) Run(ctx context.Context) {
+	log.Printf("Archiver started (interval %s, age %s, states %v, retention %s)", a.Interval, a.Age, a.States, a.Retention)
+
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if count, err := a.ArchiveOnce(ctx, now); err != nil {
+			log.Printf("Archiver error: %v", err)
+		} else if count > 0 {
+			log.Printf("Archiver moved %d work packages to the archive", count)
+		}
+		if count, err := a.PurgeOnce(ctx, now); err != nil {
+			log.Printf("Archiver retention error: %v", err)
+		} else if count > 0 {
+			log.Printf("Archiver deleted %d archived work packages past retention", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Archiver stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ArchiveOnce moves the work packages due for archiving. A package is written to the archive
+// before it is deleted from the work package collection, and is only deleted if it did not
+// change in between; a package changed by the IFS sync is archived again on the next run.
+// Returns the number of packages moved
+func (a *Archiver) ArchiveOnce(ctx context.Context, now time.Time) (int, error) {
+	count := 0
+	err := a.Store.Due(ctx, now.Add(-a.Age), a.States, func(wp models.AircraftWorkPackage) error {
+		doc, err := Pack(wp, a.Compress, now)
+		if err != nil {
+			return err
+		}
+		if err := a.Store.Put(ctx, doc); err != nil {
+			return fmt.Errorf("archive work package %d: %w", wp.AircraftWorkPackageId, err)
+		}
+		removed, err := a.Store.Remove(ctx, wp)
+		if err != nil {
+			return err
+		}
+		if removed {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// PurgeOnce deletes the archived packages past the retention period and returns how many
+func (a *Archiver) PurgeOnce(ctx context.Context, now time.Time) (int64, error) {
+	if a.Retention <= 0 {
+		return 0, nil
+	}
+	return a.Store.Purge(ctx, now.Add(-a.Retention))
+}
@@ -0,0 +1,304 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testNow is the time the archiver runs at in the tests
+var testNow = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// daysAgo returns the time the given number of days before testNow
+func daysAgo(days int) *time.Time {
+	t := testNow.AddDate(0, 0, -days)
+	return &t
+}
+
+// memoryStore keeps the work packages, the archive and the baselines in memory. beforeRemove,
+// if set, runs between writing a package to the archive and removing it
+type memoryStore struct {
+	live         map[int]models.AircraftWorkPackage
+	archive      map[int]models.ArchivedWorkPackage
+	baselines    map[int]bool
+	beforeRemove func(wp models.AircraftWorkPackage)
+}
+
+func newMemoryStore(workPackages ...models.AircraftWorkPackage) *memoryStore {
+	s := &memoryStore{
+		live:      map[int]models.AircraftWorkPackage{},
+		archive:   map[int]models.ArchivedWorkPackage{},
+		baselines: map[int]bool{},
+	}
+	for _, wp := range workPackages {
+		wp.ID = primitive.NewObjectID()
+		s.live[wp.AircraftWorkPackageId] = wp
+		s.baselines[wp.AircraftWorkPackageId] = true
+	}
+	return s
+}
+
+// sortedIds returns the keys of a map in order
+func sortedIds[V any](m map[int]V) []int {
+	ids := []int{}
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (s *memoryStore) Due(ctx context.Context, cutoff time.Time, states []string, fn func(models.AircraftWorkPackage) error) error {
+	for _, id := range sortedIds(s.live) {
+		wp := s.live[id]
+		eligible := wp.IsHistoric
+		for _, state := range states {
+			eligible = eligible || wp.Objstate == state
+		}
+		if end := EndDate(wp); !eligible || end == nil || !end.Before(cutoff) {
+			continue
+		}
+		if err := fn(wp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Put(ctx context.Context, doc models.ArchivedWorkPackage) error {
+	s.archive[doc.AircraftWorkPackageId] = doc
+	return nil
+}
+
+func (s *memoryStore) Remove(ctx context.Context, wp models.AircraftWorkPackage) (bool, error) {
+	if s.beforeRemove != nil {
+		s.beforeRemove(wp)
+	}
+	current, ok := s.live[wp.AircraftWorkPackageId]
+	if !ok || (current.ChangedDate == nil) != (wp.ChangedDate == nil) || (wp.ChangedDate != nil && !current.ChangedDate.Equal(*wp.ChangedDate)) {
+		return false, nil
+	}
+	delete(s.live, wp.AircraftWorkPackageId)
+	return true, nil
+}
+
+func (s *memoryStore) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	var count int64
+	for _, id := range sortedIds(s.archive) {
+		if s.archive[id].EndDateTime.Before(cutoff) {
+			delete(s.archive, id)
+			delete(s.baselines, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// archiveTestWorkPackage returns a historic work package that ended the given number of days
+// ago, with tasks at two sites
+func archiveTestWorkPackage(wpId, endedDaysAgo int) models.AircraftWorkPackage {
+	return models.AircraftWorkPackage{
+		AircraftWorkPackageId: wpId,
+		AircraftId:            100 + wpId,
+		LocationCode:          "AMS",
+		Objstate:              "Planned",
+		IsHistoric:            true,
+		SchedStartDateTime:    daysAgo(endedDaysAgo + 1),
+		SchedEndDateTime:      daysAgo(endedDaysAgo),
+		ChangedDate:           daysAgo(endedDaysAgo),
+		Avexetask: []models.AvExeTask{
+			{TaskSeq: 1, Site: "AMS", Company: "KL", PlannedStart: daysAgo(endedDaysAgo + 1), PlannedFinish: daysAgo(endedDaysAgo)},
+			{TaskSeq: 2, Site: "AMS", Company: "KL"},
+			{
+				TaskSeq: 3, Site: "AMS2", Company: "KL",
+				JtExecutionInstanceArray: []models.JtExecutionInstance{{ExecutionInstanceSeq: 1, TaskSeq: 3}},
+			},
+		},
+	}
+}
+
+func TestPackUnpack(t *testing.T) {
+	wp := archiveTestWorkPackage(1, 40)
+	wp.ID = primitive.NewObjectID()
+	wp.ActualEndDateTime = daysAgo(41)
+
+	for _, compress := range []bool{false, true} {
+		doc, err := Pack(wp, compress, testNow)
+		if err != nil {
+			t.Fatalf("compress %v: %v", compress, err)
+		}
+		if (doc.WorkPackage == nil) != compress || (len(doc.Compressed) > 0) != compress {
+			t.Errorf("compress %v: stored uncompressed %v, %d compressed bytes", compress, doc.WorkPackage != nil, len(doc.Compressed))
+		}
+		if doc.ID != wp.ID || doc.AircraftWorkPackageId != 1 || !doc.EndDateTime.Equal(*wp.ActualEndDateTime) || !doc.ArchivedDate.Equal(testNow) {
+			t.Errorf("compress %v: summary %+v", compress, doc)
+		}
+		if got := fmt.Sprint(doc.Sites); got != "[{AMS KL} {AMS2 KL}]" {
+			t.Errorf("compress %v: sites %s", compress, got)
+		}
+
+		unpacked, err := Unpack(doc)
+		if err != nil {
+			t.Fatalf("compress %v: %v", compress, err)
+		}
+		if unpacked.ArchivedDate == nil || !unpacked.ArchivedDate.Equal(testNow) {
+			t.Errorf("compress %v: archived date %v", compress, unpacked.ArchivedDate)
+		}
+		want, _ := json.Marshal(wp)
+		unpacked.ArchivedDate = nil
+		if got, _ := json.Marshal(unpacked); string(got) != string(want) {
+			t.Errorf("compress %v: unpacked\n%s\nwant\n%s", compress, got, want)
+		}
+	}
+
+	if _, err := Pack(models.AircraftWorkPackage{AircraftWorkPackageId: 2}, false, testNow); err == nil {
+		t.Error("packed a work package without an end date")
+	}
+	for _, doc := range []models.ArchivedWorkPackage{{AircraftWorkPackageId: 3}, {AircraftWorkPackageId: 4, Compressed: []byte("not gzip")}} {
+		if _, err := Unpack(doc); err == nil {
+			t.Errorf("unpacked archived work package %d", doc.AircraftWorkPackageId)
+		}
+	}
+}
+
+func TestArchiveOnce(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(wp *models.AircraftWorkPackage)
+		archived bool
+	}{
+		{"historic and old", func(wp *models.AircraftWorkPackage) {}, true},
+		{"historic and recent", func(wp *models.AircraftWorkPackage) {
+			wp.SchedEndDateTime = daysAgo(20)
+		}, false},
+		{"ended just after the cutoff", func(wp *models.AircraftWorkPackage) {
+			wp.SchedEndDateTime = daysAgo(30)
+		}, false},
+		{"released and old", func(wp *models.AircraftWorkPackage) {
+			wp.IsHistoric, wp.Objstate = false, "Released"
+		}, true},
+		{"planned and old", func(wp *models.AircraftWorkPackage) { wp.IsHistoric = false }, false},
+		{"actually ended long before the scheduled end", func(wp *models.AircraftWorkPackage) {
+			wp.SchedEndDateTime, wp.ActualEndDateTime = daysAgo(10), daysAgo(40)
+		}, true},
+		{"actually ended long after the scheduled end", func(wp *models.AircraftWorkPackage) {
+			wp.ActualEndDateTime = daysAgo(10)
+		}, false},
+		{"without end date", func(wp *models.AircraftWorkPackage) { wp.SchedEndDateTime = nil }, false},
+	}
+
+	workPackages := []models.AircraftWorkPackage{}
+	for i, tt := range tests {
+		wp := archiveTestWorkPackage(i+1, 40)
+		tt.change(&wp)
+		workPackages = append(workPackages, wp)
+	}
+	store := newMemoryStore(workPackages...)
+	archiver := &Archiver{Store: store, Age: 30 * 24 * time.Hour, States: []string{"Released"}, Compress: true}
+
+	count, err := archiver.ArchiveOnce(context.Background(), testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := 0
+	for i, tt := range tests {
+		wpId := i + 1
+		_, live := store.live[wpId]
+		doc, archived := store.archive[wpId]
+		if archived == live || archived != tt.archived {
+			t.Errorf("%s: live %v, archived %v", tt.name, live, archived)
+		}
+		if archived {
+			moved++
+			if len(doc.Compressed) == 0 {
+				t.Errorf("%s: archived uncompressed", tt.name)
+			}
+		}
+	}
+	if count != moved {
+		t.Errorf("count = %d, want %d", count, moved)
+	}
+}
+
+func TestArchiveOnceKeepsChangedPackages(t *testing.T) {
+	store := newMemoryStore(archiveTestWorkPackage(1, 40), archiveTestWorkPackage(2, 40))
+	archiver := &Archiver{Store: store, Age: 30 * 24 * time.Hour}
+
+	// The IFS sync updates package 1 after it was written to the archive
+	store.beforeRemove = func(wp models.AircraftWorkPackage) {
+		if wp.AircraftWorkPackageId == 1 {
+			changed := store.live[1]
+			changed.ChangedDate = &testNow
+			changed.WorkPackageName = "changed"
+			store.live[1] = changed
+		}
+	}
+	count, err := archiver.ArchiveOnce(context.Background(), testNow)
+	if err != nil || count != 1 {
+		t.Fatalf("ArchiveOnce = %d, %v, want 1", count, err)
+	}
+	if _, live := store.live[1]; !live {
+		t.Fatal("deleted the changed package from the live collection")
+	}
+	if _, live := store.live[2]; live {
+		t.Error("kept the unchanged package in the live collection")
+	}
+
+	// The next run archives the changed package, replacing the older copy
+	store.beforeRemove = nil
+	later := testNow.Add(time.Hour)
+	count, err = archiver.ArchiveOnce(context.Background(), later)
+	if err != nil || count != 1 {
+		t.Fatalf("second ArchiveOnce = %d, %v, want 1", count, err)
+	}
+	if _, live := store.live[1]; live {
+		t.Error("kept the changed package in the live collection")
+	}
+	wp, err := Unpack(store.archive[1])
+	if err != nil || wp.WorkPackageName != "changed" || !wp.ArchivedDate.Equal(later) {
+		t.Errorf("archived copy %q archived at %v, %v", wp.WorkPackageName, wp.ArchivedDate, err)
+	}
+}
+
+func TestPurgeOnce(t *testing.T) {
+	tests := []struct {
+		retention time.Duration
+		want      []int
+	}{
+		{0, []int{1, 2, 3}},
+		{90 * 24 * time.Hour, []int{2, 3}},
+		{80 * 24 * time.Hour, []int{3}},
+		{10 * 24 * time.Hour, []int{}},
+	}
+	for _, tt := range tests {
+		// Package 1 ended 100 days ago, 2 exactly 90 and 3 80
+		store := newMemoryStore()
+		for wpId, endedDaysAgo := range map[int]int{1: 100, 2: 90, 3: 80} {
+			doc, err := Pack(archiveTestWorkPackage(wpId, endedDaysAgo), false, testNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.archive[wpId] = doc
+			store.baselines[wpId] = true
+		}
+
+		archiver := &Archiver{Store: store, Retention: tt.retention}
+		count, err := archiver.PurgeOnce(context.Background(), testNow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sortedIds(store.archive); fmt.Sprint(got) != fmt.Sprint(tt.want) || count != int64(3-len(tt.want)) {
+			t.Errorf("retention %s: kept %v, deleted %d, want %v", tt.retention, got, count, tt.want)
+		}
+		if got := sortedIds(store.baselines); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("retention %s: kept baselines %v, want %v", tt.retention, got, tt.want)
+		}
+	}
+}
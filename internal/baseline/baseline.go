@@ -0,0 +1,330 @@
+package baseline
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CollectionName is the collection holding the baselines of all work packages
+const CollectionName = "scheduleBaselines"
+
+// Triggers recorded on a baseline
+const (
+	TriggerManual    = "manual"
+	TriggerScheduled = "scheduled"
+)
+
+// FromWorkPackage copies the planned dates of a work package into a new baseline
+func FromWorkPackage(wp models.AircraftWorkPackage, trigger, name, createdBy string, now time.Time) models.ScheduleBaseline {
+	baseline := models.ScheduleBaseline{
+		AircraftWorkPackageId: wp.AircraftWorkPackageId,
+		Name:                  name,
+		Trigger:               trigger,
+		Objstate:              wp.Objstate,
+		CreatedBy:             createdBy,
+		CreatedDate:           now.UTC(),
+		SchedStartDateTime:    wp.SchedStartDateTime,
+		SchedEndDateTime:      wp.SchedEndDateTime,
+		Tasks:                 []models.BaselineTask{},
+	}
+
+	for _, task := range wp.Avexetask {
+		baselineTask := models.BaselineTask{
+			TaskSeq:       task.TaskSeq,
+			Description:   task.Description,
+			PlannedStart:  task.PlannedStart,
+			PlannedFinish: task.PlannedFinish,
+			Duration:      task.Duration,
+		}
+		for _, instance := range task.JtExecutionInstanceArray {
+			baselineTask.Instances = append(baselineTask.Instances, models.BaselineInstance{
+				ExecutionInstanceSeq: instance.ExecutionInstanceSeq,
+				AllocatedStart:       instance.AllocatedStart,
+				AllocatedFinish:      instance.AllocatedFinish,
+			})
+		}
+		baseline.Tasks = append(baseline.Tasks, baselineTask)
+	}
+
+	return baseline
+}
+
+// Latest returns the most recent baseline of each of the given work packages
+func Latest(ctx context.Context, baselines *mongo.Collection, wpIds []int) (map[int]models.ScheduleBaseline, error) {
+	result := map[int]models.ScheduleBaseline{}
+	if len(wpIds) == 0 {
+		return result, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"AircraftWorkPackageId": bson.M{"$in": wpIds}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "CreatedDate", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$AircraftWorkPackageId", "baseline": bson.M{"$first": "$$ROOT"}}}},
+	}
+
+	cursor, err := baselines.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Baseline models.ScheduleBaseline `bson:"baseline"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.Baseline.AircraftWorkPackageId] = row.Baseline
+	}
+	return result, nil
+}
+
+// TaskVariance is the slip of one AvExeTask against the baseline, in hours.
+// Positive values mean later than baselined
+type TaskVariance struct {
+	TaskSeq          int        `json:"taskSeq"`
+	Description      string     `json:"description,omitempty"`
+	Status           string     `json:"status"` // "unchanged", "slipped", "advanced", "added", "removed"
+	BaselineStart    *time.Time `json:"baselineStart,omitempty"`
+	BaselineFinish   *time.Time `json:"baselineFinish,omitempty"`
+	PlannedStart     *time.Time `json:"plannedStart,omitempty"`
+	PlannedFinish    *time.Time `json:"plannedFinish,omitempty"`
+	ActualStart      *time.Time `json:"actualStart,omitempty"`
+	ActualFinish     *time.Time `json:"actualFinish,omitempty"`
+	StartSlip        *float64   `json:"startSlip,omitempty"`
+	FinishSlip       *float64   `json:"finishSlip,omitempty"`
+	ActualStartSlip  *float64   `json:"actualStartSlip,omitempty"`
+	ActualFinishSlip *float64   `json:"actualFinishSlip,omitempty"`
+}
+
+// PackageVariance summarises the slip of a work package against the baseline, in hours
+type PackageVariance struct {
+	BaselineStart   *time.Time `json:"baselineStart,omitempty"`
+	BaselineEnd     *time.Time `json:"baselineEnd,omitempty"`
+	SchedStart      *time.Time `json:"schedStart,omitempty"`
+	SchedEnd        *time.Time `json:"schedEnd,omitempty"`
+	ActualStart     *time.Time `json:"actualStart,omitempty"`
+	ActualEnd       *time.Time `json:"actualEnd,omitempty"`
+	StartSlip       *float64   `json:"startSlip,omitempty"`
+	EndSlip         *float64   `json:"endSlip,omitempty"`
+	ActualStartSlip *float64   `json:"actualStartSlip,omitempty"`
+	ActualEndSlip   *float64   `json:"actualEndSlip,omitempty"`
+	MaxFinishSlip   float64    `json:"maxFinishSlip"`
+	SlippedTasks    int        `json:"slippedTasks"`
+	AdvancedTasks   int        `json:"advancedTasks"`
+	AddedTasks      int        `json:"addedTasks"`
+	RemovedTasks    int        `json:"removedTasks"`
+}
+
+// VarianceReport compares the current plan and actuals of a work package against a baseline
+type VarianceReport struct {
+	AircraftWorkPackageId int                     `json:"aircraftWorkPackageId"`
+	Baseline              models.ScheduleBaseline `json:"baseline"`
+	Package               PackageVariance         `json:"package"`
+	Tasks                 []TaskVariance          `json:"tasks"`
+}
+
+// slipHours returns current - baseline in hours, or nil if either is missing
+func slipHours(baseline, current *time.Time) *float64 {
+	if baseline == nil || current == nil {
+		return nil
+	}
+	slip := current.Sub(*baseline).Hours()
+	return &slip
+}
+
+// ComputeVariance compares the work package against the baseline
+func ComputeVariance(baseline models.ScheduleBaseline, wp models.AircraftWorkPackage) VarianceReport {
+	report := VarianceReport{
+		AircraftWorkPackageId: wp.AircraftWorkPackageId,
+		Baseline:              baseline,
+		Package: PackageVariance{
+			BaselineStart:   baseline.SchedStartDateTime,
+			BaselineEnd:     baseline.SchedEndDateTime,
+			SchedStart:      wp.SchedStartDateTime,
+			SchedEnd:        wp.SchedEndDateTime,
+			ActualStart:     wp.ActualStartDateTime,
+			ActualEnd:       wp.ActualEndDateTime,
+			StartSlip:       slipHours(baseline.SchedStartDateTime, wp.SchedStartDateTime),
+			EndSlip:         slipHours(baseline.SchedEndDateTime, wp.SchedEndDateTime),
+			ActualStartSlip: slipHours(baseline.SchedStartDateTime, wp.ActualStartDateTime),
+			ActualEndSlip:   slipHours(baseline.SchedEndDateTime, wp.ActualEndDateTime),
+		},
+		Tasks: []TaskVariance{},
+	}
+
+	baselineTasks := map[int]models.BaselineTask{}
+	for _, task := range baseline.Tasks {
+		baselineTasks[task.TaskSeq] = task
+	}
+
+	current := map[int]bool{}
+	for _, task := range wp.Avexetask {
+		current[task.TaskSeq] = true
+
+		variance := TaskVariance{
+			TaskSeq:       task.TaskSeq,
+			Description:   task.Description,
+			PlannedStart:  task.PlannedStart,
+			PlannedFinish: task.PlannedFinish,
+			ActualStart:   task.ActualStart,
+			ActualFinish:  task.ActualFinish,
+		}
+
+		baselineTask, found := baselineTasks[task.TaskSeq]
+		if !found {
+			variance.Status = "added"
+			report.Package.AddedTasks++
+			report.Tasks = append(report.Tasks, variance)
+			continue
+		}
+
+		variance.BaselineStart = baselineTask.PlannedStart
+		variance.BaselineFinish = baselineTask.PlannedFinish
+		variance.StartSlip = slipHours(baselineTask.PlannedStart, task.PlannedStart)
+		variance.FinishSlip = slipHours(baselineTask.PlannedFinish, task.PlannedFinish)
+		variance.ActualStartSlip = slipHours(baselineTask.PlannedStart, task.ActualStart)
+		variance.ActualFinishSlip = slipHours(baselineTask.PlannedFinish, task.ActualFinish)
+
+		// Actuals win over the plan once the task has finished
+		finishSlip := variance.FinishSlip
+		if variance.ActualFinishSlip != nil {
+			finishSlip = variance.ActualFinishSlip
+		}
+
+		variance.Status = "unchanged"
+		if finishSlip != nil {
+			switch {
+			case *finishSlip > 0:
+				variance.Status = "slipped"
+				report.Package.SlippedTasks++
+			case *finishSlip < 0:
+				variance.Status = "advanced"
+				report.Package.AdvancedTasks++
+			}
+			if *finishSlip > report.Package.MaxFinishSlip {
+				report.Package.MaxFinishSlip = *finishSlip
+			}
+		}
+
+		report.Tasks = append(report.Tasks, variance)
+	}
+
+	for _, baselineTask := range baseline.Tasks {
+		if current[baselineTask.TaskSeq] {
+			continue
+		}
+		report.Package.RemovedTasks++
+		report.Tasks = append(report.Tasks, TaskVariance{
+			TaskSeq:        baselineTask.TaskSeq,
+			Description:    baselineTask.Description,
+			Status:         "removed",
+			BaselineStart:  baselineTask.PlannedStart,
+			BaselineFinish: baselineTask.PlannedFinish,
+		})
+	}
+
+	sort.SliceStable(report.Tasks, func(i, j int) bool {
+		return report.Tasks[i].TaskSeq < report.Tasks[j].TaskSeq
+	})
+
+	return report
+}
+
+// Scheduler takes a baseline of every work package that reaches one of the given states
+// (typically "Committed") and has no scheduled baseline yet
+type Scheduler struct {
+	WorkPackages *mongo.Collection
+	Baselines    *mongo.Collection
+	Interval     time.Duration
+	States       []string
+}
+
+// Run captures baselines every Interval until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("Baseline scheduler started (interval %s, states %v)", s.Interval, s.States)
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if count, err := s.CaptureCommitted(ctx); err != nil {
+			log.Printf("Baseline scheduler error: %v", err)
+		} else if count > 0 {
+			log.Printf("Baseline scheduler captured %d baselines", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Baseline scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CaptureCommitted stores a scheduled baseline for every matching work package that has none yet.
+// Returns the number of baselines captured
+func (s *Scheduler) CaptureCommitted(ctx context.Context) (int, error) {
+	cursor, err := s.WorkPackages.Find(ctx, bson.M{
+		"Objstate":   bson.M{"$in": s.States},
+		"IsHistoric": bson.M{"$ne": true},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var workPackages []models.AircraftWorkPackage
+	if err := cursor.All(ctx, &workPackages); err != nil {
+		return 0, err
+	}
+	if len(workPackages) == 0 {
+		return 0, nil
+	}
+
+	wpIds := make([]int, 0, len(workPackages))
+	for _, wp := range workPackages {
+		wpIds = append(wpIds, wp.AircraftWorkPackageId)
+	}
+	existing, err := s.Baselines.Distinct(ctx, "AircraftWorkPackageId", bson.M{
+		"AircraftWorkPackageId": bson.M{"$in": wpIds},
+		"Trigger":               TriggerScheduled,
+	})
+	if err != nil {
+		return 0, err
+	}
+	hasBaseline := map[int]bool{}
+	for _, value := range existing {
+		switch id := value.(type) {
+		case int32:
+			hasBaseline[int(id)] = true
+		case int64:
+			hasBaseline[int(id)] = true
+		}
+	}
+
+	count := 0
+	now := time.Now()
+	for _, wp := range workPackages {
+		if hasBaseline[wp.AircraftWorkPackageId] {
+			continue
+		}
+		baseline := FromWorkPackage(wp, TriggerScheduled, wp.Objstate, "", now)
+		if _, err := s.Baselines.InsertOne(ctx, baseline); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
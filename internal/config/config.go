@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,16 +13,73 @@ type Config struct {
 		Port string `yaml:"port"`
 	} `yaml:"server"`
 	MongoDB struct {
-		URL string `yaml:"url"`
+		URL            string `yaml:"url"`
+		SkipMigrations bool   `yaml:"skip_migrations"` // apply them with cmd/migrate instead
 	} `yaml:"mongodb"`
 	JWT struct {
-		Secret string `yaml:"secret"`
+		Secret     string `yaml:"secret"`
+		AccessTTL  string `yaml:"access_ttl"`  // lifetime of access tokens, e.g. "15m"
+		RefreshTTL string `yaml:"refresh_ttl"` // lifetime of refresh tokens, renewed by every refresh
 	} `yaml:"jwt"`
 	GoogleOAuth struct {
+		ClientID     string              `yaml:"client_id"`
+		ClientSecret string              `yaml:"client_secret"`
+		RedirectURL  string              `yaml:"redirect_url"`
+		DefaultRoles []string            `yaml:"default_roles"` // roles of users provisioned by Google sign-in
+		DomainRoles  map[string][]string `yaml:"domain_roles"`  // email domain -> roles, instead of default_roles
+	} `yaml:"google_oauth"`
+	Auth struct {
+		DefaultRoles []string `yaml:"default_roles"` // roles of self-registered users
+	} `yaml:"auth"`
+	OIDC struct {
+		CallbackURL string         `yaml:"callback_url"` // frontend page a browser callback is sent on to with a one-time login code
+		Providers   []OIDCProvider `yaml:"providers"`
+	} `yaml:"oidc"`
+	Baseline struct {
+		Interval string   `yaml:"interval"` // e.g. "15m"; empty disables scheduled baselines
+		States   []string `yaml:"states"`
+	} `yaml:"baseline"`
+	IFS struct {
+		BaseURL      string `yaml:"base_url"` // projection service root; empty disables the IFS sync
+		EntitySet    string `yaml:"entity_set"`
+		TokenURL     string `yaml:"token_url"`
 		ClientID     string `yaml:"client_id"`
 		ClientSecret string `yaml:"client_secret"`
-		RedirectURL  string `yaml:"redirect_url"`
-	} `yaml:"google_oauth"`
+		PageSize     int    `yaml:"page_size"`
+		SyncInterval string `yaml:"sync_interval"`
+		WriteBack    struct {
+			Interval    string `yaml:"interval"`
+			MaxAttempts int    `yaml:"max_attempts"`
+			Backoff     string `yaml:"backoff"`
+		} `yaml:"write_back"`
+	} `yaml:"ifs"`
+	Archive struct {
+		Interval      string   `yaml:"interval"` // e.g. "24h"; empty disables archiving
+		AgeDays       int      `yaml:"age_days"` // days after the end of a package before it is archived
+		States        []string `yaml:"states"`   // archived besides IsHistoric packages
+		Compress      bool     `yaml:"compress"`
+		RetentionDays int      `yaml:"retention_days"` // days after the end before archived packages are deleted; 0 keeps them
+	} `yaml:"archive"`
+	Stations struct {
+		Zones     map[string]string `yaml:"zones"`      // LocationCode -> IANA time zone
+		DefaultTZ string            `yaml:"default_tz"` // tz used when a request names none; empty is UTC
+	} `yaml:"stations"`
+}
+
+// OIDCProvider is an OpenID Connect identity provider, e.g. Azure AD, Okta or Keycloak
+type OIDCProvider struct {
+	Name         string              `yaml:"name"` // used in the login URLs
+	DisplayName  string              `yaml:"display_name"`
+	Issuer       string              `yaml:"issuer"`
+	DiscoveryURL string              `yaml:"discovery_url"` // default: issuer + /.well-known/openid-configuration
+	ClientID     string              `yaml:"client_id"`
+	ClientSecret string              `yaml:"client_secret"`
+	RedirectURL  string              `yaml:"redirect_url"`
+	RedirectURLs []string            `yaml:"redirect_urls"` // further URLs a login may return to; all must be registered at the provider
+	Scopes       []string            `yaml:"scopes"`
+	RoleClaim    string              `yaml:"role_claim"`    // ID token claim with groups or roles; dots select nested claims, e.g. realm_access.roles
+	RoleMapping  map[string][]string `yaml:"role_mapping"`  // role_claim value -> roles
+	DefaultRoles []string            `yaml:"default_roles"` // roles when no role_claim value is mapped
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -44,6 +102,73 @@ func LoadConfig(path string) (*Config, error) {
 		config.JWT.Secret = "your-secret-key-change-in-production"
 	}
 
+	if config.JWT.AccessTTL == "" {
+		config.JWT.AccessTTL = "15m"
+	}
+
+	if config.JWT.RefreshTTL == "" {
+		config.JWT.RefreshTTL = "168h"
+	}
+
+	if len(config.Auth.DefaultRoles) == 0 {
+		config.Auth.DefaultRoles = []string{"viewer"}
+	}
+
+	if len(config.GoogleOAuth.DefaultRoles) == 0 {
+		config.GoogleOAuth.DefaultRoles = []string{"viewer"}
+	}
+
+	if config.OIDC.CallbackURL == "" {
+		config.OIDC.CallbackURL = "http://localhost:3000/auth/oidc/callback"
+	}
+
+	for i := range config.OIDC.Providers {
+		provider := &config.OIDC.Providers[i]
+		if provider.DisplayName == "" {
+			provider.DisplayName = provider.Name
+		}
+		if provider.DiscoveryURL == "" && provider.Issuer != "" {
+			provider.DiscoveryURL = strings.TrimSuffix(provider.Issuer, "/") + "/.well-known/openid-configuration"
+		}
+		if provider.RedirectURL == "" {
+			provider.RedirectURL = "http://localhost:3000/auth/oidc/" + provider.Name + "/callback"
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+		if len(provider.DefaultRoles) == 0 {
+			provider.DefaultRoles = []string{"viewer"}
+		}
+	}
+
+	if len(config.Baseline.States) == 0 {
+		config.Baseline.States = []string{"Committed"}
+	}
+
+	if config.IFS.EntitySet == "" {
+		config.IFS.EntitySet = "AvAircraftWorkPackageSet"
+	}
+
+	if config.IFS.SyncInterval == "" {
+		config.IFS.SyncInterval = "5m"
+	}
+
+	if config.IFS.WriteBack.Interval == "" {
+		config.IFS.WriteBack.Interval = "30s"
+	}
+
+	if config.IFS.WriteBack.Backoff == "" {
+		config.IFS.WriteBack.Backoff = "30s"
+	}
+
+	if config.Archive.AgeDays == 0 {
+		config.Archive.AgeDays = 90
+	}
+
+	if len(config.Archive.States) == 0 {
+		config.Archive.States = []string{"Completed", "Closed"}
+	}
+
 	return &config, nil
 }
 
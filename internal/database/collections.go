@@ -0,0 +1,32 @@
+package database
+
+// Collection names shared by the server and the command line tools
+const (
+	// WorkPackageCollection holds the AircraftWorkPackage documents mirrored from IFS
+	WorkPackageCollection = "AvAircraftWorkPackage"
+
+	// LegacyWorkPackageCollection was read by the first work package endpoints.
+	// Migration 1 merges it into WorkPackageCollection
+	LegacyWorkPackageCollection = "aircraftWorkPackages"
+
+	// ArchiveCollection holds the work packages moved out of WorkPackageCollection by the archiver
+	ArchiveCollection = "AvAircraftWorkPackageArchive"
+
+	// UserCollection holds the application users
+	UserCollection = "users"
+
+	// RefreshTokenCollection holds the hashed refresh tokens
+	RefreshTokenCollection = "refreshTokens"
+
+	// RevokedTokenCollection is the revocation list of access tokens and refresh token families
+	RevokedTokenCollection = "revokedTokens"
+
+	// LoginCodeCollection holds the hashed one-time codes of browser logins
+	LoginCodeCollection = "loginCodes"
+
+	// APIKeyCollection holds the hashed API keys of machine clients
+	APIKeyCollection = "apiKeys"
+
+	// GanttDependencyCollection holds the user-defined dependencies between Gantt tasks
+	GanttDependencyCollection = "ganttDependencies"
+)
@@ -0,0 +1,522 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"stationMonitor/internal/archive"
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationCollection records the migrations applied to the database
+const migrationCollection = "schemaMigrations"
+
+// migrationLockID is the document that keeps two processes from migrating at the same time
+const migrationLockID = "lock"
+
+// migrationLockTimeout frees the lock of a process that died while migrating
+const migrationLockTimeout = 10 * time.Minute
+
+// Migration is one versioned change to the database. Up must be safe to run again
+// if it failed half way, since the migration is only recorded once it succeeds
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+}
+
+// MigrationRecord is stored for every applied migration
+type MigrationRecord struct {
+	Version   int       `bson:"_id" json:"version"`
+	Name      string    `bson:"Name" json:"name"`
+	AppliedAt time.Time `bson:"AppliedAt" json:"appliedAt"`
+	Duration  float64   `bson:"Duration" json:"duration"` // seconds
+}
+
+// MigrationState is the status of one known migration
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Migrations lists every migration in version order. Never change or reorder an
+// applied migration, add a new one instead
+var Migrations = []Migration{
+	{1, "merge aircraftWorkPackages into AvAircraftWorkPackage", mergeLegacyWorkPackages},
+	{2, "index work packages", indexWorkPackages},
+	{3, "unique user email and username", indexUsers},
+	{4, "index gantt, baseline and IFS collections", indexSupportCollections},
+	{5, "backfill IsHistoric and task AircraftWpId", backfillWorkPackageFields},
+	{6, "index task query fields", indexTaskFields},
+	{7, "index the work package archive", indexArchive},
+	{8, "index refresh tokens and the revocation list", indexTokens},
+	{9, "give users without roles the viewer role", backfillUserRoles},
+	{10, "index and backfill the fields user scopes filter on", indexScopeFields},
+	{11, "unique API key hash", indexAPIKeys},
+	{12, "unique OIDC account of users", indexUserExternalIDs},
+	{13, "one pending IFS write-back per task", indexPendingWriteBacks},
+	{14, "expire login codes", indexLoginCodes},
+}
+
+// Migrate applies the pending migrations in order and returns the ones applied
+func Migrate(ctx context.Context, db *mongo.Database) ([]MigrationRecord, error) {
+	if err := acquireMigrationLock(ctx, db); err != nil {
+		return nil, err
+	}
+	defer releaseMigrationLock(db)
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []MigrationRecord{}
+	for _, migration := range Migrations {
+		if _, done := applied[migration.Version]; done {
+			continue
+		}
+
+		log.Printf("Applying migration %d: %s", migration.Version, migration.Name)
+		started := time.Now()
+		if err := migration.Up(ctx, db); err != nil {
+			return records, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+
+		record := MigrationRecord{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now().UTC(),
+			Duration:  time.Since(started).Seconds(),
+		}
+		if _, err := db.Collection(migrationCollection).InsertOne(ctx, record); err != nil {
+			return records, fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// MigrationStatus reports which migrations have been applied
+func MigrationStatus(ctx context.Context, db *mongo.Database) ([]MigrationState, error) {
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := []MigrationState{}
+	for _, migration := range Migrations {
+		state := MigrationState{Version: migration.Version, Name: migration.Name}
+		if record, done := applied[migration.Version]; done {
+			appliedAt := record.AppliedAt
+			state.Applied = true
+			state.AppliedAt = &appliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// appliedMigrations loads the migration records by version
+func appliedMigrations(ctx context.Context, db *mongo.Database) (map[int]MigrationRecord, error) {
+	cursor, err := db.Collection(migrationCollection).Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []MigrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]MigrationRecord{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// acquireMigrationLock takes the migration lock, or fails if another process holds it
+func acquireMigrationLock(ctx context.Context, db *mongo.Database) error {
+	now := time.Now().UTC()
+	_, err := db.Collection(migrationCollection).UpdateOne(ctx,
+		bson.M{"_id": migrationLockID, "ExpiresAt": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"LockedAt": now, "ExpiresAt": now.Add(migrationLockTimeout)}},
+		options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("migrations are locked by another process")
+	}
+	return err
+}
+
+// releaseMigrationLock frees the migration lock
+func releaseMigrationLock(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := db.Collection(migrationCollection).DeleteOne(ctx, bson.M{"_id": migrationLockID}); err != nil {
+		log.Printf("Warning: Could not release migration lock: %v", err)
+	}
+}
+
+// collectionExists reports whether the database has a collection with the given name
+func collectionExists(ctx context.Context, db *mongo.Database, name string) (bool, error) {
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": name})
+	if err != nil {
+		return false, err
+	}
+	return len(names) > 0, nil
+}
+
+// mergeLegacyWorkPackages moves the documents of the legacy collection into the work package
+// collection. The collection is renamed if the target does not exist yet; otherwise documents
+// are copied unless the target already has the work package, and the legacy collection is dropped
+func mergeLegacyWorkPackages(ctx context.Context, db *mongo.Database) error {
+	legacyExists, err := collectionExists(ctx, db, LegacyWorkPackageCollection)
+	if err != nil || !legacyExists {
+		return err
+	}
+	targetExists, err := collectionExists(ctx, db, WorkPackageCollection)
+	if err != nil {
+		return err
+	}
+
+	if !targetExists {
+		return db.Client().Database("admin").RunCommand(ctx, bson.D{
+			{Key: "renameCollection", Value: db.Name() + "." + LegacyWorkPackageCollection},
+			{Key: "to", Value: db.Name() + "." + WorkPackageCollection},
+		}).Err()
+	}
+
+	legacy := db.Collection(LegacyWorkPackageCollection)
+	target := db.Collection(WorkPackageCollection)
+
+	cursor, err := legacy.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	copied, skipped := 0, 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		wpId, found := doc["AircraftWorkPackageId"]
+		if !found {
+			skipped++
+			continue
+		}
+		// Inserted whole, "@odata.etag" keys cannot be written through update operators
+		existing, err := target.CountDocuments(ctx, bson.M{"AircraftWorkPackageId": wpId})
+		if err != nil {
+			return err
+		}
+		if existing > 0 {
+			continue
+		}
+		delete(doc, "_id")
+		if _, err := target.InsertOne(ctx, doc); err != nil {
+			return err
+		}
+		copied++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("%d documents in %s have no AircraftWorkPackageId, move or delete them first", skipped, LegacyWorkPackageCollection)
+	}
+
+	log.Printf("Copied %d work packages from %s, dropping it", copied, LegacyWorkPackageCollection)
+	return legacy.Drop(ctx)
+}
+
+// indexWorkPackages creates the indexes used by the work package, Gantt and sync queries
+func indexWorkPackages(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(WorkPackageCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "AircraftWorkPackageId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "AircraftId", Value: 1}}},
+		{Keys: bson.D{{Key: "LocationCode", Value: 1}}},
+		{Keys: bson.D{{Key: "SchedStartDateTime", Value: 1}}},
+		{Keys: bson.D{{Key: "SchedEndDateTime", Value: 1}}},
+		{Keys: bson.D{{Key: "Objstate", Value: 1}}},
+	})
+	return err
+}
+
+// indexUsers makes email and username unique. Users without a username (Google sign-in)
+// are left out of the username index
+func indexUsers(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(UserCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"email": bson.M{"$type": "string"}}),
+		},
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"username": bson.M{"$type": "string"}}),
+		},
+	})
+	return err
+}
+
+// indexSupportCollections indexes the collections added next to the work packages
+func indexSupportCollections(ctx context.Context, db *mongo.Database) error {
+	indexes := map[string][]mongo.IndexModel{
+		"ganttDependencies": {
+			{Keys: bson.D{{Key: "SourceAircraftWpId", Value: 1}}},
+			{Keys: bson.D{{Key: "TargetAircraftWpId", Value: 1}}},
+		},
+		"scheduleBaselines": {
+			{Keys: bson.D{{Key: "AircraftWorkPackageId", Value: 1}, {Key: "CreatedDate", Value: -1}}},
+		},
+		"ifsWriteBackQueue": {
+			{Keys: bson.D{{Key: "Status", Value: 1}, {Key: "NextAttempt", Value: 1}}},
+			{Keys: bson.D{{Key: "AircraftWorkPackageId", Value: 1}, {Key: "TaskSeq", Value: 1}}},
+		},
+	}
+
+	for collection, indexModels := range indexes {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, indexModels); err != nil {
+			return fmt.Errorf("%s: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// backfillWorkPackageFields sets IsHistoric on work packages that have none, and copies the
+// AircraftWorkPackageId into AircraftWpId of tasks that lack it
+func backfillWorkPackageFields(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection(WorkPackageCollection)
+
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"IsHistoric": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"IsHistoric": false}})
+	if err != nil {
+		return err
+	}
+	log.Printf("Backfilled IsHistoric on %d work packages", result.ModifiedCount)
+
+	// Existing values win over the backfilled one in $mergeObjects
+	result, err = collection.UpdateMany(ctx,
+		bson.M{"AvTaskArray": bson.M{"$elemMatch": bson.M{"AircraftWpId": bson.M{"$exists": false}}}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"AvTaskArray": bson.M{"$map": bson.M{
+				"input": "$AvTaskArray",
+				"in":    bson.M{"$mergeObjects": bson.A{bson.M{"AircraftWpId": "$AircraftWorkPackageId"}, "$$this"}},
+			}},
+		}}}})
+	if err != nil {
+		return err
+	}
+	log.Printf("Backfilled AircraftWpId in the tasks of %d work packages", result.ModifiedCount)
+
+	return nil
+}
+
+// indexTaskFields creates multikey indexes on the task fields filtered by the task query API
+func indexTaskFields(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(WorkPackageCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "AvTaskArray.CrewCode", Value: 1}, {Key: "AvTaskArray.Objstate", Value: 1}}},
+		{Keys: bson.D{{Key: "AvTaskArray.Location", Value: 1}}},
+		{Keys: bson.D{{Key: "AvTaskArray.ClassCode", Value: 1}}},
+		{Keys: bson.D{{Key: "AvTaskArray.PlannedStart", Value: 1}}},
+		{Keys: bson.D{{Key: "AvTaskArray.LatestFinish", Value: 1}}},
+	})
+	return err
+}
+
+// indexArchive indexes the archive collection on the fields the work package filters and the
+// retention job use
+func indexArchive(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(ArchiveCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "AircraftWorkPackageId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "AircraftId", Value: 1}}},
+		{Keys: bson.D{{Key: "LocationCode", Value: 1}}},
+		{Keys: bson.D{{Key: "SchedStartDateTime", Value: 1}}},
+		{Keys: bson.D{{Key: "EndDateTime", Value: 1}}},
+	})
+	return err
+}
+
+// indexTokens indexes refresh tokens by family and user, and lets MongoDB delete refresh
+// tokens and revocation entries once they expire
+func indexTokens(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(RefreshTokenCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "Family", Value: 1}}},
+		{Keys: bson.D{{Key: "UserID", Value: 1}}},
+		{Keys: bson.D{{Key: "ExpiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", RefreshTokenCollection, err)
+	}
+
+	_, err = db.Collection(RevokedTokenCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "ExpiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", RevokedTokenCollection, err)
+	}
+	return nil
+}
+
+// backfillUserRoles gives existing users the least privileged role. Admins grant more with
+// PUT /api/users/:id/roles, the first admin is created with scripts/setup-user.go
+func backfillUserRoles(ctx context.Context, db *mongo.Database) error {
+	result, err := db.Collection(UserCollection).UpdateMany(ctx,
+		bson.M{"$or": bson.A{bson.M{"roles": bson.M{"$exists": false}}, bson.M{"roles": bson.A{}}}},
+		bson.M{"$set": bson.M{"roles": bson.A{"viewer"}}})
+	if err != nil {
+		return err
+	}
+	log.Printf("Gave %d users the viewer role", result.ModifiedCount)
+	return nil
+}
+
+// indexScopeFields indexes the work package fields user scopes filter on, and copies the
+// CustomerNo and the task sites of packages archived before the archive kept them
+func indexScopeFields(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(WorkPackageCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "CustomerNo", Value: 1}}},
+		{Keys: bson.D{{Key: "AvTaskArray.Site", Value: 1}, {Key: "AvTaskArray.Company", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", WorkPackageCollection, err)
+	}
+
+	collection := db.Collection(ArchiveCollection)
+	cursor, err := collection.Find(ctx, bson.M{"Sites": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var doc models.ArchivedWorkPackage
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		wp, err := archive.Unpack(doc)
+		if err != nil {
+			return err
+		}
+		set := bson.M{"Sites": archive.Sites(wp)}
+		if wp.CustomerNo != nil {
+			set["CustomerNo"] = *wp.CustomerNo
+		}
+		if _, err := collection.UpdateByID(ctx, doc.ID, bson.M{"$set": set}); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	log.Printf("Backfilled the scope fields of %d archived work packages", count)
+
+	_, err = collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "CustomerNo", Value: 1}}},
+		{Keys: bson.D{{Key: "Sites.Site", Value: 1}, {Key: "Sites.Company", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", ArchiveCollection, err)
+	}
+	return nil
+}
+
+// indexAPIKeys makes API keys unique by, and findable by, their hash
+func indexAPIKeys(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(APIKeyCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "Hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// indexUserExternalIDs links every OIDC account to at most one user
+func indexUserExternalIDs(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(UserCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "external_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"external_id": bson.M{"$type": "string"}}),
+	})
+	return err
+}
+
+// indexPendingWriteBacks supersedes all but the latest pending IFS write-back of each task or
+// execution instance, and keeps it that way with a unique index over the pending items
+func indexPendingWriteBacks(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("ifsWriteBackQueue")
+
+	cursor, err := collection.Find(ctx, bson.M{"Status": "pending"},
+		options.Find().SetSort(bson.D{{Key: "UpdatedDate", Value: -1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	seen := map[string]bool{}
+	superseded := []interface{}{}
+	for cursor.Next(ctx) {
+		var item struct {
+			ID                    interface{} `bson:"_id"`
+			AircraftWorkPackageId int         `bson:"AircraftWorkPackageId"`
+			TaskSeq               int         `bson:"TaskSeq"`
+			ExecutionInstanceSeq  *int        `bson:"ExecutionInstanceSeq"`
+		}
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%d/%d", item.AircraftWorkPackageId, item.TaskSeq)
+		if item.ExecutionInstanceSeq != nil {
+			key += fmt.Sprintf("/%d", *item.ExecutionInstanceSeq)
+		}
+		if seen[key] {
+			superseded = append(superseded, item.ID)
+		}
+		seen[key] = true
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if len(superseded) > 0 {
+		result, err := collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": superseded}},
+			bson.M{"$set": bson.M{"Status": "superseded", "UpdatedDate": time.Now().UTC()}})
+		if err != nil {
+			return err
+		}
+		log.Printf("Superseded %d duplicate pending IFS write-backs", result.ModifiedCount)
+	}
+
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "AircraftWorkPackageId", Value: 1},
+			{Key: "TaskSeq", Value: 1},
+			{Key: "ExecutionInstanceSeq", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"Status": "pending"}),
+	})
+	return err
+}
+
+// indexLoginCodes lets MongoDB delete login codes that were never exchanged
+func indexLoginCodes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(LoginCodeCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "ExpiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
@@ -6,49 +6,54 @@ import (
 	"strconv"
 	"time"
 
-	"stationMonitor/internal/database"
 	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// GetAircraftWorkPackages retrieves all aircraft work packages from MongoDB
-func GetAircraftWorkPackages(c *gin.Context) {
-	collection := database.Database.Collection("aircraftWorkPackages")
+// GetAircraftWorkPackages retrieves all aircraft work packages
+func (s *Server) GetAircraftWorkPackages(c *gin.Context) {
+	tz, ok := s.parseTZ(c)
+	if !ok {
+		return
+	}
 
 	// Parse query parameters for filtering
-	filter := bson.M{}
+	filter := repository.WorkPackageFilter{}
 
 	// Filter by AircraftId if provided
 	if aircraftIdStr := c.Query("aircraftId"); aircraftIdStr != "" {
 		if aircraftId, err := strconv.Atoi(aircraftIdStr); err == nil {
-			filter["AircraftId"] = aircraftId
+			filter.AircraftIds = []int{aircraftId}
 		}
 	}
 
 	// Filter by AircraftWorkPackageId if provided
 	if wpIdStr := c.Query("aircraftWorkPackageId"); wpIdStr != "" {
 		if wpId, err := strconv.Atoi(wpIdStr); err == nil {
-			filter["AircraftWorkPackageId"] = wpId
+			filter.WorkPackageIds = []int{wpId}
 		}
 	}
 
 	// Filter by LocationCode if provided
 	if locationCode := c.Query("locationCode"); locationCode != "" {
-		filter["LocationCode"] = locationCode
+		filter.LocationCodes = []string{locationCode}
 	}
 
 	// Filter by IsHistoric if provided
 	if isHistoricStr := c.Query("isHistoric"); isHistoricStr != "" {
 		if isHistoric, err := strconv.ParseBool(isHistoricStr); err == nil {
-			filter["IsHistoric"] = isHistoric
+			filter.IsHistoric = &isHistoric
 		}
 	}
 
+	// Read through to the archive if requested
+	if filter.IncludeArchived, ok = includeArchived(c); !ok {
+		return
+	}
+
 	// Pagination parameters
 	page := 1
 	limit := 100
@@ -65,33 +70,24 @@ func GetAircraftWorkPackages(c *gin.Context) {
 
 	skip := (page - 1) * limit
 
-	// Find options
-	findOptions := options.Find()
-	findOptions.SetSkip(int64(skip))
-	findOptions.SetLimit(int64(limit))
-	findOptions.SetSort(bson.D{{Key: "SchedStartDateTime", Value: -1}}) // Sort by scheduled start date descending
+	// Sort by scheduled start date descending
+	findOptions := repository.FindOptions{Skip: int64(skip), Limit: int64(limit), Descending: true}
 
 	// Execute query
-	cursor, err := collection.Find(c.Request.Context(), filter, findOptions)
+	workPackages, err := s.workPackages(c).Find(c.Request.Context(), filter, findOptions)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database: " + err.Error()})
 		return
 	}
-	defer cursor.Close(c.Request.Context())
-
-	var workPackages []models.AircraftWorkPackage
-	if err := cursor.All(c.Request.Context(), &workPackages); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode results: " + err.Error()})
-		return
-	}
 
 	// Get total count for pagination
-	totalCount, err := collection.CountDocuments(c.Request.Context(), filter)
+	totalCount, err := s.workPackages(c).Count(c.Request.Context(), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count documents: " + err.Error()})
 		return
 	}
 
+	localizeWorkPackages(tz, workPackages)
 	c.JSON(http.StatusOK, gin.H{
 		"data":       workPackages,
 		"total":      totalCount,
@@ -101,30 +97,42 @@ func GetAircraftWorkPackages(c *gin.Context) {
 	})
 }
 
-// GetAircraftWorkPackageByID retrieves a specific aircraft work package by ID
-func GetAircraftWorkPackageByID(c *gin.Context) {
-	collection := database.Database.Collection("aircraftWorkPackages")
-
+// GetAircraftWorkPackageByID retrieves a specific aircraft work package by ID.
+// With includeArchived=true a package that is not found is looked up in the archive
+func (s *Server) GetAircraftWorkPackageByID(c *gin.Context) {
 	id := c.Param("id")
+	tz, ok := s.parseTZ(c)
+	if !ok {
+		return
+	}
+	withArchive, ok := includeArchived(c)
+	if !ok {
+		return
+	}
 
 	// Try to parse as ObjectID first
-	var filter bson.M
-	if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
-		filter = bson.M{"_id": objectID}
+	var workPackage models.AircraftWorkPackage
+	var err error
+	if objectID, parseErr := primitive.ObjectIDFromHex(id); parseErr == nil {
+		workPackage, err = s.workPackages(c).FindByObjectID(c.Request.Context(), objectID)
+		if err == repository.ErrNotFound && withArchive {
+			workPackage, err = s.workPackages(c).FindArchivedByObjectID(c.Request.Context(), objectID)
+		}
 	} else {
 		// If not ObjectID, try as AircraftWorkPackageId (integer)
-		if wpId, err := strconv.Atoi(id); err == nil {
-			filter = bson.M{"AircraftWorkPackageId": wpId}
+		if wpId, parseErr := strconv.Atoi(id); parseErr == nil {
+			workPackage, err = s.workPackages(c).FindByWorkPackageID(c.Request.Context(), wpId)
+			if err == repository.ErrNotFound && withArchive {
+				workPackage, err = s.workPackages(c).FindArchivedByWorkPackageID(c.Request.Context(), wpId)
+			}
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
 			return
 		}
 	}
 
-	var workPackage models.AircraftWorkPackage
-	err := collection.FindOne(c.Request.Context(), filter).Decode(&workPackage)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Aircraft work package not found"})
 			return
 		}
@@ -132,39 +140,36 @@ func GetAircraftWorkPackageByID(c *gin.Context) {
 		return
 	}
 
+	localizeWorkPackages(tz, []models.AircraftWorkPackage{workPackage})
 	c.JSON(http.StatusOK, workPackage)
 }
 
 // GetAircraftWorkPackagesByAircraftId retrieves all work packages for a specific aircraft
-func GetAircraftWorkPackagesByAircraftId(c *gin.Context) {
-	collection := database.Database.Collection("aircraftWorkPackages")
-
+func (s *Server) GetAircraftWorkPackagesByAircraftId(c *gin.Context) {
 	aircraftIdStr := c.Param("aircraftId")
 	aircraftId, err := strconv.Atoi(aircraftIdStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid aircraft ID"})
 		return
 	}
+	tz, ok := s.parseTZ(c)
+	if !ok {
+		return
+	}
 
-	filter := bson.M{"AircraftId": aircraftId}
-
-	// Find options - sort by scheduled start date
-	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{Key: "SchedStartDateTime", Value: -1}})
-
-	cursor, err := collection.Find(c.Request.Context(), filter, findOptions)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database: " + err.Error()})
+	filter := repository.WorkPackageFilter{AircraftIds: []int{aircraftId}}
+	if filter.IncludeArchived, ok = includeArchived(c); !ok {
 		return
 	}
-	defer cursor.Close(c.Request.Context())
 
-	var workPackages []models.AircraftWorkPackage
-	if err := cursor.All(c.Request.Context(), &workPackages); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode results: " + err.Error()})
+	// Sort by scheduled start date
+	workPackages, err := s.workPackages(c).Find(c.Request.Context(), filter, repository.FindOptions{Descending: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database: " + err.Error()})
 		return
 	}
 
+	localizeWorkPackages(tz, workPackages)
 	c.JSON(http.StatusOK, gin.H{
 		"data":  workPackages,
 		"count": len(workPackages),
@@ -173,17 +178,16 @@ func GetAircraftWorkPackagesByAircraftId(c *gin.Context) {
 
 // PrepareWorkPackageGanttTask prepares a Gantt task from an aircraft work package
 // Returns the task, task ID, and a boolean indicating if the work package should be skipped
-// taskIDCounter is incremented internally
-func PrepareWorkPackageGanttTask(wp models.AircraftWorkPackage, taskIDCounter *int) (GanttTask, int, bool) {
+// The task ID is derived from AircraftWorkPackageId (see WorkPackageGanttID)
+func PrepareWorkPackageGanttTask(wp models.AircraftWorkPackage) (GanttTask, string, bool) {
 	// Only include work packages with valid scheduled dates
 	if wp.SchedStartDateTime == nil || wp.SchedEndDateTime == nil {
 		log.Printf("Skipping work package %d: missing scheduled dates (Start: %v, End: %v)",
 			wp.AircraftWorkPackageId, wp.SchedStartDateTime, wp.SchedEndDateTime)
-		return GanttTask{}, 0, true
+		return GanttTask{}, "", true
 	}
 
-	taskID := *taskIDCounter
-	*taskIDCounter++
+	taskID := WorkPackageGanttID(wp.AircraftWorkPackageId)
 
 	// Calculate duration in hours from scheduled dates
 	duration := int(wp.SchedEndDateTime.Sub(*wp.SchedStartDateTime).Hours())
@@ -197,7 +201,8 @@ func PrepareWorkPackageGanttTask(wp models.AircraftWorkPackage, taskIDCounter *i
 		log.Printf("wp.AircraftWorkPackageId: %+v\n", wp.AircraftWorkPackageId)
 	}
 
-	progress := 0
+	// Roll up the progress of the tasks weighted by duration
+	progress := progressPercent(ganttProgress.WorkPackageProgress(wp))
 
 	// Build task text with work package details
 	taskText := wp.WorkPackageName
@@ -215,41 +220,29 @@ func PrepareWorkPackageGanttTask(wp models.AircraftWorkPackage, taskIDCounter *i
 		Progress: &progress,
 		Type:     "summary",
 		Open:     &open,
+
+		AircraftWorkPackageId: wp.AircraftWorkPackageId,
+		ETag:                  wp.ODataEtag,
 	}
 
 	log.Printf("wpTask: %+v\n", wp)
 	return wpTask, taskID, false
 }
 
-// PrepareAvExeTaskGanttTask prepares a Gantt task from an AvExeTask
+// PrepareAvExeTaskGanttTask prepares a Gantt task from an AvExeTask of the given work package
 // Returns the task, task ID, and a boolean indicating if the task should be skipped
-// taskIDCounter is incremented internally
-func PrepareAvExeTaskGanttTask(task models.AvExeTask, wpTaskID int, taskIDCounter *int) (GanttTask, int, bool) {
+// The task ID is derived from AircraftWorkPackageId/TaskSeq (see AvExeTaskGanttID)
+func PrepareAvExeTaskGanttTask(task models.AvExeTask, aircraftWorkPackageId int) (GanttTask, string, bool) {
 	// Determine start and end dates using priority: PlannedStart/PlannedFinish, then EarliestStart/LatestFinish
-	var taskStart *time.Time
-	var taskEnd *time.Time
-
-	// Use PlannedStart/PlannedFinish if available, otherwise EarliestStart/LatestFinish
-	if task.PlannedStart != nil {
-		taskStart = task.PlannedStart
-	} else if task.EarliestStart != nil {
-		taskStart = task.EarliestStart
-	}
-
-	if task.PlannedFinish != nil {
-		taskEnd = task.PlannedFinish
-	} else if task.LatestFinish != nil {
-		taskEnd = task.LatestFinish
-	}
+	taskStart, taskEnd := avExeTaskDates(task)
 	log.Printf("taskStart: %+v, taskEnd: %+v", taskStart, taskEnd)
 
 	// Skip if no valid dates found
 	if taskStart == nil || taskEnd == nil {
-		return GanttTask{}, 0, true
+		return GanttTask{}, "", true
 	}
 
-	taskID := *taskIDCounter
-	*taskIDCounter++
+	taskID := AvExeTaskGanttID(aircraftWorkPackageId, task.TaskSeq)
 
 	// Use Duration if available, otherwise calculate from dates
 	taskDuration := 0
@@ -260,27 +253,9 @@ func PrepareAvExeTaskGanttTask(task models.AvExeTask, wpTaskID int, taskIDCounte
 		taskDuration = int(taskEnd.Sub(*taskStart).Hours())
 	}
 
-	// Calculate progress using ActualStart/ActualFinish if available
-	taskProgress := 0
-	if task.ActualStart != nil && task.ActualFinish != nil {
-		actualDuration := task.ActualFinish.Sub(*task.ActualStart).Hours()
-		scheduledDuration := taskEnd.Sub(*taskStart).Hours()
-		if scheduledDuration > 0 {
-			taskProgress = int((actualDuration / scheduledDuration) * 100)
-			if taskProgress > 100 {
-				taskProgress = 100
-			}
-		}
-	} else if task.ActualStart != nil {
-		elapsed := time.Since(*task.ActualStart).Hours()
-		scheduledDuration := taskEnd.Sub(*taskStart).Hours()
-		if scheduledDuration > 0 {
-			taskProgress = int((elapsed / scheduledDuration) * 100)
-			if taskProgress > 100 {
-				taskProgress = 100
-			}
-		}
-	}
+	// Calculate progress from Objstate, reported hours and the execution instances
+	progress, _ := ganttProgress.TaskProgress(task)
+	taskProgress := progressPercent(progress)
 
 	// Build task text with description, taskseq, workpackageid, and aircraft id
 	taskText := "WT: " + task.Description
@@ -298,16 +273,27 @@ func PrepareAvExeTaskGanttTask(task models.AvExeTask, wpTaskID int, taskIDCounte
 		Duration: &taskDuration,
 		Progress: &taskProgress,
 		Type:     "task",
-		Parent:   wpTaskID,
+		Parent:   WorkPackageGanttID(aircraftWorkPackageId),
+
+		AircraftWorkPackageId: aircraftWorkPackageId,
+		TaskSeq:               task.TaskSeq,
+		ETag:                  task.ODataEtag,
+		ChangedDate:           task.ChangedDate,
+
+		EarliestStart: task.EarliestStart,
+		LatestStart:   task.LatestStart,
+		LatestFinish:  task.LatestFinish,
+		FixedStart:    task.FixedStart,
 	}
+	checkGanttConstraints(&childTask)
 
 	return childTask, taskID, false
 }
 
-// PrepareExecutionInstanceGanttTask prepares a Gantt task from a JtExecutionInstance
+// PrepareExecutionInstanceGanttTask prepares a Gantt task from a JtExecutionInstance of the given task
 // Returns the task, task ID, and a boolean indicating if the instance should be skipped
-// taskIDCounter is incremented internally
-func PrepareExecutionInstanceGanttTask(instance models.JtExecutionInstance, parentTaskID int, taskIDCounter *int) (GanttTask, int, bool) {
+// The task ID is derived from AircraftWorkPackageId/TaskSeq/ExecutionInstanceSeq (see ExecutionInstanceGanttID)
+func PrepareExecutionInstanceGanttTask(instance models.JtExecutionInstance, aircraftWorkPackageId int, taskSeq int) (GanttTask, string, bool) {
 	log.Printf("instance: %+v\n", instance)
 
 	// Use instance.AllocatedStart and instance.AllocatedFinish for start and end
@@ -316,11 +302,10 @@ func PrepareExecutionInstanceGanttTask(instance models.JtExecutionInstance, pare
 
 	// Skip if no valid start/end
 	if instanceStart == nil || instanceEnd == nil {
-		return GanttTask{}, 0, true
+		return GanttTask{}, "", true
 	}
 
-	taskID := *taskIDCounter
-	*taskIDCounter++
+	taskID := ExecutionInstanceGanttID(aircraftWorkPackageId, taskSeq, instance.ExecutionInstanceSeq)
 
 	var instanceDuration *int
 	dur := int(instanceEnd.Sub(*instanceStart).Hours())
@@ -329,10 +314,12 @@ func PrepareExecutionInstanceGanttTask(instance models.JtExecutionInstance, pare
 	// Create default text for the instance task
 	instanceText := "ExecInstance:" + strconv.Itoa(instance.ExecutionInstanceSeq) + "] ExecInstance"
 
-	// Set progress
-	p := 0
+	// Set progress from the reported hours and time to complete
+	progress, _ := ganttProgress.InstanceProgress(instance)
+	p := progressPercent(progress)
 	instanceProgress := &p
 
+	executionInstanceSeq := instance.ExecutionInstanceSeq
 	instanceTask := GanttTask{
 		ID:       taskID,
 		Text:     instanceText,
@@ -341,8 +328,48 @@ func PrepareExecutionInstanceGanttTask(instance models.JtExecutionInstance, pare
 		Duration: instanceDuration,
 		Progress: instanceProgress,
 		Type:     "task",
-		Parent:   parentTaskID, // AvExeTask is the parent
+		Parent:   AvExeTaskGanttID(aircraftWorkPackageId, taskSeq), // AvExeTask is the parent
+
+		AircraftWorkPackageId: aircraftWorkPackageId,
+		TaskSeq:               taskSeq,
+		ExecutionInstanceSeq:  &executionInstanceSeq,
+		ChangedDate:           instance.ChangedDate,
+
+		FixedStart: instance.FixedStart,
 	}
+	checkGanttConstraints(&instanceTask)
 
 	return instanceTask, taskID, false
 }
+
+// avExeTaskDates returns the dates a task is drawn with:
+// PlannedStart/PlannedFinish if available, otherwise EarliestStart/LatestFinish
+func avExeTaskDates(task models.AvExeTask) (*time.Time, *time.Time) {
+	var taskStart *time.Time
+	var taskEnd *time.Time
+
+	if task.PlannedStart != nil {
+		taskStart = task.PlannedStart
+	} else if task.EarliestStart != nil {
+		taskStart = task.EarliestStart
+	}
+
+	if task.PlannedFinish != nil {
+		taskEnd = task.PlannedFinish
+	} else if task.LatestFinish != nil {
+		taskEnd = task.LatestFinish
+	}
+
+	return taskStart, taskEnd
+}
+
+// includeArchived reads the includeArchived query parameter.
+// On failure the error response has already been written and ok is false
+func includeArchived(c *gin.Context) (include bool, ok bool) {
+	value, err := queryBool(c, "includeArchived")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false, false
+	}
+	return value != nil && *value, true
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// workPackageTestServer returns a server with packages 1 to 3 in the live collection, 1 and 4
+// in the archive. Package 2 is at JFK, 3 is historic, and later IDs start later
+func workPackageTestServer() *Server {
+	workPackages := repository.NewMemoryWorkPackages()
+	archivedDate := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	for wpId := 1; wpId <= 4; wpId++ {
+		wp := testWorkPackage(wpId)
+		start := wp.SchedStartDateTime.AddDate(0, 0, wpId)
+		wp.SchedStartDateTime = &start
+		switch wpId {
+		case 2:
+			wp.LocationCode = "JFK"
+		case 3:
+			wp.IsHistoric = true
+		}
+		if wpId == 1 || wpId == 4 {
+			archived := wp
+			archived.Objstate = "Released"
+			workPackages.PutArchived(archived, archivedDate)
+		}
+		if wpId != 4 {
+			workPackages.Put(wp)
+		}
+	}
+	return NewServer(testConfig(), workPackages, repository.NewMemoryUsers())
+}
+
+// workPackagePage is the response of GetAircraftWorkPackages
+type workPackagePage struct {
+	Data       []models.AircraftWorkPackage `json:"data"`
+	Total      int                          `json:"total"`
+	Page       int                          `json:"page"`
+	Limit      int                          `json:"limit"`
+	TotalPages int                          `json:"totalPages"`
+}
+
+// workPackageIds returns the AircraftWorkPackageIds of a page in order
+func (p workPackagePage) workPackageIds() []int {
+	ids := []int{}
+	for _, wp := range p.Data {
+		ids = append(ids, wp.AircraftWorkPackageId)
+	}
+	return ids
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGetAircraftWorkPackages(t *testing.T) {
+	s := workPackageTestServer()
+
+	tests := []struct {
+		query string
+		want  []int
+		total int
+		pages int
+	}{
+		{"", []int{3, 2, 1}, 3, 1},
+		{"?locationCode=JFK", []int{2}, 1, 1},
+		{"?aircraftId=101", []int{1}, 1, 1},
+		{"?aircraftWorkPackageId=3", []int{3}, 1, 1},
+		{"?isHistoric=true", []int{3}, 1, 1},
+		{"?isHistoric=false&locationCode=AMS", []int{1}, 1, 1},
+		{"?limit=2", []int{3, 2}, 3, 2},
+		{"?limit=2&page=2", []int{1}, 3, 2},
+		{"?limit=2&page=3", []int{}, 3, 2},
+		{"?includeArchived=true", []int{4, 3, 2, 1}, 4, 1},
+		{"?includeArchived=true&aircraftWorkPackageId=4", []int{4}, 1, 1},
+	}
+	for _, tt := range tests {
+		w := serve(s.GetAircraftWorkPackages, http.MethodGet, "/work-packages"+tt.query, "/work-packages", nil, "planner", nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%q: got %d: %s", tt.query, w.Code, w.Body)
+			continue
+		}
+		var page workPackagePage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if got := page.workPackageIds(); !sameInts(got, tt.want) || page.Total != tt.total || page.TotalPages != tt.pages {
+			t.Errorf("%q: got %v of %d in %d pages, want %v of %d in %d", tt.query, got, page.Total, page.TotalPages, tt.want, tt.total, tt.pages)
+		}
+		// Package 1 is back in the live collection, so its archived copy is not listed
+		for _, wp := range page.Data {
+			if wp.AircraftWorkPackageId == 1 && (wp.ArchivedDate != nil || wp.Objstate != "Planned") {
+				t.Errorf("%q: listed the archived copy of package 1", tt.query)
+			}
+		}
+	}
+
+	if w := serve(s.GetAircraftWorkPackages, http.MethodGet, "/work-packages?includeArchived=maybe", "/work-packages", nil, "planner", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid includeArchived: got %d, want 400", w.Code)
+	}
+}
+
+func TestGetAircraftWorkPackageByID(t *testing.T) {
+	s := workPackageTestServer()
+	live, _ := s.WorkPackages.FindByWorkPackageID(context.Background(), 1)
+	archived, _ := s.WorkPackages.FindArchivedByWorkPackageID(context.Background(), 4)
+
+	tests := []struct {
+		id       string
+		query    string
+		want     int
+		wpId     int
+		archived bool
+	}{
+		{"1", "", http.StatusOK, 1, false},
+		{live.ID.Hex(), "", http.StatusOK, 1, false},
+		{"1", "?includeArchived=true", http.StatusOK, 1, false},
+		{"4", "", http.StatusNotFound, 0, false},
+		{"4", "?includeArchived=true", http.StatusOK, 4, true},
+		{archived.ID.Hex(), "", http.StatusNotFound, 0, false},
+		{archived.ID.Hex(), "?includeArchived=true", http.StatusOK, 4, true},
+		{"99", "?includeArchived=true", http.StatusNotFound, 0, false},
+		{"wp-1", "", http.StatusBadRequest, 0, false},
+		{"1", "?includeArchived=maybe", http.StatusBadRequest, 0, false},
+	}
+	for _, tt := range tests {
+		w := serve(s.GetAircraftWorkPackageByID, http.MethodGet, "/work-packages/"+tt.id+tt.query, "/work-packages/:id", nil, "planner", nil)
+		if w.Code != tt.want {
+			t.Errorf("%s%s: got %d, want %d: %s", tt.id, tt.query, w.Code, tt.want, w.Body)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var wp models.AircraftWorkPackage
+		if err := json.Unmarshal(w.Body.Bytes(), &wp); err != nil {
+			t.Fatal(err)
+		}
+		if wp.AircraftWorkPackageId != tt.wpId || (wp.ArchivedDate != nil) != tt.archived {
+			t.Errorf("%s%s: got package %d (archived %v), want %d (archived %v)", tt.id, tt.query, wp.AircraftWorkPackageId, wp.ArchivedDate, tt.wpId, tt.archived)
+		}
+	}
+}
+
+func TestScopedUserSeesOwnStation(t *testing.T) {
+	s := workPackageTestServer()
+	jfk := models.Scope{LocationCodes: []string{"JFK"}}
+
+	for _, query := range []string{"", "?includeArchived=true", "?locationCode=AMS"} {
+		w := serve(s.GetAircraftWorkPackages, http.MethodGet, "/work-packages"+query, "/work-packages", nil, "technician", &jfk)
+		var page workPackagePage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%q: got %d: %s", query, w.Code, w.Body)
+		}
+		want := []int{2}
+		if query == "?locationCode=AMS" {
+			want = []int{}
+		}
+		if got := page.workPackageIds(); !sameInts(got, want) || page.Total != len(want) {
+			t.Errorf("%q: got %v of %d, want %v", query, got, page.Total, want)
+		}
+	}
+
+	tests := []struct {
+		handler gin.HandlerFunc
+		path    string
+		route   string
+		want    int
+	}{
+		{s.GetAircraftWorkPackageByID, "/work-packages/2", "/work-packages/:id", http.StatusOK},
+		{s.GetAircraftWorkPackageByID, "/work-packages/1", "/work-packages/:id", http.StatusNotFound},
+		{s.GetAircraftWorkPackageByID, "/work-packages/4?includeArchived=true", "/work-packages/:id", http.StatusNotFound},
+		{s.GetTask, "/tasks/2/1", "/tasks/:aircraftWorkPackageId/:taskSeq", http.StatusOK},
+		{s.GetTask, "/tasks/1/1", "/tasks/:aircraftWorkPackageId/:taskSeq", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := serve(tt.handler, http.MethodGet, tt.path, tt.route, nil, "technician", &jfk); w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.path, w.Code, tt.want)
+		}
+	}
+
+	w := serve(s.GetTasks, http.MethodGet, "/tasks", "/tasks", nil, "technician", &jfk)
+	var tasks struct {
+		Data []models.WorkPackageTask `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &tasks); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("tasks: got %d: %s", w.Code, w.Body)
+	}
+	if got := taskKeys(tasks.Data); fmt.Sprint(got) != "[2/1 2/2]" {
+		t.Errorf("tasks = %v, want those of package 2", got)
+	}
+}
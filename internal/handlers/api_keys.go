@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"stationMonitor/internal/middleware"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/rbac"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// apiKeyPrefixLength is how much of a key, after models.APIKeyPrefix, is kept to recognise it by
+const apiKeyPrefixLength = 8
+
+// GetAPIKeys lists the API keys, without their values
+func (s *Server) GetAPIKeys(c *gin.Context) {
+	if s.APIKeys == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "API keys are not enabled"})
+		return
+	}
+
+	keys, err := s.APIKeys.List(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing API keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": keys, "count": len(keys)})
+}
+
+// CreateAPIKey creates an API key and returns its value, which is not stored and cannot be
+// shown again. A key can only be granted permissions the caller has, and a scope within the
+// caller's
+func (s *Server) CreateAPIKey(c *gin.Context) {
+	if s.APIKeys == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "API keys are not enabled"})
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if len(req.Permissions) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one permission is required"})
+		return
+	}
+	if err := rbac.ValidatePermissions(req.Permissions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, permission := range req.Permissions {
+		if !middleware.Permitted(c, rbac.Permission(permission)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot grant a permission you do not have", "permission": permission})
+			return
+		}
+	}
+
+	if scope, exists := c.Get("scope"); exists {
+		if scope, ok := scope.(models.Scope); !ok || !scope.Contains(req.Scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot grant a scope beyond your own", "scope": scope})
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"})
+		return
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+	value := models.APIKeyPrefix + secret
+
+	key := models.APIKey{
+		ID:          primitive.NewObjectID(),
+		Name:        req.Name,
+		Hash:        repository.HashAPIKey(value),
+		Prefix:      value[:len(models.APIKeyPrefix)+apiKeyPrefixLength],
+		Permissions: req.Permissions,
+		Scope:       req.Scope,
+		CreatedBy:   c.GetString("username"),
+		CreatedAt:   now,
+		ExpiresAt:   req.ExpiresAt,
+	}
+	if err := s.APIKeys.Create(c.Request.Context(), key); err != nil {
+		log.Printf("Error creating API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	log.Printf("%s created API key %s (%s) with %v", key.CreatedBy, key.ID.Hex(), key.Name, key.Permissions)
+	c.JSON(http.StatusCreated, models.CreateAPIKeyResponse{Key: value, APIKey: key})
+}
+
+// RevokeAPIKey revokes an API key; requests with it are rejected from then on
+func (s *Server) RevokeAPIKey(c *gin.Context) {
+	if s.APIKeys == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "API keys are not enabled"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := s.APIKeys.Revoke(c.Request.Context(), id, time.Now().UTC()); err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		log.Printf("Error revoking API key %s: %v", id.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	log.Printf("%s revoked API key %s", c.GetString("username"), id.Hex())
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked", "id": id.Hex()})
+}
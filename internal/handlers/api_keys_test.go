@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/rbac"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateAPIKeyScope(t *testing.T) {
+	s := NewServer(testConfig(), repository.NewMemoryWorkPackages(), repository.NewMemoryUsers())
+	s.APIKeys = repository.NewMemoryAPIKeys()
+
+	ams := models.Scope{LocationCodes: []string{"AMS"}}
+	hangar := models.Scope{LocationCodes: []string{"AMS"}, Sites: []string{"HANGAR2"}}
+	tests := []struct {
+		name   string
+		caller *models.Scope
+		scope  models.Scope
+		want   int
+	}{
+		{"caller without a scope", nil, models.Scope{}, http.StatusCreated},
+		{"caller without a scope grants one", nil, ams, http.StatusCreated},
+		{"same scope", &ams, ams, http.StatusCreated},
+		{"narrower scope", &ams, hangar, http.StatusCreated},
+		{"deny all", &ams, models.DenyAllScope, http.StatusCreated},
+		{"no scope", &ams, models.Scope{}, http.StatusForbidden},
+		{"other location", &ams, models.Scope{LocationCodes: []string{"AMS", "JFK"}}, http.StatusForbidden},
+		{"wider scope", &hangar, ams, http.StatusForbidden},
+		{"caller denied all", &models.DenyAllScope, ams, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		router := gin.New()
+		router.POST("/api-keys", func(c *gin.Context) {
+			c.Set("username", "admin")
+			c.Set("roles", []string{rbac.Admin})
+			if tt.caller != nil {
+				c.Set("scope", *tt.caller)
+			}
+		}, s.CreateAPIKey)
+
+		data, _ := json.Marshal(models.CreateAPIKeyRequest{Name: tt.name, Permissions: []string{string(rbac.ReadWorkPackages)}, Scope: tt.scope})
+		req := httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
@@ -5,51 +5,41 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"time"
 
-	"stationMonitor/internal/config"
-	"stationMonitor/internal/database"
 	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
 type Claims struct {
-	Username string `json:"username"`
+	Username string        `json:"username"`
+	Roles    []string      `json:"roles,omitempty"`
+	Scope    *models.Scope `json:"scope,omitempty"` // nil when the user sees all data
+	Family   string        `json:"fam,omitempty"`   // refresh token family of the session
 	jwt.RegisteredClaims
 }
 
-func Login(c *gin.Context) {
+func (s *Server) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	// Find user in MongoDB (try username first, then email)
-	var user models.User
-	collection := database.Database.Collection("users")
-
-	// Try to find by username or email
-	filter := bson.M{
-		"$or": []bson.M{
-			{"username": req.Username},
-			{"email": req.Username},
-		},
-	}
-
-	err := collection.FindOne(c.Request.Context(), filter).Decode(&user)
+	// Find user by username or email
+	user, err := s.Users.FindByLogin(c.Request.Context(), req.Username)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			return
 		}
@@ -64,65 +54,44 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Load config to get JWT secret
-	cfg, err := config.LoadConfig("config/config.yaml")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration error"})
-		return
-	}
-
-	// Generate JWT token
-	expirationTime := time.Now().Add(24 * time.Hour)
-	// Use email as username in JWT if username is empty (for email-only users)
-	jwtUsername := user.Username
-	if jwtUsername == "" {
-		jwtUsername = user.Email
-	}
-	claims := &Claims{
-		Username: jwtUsername,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(cfg.JWT.Secret))
+	// Generate access and refresh tokens, starting a new session
+	tokens, err := s.issueTokens(c.Request.Context(), user, "")
 	if err != nil {
+		log.Printf("Failed to generate token: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	// Return response
 	response := models.LoginResponse{
-		Token: tokenString,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 		User: models.User{
 			ID:       user.ID,
 			Email:    user.Email,
 			Username: user.Username,
+			Roles:    user.Roles,
 		},
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-func Register(c *gin.Context) {
+func (s *Server) Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
 		return
 	}
 
-	collection := database.Database.Collection("users")
-
 	// Check if user with this email already exists
-	var existingUser models.User
-	err := collection.FindOne(c.Request.Context(), bson.M{"email": req.Email}).Decode(&existingUser)
+	_, err := s.Users.FindByEmail(c.Request.Context(), req.Email)
 	if err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
 		return
 	}
-	if err != mongo.ErrNoDocuments {
+	if err != repository.ErrNotFound {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
@@ -134,49 +103,42 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Create new user
+	// Create new user. It sees no data until an admin assigns it a scope
 	newUser := models.User{
 		ID:       primitive.NewObjectID(),
 		Email:    req.Email,
 		Password: string(hashedPassword),
+		Roles:    s.Config.Auth.DefaultRoles,
+		Scope:    models.DenyAllScope,
 	}
 
-	_, err = collection.InsertOne(c.Request.Context(), newUser)
+	err = s.Users.Create(c.Request.Context(), newUser)
 	if err != nil {
+		if err == repository.ErrDuplicate {
+			c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	// Load config to get JWT secret
-	cfg, err := config.LoadConfig("config/config.yaml")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration error"})
-		return
-	}
-
-	// Generate JWT token
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		Username: newUser.Email, // Use email as username in JWT
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(cfg.JWT.Secret))
+	// Generate access and refresh tokens, using email as username in JWT
+	tokens, err := s.issueTokens(c.Request.Context(), newUser, "")
 	if err != nil {
+		log.Printf("Failed to generate token: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	// Return response
 	response := models.RegisterResponse{
-		Token: tokenString,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 		User: models.User{
 			ID:    newUser.ID,
 			Email: newUser.Email,
+			Roles: newUser.Roles,
 		},
 		Message: "User registered successfully",
 	}
@@ -184,14 +146,7 @@ func Register(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
-func VerifyToken(c *gin.Context) {
-	cfg, err := config.LoadConfig("config/config.yaml")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration error"})
-		c.Abort()
-		return
-	}
-
+func (s *Server) VerifyToken(c *gin.Context) {
 	tokenString := c.GetHeader("Authorization")
 	if tokenString == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -206,7 +161,7 @@ func VerifyToken(c *gin.Context) {
 
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(cfg.JWT.Secret), nil
+		return []byte(s.Config.JWT.Secret), nil
 	})
 
 	if err != nil || !token.Valid {
@@ -233,13 +188,8 @@ func Dashboard(c *gin.Context) {
 }
 
 // GoogleOAuthLogin initiates Google OAuth flow
-func GoogleOAuthLogin(c *gin.Context) {
-	cfg, err := config.LoadConfig("config/config.yaml")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration error"})
-		return
-	}
-
+func (s *Server) GoogleOAuthLogin(c *gin.Context) {
+	cfg := s.Config
 	if cfg.GoogleOAuth.ClientID == "" || cfg.GoogleOAuth.ClientSecret == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Google OAuth not configured",
@@ -287,12 +237,8 @@ func GoogleOAuthLogin(c *gin.Context) {
 }
 
 // GoogleOAuthCallback handles Google OAuth callback
-func GoogleOAuthCallback(c *gin.Context) {
-	cfg, err := config.LoadConfig("config/config.yaml")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration error"})
-		return
-	}
+func (s *Server) GoogleOAuthCallback(c *gin.Context) {
+	cfg := s.Config
 
 	// Verify state token - try cookie first (same-origin), then query param (cross-origin)
 	stateFromQuery := c.Query("state")
@@ -377,45 +323,37 @@ func GoogleOAuthCallback(c *gin.Context) {
 		return
 	}
 
-	// Find or create user in database
-	collection := database.Database.Collection("users")
-	var user models.User
+	// Find or create user
 
 	// Try to find user by Google ID first
-	err = collection.FindOne(c.Request.Context(), bson.M{"google_id": googleUser.ID}).Decode(&user)
-	if err != nil && err != mongo.ErrNoDocuments {
+	user, err := s.Users.FindByGoogleID(c.Request.Context(), googleUser.ID)
+	if err != nil && err != repository.ErrNotFound {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
 	// If not found by Google ID, try by email
-	if err == mongo.ErrNoDocuments {
-		err = collection.FindOne(c.Request.Context(), bson.M{"email": googleUser.Email}).Decode(&user)
-		if err != nil && err != mongo.ErrNoDocuments {
+	if err == repository.ErrNotFound {
+		user, err = s.Users.FindByEmail(c.Request.Context(), googleUser.Email)
+		if err != nil && err != repository.ErrNotFound {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 			return
 		}
 
 		// If found by email, update with Google ID
 		if err == nil {
-			update := bson.M{
-				"$set": bson.M{
-					"google_id": googleUser.ID,
-					"provider":  "google",
-					"name":      googleUser.Name,
-					"picture":   googleUser.Picture,
-				},
-			}
-			collection.UpdateOne(c.Request.Context(), bson.M{"_id": user.ID}, update)
 			user.GoogleID = googleUser.ID
 			user.Provider = "google"
 			user.Name = googleUser.Name
 			user.Picture = googleUser.Picture
+			if updateErr := s.Users.Update(c.Request.Context(), user); updateErr != nil {
+				log.Printf("Warning: Could not link Google account to user %s: %v", user.Email, updateErr)
+			}
 		}
 	}
 
-	// Create new user if doesn't exist
-	if err == mongo.ErrNoDocuments {
+	// Create new user if doesn't exist. It sees no data until an admin assigns it a scope
+	if err == repository.ErrNotFound {
 		user = models.User{
 			ID:       primitive.NewObjectID(),
 			Email:    googleUser.Email,
@@ -424,36 +362,17 @@ func GoogleOAuthCallback(c *gin.Context) {
 			Name:     googleUser.Name,
 			Picture:  googleUser.Picture,
 			Username: googleUser.Email, // Use email as username
+			Roles:    googleRoles(cfg, googleUser.Email),
+			Scope:    models.DenyAllScope,
 		}
 
-		_, err = collection.InsertOne(c.Request.Context(), user)
+		err = s.Users.Create(c.Request.Context(), user)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 			return
 		}
 	}
 
-	// Generate JWT token
-	expirationTime := time.Now().Add(24 * time.Hour)
-	jwtUsername := user.Username
-	if jwtUsername == "" {
-		jwtUsername = user.Email
-	}
-	claims := &Claims{
-		Username: jwtUsername,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := jwtToken.SignedString([]byte(cfg.JWT.Secret))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
 	// Check if this is an API call (from frontend) or browser redirect
 	// API calls typically have X-Requested-With header or Accept: application/json
 	acceptHeader := c.GetHeader("Accept")
@@ -467,9 +386,19 @@ func GoogleOAuthCallback(c *gin.Context) {
 		c.SetCookie("oauth_state", "", -1, "/", "", false, true)
 		c.SetCookie("oauth_redirect_uri", "", -1, "/", "", false, true)
 
+		// Generate access and refresh tokens
+		tokens, err := s.issueTokens(c.Request.Context(), user, "")
+		if err != nil {
+			log.Printf("Failed to generate token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
 		// Return JSON response for API calls from frontend
 		response := models.LoginResponse{
-			Token: tokenString,
+			Token:        tokens.Token,
+			RefreshToken: tokens.RefreshToken,
+			ExpiresIn:    tokens.ExpiresIn,
 			User: models.User{
 				ID:       user.ID,
 				Email:    user.Email,
@@ -477,19 +406,26 @@ func GoogleOAuthCallback(c *gin.Context) {
 				Provider: user.Provider,
 				Name:     user.Name,
 				Picture:  user.Picture,
+				Roles:    user.Roles,
 			},
 		}
 		c.JSON(http.StatusOK, response)
 	} else {
-		// Redirect to frontend with token in URL query parameter (for direct browser redirects)
+		// Redirect to frontend with a one-time login code (for direct browser redirects). The
+		// frontend exchanges it for the tokens with POST /api/auth/exchange
 		frontendURL := "http://localhost:3000/auth/google/callback"
 
-		// URL encode the values to handle special characters
-		redirectURL = fmt.Sprintf("%s?token=%s&email=%s&name=%s",
-			frontendURL,
-			url.QueryEscape(tokenString),
-			url.QueryEscape(user.Email),
-			url.QueryEscape(user.Name))
+		if s.Tokens == nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Login codes are not enabled"})
+			return
+		}
+		loginCode, err := s.issueLoginCode(c.Request.Context(), user)
+		if err != nil {
+			log.Printf("Failed to issue login code: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		redirectURL = fmt.Sprintf("%s?login_code=%s", frontendURL, url.QueryEscape(loginCode))
 
 		// Clear the OAuth state and redirect_uri cookies
 		c.SetCookie("oauth_state", "", -1, "/", "", false, true)
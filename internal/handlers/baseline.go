@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stationMonitor/internal/baseline"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// findBaselineWorkPackage loads the work package named by the aircraftWorkPackageId path parameter.
+// On failure the error response has already been written and ok is false
+func (s *Server) findBaselineWorkPackage(c *gin.Context) (wp models.AircraftWorkPackage, ok bool) {
+	if s.Baselines == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Baselines are not enabled"})
+		return wp, false
+	}
+
+	wpId, err := strconv.Atoi(c.Param("aircraftWorkPackageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work package ID"})
+		return wp, false
+	}
+
+	wp, err = s.workPackages(c).FindByWorkPackageID(c.Request.Context(), wpId)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Aircraft work package not found"})
+			return wp, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+		return wp, false
+	}
+
+	return wp, true
+}
+
+// CreateScheduleBaseline freezes the current planned dates of a work package
+func (s *Server) CreateScheduleBaseline(c *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	wp, ok := s.findBaselineWorkPackage(c)
+	if !ok {
+		return
+	}
+
+	createdBy := ""
+	if username, exists := c.Get("username"); exists {
+		createdBy, _ = username.(string)
+	}
+
+	snapshot := baseline.FromWorkPackage(wp, baseline.TriggerManual, req.Name, createdBy, time.Now())
+	snapshot.ID = primitive.NewObjectID()
+
+	if err := s.Baselines.Create(c.Request.Context(), snapshot); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create baseline: " + err.Error()})
+		return
+	}
+
+	log.Printf("Created baseline %s for work package %d (%d tasks)", snapshot.ID.Hex(), wp.AircraftWorkPackageId, len(snapshot.Tasks))
+	c.JSON(http.StatusCreated, snapshot)
+}
+
+// GetScheduleBaselines lists the baselines of a work package, newest first
+func (s *Server) GetScheduleBaselines(c *gin.Context) {
+	wp, ok := s.findBaselineWorkPackage(c)
+	if !ok {
+		return
+	}
+
+	baselines, err := s.Baselines.List(c.Request.Context(), wp.AircraftWorkPackageId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  baselines,
+		"count": len(baselines),
+	})
+}
+
+// GetScheduleVariance compares a work package against one of its baselines.
+// The baselineId query parameter selects the baseline; by default the latest one is used
+func (s *Server) GetScheduleVariance(c *gin.Context) {
+	wp, ok := s.findBaselineWorkPackage(c)
+	if !ok {
+		return
+	}
+
+	var snapshot models.ScheduleBaseline
+	if baselineId := c.Query("baselineId"); baselineId != "" {
+		objectID, err := primitive.ObjectIDFromHex(baselineId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid baseline ID format"})
+			return
+		}
+		if snapshot, err = s.Baselines.Find(c.Request.Context(), wp.AircraftWorkPackageId, objectID); err != nil {
+			if err == repository.ErrNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Baseline not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+			return
+		}
+	} else {
+		latest, err := s.Baselines.Latest(c.Request.Context(), []int{wp.AircraftWorkPackageId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+			return
+		}
+		latestSnapshot, found := latest[wp.AircraftWorkPackageId]
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Work package has no baseline"})
+			return
+		}
+		snapshot = latestSnapshot
+	}
+
+	c.JSON(http.StatusOK, baseline.ComputeVariance(snapshot, wp))
+}
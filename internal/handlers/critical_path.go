@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// criticalSlackTolerance is the slack below which a task counts as critical.
+// IFS stores dates to the minute, so anything smaller is rounding noise
+const criticalSlackTolerance = time.Minute
+
+// CriticalPathTask holds the schedule of one AvExeTask computed by the forward/backward pass
+type CriticalPathTask struct {
+	TaskSeq     int       `json:"taskSeq"`
+	Description string    `json:"description,omitempty"`
+	EarlyStart  time.Time `json:"earlyStart"`
+	EarlyFinish time.Time `json:"earlyFinish"`
+	LateStart   time.Time `json:"lateStart"`
+	LateFinish  time.Time `json:"lateFinish"`
+	Slack       float64   `json:"slack"` // hours
+	Critical    bool      `json:"critical"`
+}
+
+// CriticalPathResult is the critical path analysis of one work package
+type CriticalPathResult struct {
+	AircraftWorkPackageId int                `json:"aircraftWorkPackageId"`
+	ProjectFinish         *time.Time         `json:"projectFinish,omitempty"`
+	SchedEndDateTime      *time.Time         `json:"schedEndDateTime,omitempty"`
+	ReleaseSlack          *float64           `json:"releaseSlack,omitempty"` // hours between projectFinish and SchedEndDateTime
+	CriticalPath          []int              `json:"criticalPath"`           // TaskSeq of the critical tasks in start order
+	Tasks                 []CriticalPathTask `json:"tasks"`
+}
+
+// cpmNode is a task in the dependency network
+type cpmNode struct {
+	task         CriticalPathTask
+	start        time.Time
+	duration     time.Duration
+	predecessors []cpmEdge
+	successors   []cpmEdge
+}
+
+// cpmEdge connects two nodes with a dependency type
+type cpmEdge struct {
+	node     int
+	linkType string
+}
+
+// ComputeCriticalPath runs a forward and backward pass over the tasks of a work package.
+// Tasks without dates or excluded from scheduling are ignored. A task never starts before
+// its own planned start; the project finishes when its last task does, and tasks with no
+// slack against that finish are critical. Dependencies to tasks of other packages are ignored
+func ComputeCriticalPath(wp models.AircraftWorkPackage, dependencies []taskDependency) (CriticalPathResult, error) {
+	result := CriticalPathResult{
+		AircraftWorkPackageId: wp.AircraftWorkPackageId,
+		SchedEndDateTime:      wp.SchedEndDateTime,
+		CriticalPath:          []int{},
+		Tasks:                 []CriticalPathTask{},
+	}
+
+	nodes := []*cpmNode{}
+	positions := map[int]int{}
+	for _, task := range wp.Avexetask {
+		if task.ExcludeFromScheduling {
+			continue
+		}
+		taskStart, taskEnd := avExeTaskDates(task)
+		if taskStart == nil || taskEnd == nil || taskEnd.Before(*taskStart) {
+			continue
+		}
+		if _, duplicate := positions[task.TaskSeq]; duplicate {
+			continue
+		}
+
+		positions[task.TaskSeq] = len(nodes)
+		nodes = append(nodes, &cpmNode{
+			task:     CriticalPathTask{TaskSeq: task.TaskSeq, Description: task.Description},
+			start:    *taskStart,
+			duration: taskEnd.Sub(*taskStart),
+		})
+	}
+
+	if len(nodes) == 0 {
+		return result, nil
+	}
+
+	for _, dep := range dependencies {
+		if dep.Source.AircraftWpId != wp.AircraftWorkPackageId || dep.Target.AircraftWpId != wp.AircraftWorkPackageId {
+			continue
+		}
+		source, sourceFound := positions[dep.Source.TaskSeq]
+		target, targetFound := positions[dep.Target.TaskSeq]
+		if !sourceFound || !targetFound || source == target {
+			continue
+		}
+		nodes[target].predecessors = append(nodes[target].predecessors, cpmEdge{source, dep.Type})
+		nodes[source].successors = append(nodes[source].successors, cpmEdge{target, dep.Type})
+	}
+
+	order, err := topologicalOrder(nodes)
+	if err != nil {
+		return result, err
+	}
+
+	// Forward pass
+	var projectFinish time.Time
+	for _, i := range order {
+		node := nodes[i]
+		earlyStart := node.start
+		for _, edge := range node.predecessors {
+			predecessor := nodes[edge.node].task
+			var bound time.Time
+			switch edge.linkType {
+			case "s2s":
+				bound = predecessor.EarlyStart
+			case "e2e":
+				bound = predecessor.EarlyFinish.Add(-node.duration)
+			case "s2e":
+				bound = predecessor.EarlyStart.Add(-node.duration)
+			default: // e2s
+				bound = predecessor.EarlyFinish
+			}
+			if bound.After(earlyStart) {
+				earlyStart = bound
+			}
+		}
+		node.task.EarlyStart = earlyStart
+		node.task.EarlyFinish = earlyStart.Add(node.duration)
+		if node.task.EarlyFinish.After(projectFinish) {
+			projectFinish = node.task.EarlyFinish
+		}
+	}
+
+	// Backward pass
+	for i := len(order) - 1; i >= 0; i-- {
+		node := nodes[order[i]]
+		lateFinish := projectFinish
+		for _, edge := range node.successors {
+			successor := nodes[edge.node].task
+			var bound time.Time
+			switch edge.linkType {
+			case "s2s":
+				bound = successor.LateStart.Add(node.duration)
+			case "e2e":
+				bound = successor.LateFinish
+			case "s2e":
+				bound = successor.LateFinish.Add(node.duration)
+			default: // e2s
+				bound = successor.LateStart
+			}
+			if bound.Before(lateFinish) {
+				lateFinish = bound
+			}
+		}
+		node.task.LateFinish = lateFinish
+		node.task.LateStart = lateFinish.Add(-node.duration)
+
+		slack := node.task.LateStart.Sub(node.task.EarlyStart)
+		node.task.Slack = slack.Hours()
+		node.task.Critical = slack < criticalSlackTolerance
+	}
+
+	result.ProjectFinish = &projectFinish
+	if wp.SchedEndDateTime != nil {
+		releaseSlack := wp.SchedEndDateTime.Sub(projectFinish).Hours()
+		result.ReleaseSlack = &releaseSlack
+	}
+
+	for _, node := range nodes {
+		result.Tasks = append(result.Tasks, node.task)
+	}
+	sort.SliceStable(result.Tasks, func(i, j int) bool {
+		return result.Tasks[i].EarlyStart.Before(result.Tasks[j].EarlyStart)
+	})
+	for _, task := range result.Tasks {
+		if task.Critical {
+			result.CriticalPath = append(result.CriticalPath, task.TaskSeq)
+		}
+	}
+
+	return result, nil
+}
+
+// topologicalOrder orders the nodes so every node comes after its predecessors.
+// Returns an error naming the tasks involved if the dependencies contain a cycle
+func topologicalOrder(nodes []*cpmNode) ([]int, error) {
+	inDegree := make([]int, len(nodes))
+	for i, node := range nodes {
+		inDegree[i] = len(node.predecessors)
+	}
+
+	queue := []int{}
+	for i := range nodes {
+		if inDegree[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+
+	order := make([]int, 0, len(nodes))
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		order = append(order, i)
+		for _, edge := range nodes[i].successors {
+			inDegree[edge.node]--
+			if inDegree[edge.node] == 0 {
+				queue = append(queue, edge.node)
+			}
+		}
+	}
+
+	if len(order) < len(nodes) {
+		cycle := []int{}
+		for i := range nodes {
+			if inDegree[i] > 0 {
+				cycle = append(cycle, nodes[i].task.TaskSeq)
+			}
+		}
+		return nil, fmt.Errorf("task dependencies contain a cycle involving tasks %v", cycle)
+	}
+
+	return order, nil
+}
+
+// GetCriticalPath computes the critical path of a work package
+func (s *Server) GetCriticalPath(c *gin.Context) {
+	wpId, err := strconv.Atoi(c.Param("aircraftWorkPackageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work package ID"})
+		return
+	}
+
+	wp, err := s.workPackages(c).FindByWorkPackageID(c.Request.Context(), wpId)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Aircraft work package not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+		return
+	}
+
+	userDependencies, err := s.findGanttDependencies(c.Request.Context(), []int{wpId})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dependencies: " + err.Error()})
+		return
+	}
+
+	dependencies := append(deriveTaskDependencies(wp), userTaskDependencies(userDependencies)...)
+	result, err := ComputeCriticalPath(wp, dependencies)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Critical path for work package %d: %v", wpId, result.CriticalPath)
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/models"
+)
+
+// cpmBase is the time the tasks of the critical path tests are planned from
+var cpmBase = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+// cpmTask returns a task planned from start for the given number of hours after cpmBase
+func cpmTask(seq int, start, hours float64) models.AvExeTask {
+	plannedStart := cpmBase.Add(time.Duration(start * float64(time.Hour)))
+	plannedFinish := plannedStart.Add(time.Duration(hours * float64(time.Hour)))
+	return models.AvExeTask{TaskSeq: seq, PlannedStart: &plannedStart, PlannedFinish: &plannedFinish}
+}
+
+// cpmDep returns a dependency of linkType between two tasks of work package 1
+func cpmDep(source, target int, linkType string) taskDependency {
+	return taskDependency{
+		Source: ganttTaskKey{AircraftWpId: 1, TaskSeq: source},
+		Target: ganttTaskKey{AircraftWpId: 1, TaskSeq: target},
+		Type:   linkType,
+	}
+}
+
+func TestComputeCriticalPath(t *testing.T) {
+	undated := models.AvExeTask{TaskSeq: 9}
+	excluded := cpmTask(8, 0, 100)
+	excluded.ExcludeFromScheduling = true
+
+	tests := []struct {
+		name         string
+		tasks        []models.AvExeTask
+		dependencies []taskDependency
+		wantPath     []int
+		wantSlack    map[int]float64 // hours, per TaskSeq
+		wantFinish   float64         // hours after cpmBase
+		wantErr      string
+	}{
+		{
+			name:       "single task",
+			tasks:      []models.AvExeTask{cpmTask(1, 2, 6)},
+			wantPath:   []int{1},
+			wantSlack:  map[int]float64{1: 0},
+			wantFinish: 8,
+		},
+		{
+			name:         "chain pushes the successor back",
+			tasks:        []models.AvExeTask{cpmTask(1, 0, 8), cpmTask(2, 0, 4)},
+			dependencies: []taskDependency{cpmDep(1, 2, "e2s")},
+			wantPath:     []int{1, 2},
+			wantSlack:    map[int]float64{1: 0, 2: 0},
+			wantFinish:   12,
+		},
+		{
+			name:         "shorter branch has slack",
+			tasks:        []models.AvExeTask{cpmTask(1, 0, 8), cpmTask(2, 0, 2), cpmTask(3, 0, 4)},
+			dependencies: []taskDependency{cpmDep(1, 3, "e2s"), cpmDep(2, 3, "e2s")},
+			wantPath:     []int{1, 3},
+			wantSlack:    map[int]float64{1: 0, 2: 6, 3: 0},
+			wantFinish:   12,
+		},
+		{
+			name:         "zero-duration milestone on the path",
+			tasks:        []models.AvExeTask{cpmTask(1, 0, 8), cpmTask(2, 0, 0), cpmTask(3, 0, 4)},
+			dependencies: []taskDependency{cpmDep(1, 2, "e2s"), cpmDep(2, 3, "e2s")},
+			wantPath:     []int{1, 2, 3},
+			wantSlack:    map[int]float64{1: 0, 2: 0, 3: 0},
+			wantFinish:   12,
+		},
+		{
+			name:       "unlinked zero-duration task",
+			tasks:      []models.AvExeTask{cpmTask(1, 0, 8), cpmTask(2, 2, 0)},
+			wantPath:   []int{1},
+			wantSlack:  map[int]float64{1: 0, 2: 6},
+			wantFinish: 8,
+		},
+		{
+			name:         "start to start",
+			tasks:        []models.AvExeTask{cpmTask(1, 0, 8), cpmTask(2, 0, 4)},
+			dependencies: []taskDependency{cpmDep(1, 2, "s2s")},
+			wantPath:     []int{1},
+			wantSlack:    map[int]float64{1: 0, 2: 4},
+			wantFinish:   8,
+		},
+		{
+			name:         "end to end",
+			tasks:        []models.AvExeTask{cpmTask(1, 0, 8), cpmTask(2, 0, 2)},
+			dependencies: []taskDependency{cpmDep(1, 2, "e2e")},
+			wantPath:     []int{1, 2},
+			wantSlack:    map[int]float64{1: 0, 2: 0},
+			wantFinish:   8,
+		},
+		{
+			name:         "planned start later than the predecessor",
+			tasks:        []models.AvExeTask{cpmTask(1, 0, 4), cpmTask(2, 6, 4)},
+			dependencies: []taskDependency{cpmDep(1, 2, "e2s")},
+			wantPath:     []int{2},
+			wantSlack:    map[int]float64{1: 2, 2: 0},
+			wantFinish:   10,
+		},
+		{
+			name:  "undated, excluded and foreign tasks are ignored",
+			tasks: []models.AvExeTask{cpmTask(1, 0, 4), undated, excluded},
+			dependencies: []taskDependency{
+				cpmDep(9, 1, "e2s"),
+				cpmDep(8, 1, "e2s"),
+				{Source: ganttTaskKey{AircraftWpId: 2, TaskSeq: 1}, Target: ganttTaskKey{AircraftWpId: 1, TaskSeq: 1}},
+			},
+			wantPath:   []int{1},
+			wantSlack:  map[int]float64{1: 0},
+			wantFinish: 4,
+		},
+		{
+			name:         "cycle",
+			tasks:        []models.AvExeTask{cpmTask(1, 0, 4), cpmTask(2, 0, 4), cpmTask(3, 0, 4)},
+			dependencies: []taskDependency{cpmDep(1, 2, "e2s"), cpmDep(2, 3, "e2s"), cpmDep(3, 2, "e2s")},
+			wantErr:      "cycle involving tasks [2 3]",
+		},
+		{
+			name:     "no schedulable tasks",
+			tasks:    []models.AvExeTask{undated},
+			wantPath: []int{},
+		},
+	}
+	for _, tt := range tests {
+		wp := models.AircraftWorkPackage{AircraftWorkPackageId: 1, Avexetask: tt.tasks}
+		result, err := ComputeCriticalPath(wp, tt.dependencies)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(result.CriticalPath, tt.wantPath) {
+			t.Errorf("%s: critical path %v, want %v", tt.name, result.CriticalPath, tt.wantPath)
+		}
+		if len(result.Tasks) != len(tt.wantSlack) {
+			t.Errorf("%s: %d tasks scheduled, want %d", tt.name, len(result.Tasks), len(tt.wantSlack))
+		}
+		for _, task := range result.Tasks {
+			if want, found := tt.wantSlack[task.TaskSeq]; !found || task.Slack != want {
+				t.Errorf("%s: slack of task %d = %v, want %v", tt.name, task.TaskSeq, task.Slack, want)
+			}
+		}
+		if len(tt.wantSlack) == 0 {
+			if result.ProjectFinish != nil {
+				t.Errorf("%s: project finish %s, want none", tt.name, result.ProjectFinish)
+			}
+			continue
+		}
+		if want := cpmBase.Add(time.Duration(tt.wantFinish * float64(time.Hour))); result.ProjectFinish == nil || !result.ProjectFinish.Equal(want) {
+			t.Errorf("%s: project finish %v, want %s", tt.name, result.ProjectFinish, want)
+		}
+	}
+}
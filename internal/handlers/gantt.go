@@ -1,17 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"stationMonitor/internal/database"
 	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+	"stationMonitor/internal/stationtime"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // GanttTask represents a task in the Gantt chart format
@@ -25,6 +27,31 @@ type GanttTask struct {
 	Type     string      `json:"type,omitempty"`
 	Parent   interface{} `json:"parent,omitempty"`
 	Open     *bool       `json:"open,omitempty"`
+	Critical *bool       `json:"critical,omitempty"`
+	Slack    *float64    `json:"slack,omitempty"` // hours
+
+	// Milestone kind ("start", "end" or "rts") when Type is "milestone"
+	Milestone string `json:"milestone,omitempty"`
+
+	// Scheduling constraints of the source task; ConstraintViolated is set when the
+	// drawn dates break one of them, and Violations names which
+	EarliestStart      *time.Time `json:"earliestStart,omitempty"`
+	LatestStart        *time.Time `json:"latestStart,omitempty"`
+	LatestFinish       *time.Time `json:"latestFinish,omitempty"` // hard deadline
+	FixedStart         *time.Time `json:"fixedStart,omitempty"`
+	ConstraintViolated bool       `json:"constraintViolated,omitempty"`
+	Violations         []string   `json:"violations,omitempty"`
+
+	// Dates of the latest schedule baseline, if one was taken
+	BaselineStart *time.Time `json:"baselineStart,omitempty"`
+	BaselineEnd   *time.Time `json:"baselineEnd,omitempty"`
+
+	// Source document references, sent back by the client when saving edits
+	AircraftWorkPackageId int        `json:"aircraftWorkPackageId,omitempty"`
+	TaskSeq               int        `json:"taskSeq,omitempty"`
+	ExecutionInstanceSeq  *int       `json:"executionInstanceSeq,omitempty"`
+	ETag                  string     `json:"etag,omitempty"`
+	ChangedDate           *time.Time `json:"changedDate,omitempty"`
 }
 
 // GanttLink represents a link between tasks in the Gantt chart
@@ -42,132 +69,158 @@ type GanttDataResponse struct {
 }
 
 // GetGanttTasks retrieves aircraft work packages and transforms them into Gantt chart format
-func GetGanttTasks(c *gin.Context) {
-	// Verify database connection
-	if database.Database == nil {
-		log.Printf("Error: Database connection is nil")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection not established"})
+func (s *Server) GetGanttTasks(c *gin.Context) {
+	workPackages, window, tz, ok := s.loadGanttWorkPackages(c)
+	if !ok {
 		return
 	}
 
-	collection := database.Database.Collection("AvAircraftWorkPackage")
-	log.Printf("Querying collection: aircraftWorkPackages")
+	data := s.buildGanttData(c.Request.Context(), workPackages, window)
+	localizeGanttTasks(data.Tasks, workPackageZones(tz, workPackages))
+	tasks := data.Tasks
 
-	// Query parameters for filtering
-	filter := bson.M{}
+	log.Printf("Transformed %d work packages into %d Gantt tasks (skipped %d)",
+		len(workPackages), len(tasks), data.skipped)
 
-	// Filter by AircraftWorkPackageId = 1061
-	filter["AircraftWorkPackageId"] = 547
-	log.Printf("Filtering by AircraftWorkPackageId: 1227")
+	response := data.GanttDataResponse
 
-	// Filter by AircraftId if provided
-	if aircraftIdStr := c.Query("aircraftId"); aircraftIdStr != "" {
-		if aircraftId, err := strconv.Atoi(aircraftIdStr); err == nil {
-			filter["AircraftId"] = aircraftId
-			log.Printf("Filtering by AircraftId: %d", aircraftId)
-		}
+	// If no tasks found, return a helpful message
+	if len(tasks) == 0 {
+		log.Printf("No tasks to return. Total work packages: %d, Skipped: %d",
+			len(workPackages), data.skipped)
+		// Still return empty arrays so frontend doesn't error
+		c.JSON(http.StatusOK, gin.H{
+			"tasks":   []GanttTask{},
+			"links":   []GanttLink{},
+			"message": "No tasks found. Ensure work packages have SchedStartDateTime and SchedEndDateTime set.",
+			"debug": gin.H{
+				"totalWorkPackages": len(workPackages),
+				"skipped":           data.skipped,
+			},
+		})
+		return
 	}
 
-	// Filter by AircraftWorkPackageId if provided
-	if wpIdStr := c.Query("aircraftWorkPackageId"); wpIdStr != "" {
-		if wpId, err := strconv.Atoi(wpIdStr); err == nil {
-			filter["AircraftWorkPackageId"] = wpId
-			log.Printf("Filtering by AircraftWorkPackageId: %d", wpId)
-		}
+	c.JSON(http.StatusOK, response)
+}
+
+// loadGanttWorkPackages parses the Gantt query parameters and loads the matching work packages.
+// tz is the zone the response is shown in; plain dates of the window start at its midnight.
+// On failure the error response has already been written and ok is false
+func (s *Server) loadGanttWorkPackages(c *gin.Context) (workPackages []models.AircraftWorkPackage, window ganttWindow, tz stationtime.TZ, ok bool) {
+	tz, ok = s.parseTZ(c)
+	if !ok {
+		return nil, window, tz, false
+	}
+
+	// Query parameters for filtering
+	filter := repository.WorkPackageFilter{}
+
+	// Filter by AircraftId if provided (comma-separated or repeated)
+	aircraftIds, err := queryIntList(c, "aircraftId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, window, tz, false
+	}
+	if len(aircraftIds) > 0 {
+		filter.AircraftIds = aircraftIds
+		log.Printf("Filtering by AircraftId: %v", aircraftIds)
 	}
 
-	// Filter by LocationCode if provided
-	if locationCode := c.Query("locationCode"); locationCode != "" {
-		filter["LocationCode"] = locationCode
-		log.Printf("Filtering by LocationCode: %s", locationCode)
+	// Filter by AircraftWorkPackageId if provided (comma-separated or repeated)
+	wpIds, err := queryIntList(c, "aircraftWorkPackageId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, window, tz, false
+	}
+	if len(wpIds) > 0 {
+		filter.WorkPackageIds = wpIds
+		log.Printf("Filtering by AircraftWorkPackageId: %v", wpIds)
+	}
+
+	// Filter by LocationCode if provided (comma-separated or repeated)
+	if locationCodes := queryStringList(c, "locationCode"); len(locationCodes) > 0 {
+		filter.LocationCodes = locationCodes
+		log.Printf("Filtering by LocationCode: %v", locationCodes)
 	}
 
 	// Filter by IsHistoric if provided
 	if isHistoricStr := c.Query("isHistoric"); isHistoricStr != "" {
 		if isHistoric, err := strconv.ParseBool(isHistoricStr); err == nil {
-			filter["IsHistoric"] = isHistoric
+			filter.IsHistoric = &isHistoric
 			log.Printf("Filtering by IsHistoric: %v", isHistoric)
 		}
 	}
 
-	// // Get date range filters
-	// startDateStr := c.Query("startDate")
-	// endDateStr := c.Query("endDate")
-
-	// if startDateStr != "" || endDateStr != "" {
-	// 	dateFilter := bson.M{}
-	// 	if startDateStr != "" {
-	// 		if startDate, err := time.Parse(time.RFC3339, startDateStr); err == nil {
-	// 			dateFilter["$gte"] = startDate
-	// 		}
-	// 	}
-	// 	if endDateStr != "" {
-	// 		if endDate, err := time.Parse(time.RFC3339, endDateStr); err == nil {
-	// 			dateFilter["$lte"] = endDate
-	// 		}
-	// 	}
-	// 	if len(dateFilter) > 0 {
-	// 		filter["SchedStartDateTime"] = dateFilter
-	// 	}
-	// }
-
-	// Check total count in collection for debugging
-	totalCount, err := collection.CountDocuments(c.Request.Context(), bson.M{})
+	// Get date window - a work package matches if its scheduled window overlaps it
+	window, err = parseGanttWindow(c.Query("startDate"), c.Query("endDate"), tz.DayZone(filter.LocationCodes))
 	if err != nil {
-		log.Printf("Warning: Could not count documents: %v", err)
-	} else {
-		log.Printf("Total documents in aircraftWorkPackages collection: %d", totalCount)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, window, tz, false
+	}
+	filter.From, filter.To = window.Start, window.End
+	if window.Start != nil || window.End != nil {
+		log.Printf("Filtering by date window: %v - %v", window.Start, window.End)
 	}
 
 	// Check count with current filter
-	filteredCount, err := collection.CountDocuments(c.Request.Context(), filter)
+	filteredCount, err := s.workPackages(c).Count(c.Request.Context(), filter)
 	if err != nil {
 		log.Printf("Warning: Could not count filtered documents: %v", err)
 	} else {
 		log.Printf("Documents matching filter: %d", filteredCount)
 	}
 
-	// Find options - limit to reasonable number and sort by start date
-	findOptions := options.Find()
-	findOptions.SetLimit(1000) // Limit to prevent overwhelming the frontend
-	findOptions.SetSort(bson.D{{Key: "SchedStartDateTime", Value: 1}})
-
-	// Execute query
-	cursor, err := collection.Find(c.Request.Context(), filter, findOptions)
+	// Limit to a reasonable number to prevent overwhelming the frontend, sorted by start date
+	workPackages, err = s.workPackages(c).Find(c.Request.Context(), filter, repository.FindOptions{Limit: 1000})
 	if err != nil {
 		log.Printf("Error querying database: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database: " + err.Error()})
-		return
-	}
-	defer cursor.Close(c.Request.Context())
-
-	var workPackages []models.AircraftWorkPackage
-	if err := cursor.All(c.Request.Context(), &workPackages); err != nil {
-		log.Printf("Error decoding work packages: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode results: " + err.Error()})
-		return
+		return nil, window, tz, false
 	}
 
 	log.Printf("Found %d work packages from database", len(workPackages))
 
+	return workPackages, window, tz, true
+}
+
+// ganttData is the transformed Gantt model together with the bookkeeping
+// needed by the other Gantt views
+type ganttData struct {
+	GanttDataResponse
+	index   ganttTaskIndex
+	skipped int
+}
+
+// buildGanttData transforms work packages into Gantt tasks clipped to the window and derives their links
+func (s *Server) buildGanttData(ctx context.Context, workPackages []models.AircraftWorkPackage, window ganttWindow) ganttData {
 	// Transform work packages to Gantt tasks
 	// Only using the important fields: taskseq, workpackageid, aircraft id, descriptions,
 	// SchedStartDateTime, SchedEndDateTime, PlannedStart, PlannedFinish, Duration,
 	// ActualStart, ActualFinish, EarliestStart, LatestStart, LatestFinish,
 	// FixedStart (as boolean), ExcludeFromScheduling, AdjustedDuration
 	tasks := []GanttTask{}
-	links := []GanttLink{}
-	taskIDCounter := 1
+	index := newGanttTaskIndex()
+	includedWpIds := []int{}
 	skippedCount := 0
 
 	for _, wp := range workPackages {
 		// Prepare work package task
-		wpTask, wpTaskID, skipped := PrepareWorkPackageGanttTask(wp, &taskIDCounter)
-		if skipped {
+		wpTask, wpTaskID, skipped := PrepareWorkPackageGanttTask(wp)
+		if skipped || !window.clip(&wpTask) {
 			skippedCount++
 			continue
 		}
 		tasks = append(tasks, wpTask)
+		index.workPackages[wp.AircraftWorkPackageId] = wpTaskID
+		includedWpIds = append(includedWpIds, wp.AircraftWorkPackageId)
+
+		// Package start/end and release-to-service milestones
+		for _, milestone := range PrepareWorkPackageMilestones(wp) {
+			if window.clip(&milestone) {
+				tasks = append(tasks, milestone)
+			}
+		}
 
 		// Process nested tasks (avexetask)
 		if len(wp.Avexetask) == 0 {
@@ -176,11 +229,12 @@ func GetGanttTasks(c *gin.Context) {
 
 		for _, task := range wp.Avexetask {
 			// Prepare AvExeTask
-			childTask, taskID, skipped := PrepareAvExeTaskGanttTask(task, wpTaskID, &taskIDCounter)
-			if skipped {
+			childTask, taskID, skipped := PrepareAvExeTaskGanttTask(task, wp.AircraftWorkPackageId)
+			if skipped || !window.clip(&childTask) {
 				continue
 			}
 			tasks = append(tasks, childTask)
+			index.tasks[ganttTaskKey{wp.AircraftWorkPackageId, task.TaskSeq}] = taskID
 
 			// Process execution instances
 			log.Printf("task.JtExecutionInstanceArray: %+v\n", task.JtExecutionInstanceArray)
@@ -190,60 +244,194 @@ func GetGanttTasks(c *gin.Context) {
 
 			for _, instance := range task.JtExecutionInstanceArray {
 				// Prepare execution instance
-				instanceTask, _, skipped := PrepareExecutionInstanceGanttTask(instance, taskID, &taskIDCounter)
-				if skipped {
+				instanceTask, instanceTaskID, skipped := PrepareExecutionInstanceGanttTask(instance, wp.AircraftWorkPackageId, task.TaskSeq)
+				if skipped || !window.clip(&instanceTask) {
 					continue
 				}
 				tasks = append(tasks, instanceTask)
+				index.instances[ganttInstanceKey{wp.AircraftWorkPackageId, task.TaskSeq, instance.ExecutionInstanceSeq}] = instanceTaskID
 			}
 		}
 	}
 
-	// Create links between consecutive work packages (optional)
-	// This creates end-to-start links between work packages
-	for i := 0; i < len(tasks)-1; i++ {
-		if tasks[i].Type == "summary" {
-			// Find next summary task
-			for j := i + 1; j < len(tasks); j++ {
-				if tasks[j].Type == "summary" {
-					link := GanttLink{
-						ID:     taskIDCounter,
-						Type:   "e2s", // end-to-start
-						Source: tasks[i].ID,
-						Target: tasks[j].ID,
-					}
-					links = append(links, link)
-					taskIDCounter++
-					break
-				}
+	// Derive links from the task data and the user-defined dependencies
+	dependencies, err := s.findGanttDependencies(ctx, includedWpIds)
+	if err != nil {
+		log.Printf("Warning: Could not load user-defined dependencies: %v", err)
+	}
+	links := BuildGanttLinks(workPackages, index, dependencies)
+
+	// Mark the critical tasks of every package
+	positions := map[interface{}]int{}
+	for i, task := range tasks {
+		positions[task.ID] = i
+	}
+	userDependencies := userTaskDependencies(dependencies)
+	for _, wp := range workPackages {
+		if index.workPackages[wp.AircraftWorkPackageId] == "" {
+			continue
+		}
+		result, err := ComputeCriticalPath(wp, append(deriveTaskDependencies(wp), userDependencies...))
+		if err != nil {
+			log.Printf("Warning: Could not compute critical path for work package %d: %v", wp.AircraftWorkPackageId, err)
+			continue
+		}
+		for _, cpTask := range result.Tasks {
+			position, found := positions[index.tasks[ganttTaskKey{wp.AircraftWorkPackageId, cpTask.TaskSeq}]]
+			if !found {
+				continue
 			}
+			critical, slack := cpTask.Critical, cpTask.Slack
+			tasks[position].Critical = &critical
+			tasks[position].Slack = &slack
 		}
 	}
 
-	log.Printf("Transformed %d work packages into %d Gantt tasks (skipped %d)",
-		len(workPackages), len(tasks), skippedCount)
+	// Add the dates of the latest baseline of every package
+	var baselines map[int]models.ScheduleBaseline
+	if s.Baselines != nil {
+		baselines, err = s.Baselines.Latest(ctx, includedWpIds)
+		if err != nil {
+			log.Printf("Warning: Could not load schedule baselines: %v", err)
+		}
+	}
+	for _, snapshot := range baselines {
+		setBaseline := func(id string, start, end *time.Time) {
+			if position, found := positions[id]; found && id != "" {
+				tasks[position].BaselineStart = start
+				tasks[position].BaselineEnd = end
+			}
+		}
+		wpId := snapshot.AircraftWorkPackageId
+		setBaseline(index.workPackages[wpId], snapshot.SchedStartDateTime, snapshot.SchedEndDateTime)
+		for _, task := range snapshot.Tasks {
+			setBaseline(index.tasks[ganttTaskKey{wpId, task.TaskSeq}], task.PlannedStart, task.PlannedFinish)
+			for _, instance := range task.Instances {
+				setBaseline(index.instances[ganttInstanceKey{wpId, task.TaskSeq, instance.ExecutionInstanceSeq}],
+					instance.AllocatedStart, instance.AllocatedFinish)
+			}
+		}
+	}
 
-	response := GanttDataResponse{
-		Tasks: tasks,
-		Links: links,
+	return ganttData{
+		GanttDataResponse: GanttDataResponse{
+			Tasks: tasks,
+			Links: links,
+		},
+		index:   index,
+		skipped: skippedCount,
 	}
+}
 
-	// If no tasks found, return a helpful message
-	if len(tasks) == 0 {
-		log.Printf("No tasks to return. Total work packages: %d, Skipped: %d",
-			len(workPackages), skippedCount)
-		// Still return empty arrays so frontend doesn't error
-		c.JSON(http.StatusOK, gin.H{
-			"tasks":   []GanttTask{},
-			"links":   []GanttLink{},
-			"message": "No tasks found. Ensure work packages have SchedStartDateTime and SchedEndDateTime set.",
-			"debug": gin.H{
-				"totalWorkPackages": len(workPackages),
-				"skipped":           skippedCount,
-			},
-		})
-		return
+// ganttWindow is the optional date window requested by the client.
+// A nil bound means the window is open on that side.
+type ganttWindow struct {
+	Start *time.Time
+	End   *time.Time
+}
+
+// parseGanttWindow parses the startDate/endDate query parameters.
+// Both RFC3339 timestamps and plain dates (2006-01-02) are accepted; a plain
+// endDate covers the whole day. Plain dates start at midnight in location.
+func parseGanttWindow(startDateStr, endDateStr string, location *time.Location) (ganttWindow, error) {
+	var window ganttWindow
+
+	if startDateStr != "" {
+		startDate, _, err := parseDateParam(startDateStr, location)
+		if err != nil {
+			return window, fmt.Errorf("invalid startDate: %s", startDateStr)
+		}
+		window.Start = &startDate
 	}
 
-	c.JSON(http.StatusOK, response)
+	if endDateStr != "" {
+		endDate, dateOnly, err := parseDateParam(endDateStr, location)
+		if err != nil {
+			return window, fmt.Errorf("invalid endDate: %s", endDateStr)
+		}
+		if dateOnly {
+			endDate = endOfDay(endDate)
+		}
+		window.End = &endDate
+	}
+
+	if window.Start != nil && window.End != nil && window.End.Before(*window.Start) {
+		return window, fmt.Errorf("endDate must not be before startDate")
+	}
+
+	return window, nil
+}
+
+// parseDateParam parses an RFC3339 timestamp or a plain date, which is taken as midnight in location.
+// The boolean result reports whether the value was a plain date.
+func parseDateParam(value string, location *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+// endOfDay returns the last instant of the day starting at midnight.
+// Days are not always 24 hours long where daylight saving time applies
+func endOfDay(midnight time.Time) time.Time {
+	return midnight.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// clip trims the task to the window and recalculates its duration.
+// Returns false if the task lies entirely outside the window.
+func (w ganttWindow) clip(task *GanttTask) bool {
+	if w.Start != nil && task.End.Before(*w.Start) {
+		return false
+	}
+	if w.End != nil && task.Start.After(*w.End) {
+		return false
+	}
+
+	clipped := false
+	if w.Start != nil && task.Start.Before(*w.Start) {
+		task.Start = *w.Start
+		clipped = true
+	}
+	if w.End != nil && task.End.After(*w.End) {
+		task.End = *w.End
+		clipped = true
+	}
+
+	if clipped {
+		duration := int(task.End.Sub(task.Start).Hours())
+		task.Duration = &duration
+	}
+
+	return true
+}
+
+// queryStringList collects a query parameter given either repeated
+// (?locationCode=A&locationCode=B) or comma-separated (?locationCode=A,B)
+func queryStringList(c *gin.Context, key string) []string {
+	values := []string{}
+	for _, raw := range c.QueryArray(key) {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// queryIntList is like queryStringList for integer values; any other value is an error
+func queryIntList(c *gin.Context, key string) ([]int, error) {
+	values := []int{}
+	for _, value := range queryStringList(c, key) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", key, value)
+		}
+		values = append(values, n)
+	}
+	return values, nil
 }
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gantt task IDs are derived from the source document keys, so they stay the same
+// whatever filter was used to load the chart:
+//
+//	wp:<AircraftWorkPackageId>
+//	wp:<AircraftWorkPackageId>:task:<TaskSeq>
+//	wp:<AircraftWorkPackageId>:task:<TaskSeq>:ei:<ExecutionInstanceSeq>
+//	wp:<AircraftWorkPackageId>:milestone:<start|end|rts>
+//
+// Link IDs combine both ends and the type: <source>~<type>~<target>
+
+// Kinds of documents a Gantt task ID can refer to
+const (
+	GanttKindWorkPackage       = "workPackage"
+	GanttKindTask              = "task"
+	GanttKindExecutionInstance = "executionInstance"
+	GanttKindMilestone         = "milestone"
+)
+
+// GanttTaskRef is a parsed Gantt task ID
+type GanttTaskRef struct {
+	AircraftWorkPackageId int    `json:"aircraftWorkPackageId"`
+	TaskSeq               *int   `json:"taskSeq,omitempty"`
+	ExecutionInstanceSeq  *int   `json:"executionInstanceSeq,omitempty"`
+	Milestone             string `json:"milestone,omitempty"`
+}
+
+// Kind returns the kind of document the reference points to
+func (r GanttTaskRef) Kind() string {
+	switch {
+	case r.Milestone != "":
+		return GanttKindMilestone
+	case r.ExecutionInstanceSeq != nil:
+		return GanttKindExecutionInstance
+	case r.TaskSeq != nil:
+		return GanttKindTask
+	default:
+		return GanttKindWorkPackage
+	}
+}
+
+// WorkPackageGanttID returns the Gantt task ID of a work package summary task
+func WorkPackageGanttID(aircraftWorkPackageId int) string {
+	return "wp:" + strconv.Itoa(aircraftWorkPackageId)
+}
+
+// AvExeTaskGanttID returns the Gantt task ID of an AvExeTask
+func AvExeTaskGanttID(aircraftWorkPackageId, taskSeq int) string {
+	return WorkPackageGanttID(aircraftWorkPackageId) + ":task:" + strconv.Itoa(taskSeq)
+}
+
+// ExecutionInstanceGanttID returns the Gantt task ID of a JtExecutionInstance
+func ExecutionInstanceGanttID(aircraftWorkPackageId, taskSeq, executionInstanceSeq int) string {
+	return AvExeTaskGanttID(aircraftWorkPackageId, taskSeq) + ":ei:" + strconv.Itoa(executionInstanceSeq)
+}
+
+// MilestoneGanttID returns the Gantt task ID of a work package milestone
+func MilestoneGanttID(aircraftWorkPackageId int, milestone string) string {
+	return WorkPackageGanttID(aircraftWorkPackageId) + ":milestone:" + milestone
+}
+
+// GanttLinkID returns the ID of a link between two Gantt tasks
+func GanttLinkID(source, linkType, target string) string {
+	return source + "~" + linkType + "~" + target
+}
+
+// ParseGanttID parses a Gantt task ID back into the keys of its source document
+func ParseGanttID(id string) (GanttTaskRef, error) {
+	var ref GanttTaskRef
+
+	parts := strings.Split(id, ":")
+	if len(parts) == 4 && parts[0] == "wp" && parts[2] == "milestone" {
+		wpId, err := strconv.Atoi(parts[1])
+		if err != nil || !isWorkPackageMilestone(parts[3]) {
+			return ref, fmt.Errorf("invalid Gantt task ID: %s", id)
+		}
+		ref.AircraftWorkPackageId = wpId
+		ref.Milestone = parts[3]
+		return ref, nil
+	}
+	if len(parts) != 2 && len(parts) != 4 && len(parts) != 6 {
+		return ref, fmt.Errorf("invalid Gantt task ID: %s", id)
+	}
+
+	labels := []string{"wp", "task", "ei"}
+	values := []int{}
+	for i := 0; i < len(parts); i += 2 {
+		if parts[i] != labels[i/2] {
+			return ref, fmt.Errorf("invalid Gantt task ID: %s", id)
+		}
+		value, err := strconv.Atoi(parts[i+1])
+		if err != nil {
+			return ref, fmt.Errorf("invalid Gantt task ID: %s", id)
+		}
+		values = append(values, value)
+	}
+
+	ref.AircraftWorkPackageId = values[0]
+	if len(values) > 1 {
+		ref.TaskSeq = &values[1]
+	}
+	if len(values) > 2 {
+		ref.ExecutionInstanceSeq = &values[2]
+	}
+	return ref, nil
+}
+
+// GanttSourceDocument is a Gantt task ID resolved to the document it was built from
+type GanttSourceDocument struct {
+	ID       string       `json:"id"`
+	Kind     string       `json:"kind"`
+	Ref      GanttTaskRef `json:"ref"`
+	Document interface{}  `json:"document"`
+}
+
+// resolveGanttRef loads the source document of a parsed Gantt task ID.
+// Returns repository.ErrNotFound if the work package, task or execution instance does not exist
+func (s *Server) resolveGanttRef(c *gin.Context, id string, ref GanttTaskRef) (GanttSourceDocument, error) {
+	wp, err := s.workPackages(c).FindByWorkPackageID(c.Request.Context(), ref.AircraftWorkPackageId)
+	if err != nil {
+		return GanttSourceDocument{}, err
+	}
+
+	resolved := GanttSourceDocument{ID: id, Kind: ref.Kind(), Ref: ref}
+	switch resolved.Kind {
+	case GanttKindWorkPackage, GanttKindMilestone:
+		resolved.Document = wp
+	case GanttKindTask:
+		task := findAvExeTask(&wp, *ref.TaskSeq)
+		if task == nil {
+			return GanttSourceDocument{}, repository.ErrNotFound
+		}
+		resolved.Document = task
+	case GanttKindExecutionInstance:
+		task := findAvExeTask(&wp, *ref.TaskSeq)
+		if task == nil {
+			return GanttSourceDocument{}, repository.ErrNotFound
+		}
+		instance := findExecutionInstance(task, *ref.ExecutionInstanceSeq)
+		if instance == nil {
+			return GanttSourceDocument{}, repository.ErrNotFound
+		}
+		resolved.Document = instance
+	}
+
+	return resolved, nil
+}
+
+// GetGanttTaskSource resolves a Gantt task ID to its work package, task or execution instance
+func (s *Server) GetGanttTaskSource(c *gin.Context) {
+	id := c.Param("id")
+	ref, err := ParseGanttID(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resolved, err := s.resolveGanttRef(c, id, ref)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Source document not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resolved)
+}
+
+// ResolveGanttTaskSources resolves several Gantt task IDs at once, e.g. to restore a selection.
+// IDs that cannot be resolved are reported in notFound
+func (s *Server) ResolveGanttTaskSources(c *gin.Context) {
+	var req struct {
+		IDs []string `json:"ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	resolved := []GanttSourceDocument{}
+	notFound := []string{}
+	for _, id := range req.IDs {
+		ref, err := ParseGanttID(id)
+		if err != nil {
+			notFound = append(notFound, id)
+			continue
+		}
+		document, err := s.resolveGanttRef(c, id, ref)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				notFound = append(notFound, id)
+				continue
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+			return
+		}
+		resolved = append(resolved, document)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     resolved,
+		"notFound": notFound,
+	})
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ganttTaskKey identifies an AvExeTask across work packages
+type ganttTaskKey struct {
+	AircraftWpId int
+	TaskSeq      int
+}
+
+// ganttInstanceKey identifies a JtExecutionInstance across work packages
+type ganttInstanceKey struct {
+	AircraftWpId         int
+	TaskSeq              int
+	ExecutionInstanceSeq int
+}
+
+// ganttTaskIndex maps source documents to the Gantt task IDs emitted for them,
+// so links can be resolved after all tasks have been prepared
+type ganttTaskIndex struct {
+	workPackages map[int]string
+	tasks        map[ganttTaskKey]string
+	instances    map[ganttInstanceKey]string
+}
+
+func newGanttTaskIndex() ganttTaskIndex {
+	return ganttTaskIndex{
+		workPackages: map[int]string{},
+		tasks:        map[ganttTaskKey]string{},
+		instances:    map[ganttInstanceKey]string{},
+	}
+}
+
+// isValidLinkType reports whether t is one of the Gantt dependency types
+func isValidLinkType(t string) bool {
+	switch t {
+	case "s2s", "s2e", "e2s", "e2e":
+		return true
+	}
+	return false
+}
+
+// taskDependency is a dependency between two AvExeTasks, before it is mapped onto Gantt task IDs
+type taskDependency struct {
+	Source ganttTaskKey
+	Target ganttTaskKey
+	Type   string
+}
+
+// deriveTaskDependencies derives the dependencies between the tasks of one work package:
+//   - duplicate tasks start together with their master task (MasterTaskSeq, s2s)
+//   - a task whose EarliestStart equals another task's LatestFinish follows it (e2s)
+func deriveTaskDependencies(wp models.AircraftWorkPackage) []taskDependency {
+	wpId := wp.AircraftWorkPackageId
+	dependencies := []taskDependency{}
+
+	for _, task := range wp.Avexetask {
+		target := ganttTaskKey{wpId, task.TaskSeq}
+
+		// Master/duplicate tasks
+		if task.MasterTaskSeq != nil && *task.MasterTaskSeq != task.TaskSeq {
+			dependencies = append(dependencies, taskDependency{ganttTaskKey{wpId, *task.MasterTaskSeq}, target, "s2s"})
+		}
+
+		// EarliestStart constrained by another task's LatestFinish
+		if task.EarliestStart == nil {
+			continue
+		}
+		for _, predecessor := range wp.Avexetask {
+			if predecessor.TaskSeq == task.TaskSeq || predecessor.LatestFinish == nil {
+				continue
+			}
+			if predecessor.LatestFinish.Equal(*task.EarliestStart) {
+				dependencies = append(dependencies, taskDependency{ganttTaskKey{wpId, predecessor.TaskSeq}, target, "e2s"})
+			}
+		}
+	}
+
+	return dependencies
+}
+
+// userTaskDependencies converts the stored user-defined dependencies
+func userTaskDependencies(dependencies []models.GanttDependency) []taskDependency {
+	result := make([]taskDependency, 0, len(dependencies))
+	for _, dep := range dependencies {
+		result = append(result, taskDependency{
+			Source: ganttTaskKey{dep.SourceAircraftWpId, dep.SourceTaskSeq},
+			Target: ganttTaskKey{dep.TargetAircraftWpId, dep.TargetTaskSeq},
+			Type:   dep.Type,
+		})
+	}
+	return result
+}
+
+// BuildGanttLinks derives the links between the prepared Gantt tasks from the work package data:
+//   - the task dependencies within each package (see deriveTaskDependencies)
+//   - carried-over tasks follow the package they were moved from (PrevAircraftWpId, e2s)
+//   - user-defined dependencies stored in the ganttDependencies collection
+//
+// Links are only emitted when both ends are part of the current response.
+// Link IDs are derived from both ends and the type (see GanttLinkID)
+func BuildGanttLinks(workPackages []models.AircraftWorkPackage, index ganttTaskIndex, dependencies []models.GanttDependency) []GanttLink {
+	links := []GanttLink{}
+	seen := map[string]bool{}
+
+	addLink := func(linkType string, source, target string) {
+		if source == "" || target == "" || source == target {
+			return
+		}
+		linkID := GanttLinkID(source, linkType, target)
+		if seen[linkID] {
+			return
+		}
+		seen[linkID] = true
+
+		links = append(links, GanttLink{
+			ID:     linkID,
+			Type:   linkType,
+			Source: source,
+			Target: target,
+		})
+	}
+
+	for _, wp := range workPackages {
+		for _, dep := range deriveTaskDependencies(wp) {
+			addLink(dep.Type, index.tasks[dep.Source], index.tasks[dep.Target])
+		}
+
+		// Carry-over from a previous work package
+		for _, task := range wp.Avexetask {
+			if task.PrevAircraftWpId != nil && *task.PrevAircraftWpId != wp.AircraftWorkPackageId {
+				addLink("e2s", index.workPackages[*task.PrevAircraftWpId],
+					index.tasks[ganttTaskKey{wp.AircraftWorkPackageId, task.TaskSeq}])
+			}
+		}
+	}
+
+	for _, dep := range userTaskDependencies(dependencies) {
+		addLink(dep.Type, index.tasks[dep.Source], index.tasks[dep.Target])
+	}
+
+	return links
+}
+
+// findGanttDependencies loads the user-defined dependencies touching any of the given work packages.
+// Without a dependency repository there are none
+func (s *Server) findGanttDependencies(ctx context.Context, wpIds []int) ([]models.GanttDependency, error) {
+	if len(wpIds) == 0 || s.Dependencies == nil {
+		return []models.GanttDependency{}, nil
+	}
+	return s.Dependencies.Find(ctx, wpIds)
+}
+
+// visibleDependencies leaves out the dependencies with an end outside the scope of the user
+func (s *Server) visibleDependencies(c *gin.Context, dependencies []models.GanttDependency) ([]models.GanttDependency, error) {
+	if s.workPackages(c) == s.WorkPackages || len(dependencies) == 0 {
+		return dependencies, nil
+	}
+	return s.dependenciesBetweenTasks(c, dependencies)
+}
+
+// dependenciesBetweenTasks keeps the dependencies whose ends are both tasks the user sees, so
+// it also leaves out dependencies on tasks that do not exist
+func (s *Server) dependenciesBetweenTasks(c *gin.Context, dependencies []models.GanttDependency) ([]models.GanttDependency, error) {
+	wpIds := []int{}
+	seen := map[int]bool{}
+	for _, dep := range dependencies {
+		for _, wpId := range []int{dep.SourceAircraftWpId, dep.TargetAircraftWpId} {
+			if !seen[wpId] {
+				seen[wpId] = true
+				wpIds = append(wpIds, wpId)
+			}
+		}
+	}
+	found, err := s.workPackages(c).Find(c.Request.Context(), repository.WorkPackageFilter{WorkPackageIds: wpIds}, repository.FindOptions{})
+	if err != nil {
+		return nil, err
+	}
+	visible := map[ganttTaskKey]bool{}
+	for _, wp := range found {
+		for _, task := range wp.Avexetask {
+			visible[ganttTaskKey{AircraftWpId: wp.AircraftWorkPackageId, TaskSeq: task.TaskSeq}] = true
+		}
+	}
+
+	filtered := []models.GanttDependency{}
+	for _, dep := range dependencies {
+		if visible[ganttTaskKey{AircraftWpId: dep.SourceAircraftWpId, TaskSeq: dep.SourceTaskSeq}] &&
+			visible[ganttTaskKey{AircraftWpId: dep.TargetAircraftWpId, TaskSeq: dep.TargetTaskSeq}] {
+			filtered = append(filtered, dep)
+		}
+	}
+	return filtered, nil
+}
+
+var (
+	// errInvalidDependencyType is returned for a dependency type other than s2s, s2e, e2s and e2e
+	errInvalidDependencyType = errors.New("invalid dependency type")
+	// errSelfDependency is returned for a dependency from a task to itself
+	errSelfDependency = errors.New("a task cannot depend on itself")
+	// errDependencyTaskNotFound is returned when an end of a new dependency is not a task the user sees
+	errDependencyTaskNotFound = errors.New("task not found")
+	// errDependencyHidden is returned when a user removes a dependency with an end outside their scope
+	errDependencyHidden = errors.New("dependency not found")
+)
+
+// createDependency validates a new dependency, checks that the user sees both tasks and stores it.
+// CreateGanttDependency and SyncGantt both create dependencies through it
+func (s *Server) createDependency(c *gin.Context, dep models.GanttDependency) (models.GanttDependency, error) {
+	if dep.Type == "" {
+		dep.Type = "e2s"
+	}
+	if !isValidLinkType(dep.Type) {
+		return dep, errInvalidDependencyType
+	}
+	if dep.SourceAircraftWpId == dep.TargetAircraftWpId && dep.SourceTaskSeq == dep.TargetTaskSeq {
+		return dep, errSelfDependency
+	}
+	visible, err := s.dependenciesBetweenTasks(c, []models.GanttDependency{dep})
+	if err != nil {
+		return dep, err
+	}
+	if len(visible) == 0 {
+		return dep, errDependencyTaskNotFound
+	}
+
+	dep.ID = primitive.NewObjectID()
+	dep.CreatedDate = time.Now().UTC()
+	if username, exists := c.Get("username"); exists {
+		dep.CreatedBy, _ = username.(string)
+	}
+	if err := s.Dependencies.Create(c.Request.Context(), dep); err != nil {
+		return dep, err
+	}
+
+	log.Printf("Created dependency %s: WP %d Task %d -> WP %d Task %d (%s)", dep.ID.Hex(),
+		dep.SourceAircraftWpId, dep.SourceTaskSeq, dep.TargetAircraftWpId, dep.TargetTaskSeq, dep.Type)
+	return dep, nil
+}
+
+// deleteDependency removes a dependency if the user sees both of its tasks. It returns
+// repository.ErrNotFound if the dependency does not exist and errDependencyHidden if the user
+// does not see it. DeleteGanttDependency and SyncGantt both remove dependencies through it
+func (s *Server) deleteDependency(c *gin.Context, id primitive.ObjectID) error {
+	dep, err := s.Dependencies.FindByID(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+	visible, err := s.visibleDependencies(c, []models.GanttDependency{dep})
+	if err != nil {
+		return err
+	}
+	if len(visible) == 0 {
+		return errDependencyHidden
+	}
+	return s.Dependencies.Delete(c.Request.Context(), id)
+}
+
+// GetGanttDependencies lists user-defined dependencies, optionally filtered by work package
+func (s *Server) GetGanttDependencies(c *gin.Context) {
+	if !s.dependenciesEnabled(c) {
+		return
+	}
+
+	wpIds, err := queryIntList(c, "aircraftWorkPackageId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dependencies, err := s.Dependencies.Find(c.Request.Context(), wpIds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database: " + err.Error()})
+		return
+	}
+	dependencies, err = s.visibleDependencies(c, dependencies)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  dependencies,
+		"count": len(dependencies),
+	})
+}
+
+// dependenciesEnabled answers 501 and returns false when the server stores no dependencies
+func (s *Server) dependenciesEnabled(c *gin.Context) bool {
+	if s.Dependencies == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Dependencies are not enabled"})
+		return false
+	}
+	return true
+}
+
+// CreateGanttDependency stores a user-defined dependency between two tasks
+func (s *Server) CreateGanttDependency(c *gin.Context) {
+	if !s.dependenciesEnabled(c) {
+		return
+	}
+
+	var dep models.GanttDependency
+	if err := c.ShouldBindJSON(&dep); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	dep, err := s.createDependency(c, dep)
+	if err != nil {
+		switch err {
+		case errInvalidDependencyType:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dependency type: " + dep.Type})
+		case errSelfDependency:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A task cannot depend on itself"})
+		case errDependencyTaskNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dependency: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, dep)
+}
+
+// DeleteGanttDependency removes a user-defined dependency. Users with a scope can only remove
+// dependencies between tasks they see
+func (s *Server) DeleteGanttDependency(c *gin.Context) {
+	if !s.dependenciesEnabled(c) {
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := s.deleteDependency(c, objectID); err != nil {
+		if err == repository.ErrNotFound || err == errDependencyHidden {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dependency not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dependency deleted", "id": objectID.Hex()})
+}
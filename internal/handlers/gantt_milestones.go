@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"time"
+
+	"stationMonitor/internal/models"
+)
+
+// Milestones emitted for every work package
+const (
+	MilestoneStart            = "start"
+	MilestoneEnd              = "end"
+	MilestoneReleaseToService = "rts"
+)
+
+// Constraint violations reported on Gantt tasks
+const (
+	ViolationEarliestStart = "earliestStart" // starts before EarliestStart
+	ViolationLatestStart   = "latestStart"   // starts after LatestStart
+	ViolationLatestFinish  = "latestFinish"  // finishes after LatestFinish
+	ViolationFixedStart    = "fixedStart"    // does not start at FixedStart
+)
+
+// constraintTolerance ignores differences below the minute precision of IFS dates
+const constraintTolerance = time.Minute
+
+// isWorkPackageMilestone reports whether m is one of the work package milestones
+func isWorkPackageMilestone(m string) bool {
+	switch m {
+	case MilestoneStart, MilestoneEnd, MilestoneReleaseToService:
+		return true
+	}
+	return false
+}
+
+// PrepareWorkPackageMilestones returns the milestones of a work package: its scheduled start, and
+// its scheduled end, shown as release to service when the package has IsReleaseToService set.
+// Actual dates win over scheduled ones once they are known
+func PrepareWorkPackageMilestones(wp models.AircraftWorkPackage) []GanttTask {
+	milestones := []GanttTask{}
+
+	addMilestone := func(milestone, text string, date *time.Time) {
+		if date == nil {
+			return
+		}
+		duration := 0
+		milestones = append(milestones, GanttTask{
+			ID:        MilestoneGanttID(wp.AircraftWorkPackageId, milestone),
+			Text:      text,
+			Start:     *date,
+			End:       *date,
+			Duration:  &duration,
+			Type:      "milestone",
+			Parent:    WorkPackageGanttID(wp.AircraftWorkPackageId),
+			Milestone: milestone,
+
+			AircraftWorkPackageId: wp.AircraftWorkPackageId,
+		})
+	}
+
+	start := wp.SchedStartDateTime
+	if wp.ActualStartDateTime != nil {
+		start = wp.ActualStartDateTime
+	}
+	end := wp.SchedEndDateTime
+	if wp.ActualEndDateTime != nil {
+		end = wp.ActualEndDateTime
+	}
+
+	addMilestone(MilestoneStart, "Package start", start)
+	if wp.IsReleaseToService != nil && *wp.IsReleaseToService {
+		addMilestone(MilestoneReleaseToService, "Release to service", end)
+	} else {
+		addMilestone(MilestoneEnd, "Package end", end)
+	}
+
+	return milestones
+}
+
+// checkGanttConstraints compares the drawn dates of a task with its constraint fields
+// and sets ConstraintViolated and Violations accordingly
+func checkGanttConstraints(task *GanttTask) {
+	task.Violations = nil
+	if task.EarliestStart != nil && task.EarliestStart.Sub(task.Start) >= constraintTolerance {
+		task.Violations = append(task.Violations, ViolationEarliestStart)
+	}
+	if task.LatestStart != nil && task.Start.Sub(*task.LatestStart) >= constraintTolerance {
+		task.Violations = append(task.Violations, ViolationLatestStart)
+	}
+	if task.LatestFinish != nil && task.End.Sub(*task.LatestFinish) >= constraintTolerance {
+		task.Violations = append(task.Violations, ViolationLatestFinish)
+	}
+	if task.FixedStart != nil {
+		offset := task.Start.Sub(*task.FixedStart)
+		if offset >= constraintTolerance || offset <= -constraintTolerance {
+			task.Violations = append(task.Violations, ViolationFixedStart)
+		}
+	}
+	task.ConstraintViolated = len(task.Violations) > 0
+}
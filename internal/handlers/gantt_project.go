@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"stationMonitor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stationCalendarID is the calendar used by every task. Line and base maintenance
+// run around the clock, so the station calendar has no non-working time.
+const stationCalendarID = "station"
+
+// BryntumProject represents the project settings of the Bryntum Gantt project model
+type BryntumProject struct {
+	Calendar     string     `json:"calendar"`
+	StartDate    *time.Time `json:"startDate,omitempty"`
+	EndDate      *time.Time `json:"endDate,omitempty"`
+	HoursPerDay  int        `json:"hoursPerDay"`
+	DaysPerWeek  int        `json:"daysPerWeek"`
+	DaysPerMonth int        `json:"daysPerMonth"`
+}
+
+// BryntumTask represents a task row of the Bryntum Gantt project model
+type BryntumTask struct {
+	ID                interface{}       `json:"id"`
+	Name              string            `json:"name"`
+	StartDate         time.Time         `json:"startDate"`
+	EndDate           time.Time         `json:"endDate"`
+	Duration          float64           `json:"duration"`
+	DurationUnit      string            `json:"durationUnit"`
+	PercentDone       int               `json:"percentDone"`
+	ManuallyScheduled bool              `json:"manuallyScheduled"`
+	Expanded          bool              `json:"expanded,omitempty"`
+	Calendar          string            `json:"calendar,omitempty"`
+	Children          []*BryntumTask    `json:"children,omitempty"`
+	Baselines         []BryntumBaseline `json:"baselines,omitempty"`
+
+	// Constraints; tasks are manually scheduled, so they are only displayed
+	ConstraintType     string     `json:"constraintType,omitempty"`
+	ConstraintDate     *time.Time `json:"constraintDate,omitempty"`
+	DeadlineDate       *time.Time `json:"deadlineDate,omitempty"`
+	ConstraintViolated bool       `json:"constraintViolated,omitempty"`
+	Violations         []string   `json:"violations,omitempty"`
+	Milestone          string     `json:"milestone,omitempty"`
+
+	// Source document references, sent back by the client when saving edits
+	AircraftWorkPackageId int        `json:"aircraftWorkPackageId,omitempty"`
+	TaskSeq               int        `json:"taskSeq,omitempty"`
+	ExecutionInstanceSeq  *int       `json:"executionInstanceSeq,omitempty"`
+	ETag                  string     `json:"etag,omitempty"`
+	ChangedDate           *time.Time `json:"changedDate,omitempty"`
+}
+
+// BryntumBaseline represents the baseline dates of a task, shown by the Baselines feature
+type BryntumBaseline struct {
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate"`
+}
+
+// BryntumDependency represents a dependency row of the Bryntum Gantt project model
+type BryntumDependency struct {
+	ID   interface{} `json:"id"`
+	From interface{} `json:"fromTask"`
+	To   interface{} `json:"toTask"`
+	Type int         `json:"type"` // 0 StartToStart, 1 StartToEnd, 2 EndToStart, 3 EndToEnd
+}
+
+// BryntumResource represents a resource row (a crew or an individual resource)
+type BryntumResource struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"` // "crew" or "resource"
+}
+
+// BryntumAssignment assigns a resource to a task
+type BryntumAssignment struct {
+	ID       string      `json:"id"`
+	Event    interface{} `json:"event"`
+	Resource string      `json:"resource"`
+	Units    int         `json:"units"`
+}
+
+// BryntumCalendarInterval represents a recurring working/non-working interval
+type BryntumCalendarInterval struct {
+	RecurrentStartDate string `json:"recurrentStartDate,omitempty"`
+	RecurrentEndDate   string `json:"recurrentEndDate,omitempty"`
+	IsWorking          bool   `json:"isWorking"`
+}
+
+// BryntumCalendar represents a calendar row of the Bryntum Gantt project model
+type BryntumCalendar struct {
+	ID                       string                    `json:"id"`
+	Name                     string                    `json:"name"`
+	UnspecifiedTimeIsWorking bool                      `json:"unspecifiedTimeIsWorking"`
+	Intervals                []BryntumCalendarInterval `json:"intervals"`
+}
+
+// BryntumRows wraps a store's rows the way the Bryntum CrudManager expects them
+type BryntumRows[T any] struct {
+	Rows []T `json:"rows"`
+}
+
+// BryntumProjectResponse is the full Bryntum CrudManager load response
+type BryntumProjectResponse struct {
+	Success      bool                           `json:"success"`
+	Project      BryntumProject                 `json:"project"`
+	Calendars    BryntumRows[BryntumCalendar]   `json:"calendars"`
+	Tasks        BryntumRows[*BryntumTask]      `json:"tasks"`
+	Dependencies BryntumRows[BryntumDependency] `json:"dependencies"`
+	Resources    BryntumRows[BryntumResource]   `json:"resources"`
+	Assignments  BryntumRows[BryntumAssignment] `json:"assignments"`
+}
+
+// bryntumDependencyTypes maps Gantt link types to Bryntum DependencyType values
+var bryntumDependencyTypes = map[string]int{
+	"s2s": 0,
+	"s2e": 1,
+	"e2s": 2,
+	"e2e": 3,
+}
+
+// GetGanttProject returns the Gantt data in the Bryntum project model format.
+// It accepts the same filters as GetGanttTasks
+func (s *Server) GetGanttProject(c *gin.Context) {
+	workPackages, window, tz, ok := s.loadGanttWorkPackages(c)
+	if !ok {
+		return
+	}
+
+	data := s.buildGanttData(c.Request.Context(), workPackages, window)
+	localizeGanttTasks(data.Tasks, workPackageZones(tz, workPackages))
+	response := BuildBryntumProject(workPackages, data)
+
+	log.Printf("Built Bryntum project with %d tasks, %d dependencies, %d resources and %d assignments",
+		len(data.Tasks), len(response.Dependencies.Rows), len(response.Resources.Rows), len(response.Assignments.Rows))
+
+	c.JSON(http.StatusOK, response)
+}
+
+// BuildBryntumProject converts the transformed Gantt data into the Bryntum project model.
+// Crews are assigned to their AvExeTasks and individual resources to their execution instances
+func BuildBryntumProject(workPackages []models.AircraftWorkPackage, data ganttData) BryntumProjectResponse {
+	response := BryntumProjectResponse{
+		Success: true,
+		Project: BryntumProject{
+			Calendar:     stationCalendarID,
+			HoursPerDay:  24,
+			DaysPerWeek:  7,
+			DaysPerMonth: 30,
+		},
+		Calendars: BryntumRows[BryntumCalendar]{Rows: []BryntumCalendar{
+			{
+				ID:                       stationCalendarID,
+				Name:                     "Station (24/7)",
+				UnspecifiedTimeIsWorking: true,
+				Intervals:                []BryntumCalendarInterval{},
+			},
+		}},
+		Tasks:        BryntumRows[*BryntumTask]{Rows: []*BryntumTask{}},
+		Dependencies: BryntumRows[BryntumDependency]{Rows: []BryntumDependency{}},
+		Resources:    BryntumRows[BryntumResource]{Rows: []BryntumResource{}},
+		Assignments:  BryntumRows[BryntumAssignment]{Rows: []BryntumAssignment{}},
+	}
+
+	// Build the task tree; parents are always emitted before their children
+	byID := map[interface{}]*BryntumTask{}
+	for _, task := range data.Tasks {
+		bryntumTask := toBryntumTask(task)
+		byID[task.ID] = bryntumTask
+
+		if parent, found := byID[task.Parent]; task.Parent != nil && found {
+			parent.Children = append(parent.Children, bryntumTask)
+			continue
+		}
+		response.Tasks.Rows = append(response.Tasks.Rows, bryntumTask)
+
+		if response.Project.StartDate == nil || task.Start.Before(*response.Project.StartDate) {
+			start := task.Start
+			response.Project.StartDate = &start
+		}
+		if response.Project.EndDate == nil || task.End.After(*response.Project.EndDate) {
+			end := task.End
+			response.Project.EndDate = &end
+		}
+	}
+
+	for _, link := range data.Links {
+		linkType, found := bryntumDependencyTypes[link.Type]
+		if !found {
+			continue
+		}
+		response.Dependencies.Rows = append(response.Dependencies.Rows, BryntumDependency{
+			ID:   link.ID,
+			From: link.Source,
+			To:   link.Target,
+			Type: linkType,
+		})
+	}
+
+	// Resources and assignments
+	resources := map[string]bool{}
+	addResource := func(resource BryntumResource) {
+		if !resources[resource.ID] {
+			resources[resource.ID] = true
+			response.Resources.Rows = append(response.Resources.Rows, resource)
+		}
+	}
+
+	for _, wp := range workPackages {
+		for _, task := range wp.Avexetask {
+			taskID := data.index.tasks[ganttTaskKey{wp.AircraftWorkPackageId, task.TaskSeq}]
+			if taskID == "" {
+				continue
+			}
+
+			if task.CrewCode != nil && *task.CrewCode != "" {
+				crewID := "crew:" + *task.CrewCode
+				crewName := *task.CrewCode
+				if task.CrewName != nil && *task.CrewName != "" {
+					crewName = *task.CrewName
+				}
+				addResource(BryntumResource{ID: crewID, Name: crewName, Type: "crew"})
+				response.Assignments.Rows = append(response.Assignments.Rows, BryntumAssignment{
+					ID:       taskID + ":" + crewID,
+					Event:    taskID,
+					Resource: crewID,
+					Units:    100,
+				})
+			}
+
+			for _, instance := range task.JtExecutionInstanceArray {
+				instanceID := data.index.instances[ganttInstanceKey{wp.AircraftWorkPackageId, task.TaskSeq, instance.ExecutionInstanceSeq}]
+				if instanceID == "" || instance.ResourceSeq == 0 {
+					continue
+				}
+
+				resourceID := fmt.Sprintf("resource:%d", instance.ResourceSeq)
+				addResource(BryntumResource{ID: resourceID, Name: fmt.Sprintf("Resource %d", instance.ResourceSeq), Type: "resource"})
+				response.Assignments.Rows = append(response.Assignments.Rows, BryntumAssignment{
+					ID:       instanceID + ":" + resourceID,
+					Event:    instanceID,
+					Resource: resourceID,
+					Units:    100,
+				})
+			}
+		}
+	}
+
+	return response
+}
+
+// toBryntumTask converts a Gantt task into a Bryntum task row.
+// Dates come from IFS, so tasks are manually scheduled and the Bryntum engine does not move them
+func toBryntumTask(task GanttTask) *BryntumTask {
+	progress := 0
+	if task.Progress != nil {
+		progress = *task.Progress
+	}
+
+	bryntumTask := &BryntumTask{
+		ID:                task.ID,
+		Name:              task.Text,
+		StartDate:         task.Start,
+		EndDate:           task.End,
+		Duration:          task.End.Sub(task.Start).Hours(),
+		DurationUnit:      "h",
+		PercentDone:       progress,
+		ManuallyScheduled: true,
+		Expanded:          task.Open != nil && *task.Open,
+		Calendar:          stationCalendarID,
+
+		AircraftWorkPackageId: task.AircraftWorkPackageId,
+		TaskSeq:               task.TaskSeq,
+		ExecutionInstanceSeq:  task.ExecutionInstanceSeq,
+		ETag:                  task.ETag,
+		ChangedDate:           task.ChangedDate,
+
+		DeadlineDate:       task.LatestFinish,
+		ConstraintViolated: task.ConstraintViolated,
+		Violations:         task.Violations,
+		Milestone:          task.Milestone,
+	}
+
+	// Bryntum allows a single constraint per task; a fixed start is the strongest
+	switch {
+	case task.FixedStart != nil:
+		bryntumTask.ConstraintType = "muststarton"
+		bryntumTask.ConstraintDate = task.FixedStart
+	case task.EarliestStart != nil:
+		bryntumTask.ConstraintType = "startnoearlierthan"
+		bryntumTask.ConstraintDate = task.EarliestStart
+	}
+
+	if task.BaselineStart != nil && task.BaselineEnd != nil {
+		bryntumTask.Baselines = []BryntumBaseline{{StartDate: *task.BaselineStart, EndDate: *task.BaselineEnd}}
+	}
+
+	return bryntumTask
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GanttTaskChange is a task edited in the chart. The source document is referenced by its
+// Gantt task ID or by AircraftWorkPackageId/TaskSeq and, for execution instances, ExecutionInstanceSeq.
+// ETag and ChangedDate are the values the client loaded and are used to reject stale edits.
+// Applied changes carry the etag they were made on, which is sent to IFS with the change
+type GanttTaskChange struct {
+	ID                    interface{} `json:"id"`
+	AircraftWorkPackageId int         `json:"aircraftWorkPackageId"`
+	TaskSeq               int         `json:"taskSeq"`
+	ExecutionInstanceSeq  *int        `json:"executionInstanceSeq,omitempty"`
+	StartDate             *time.Time  `json:"startDate"`
+	EndDate               *time.Time  `json:"endDate"`
+	Duration              *int        `json:"duration,omitempty"` // hours
+	ETag                  string      `json:"etag,omitempty"`
+	ChangedDate           *time.Time  `json:"changedDate,omitempty"`
+}
+
+// resolveRef fills the source document keys from the Gantt task ID when the client only sent the ID
+func (change *GanttTaskChange) resolveRef() error {
+	if change.AircraftWorkPackageId != 0 && change.TaskSeq != 0 {
+		return nil
+	}
+
+	id, _ := change.ID.(string)
+	ref, err := ParseGanttID(id)
+	if err != nil {
+		return err
+	}
+	if ref.Milestone != "" {
+		return fmt.Errorf("milestones cannot be edited in the chart")
+	}
+	if ref.TaskSeq == nil {
+		return fmt.Errorf("work package dates cannot be edited in the chart")
+	}
+
+	change.AircraftWorkPackageId = ref.AircraftWorkPackageId
+	change.TaskSeq = *ref.TaskSeq
+	change.ExecutionInstanceSeq = ref.ExecutionInstanceSeq
+	return nil
+}
+
+// GanttDependencyRemoval identifies a user-defined dependency to delete
+type GanttDependencyRemoval struct {
+	ID string `json:"id"`
+}
+
+// GanttSyncRequest holds the changes made in the chart since the last load
+type GanttSyncRequest struct {
+	Tasks struct {
+		Added   []GanttTaskChange `json:"added"`
+		Updated []GanttTaskChange `json:"updated"`
+		Removed []GanttTaskChange `json:"removed"`
+	} `json:"tasks"`
+	Dependencies struct {
+		Added   []models.GanttDependency `json:"added"`
+		Removed []GanttDependencyRemoval `json:"removed"`
+	} `json:"dependencies"`
+}
+
+// GanttSyncRejection explains why a change was not applied
+type GanttSyncRejection struct {
+	ID                    interface{} `json:"id,omitempty"`
+	AircraftWorkPackageId int         `json:"aircraftWorkPackageId,omitempty"`
+	Reason                string      `json:"reason"`
+}
+
+// GanttSyncResponse reports the outcome of a sync request
+type GanttSyncResponse struct {
+	Success      bool                     `json:"success"`
+	Tasks        []GanttTaskChange        `json:"tasks"`
+	Dependencies []models.GanttDependency `json:"dependencies"`
+	Removed      []string                 `json:"removedDependencies"`
+	Rejected     []GanttSyncRejection     `json:"rejected"`
+}
+
+// errStaleGanttEdit is returned when the stored document changed after the client loaded it
+var errStaleGanttEdit = fmt.Errorf("document was changed by someone else, reload and try again")
+
+// SyncGantt persists drag/resize edits from the chart.
+// Task dates are written back to AvExeTask.PlannedStart/PlannedFinish/Duration and
+// JtExecutionInstance.AllocatedStart/AllocatedFinish. All edits of a work package are applied
+// in a single conditional update, so either all of them are stored or none are.
+// Tasks are owned by IFS and cannot be added or removed here.
+func (s *Server) SyncGantt(c *gin.Context) {
+	var req GanttSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	response := GanttSyncResponse{
+		Tasks:        []GanttTaskChange{},
+		Dependencies: []models.GanttDependency{},
+		Removed:      []string{},
+		Rejected:     []GanttSyncRejection{},
+	}
+
+	for _, change := range append(req.Tasks.Added, req.Tasks.Removed...) {
+		response.Rejected = append(response.Rejected, GanttSyncRejection{
+			ID:                    change.ID,
+			AircraftWorkPackageId: change.AircraftWorkPackageId,
+			Reason:                "tasks can only be created or removed in IFS",
+		})
+	}
+
+	// Group task updates per work package
+	changesByWp := map[int][]GanttTaskChange{}
+	for _, change := range req.Tasks.Updated {
+		if err := change.resolveRef(); err != nil {
+			response.Rejected = append(response.Rejected, GanttSyncRejection{ID: change.ID, Reason: err.Error()})
+			continue
+		}
+		changesByWp[change.AircraftWorkPackageId] = append(changesByWp[change.AircraftWorkPackageId], change)
+	}
+	wpIds := make([]int, 0, len(changesByWp))
+	for wpId := range changesByWp {
+		wpIds = append(wpIds, wpId)
+	}
+	sort.Ints(wpIds)
+
+	for _, wpId := range wpIds {
+		applied, err := s.applyGanttTaskChanges(c, wpId, changesByWp[wpId])
+		if err != nil {
+			log.Printf("Rejected Gantt changes for work package %d: %v", wpId, err)
+			for _, change := range changesByWp[wpId] {
+				response.Rejected = append(response.Rejected, GanttSyncRejection{
+					ID:                    change.ID,
+					AircraftWorkPackageId: wpId,
+					Reason:                err.Error(),
+				})
+			}
+			continue
+		}
+		response.Tasks = append(response.Tasks, applied...)
+		s.queueGanttWriteBack(c, wpId, applied)
+	}
+
+	const noDependencyStore = "dependencies are not enabled"
+	for _, dep := range req.Dependencies.Added {
+		if s.Dependencies == nil {
+			response.Rejected = append(response.Rejected, GanttSyncRejection{Reason: noDependencyStore})
+			continue
+		}
+		created, err := s.createDependency(c, dep)
+		if err != nil {
+			reason := err.Error()
+			if err == errInvalidDependencyType {
+				reason += ": " + created.Type
+			}
+			response.Rejected = append(response.Rejected, GanttSyncRejection{AircraftWorkPackageId: dep.SourceAircraftWpId, Reason: reason})
+			continue
+		}
+		response.Dependencies = append(response.Dependencies, created)
+	}
+
+	for _, removal := range req.Dependencies.Removed {
+		if s.Dependencies == nil {
+			response.Rejected = append(response.Rejected, GanttSyncRejection{ID: removal.ID, Reason: noDependencyStore})
+			continue
+		}
+		objectID, err := primitive.ObjectIDFromHex(removal.ID)
+		if err != nil {
+			response.Rejected = append(response.Rejected, GanttSyncRejection{ID: removal.ID, Reason: "invalid dependency ID"})
+			continue
+		}
+		// Removing a dependency that is already gone is not an error
+		if err := s.deleteDependency(c, objectID); err != nil && err != repository.ErrNotFound {
+			response.Rejected = append(response.Rejected, GanttSyncRejection{ID: removal.ID, Reason: "failed to remove dependency: " + err.Error()})
+			continue
+		}
+		response.Removed = append(response.Removed, removal.ID)
+	}
+
+	response.Success = len(response.Rejected) == 0
+	c.JSON(http.StatusOK, response)
+}
+
+// applyGanttTaskChanges validates the changes of one work package against the stored document
+// and writes them in one update. The update only matches if every touched task and execution
+// instance still has the ChangedDate that was validated, so concurrent edits are rejected too
+func (s *Server) applyGanttTaskChanges(c *gin.Context, wpId int, changes []GanttTaskChange) ([]GanttTaskChange, error) {
+	wp, err := s.workPackages(c).FindByWorkPackageID(c.Request.Context(), wpId)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, fmt.Errorf("work package %d not found", wpId)
+		}
+		return nil, err
+	}
+
+	// Mongo stores milliseconds, truncate so the returned ChangedDate matches the stored one
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	edits := []repository.ScheduleEdit{}
+	seen := map[ganttInstanceKey]bool{}
+
+	for i := range changes {
+		change := &changes[i]
+
+		if change.TaskSeq < 0 || (change.ExecutionInstanceSeq != nil && *change.ExecutionInstanceSeq < 0) {
+			return nil, fmt.Errorf("task %d: taskSeq and executionInstanceSeq must not be negative", change.TaskSeq)
+		}
+		if change.StartDate == nil || change.EndDate == nil {
+			return nil, fmt.Errorf("task %d: startDate and endDate are required", change.TaskSeq)
+		}
+		if change.EndDate.Before(*change.StartDate) {
+			return nil, fmt.Errorf("task %d: endDate is before startDate", change.TaskSeq)
+		}
+
+		key := ganttInstanceKey{wpId, change.TaskSeq, 0}
+		if change.ExecutionInstanceSeq != nil {
+			key.ExecutionInstanceSeq = *change.ExecutionInstanceSeq
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("task %d: edited more than once in the same request", change.TaskSeq)
+		}
+		seen[key] = true
+
+		task := findAvExeTask(&wp, change.TaskSeq)
+		if task == nil {
+			return nil, fmt.Errorf("task %d not found in work package %d", change.TaskSeq, wpId)
+		}
+
+		edit := repository.ScheduleEdit{
+			TaskSeq:     change.TaskSeq,
+			Start:       *change.StartDate,
+			End:         *change.EndDate,
+			ChangedDate: now,
+		}
+
+		if change.ExecutionInstanceSeq == nil {
+			// AvExeTask
+			if !isCurrentGanttEdit(change, task.ODataEtag, task.ChangedDate) {
+				return nil, errStaleGanttEdit
+			}
+			change.ETag = task.ODataEtag
+
+			duration := int(math.Round(change.EndDate.Sub(*change.StartDate).Hours()))
+			if change.Duration != nil {
+				duration = *change.Duration
+			}
+			change.Duration = &duration
+
+			edit.ExpectedChangedDate = task.ChangedDate
+			edit.Duration = duration
+		} else {
+			// JtExecutionInstance
+			instance := findExecutionInstance(task, *change.ExecutionInstanceSeq)
+			if instance == nil {
+				return nil, fmt.Errorf("execution instance %d not found in task %d", *change.ExecutionInstanceSeq, change.TaskSeq)
+			}
+			if !isCurrentGanttEdit(change, instance.ODataEtag, instance.ChangedDate) {
+				return nil, errStaleGanttEdit
+			}
+			change.ETag = instance.ODataEtag
+
+			edit.ExecutionInstanceSeq = change.ExecutionInstanceSeq
+			edit.ExpectedChangedDate = instance.ChangedDate
+		}
+
+		edits = append(edits, edit)
+		change.ChangedDate = &now
+	}
+
+	if err := s.workPackages(c).UpdateSchedule(c.Request.Context(), wpId, edits); err != nil {
+		if err == repository.ErrConflict {
+			return nil, errStaleGanttEdit
+		}
+		return nil, err
+	}
+
+	log.Printf("Applied %d Gantt changes to work package %d", len(changes), wpId)
+	return changes, nil
+}
+
+// queueGanttWriteBack queues the applied changes of a work package to be sent to IFS with the
+// etag of the version they were made on. The changes are already stored, so a queueing failure
+// is only logged. Without a write-back queue nothing is sent
+func (s *Server) queueGanttWriteBack(c *gin.Context, wpId int, changes []GanttTaskChange) {
+	if s.WriteBacks == nil {
+		return
+	}
+	username, _ := c.Get("username")
+	createdBy, _ := username.(string)
+
+	for _, change := range changes {
+		item := models.IFSWriteBack{
+			AircraftWorkPackageId: wpId,
+			TaskSeq:               change.TaskSeq,
+			ExecutionInstanceSeq:  change.ExecutionInstanceSeq,
+			ETag:                  change.ETag,
+			CreatedBy:             createdBy,
+		}
+		start, end := change.StartDate.UTC(), change.EndDate.UTC()
+		if change.ExecutionInstanceSeq == nil {
+			item.PlannedStart, item.PlannedFinish, item.Duration = &start, &end, change.Duration
+		} else {
+			item.AllocatedStart, item.AllocatedFinish = &start, &end
+		}
+
+		if err := s.WriteBacks.Enqueue(c.Request.Context(), item); err != nil {
+			log.Printf("Warning: Could not queue IFS write-back for task %d of work package %d: %v", change.TaskSeq, wpId, err)
+		}
+	}
+}
+
+// isCurrentGanttEdit reports whether the client edited the version that is stored.
+// Both the etag and ChangedDate are compared when the client sent them; a change without
+// either is never current, so it cannot blindly overwrite the stored version
+func isCurrentGanttEdit(change *GanttTaskChange, storedETag string, storedChangedDate *time.Time) bool {
+	if change.ETag == "" && change.ChangedDate == nil {
+		return false
+	}
+	if change.ETag != "" && change.ETag != storedETag {
+		return false
+	}
+	if change.ChangedDate != nil {
+		if storedChangedDate == nil || !change.ChangedDate.Equal(*storedChangedDate) {
+			return false
+		}
+	}
+	return true
+}
+
+// findAvExeTask returns the task with the given TaskSeq or nil
+func findAvExeTask(wp *models.AircraftWorkPackage, taskSeq int) *models.AvExeTask {
+	for i := range wp.Avexetask {
+		if wp.Avexetask[i].TaskSeq == taskSeq {
+			return &wp.Avexetask[i]
+		}
+	}
+	return nil
+}
+
+// findExecutionInstance returns the execution instance with the given sequence or nil
+func findExecutionInstance(task *models.AvExeTask, executionInstanceSeq int) *models.JtExecutionInstance {
+	for i := range task.JtExecutionInstanceArray {
+		if task.JtExecutionInstanceArray[i].ExecutionInstanceSeq == executionInstanceSeq {
+			return &task.JtExecutionInstanceArray[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/ifs"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSyncGanttQueuesWriteBackWithEditedETag(t *testing.T) {
+	wp := testWorkPackage(1)
+	wp.Avexetask[0].ODataEtag = `W/"1"`
+	// ChangedDate is compared with what the client loaded, the etag is not sent by this client
+	changed := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	wp.Avexetask[0].ChangedDate = &changed
+	workPackages := repository.NewMemoryWorkPackages(wp)
+
+	s := NewServer(testConfig(), workPackages, repository.NewMemoryUsers())
+	queue := repository.NewMemoryWriteBacks()
+	s.WriteBacks = queue
+
+	edit := func(changedDate time.Time, start time.Time) {
+		t.Helper()
+		end := start.Add(4 * time.Hour)
+		var req GanttSyncRequest
+		req.Tasks.Updated = []GanttTaskChange{{
+			AircraftWorkPackageId: 1,
+			TaskSeq:               1,
+			StartDate:             &start,
+			EndDate:               &end,
+			ChangedDate:           &changedDate,
+		}}
+		w := serve(s.SyncGantt, http.MethodPost, "/gantt/sync", "/gantt/sync", req, "planner", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("sync: got %d: %s", w.Code, w.Body)
+		}
+	}
+
+	edit(changed, time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC))
+	items, _ := queue.List(context.Background(), repository.WriteBackFilter{}, 0)
+	if len(items) != 1 || items[0].ETag != `W/"1"` || items[0].Status != ifs.WriteBackPending {
+		t.Fatalf("queue after the first edit = %+v", items)
+	}
+
+	// A second edit of the task replaces the pending change
+	stored, _ := workPackages.FindTask(context.Background(), 1, 1)
+	edit(*stored.Task.ChangedDate, time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+	items, _ = queue.List(context.Background(), repository.WriteBackFilter{}, 0)
+	if len(items) != 1 || !items[0].PlannedStart.Equal(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("queue after the second edit = %+v", items)
+	}
+}
+
+func TestRetryIFSWriteBackSupersededByPendingChange(t *testing.T) {
+	seq := func(v int) *int { return &v }
+	failed := models.IFSWriteBack{ID: primitive.NewObjectID(), AircraftWorkPackageId: 1, TaskSeq: 1, ETag: `W/"1"`, Status: ifs.WriteBackFailed}
+	conflict := models.IFSWriteBack{ID: primitive.NewObjectID(), AircraftWorkPackageId: 1, TaskSeq: 2, ExecutionInstanceSeq: seq(1), ETag: `W/"2"`, Status: ifs.WriteBackConflict}
+	pending := models.IFSWriteBack{ID: primitive.NewObjectID(), AircraftWorkPackageId: 1, TaskSeq: 1, ETag: `W/"1"`, Status: ifs.WriteBackPending}
+
+	s := NewServer(testConfig(), repository.NewMemoryWorkPackages(testWorkPackage(1)), repository.NewMemoryUsers())
+	queue := repository.NewMemoryWriteBacks(failed, conflict, pending)
+	s.WriteBacks = queue
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+		// Status of the retried item afterwards
+		status string
+	}{
+		{"later change pending", failed.ID.Hex(), http.StatusConflict, ifs.WriteBackSuperseded},
+		{"conflict", conflict.ID.Hex(), http.StatusOK, ifs.WriteBackPending},
+		{"already pending", pending.ID.Hex(), http.StatusNotFound, ifs.WriteBackPending},
+		{"invalid ID", "x", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		w := serve(s.RetryIFSWriteBack, http.MethodPost, "/ifs/write-back/"+tt.id+"/retry", "/ifs/write-back/:id/retry", nil, "planner", nil)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		if tt.status == "" {
+			continue
+		}
+		id, _ := primitive.ObjectIDFromHex(tt.id)
+		item, err := queue.FindByID(context.Background(), id)
+		if err != nil || item.Status != tt.status {
+			t.Errorf("%s: status %q, want %q (%v)", tt.name, item.Status, tt.status, err)
+		}
+	}
+}
+
+func TestSyncGanttDependencies(t *testing.T) {
+	jfk := testWorkPackage(2)
+	jfk.LocationCode = "JFK"
+	s := NewServer(testConfig(), repository.NewMemoryWorkPackages(testWorkPackage(1), jfk), repository.NewMemoryUsers())
+	deps := repository.NewMemoryDependencies()
+	s.Dependencies = deps
+	ctx := context.Background()
+	crossing := models.GanttDependency{ID: primitive.NewObjectID(), SourceAircraftWpId: 1, SourceTaskSeq: 1, TargetAircraftWpId: 2, TargetTaskSeq: 1, Type: "e2s"}
+	if err := deps.Create(ctx, crossing); err != nil {
+		t.Fatal(err)
+	}
+
+	sync := func(req GanttSyncRequest, scope *models.Scope) GanttSyncResponse {
+		t.Helper()
+		w := serve(s.SyncGantt, http.MethodPost, "/gantt/sync", "/gantt/sync", req, "planner", scope)
+		if w.Code != http.StatusOK {
+			t.Fatalf("sync: got %d: %s", w.Code, w.Body)
+		}
+		var response GanttSyncResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	// A user scoped to AMS can neither link into nor unlink from the JFK package
+	ams := models.Scope{LocationCodes: []string{"AMS"}}
+	var req GanttSyncRequest
+	req.Dependencies.Added = []models.GanttDependency{
+		{SourceAircraftWpId: 1, SourceTaskSeq: 1, TargetAircraftWpId: 1, TargetTaskSeq: 2},
+		{SourceAircraftWpId: 1, SourceTaskSeq: 2, TargetAircraftWpId: 2, TargetTaskSeq: 2},
+	}
+	req.Dependencies.Removed = []GanttDependencyRemoval{{ID: crossing.ID.Hex()}}
+	response := sync(req, &ams)
+	if response.Success || len(response.Dependencies) != 1 || len(response.Removed) != 0 || len(response.Rejected) != 2 {
+		t.Errorf("scoped sync = %+v", response)
+	}
+	if _, err := deps.FindByID(ctx, crossing.ID); err != nil {
+		t.Errorf("dependency removed by a user outside its scope: %v", err)
+	}
+	stored, _ := deps.Find(ctx, []int{2})
+	if len(stored) != 1 {
+		t.Errorf("dependencies of package 2 = %+v, want only the existing one", stored)
+	}
+
+	// Without a scope, links still need both tasks to exist
+	req = GanttSyncRequest{}
+	req.Dependencies.Added = []models.GanttDependency{
+		{SourceAircraftWpId: 1, SourceTaskSeq: 1, TargetAircraftWpId: 3, TargetTaskSeq: 1},
+		{SourceAircraftWpId: 1, SourceTaskSeq: 1, TargetAircraftWpId: 2, TargetTaskSeq: 9},
+	}
+	req.Dependencies.Removed = []GanttDependencyRemoval{{ID: crossing.ID.Hex()}}
+	response = sync(req, nil)
+	if len(response.Dependencies) != 0 || len(response.Rejected) != 2 || len(response.Removed) != 1 {
+		t.Errorf("unscoped sync = %+v", response)
+	}
+	if _, err := deps.FindByID(ctx, crossing.ID); err != repository.ErrNotFound {
+		t.Errorf("dependency after an unscoped removal: %v", err)
+	}
+}
+
+// racingWorkPackages edits task 1 of every work package after it was read, as a concurrent
+// request would between the read and the write of a sync
+type racingWorkPackages struct {
+	*repository.MemoryWorkPackages
+}
+
+func (r racingWorkPackages) FindByWorkPackageID(ctx context.Context, aircraftWorkPackageId int) (models.AircraftWorkPackage, error) {
+	wp, err := r.MemoryWorkPackages.FindByWorkPackageID(ctx, aircraftWorkPackageId)
+	if err != nil {
+		return wp, err
+	}
+	task := wp.Avexetask[0]
+	err = r.MemoryWorkPackages.UpdateSchedule(ctx, aircraftWorkPackageId, []repository.ScheduleEdit{{
+		TaskSeq:             task.TaskSeq,
+		ExpectedChangedDate: task.ChangedDate,
+		Start:               *task.PlannedStart,
+		End:                 *task.PlannedFinish,
+		ChangedDate:         time.Now().UTC(),
+	}})
+	return wp, err
+}
+
+func TestSyncGanttRejectsStaleEdits(t *testing.T) {
+	loaded := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	newWorkPackage := func() models.AircraftWorkPackage {
+		wp := testWorkPackage(1)
+		for i := range wp.Avexetask {
+			wp.Avexetask[i].ODataEtag = fmt.Sprintf(`W/"%d"`, i+1)
+			wp.Avexetask[i].ChangedDate = &loaded
+		}
+		return wp
+	}
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Minute)
+	earlier := loaded.Add(-time.Minute)
+	change := func(taskSeq int, etag string, changedDate *time.Time) GanttTaskChange {
+		return GanttTaskChange{AircraftWorkPackageId: 1, TaskSeq: taskSeq, StartDate: &start, EndDate: &end, ETag: etag, ChangedDate: changedDate}
+	}
+
+	tests := []struct {
+		name    string
+		changes []GanttTaskChange
+		racing  bool
+		applied int
+	}{
+		{"current etag", []GanttTaskChange{change(1, `W/"1"`, nil)}, false, 1},
+		{"current changedDate", []GanttTaskChange{change(1, "", &loaded)}, false, 1},
+		{"both tasks", []GanttTaskChange{change(1, `W/"1"`, &loaded), change(2, `W/"2"`, nil)}, false, 2},
+		{"wrong etag", []GanttTaskChange{change(1, `W/"2"`, nil)}, false, 0},
+		{"wrong changedDate", []GanttTaskChange{change(1, "", &earlier)}, false, 0},
+		{"current etag, wrong changedDate", []GanttTaskChange{change(1, `W/"1"`, &earlier)}, false, 0},
+		{"neither etag nor changedDate", []GanttTaskChange{change(1, "", nil)}, false, 0},
+		{"changed between read and write", []GanttTaskChange{change(1, `W/"1"`, nil)}, true, 0},
+		{"one stale change in the package", []GanttTaskChange{change(1, `W/"1"`, nil), change(2, `W/"1"`, nil)}, false, 0},
+		{"task edited twice", []GanttTaskChange{change(1, `W/"1"`, nil), change(1, "", &loaded)}, false, 0},
+	}
+	for _, tt := range tests {
+		memory := repository.NewMemoryWorkPackages(newWorkPackage())
+		var workPackages repository.WorkPackageRepository = memory
+		if tt.racing {
+			workPackages = racingWorkPackages{memory}
+		}
+		s := NewServer(testConfig(), workPackages, repository.NewMemoryUsers())
+
+		var req GanttSyncRequest
+		req.Tasks.Updated = tt.changes
+		w := serve(s.SyncGantt, http.MethodPost, "/gantt/sync", "/gantt/sync", req, "planner", nil)
+		var response GanttSyncResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", tt.name, w.Code, w.Body)
+		}
+		if len(response.Tasks) != tt.applied || len(response.Rejected) != len(tt.changes)-tt.applied {
+			t.Errorf("%s: applied %d, rejected %d, want %d applied", tt.name, len(response.Tasks), len(response.Rejected), tt.applied)
+		}
+
+		// A rejected package is left as it was, an applied change rounds the duration to hours
+		stored, _ := memory.FindByWorkPackageID(context.Background(), 1)
+		for _, task := range stored.Avexetask {
+			moved := task.PlannedStart.Equal(start)
+			if tt.applied == 0 && moved {
+				t.Errorf("%s: task %d written although the package was rejected", tt.name, task.TaskSeq)
+			}
+			if moved && (task.Duration == nil || *task.Duration != 2) {
+				t.Errorf("%s: task %d duration %v, want 2 hours", tt.name, task.TaskSeq, task.Duration)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"stationMonitor/internal/ifs"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// writeBacksEnabled answers 501 and returns false when the server has no write-back queue
+func (s *Server) writeBacksEnabled(c *gin.Context) bool {
+	if s.WriteBacks == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "IFS write-back is not enabled"})
+		return false
+	}
+	return true
+}
+
+// visibleWriteBackTasks limits filter to the work packages visible to the user and returns the
+// visible tasks, or nil if the user has no scope. ok is false if no work package is visible
+func (s *Server) visibleWriteBackTasks(c *gin.Context, filter *repository.WriteBackFilter) (visible map[ganttTaskKey]bool, ok bool, err error) {
+	workPackages := s.workPackages(c)
+	if workPackages == s.WorkPackages {
+		return nil, true, nil
+	}
+
+	found, err := workPackages.Find(c.Request.Context(), repository.WorkPackageFilter{WorkPackageIds: filter.WorkPackageIds}, repository.FindOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	visible = map[ganttTaskKey]bool{}
+	filter.WorkPackageIds = []int{}
+	for _, wp := range found {
+		filter.WorkPackageIds = append(filter.WorkPackageIds, wp.AircraftWorkPackageId)
+		for _, task := range wp.Avexetask {
+			visible[ganttTaskKey{AircraftWpId: wp.AircraftWorkPackageId, TaskSeq: task.TaskSeq}] = true
+		}
+	}
+	return visible, len(found) > 0, nil
+}
+
+// GetIFSWriteBackQueue lists queued IFS write-backs, newest first. Users with a scope only see
+// the write-backs of the tasks within it.
+// Optional filters: status, aircraftWorkPackageId
+func (s *Server) GetIFSWriteBackQueue(c *gin.Context) {
+	if !s.writeBacksEnabled(c) {
+		return
+	}
+
+	filter := repository.WriteBackFilter{Statuses: queryStringList(c, "status")}
+	var err error
+	if filter.WorkPackageIds, err = queryIntList(c, "aircraftWorkPackageId"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	visible, ok, err := s.visibleWriteBackTasks(c, &filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+		return
+	}
+	items := []models.IFSWriteBack{}
+	if ok {
+		if items, err = s.WriteBacks.List(c.Request.Context(), filter, 500); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+			return
+		}
+	}
+	if visible != nil {
+		filtered := []models.IFSWriteBack{}
+		for _, item := range items {
+			if visible[ganttTaskKey{AircraftWpId: item.AircraftWorkPackageId, TaskSeq: item.TaskSeq}] {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  items,
+		"count": len(items),
+	})
+}
+
+// RetryIFSWriteBack puts a failed or conflicting write-back back into the queue. Users with a
+// scope can only retry the write-backs of the tasks within it
+func (s *Server) RetryIFSWriteBack(c *gin.Context) {
+	if !s.writeBacksEnabled(c) {
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if workPackages := s.workPackages(c); workPackages != s.WorkPackages {
+		item, err := s.WriteBacks.FindByID(ctx, objectID)
+		if err == nil {
+			_, err = workPackages.FindTask(ctx, item.AircraftWorkPackageId, item.TaskSeq)
+		}
+		if err != nil {
+			if err == repository.ErrNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "No failed write-back with this ID"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+			return
+		}
+	}
+
+	if err := s.WriteBacks.Retry(ctx, objectID, time.Now().UTC()); err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No failed write-back with this ID"})
+			return
+		}
+		if err == ifs.ErrWriteBackSuperseded {
+			c.JSON(http.StatusConflict, gin.H{"error": "A later change of this task is already queued; the write-back was superseded"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Write-back queued for retry"})
+}
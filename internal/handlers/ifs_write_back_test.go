@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"stationMonitor/internal/ifs"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// failedWriteBack returns a failed write-back of a task
+func failedWriteBack(wpId, taskSeq int) models.IFSWriteBack {
+	return models.IFSWriteBack{ID: primitive.NewObjectID(), AircraftWorkPackageId: wpId, TaskSeq: taskSeq, ETag: `W/"1"`, Status: ifs.WriteBackFailed}
+}
+
+func TestIFSWriteBackQueueScope(t *testing.T) {
+	jfk := testWorkPackage(2)
+	jfk.LocationCode = "JFK"
+	hangar := testWorkPackage(3)
+	hangar.Avexetask[0].Site = "HANGAR2"
+	hangar.Avexetask[1].Site = "HANGAR3"
+	s := NewServer(testConfig(), repository.NewMemoryWorkPackages(testWorkPackage(1), jfk, hangar), repository.NewMemoryUsers())
+
+	ams := failedWriteBack(1, 1)
+	other := failedWriteBack(2, 1)
+	hangar2 := failedWriteBack(3, 1)
+	hangar3 := failedWriteBack(3, 2)
+	s.WriteBacks = repository.NewMemoryWriteBacks(ams, other, hangar2, hangar3)
+
+	list := func(path string, scope *models.Scope) []models.IFSWriteBack {
+		t.Helper()
+		w := serve(s.GetIFSWriteBackQueue, http.MethodGet, path, "/ifs/write-back", nil, "planner", scope)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list %s: got %d: %s", path, w.Code, w.Body)
+		}
+		var body struct {
+			Data []models.IFSWriteBack `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Data
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		scope *models.Scope
+		want  []primitive.ObjectID
+	}{
+		{"no scope", "/ifs/write-back", nil, []primitive.ObjectID{ams.ID, other.ID, hangar2.ID, hangar3.ID}},
+		{"location", "/ifs/write-back", &models.Scope{LocationCodes: []string{"JFK"}}, []primitive.ObjectID{other.ID}},
+		{"location and filter outside it", "/ifs/write-back?aircraftWorkPackageId=1", &models.Scope{LocationCodes: []string{"JFK"}}, nil},
+		{"site", "/ifs/write-back", &models.Scope{Sites: []string{"HANGAR2"}}, []primitive.ObjectID{hangar2.ID}},
+		{"deny all", "/ifs/write-back", &models.DenyAllScope, nil},
+	}
+	for _, tt := range tests {
+		got := map[primitive.ObjectID]bool{}
+		for _, item := range list(tt.path, tt.scope) {
+			got[item.ID] = true
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d items, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for _, id := range tt.want {
+			if !got[id] {
+				t.Errorf("%s: item %s missing", tt.name, id.Hex())
+			}
+		}
+	}
+
+	retry := func(id primitive.ObjectID, scope *models.Scope) int {
+		return serve(s.RetryIFSWriteBack, http.MethodPost, "/ifs/write-back/"+id.Hex()+"/retry", "/ifs/write-back/:id/retry", nil, "planner", scope).Code
+	}
+	if got := retry(other.ID, &models.Scope{LocationCodes: []string{"AMS"}}); got != http.StatusNotFound {
+		t.Errorf("retry outside the scope: got %d, want 404", got)
+	}
+	if got := retry(hangar3.ID, &models.Scope{Sites: []string{"HANGAR2"}}); got != http.StatusNotFound {
+		t.Errorf("retry of a task outside the scope: got %d, want 404", got)
+	}
+	if got := retry(ams.ID, &models.Scope{LocationCodes: []string{"AMS"}}); got != http.StatusOK {
+		t.Errorf("retry within the scope: got %d, want 200", got)
+	}
+}
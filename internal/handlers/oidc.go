@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/oidc"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// oidcStateTTL is how long a user has to log in at the provider
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	// errOIDCNoEmail is returned when a new user cannot be created because the provider sent no email
+	errOIDCNoEmail = errors.New("the identity provider did not return an email address")
+	// errOIDCEmailTaken is returned when a user with the email exists but the provider did not verify it
+	errOIDCEmailTaken = errors.New("an account with this email already exists; it is only linked if the identity provider reports the email as verified")
+)
+
+// oidcStateCookie binds a login to the browser that started it. The callback only accepts the
+// state stored in it, so a code obtained by someone else cannot be completed in this browser
+const oidcStateCookie = "oidc_state"
+
+// oidcState travels through the provider in the state parameter. It is signed, so the callback
+// can trust the nonce and redirect URI without storing them server-side
+type oidcState struct {
+	Provider    string `json:"prv"`
+	Nonce       string `json:"nonce"`
+	RedirectURL string `json:"redirect_uri"`
+	jwt.RegisteredClaims
+}
+
+// oidcStateKey derives the key states are signed with from the JWT secret, so a state can never
+// pass as an access token or the other way round
+func oidcStateKey(cfg *config.Config) []byte {
+	sum := sha256.Sum256([]byte("oidc-state:" + cfg.JWT.Secret))
+	return sum[:]
+}
+
+// findOIDCProvider returns the configuration of the provider named by the provider path parameter.
+// On failure the error response has already been written and ok is false
+func (s *Server) findOIDCProvider(c *gin.Context) (provider config.OIDCProvider, ok bool) {
+	for _, provider := range s.Config.OIDC.Providers {
+		if provider.Name == c.Param("provider") {
+			return provider, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider: " + c.Param("provider")})
+	return provider, false
+}
+
+// oidcRedirectURL returns the redirect URL a login asked for, which must be one configured for
+// the provider. Without one the provider's redirect_url is used
+func oidcRedirectURL(provider config.OIDCProvider, requested string) (string, bool) {
+	if requested == "" || requested == provider.RedirectURL {
+		return provider.RedirectURL, true
+	}
+	for _, allowed := range provider.RedirectURLs {
+		if requested == allowed {
+			return allowed, true
+		}
+	}
+	return "", false
+}
+
+// setOIDCStateCookie stores the state of a login in the browser, or removes it when state is empty
+func setOIDCStateCookie(c *gin.Context, state string) {
+	maxAge := int(oidcStateTTL.Seconds())
+	if state == "" {
+		maxAge = -1
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, "/api/auth/oidc/", "", c.Request.TLS != nil, true)
+}
+
+// GetAuthProviders lists the identity providers users can log in with, for the login page
+func (s *Server) GetAuthProviders(c *gin.Context) {
+	cfg := s.Config
+	providers := []gin.H{}
+	for _, provider := range cfg.OIDC.Providers {
+		providers = append(providers, gin.H{
+			"name":        provider.Name,
+			"displayName": provider.DisplayName,
+			"loginUrl":    "/api/auth/oidc/" + provider.Name,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"providers": providers,
+		"google":    cfg.GoogleOAuth.ClientID != "" && cfg.GoogleOAuth.ClientSecret != "",
+	})
+}
+
+// OIDCLogin starts a login at an OpenID Connect provider. It returns the URL of the provider's
+// login page and the state the callback must be called with
+func (s *Server) OIDCLogin(c *gin.Context) {
+	providerCfg, ok := s.findOIDCProvider(c)
+	if !ok {
+		return
+	}
+	if s.OIDC == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "OIDC login is not enabled"})
+		return
+	}
+
+	provider, err := s.OIDC.Provider(c.Request.Context(), providerCfg)
+	if err != nil {
+		log.Printf("OIDC provider %s unavailable: %v", providerCfg.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	redirectURL, ok := oidcRedirectURL(providerCfg, c.Query("redirect_url"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "redirect_url is not configured for this identity provider"})
+		return
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	now := time.Now()
+	state, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &oidcState{
+		Provider:    providerCfg.Name,
+		Nonce:       nonce,
+		RedirectURL: redirectURL,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcStateTTL)),
+		},
+	}).SignedString(oidcStateKey(s.Config))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	setOIDCStateCookie(c, state)
+	c.JSON(http.StatusOK, gin.H{
+		"auth_url": provider.AuthCodeURL(redirectURL, state, nonce),
+		"state":    state,
+	})
+}
+
+// OIDCCallback completes a login: it checks the state against the one stored in the browser by
+// OIDCLogin, redeems the code, verifies the ID token and logs in the user, creating or linking
+// the account on first login
+func (s *Server) OIDCCallback(c *gin.Context) {
+	providerCfg, ok := s.findOIDCProvider(c)
+	if !ok {
+		return
+	}
+	if s.OIDC == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "OIDC login is not enabled"})
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login failed: " + providerError, "message": c.Query("error_description")})
+		return
+	}
+
+	stateString := c.Query("state")
+	if stateString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "State parameter required"})
+		return
+	}
+	state := &oidcState{}
+	_, err := jwt.ParseWithClaims(stateString, state, func(token *jwt.Token) (interface{}, error) {
+		return oidcStateKey(s.Config), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil || state.Provider != providerCfg.Name {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state token"})
+		return
+	}
+	if stateCookie, err := c.Cookie(oidcStateCookie); err != nil || stateCookie != stateString {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login was not started in this browser"})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code not provided"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	provider, err := s.OIDC.Provider(ctx, providerCfg)
+	if err != nil {
+		log.Printf("OIDC provider %s unavailable: %v", providerCfg.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+	identity, err := provider.Exchange(ctx, code, state.RedirectURL, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC login with %s failed: %v", providerCfg.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed: " + err.Error()})
+		return
+	}
+
+	user, err := s.oidcUser(ctx, provider, identity)
+	if err != nil {
+		if err == errOIDCNoEmail {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == errOIDCEmailTaken {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("OIDC login with %s: %v", providerCfg.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	setOIDCStateCookie(c, "")
+
+	if wantsJSON(c) {
+		tokens, err := s.issueTokens(ctx, user, "")
+		if err != nil {
+			log.Printf("Failed to generate token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, models.LoginResponse{
+			Token:        tokens.Token,
+			RefreshToken: tokens.RefreshToken,
+			ExpiresIn:    tokens.ExpiresIn,
+			User:         user,
+		})
+		return
+	}
+
+	// A browser sent here by the provider goes on to the frontend with a login code, which the
+	// frontend exchanges for the tokens with POST /api/auth/exchange
+	if s.Tokens == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Login codes are not enabled"})
+		return
+	}
+	loginCode, err := s.issueLoginCode(ctx, user)
+	if err != nil {
+		log.Printf("Failed to issue login code: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	query := url.Values{}
+	query.Set("login_code", loginCode)
+	query.Set("provider", providerCfg.Name)
+	c.Redirect(http.StatusTemporaryRedirect, s.Config.OIDC.CallbackURL+"?"+query.Encode())
+}
+
+// oidcUser returns the user linked to an identity. On first login an existing user with the same
+// verified email is linked, else a user is created that sees no data until an admin assigns it a
+// scope. If the provider has a role claim, the roles of the user are set from it on every login
+func (s *Server) oidcUser(ctx context.Context, provider *oidc.Provider, identity oidc.Identity) (models.User, error) {
+	providerName := provider.Config.Name
+	externalID := providerName + ":" + identity.Subject
+
+	user, err := s.Users.FindByExternalID(ctx, externalID)
+	if err == repository.ErrNotFound && identity.Email != "" && identity.EmailVerified {
+		user, err = s.Users.FindByEmail(ctx, identity.Email)
+		if err == nil {
+			user.ExternalID = externalID
+			user.Provider = providerName
+			if user.Name == "" {
+				user.Name = identity.Name
+			}
+			if user.Picture == "" {
+				user.Picture = identity.Picture
+			}
+			if provider.Config.RoleClaim != "" {
+				user.Roles = provider.Roles(identity)
+			}
+			if err := s.Users.Update(ctx, user); err != nil {
+				return user, fmt.Errorf("linking %s to user %s: %w", externalID, user.Email, err)
+			}
+			log.Printf("Linked %s account %s to user %s", providerName, identity.Subject, user.Email)
+			return user, nil
+		}
+	}
+	if err == repository.ErrNotFound {
+		if identity.Email == "" {
+			return user, errOIDCNoEmail
+		}
+		user = models.User{
+			ID:         primitive.NewObjectID(),
+			Email:      identity.Email,
+			Username:   identity.Email,
+			ExternalID: externalID,
+			Provider:   providerName,
+			Name:       identity.Name,
+			Picture:    identity.Picture,
+			Roles:      provider.Roles(identity),
+			Scope:      models.DenyAllScope,
+		}
+		if err := s.Users.Create(ctx, user); err != nil {
+			if err == repository.ErrDuplicate {
+				return user, errOIDCEmailTaken
+			}
+			return user, fmt.Errorf("creating user %s: %w", user.Email, err)
+		}
+		log.Printf("Created user %s from %s with roles %v", user.Email, providerName, user.Roles)
+		return user, nil
+	}
+	if err != nil {
+		return user, err
+	}
+
+	if provider.Config.RoleClaim != "" {
+		roles := provider.Roles(identity)
+		if strings.Join(roles, ",") != strings.Join(user.Roles, ",") {
+			user.Roles = roles
+			if err := s.Users.Update(ctx, user); err != nil {
+				return user, fmt.Errorf("updating roles of %s: %w", user.Email, err)
+			}
+			log.Printf("Roles of %s set to %v by %s", user.Email, roles, providerName)
+		}
+	}
+	return user, nil
+}
+
+// wantsJSON reports whether a request comes from the frontend rather than a browser redirect
+func wantsJSON(c *gin.Context) bool {
+	accept := c.GetHeader("Accept")
+	return c.GetHeader("X-Requested-With") == "XMLHttpRequest" ||
+		strings.HasPrefix(accept, "application/json") || accept == "*/*"
+}
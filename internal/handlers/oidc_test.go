@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/oidc"
+	"stationMonitor/internal/oidc/oidctest"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcTestServer returns a router serving the OIDC login of a stand-in provider named test
+func oidcTestServer(t *testing.T) (*gin.Engine, *Server) {
+	t.Helper()
+	idp := oidctest.NewServer("station-monitor", "secret")
+	t.Cleanup(idp.Close)
+
+	cfg := testConfig()
+	cfg.OIDC.CallbackURL = "http://localhost:3000/auth/oidc/callback"
+	cfg.OIDC.Providers = []config.OIDCProvider{{
+		Name:         "test",
+		Issuer:       idp.Issuer,
+		DiscoveryURL: idp.Issuer + "/.well-known/openid-configuration",
+		ClientID:     "station-monitor",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:3000/auth/oidc/test/callback",
+		RedirectURLs: []string{"https://planning.example.com/auth/oidc/test/callback"},
+		Scopes:       []string{"openid", "email"},
+		DefaultRoles: []string{"viewer"},
+	}}
+	s := NewServer(cfg, repository.NewMemoryWorkPackages(), repository.NewMemoryUsers())
+	s.OIDC = oidc.NewRegistry(http.DefaultClient)
+
+	router := gin.New()
+	router.GET("/api/auth/oidc/:provider", s.OIDCLogin)
+	router.GET("/api/auth/oidc/:provider/callback", s.OIDCCallback)
+	router.POST("/api/auth/exchange", s.ExchangeLoginCode)
+	return router, s
+}
+
+// oidcLogin is a login started with OIDCLogin and completed at the provider
+type oidcLogin struct {
+	cookie *http.Cookie
+	code   string
+	state  string
+}
+
+// startOIDCLogin starts a login and follows the provider's redirect back with the code
+func startOIDCLogin(t *testing.T, router *gin.Engine) oidcLogin {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/test", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: got %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		AuthURL string `json:"auth_url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	var login oidcLogin
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oidcStateCookie {
+			login.cookie = cookie
+		}
+	}
+	if login.cookie == nil || !login.cookie.HttpOnly {
+		t.Fatalf("login set no HttpOnly state cookie: %v", w.Result().Cookies())
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(body.AuthURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	login.code, login.state = location.Query().Get("code"), location.Query().Get("state")
+	return login
+}
+
+// callOIDCCallback calls the callback with the code and state, sending cookie if not nil
+func callOIDCCallback(router *gin.Engine, login oidcLogin, cookie *http.Cookie, accept string) *httptest.ResponseRecorder {
+	query := url.Values{"code": {login.code}, "state": {login.state}}
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/test/callback?"+query.Encode(), nil)
+	req.Header.Set("Accept", accept)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// completeOIDCLogin calls the callback as the frontend does, asking for JSON
+func completeOIDCLogin(router *gin.Engine, login oidcLogin, cookie *http.Cookie) *httptest.ResponseRecorder {
+	return callOIDCCallback(router, login, cookie, "application/json")
+}
+
+func TestOIDCLoginRedirectURL(t *testing.T) {
+	router, _ := oidcTestServer(t)
+
+	tests := []struct {
+		redirectURL string
+		want        int
+	}{
+		{"", http.StatusOK},
+		{"http://localhost:3000/auth/oidc/test/callback", http.StatusOK},
+		{"https://planning.example.com/auth/oidc/test/callback", http.StatusOK},
+		{"https://evil.example.com/auth/oidc/test/callback", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/test?"+url.Values{"redirect_url": {tt.redirectURL}}.Encode(), nil))
+		if w.Code != tt.want {
+			t.Errorf("redirect_url %q: got %d, want %d", tt.redirectURL, w.Code, tt.want)
+		}
+	}
+}
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	router, s := oidcTestServer(t)
+
+	login := startOIDCLogin(t, router)
+	other := startOIDCLogin(t, router)
+
+	if w := completeOIDCLogin(router, login, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("without the cookie: got %d, want 400", w.Code)
+	}
+	if w := completeOIDCLogin(router, login, other.cookie); w.Code != http.StatusBadRequest {
+		t.Errorf("with the cookie of another login: got %d, want 400", w.Code)
+	}
+
+	w := completeOIDCLogin(router, login, login.cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("with the cookie: got %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Token == "" {
+		t.Errorf("callback response = %s", w.Body)
+	}
+	if user, err := s.Users.FindByEmail(context.Background(), "user@example.com"); err != nil || !user.Scope.DenyAll {
+		t.Errorf("created user = %+v, %v, want one that sees no data", user, err)
+	}
+
+	// The code was redeemed, so the login cannot be completed again
+	if w := completeOIDCLogin(router, login, login.cookie); w.Code != http.StatusUnauthorized {
+		t.Errorf("completing the login twice: got %d, want 401", w.Code)
+	}
+}
+
+func TestOIDCCallbackRedirectsWithLoginCode(t *testing.T) {
+	router, s := oidcTestServer(t)
+	s.Tokens = repository.NewMemoryTokens()
+
+	login := startOIDCLogin(t, router)
+	w := callOIDCCallback(router, login, login.cookie, "text/html")
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("browser callback: got %d: %s", w.Code, w.Body)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := location.Query()
+	if query.Has("token") || query.Has("refresh_token") || query.Get("login_code") == "" {
+		t.Fatalf("redirect = %s, want a login code and no tokens", location)
+	}
+
+	w = postJSON(router, "/api/auth/exchange", models.LoginCodeRequest{Code: query.Get("login_code")}, "")
+	if tokens := decodeTokens(t, w); tokens.Token == "" {
+		t.Errorf("exchange response = %s", w.Body)
+	}
+}
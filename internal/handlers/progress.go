@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"stationMonitor/internal/models"
+)
+
+// maxUnfinishedProgress caps estimated progress, so a task is only shown as 100% once IFS reports it done
+const maxUnfinishedProgress = 0.99
+
+// ProgressCalculator derives progress from the IFS status and reported hours.
+// Now is only used for started work that has no hours reported yet, and can be
+// replaced to make the result deterministic
+type ProgressCalculator struct {
+	Now func() time.Time
+}
+
+// NewProgressCalculator returns a calculator using the given clock (time.Now if nil)
+func NewProgressCalculator(now func() time.Time) ProgressCalculator {
+	if now == nil {
+		now = time.Now
+	}
+	return ProgressCalculator{Now: now}
+}
+
+// ganttProgress is the calculator used by the Prepare* functions
+var ganttProgress = NewProgressCalculator(time.Now)
+
+// isCompletedState reports whether an Objstate means the work is done
+func isCompletedState(objstate string) bool {
+	switch strings.ToLower(objstate) {
+	case "completed", "closed", "released", "finished":
+		return true
+	}
+	return false
+}
+
+// isCancelledState reports whether an Objstate means the work will not be done
+func isCancelledState(objstate string) bool {
+	switch strings.ToLower(objstate) {
+	case "cancelled", "canceled":
+		return true
+	}
+	return false
+}
+
+// clampProgress limits unfinished progress to [0, maxUnfinishedProgress]
+func clampProgress(progress float64) float64 {
+	return math.Max(0, math.Min(progress, maxUnfinishedProgress))
+}
+
+// elapsedProgress estimates progress from the time elapsed since start against the planned span
+func (p ProgressCalculator) elapsedProgress(start time.Time, plannedStart, plannedEnd *time.Time) float64 {
+	if plannedStart == nil || plannedEnd == nil || !plannedEnd.After(*plannedStart) {
+		return 0
+	}
+	return clampProgress(p.Now().Sub(start).Hours() / plannedEnd.Sub(*plannedStart).Hours())
+}
+
+// InstanceProgress returns the progress (0-1) of an execution instance.
+// ok is false for cancelled instances, which do not count towards their task. In order:
+//   - WorkFinish set: done
+//   - ActualWorkedHours and a time to complete (ModifiedTimeToComplete, else TimeToCompletion): worked / (worked + remaining)
+//   - ActualWorkedHours only: worked / AllocatedHours
+//   - time to complete only: 1 - remaining / AllocatedHours
+//   - WorkStart set: elapsed time against the allocated span
+func (p ProgressCalculator) InstanceProgress(instance models.JtExecutionInstance) (progress float64, ok bool) {
+	if instance.CancelCause != nil && *instance.CancelCause != "" {
+		return 0, false
+	}
+	if instance.WorkFinish != nil {
+		return 1, true
+	}
+
+	worked := instance.ActualWorkedHours
+	remaining := instance.ModifiedTimeToComplete
+	if remaining == nil {
+		remaining = instance.TimeToCompletion
+	}
+	allocated := 0.0
+	if instance.AllocatedHours != nil {
+		allocated = *instance.AllocatedHours
+	}
+
+	switch {
+	case worked != nil && remaining != nil && *worked+*remaining > 0:
+		return clampProgress(*worked / (*worked + *remaining)), true
+	case worked != nil && allocated > 0:
+		return clampProgress(*worked / allocated), true
+	case remaining != nil && allocated > 0:
+		return clampProgress(1 - *remaining/allocated), true
+	case instance.WorkStart != nil:
+		return p.elapsedProgress(*instance.WorkStart, instance.AllocatedStart, instance.AllocatedFinish), true
+	}
+	return 0, true
+}
+
+// instanceWeight is the weight of an instance in its task: its allocated hours, else its allocated span
+func instanceWeight(instance models.JtExecutionInstance) float64 {
+	if instance.AllocatedHours != nil && *instance.AllocatedHours > 0 {
+		return *instance.AllocatedHours
+	}
+	if instance.AllocatedStart != nil && instance.AllocatedFinish != nil && instance.AllocatedFinish.After(*instance.AllocatedStart) {
+		return instance.AllocatedFinish.Sub(*instance.AllocatedStart).Hours()
+	}
+	return 1
+}
+
+// TaskProgress returns the progress (0-1) of an AvExeTask.
+// ok is false for cancelled tasks, which do not count towards their work package.
+// A completed Objstate or ActualFinish means done; otherwise the execution instances are
+// rolled up weighted by their allocated hours. A started task without instance data
+// is estimated from the time elapsed since ActualStart
+func (p ProgressCalculator) TaskProgress(task models.AvExeTask) (progress float64, ok bool) {
+	if isCancelledState(task.Objstate) {
+		return 0, false
+	}
+	if isCompletedState(task.Objstate) || task.ActualFinish != nil {
+		return 1, true
+	}
+
+	weighted, totalWeight := 0.0, 0.0
+	for _, instance := range task.JtExecutionInstanceArray {
+		instanceProgress, counted := p.InstanceProgress(instance)
+		if !counted {
+			continue
+		}
+		weight := instanceWeight(instance)
+		weighted += instanceProgress * weight
+		totalWeight += weight
+	}
+	if totalWeight > 0 {
+		return clampProgress(weighted / totalWeight), true
+	}
+
+	if task.ActualStart != nil {
+		taskStart, taskEnd := avExeTaskDates(task)
+		return p.elapsedProgress(*task.ActualStart, taskStart, taskEnd), true
+	}
+	return 0, true
+}
+
+// taskWeight is the weight of a task in its work package: its planned span in hours, else Duration
+func taskWeight(task models.AvExeTask) float64 {
+	taskStart, taskEnd := avExeTaskDates(task)
+	if taskStart != nil && taskEnd != nil && taskEnd.After(*taskStart) {
+		return taskEnd.Sub(*taskStart).Hours()
+	}
+	if task.Duration != nil && *task.Duration > 0 {
+		return float64(*task.Duration)
+	}
+	return 1
+}
+
+// WorkPackageProgress returns the progress (0-1) of a work package: done if its Objstate is
+// completed, otherwise the progress of its tasks weighted by duration
+func (p ProgressCalculator) WorkPackageProgress(wp models.AircraftWorkPackage) float64 {
+	if isCompletedState(wp.Objstate) {
+		return 1
+	}
+
+	weighted, totalWeight := 0.0, 0.0
+	for _, task := range wp.Avexetask {
+		taskProgress, counted := p.TaskProgress(task)
+		if !counted {
+			continue
+		}
+		weight := taskWeight(task)
+		weighted += taskProgress * weight
+		totalWeight += weight
+	}
+	if totalWeight == 0 {
+		return 0
+	}
+	return weighted / totalWeight
+}
+
+// progressPercent converts a progress fraction into the whole percentage used by the Gantt
+func progressPercent(progress float64) int {
+	return int(math.Floor(progress*100 + 1e-9))
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/models"
+)
+
+// progressBase is the allocated start of the work in the progress tests; the clock is 4 hours later
+var progressBase = time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+
+// progressAt returns a time the given number of hours after progressBase
+func progressAt(hours float64) *time.Time {
+	at := progressBase.Add(time.Duration(hours * float64(time.Hour)))
+	return &at
+}
+
+// hoursPtr returns a pointer to a number of hours
+func hoursPtr(hours float64) *float64 {
+	return &hours
+}
+
+// testProgress is a calculator whose clock stands 4 hours after progressBase
+var testProgress = NewProgressCalculator(func() time.Time { return *progressAt(4) })
+
+// progressInstance returns an instance allocated 8 hours from progressBase
+func progressInstance() models.JtExecutionInstance {
+	return models.JtExecutionInstance{AllocatedStart: progressAt(0), AllocatedFinish: progressAt(8), AllocatedHours: hoursPtr(8)}
+}
+
+func TestInstanceProgress(t *testing.T) {
+	cancelCause := "Not needed"
+	tests := []struct {
+		name   string
+		modify func(*models.JtExecutionInstance)
+		want   float64
+		wantOk bool
+	}{
+		{"cancelled", func(i *models.JtExecutionInstance) { i.CancelCause = &cancelCause }, 0, false},
+		{"finished", func(i *models.JtExecutionInstance) { i.WorkFinish = progressAt(2) }, 1, true},
+		{"worked and remaining", func(i *models.JtExecutionInstance) {
+			i.ActualWorkedHours, i.TimeToCompletion = hoursPtr(3), hoursPtr(1)
+		}, 0.75, true},
+		{"modified remaining wins", func(i *models.JtExecutionInstance) {
+			i.ActualWorkedHours, i.ModifiedTimeToComplete, i.TimeToCompletion = hoursPtr(2), hoursPtr(2), hoursPtr(6)
+		}, 0.5, true},
+		{"worked against allocated", func(i *models.JtExecutionInstance) { i.ActualWorkedHours = hoursPtr(2) }, 0.25, true},
+		{"worked beyond allocated", func(i *models.JtExecutionInstance) { i.ActualWorkedHours = hoursPtr(10) }, maxUnfinishedProgress, true},
+		{"remaining against allocated", func(i *models.JtExecutionInstance) { i.TimeToCompletion = hoursPtr(6) }, 0.25, true},
+		{"started, elapsed time", func(i *models.JtExecutionInstance) { i.WorkStart = progressAt(0) }, 0.5, true},
+		{"started, overrun", func(i *models.JtExecutionInstance) { i.WorkStart = progressAt(-8) }, maxUnfinishedProgress, true},
+		{"started without allocated span", func(i *models.JtExecutionInstance) {
+			i.WorkStart, i.AllocatedFinish = progressAt(0), nil
+		}, 0, true},
+		{"not started", func(i *models.JtExecutionInstance) {}, 0, true},
+	}
+	for _, tt := range tests {
+		instance := progressInstance()
+		tt.modify(&instance)
+		got, ok := testProgress.InstanceProgress(instance)
+		if ok != tt.wantOk || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestTaskProgress(t *testing.T) {
+	cancelCause := "Not needed"
+	done := progressInstance()
+	done.AllocatedHours = hoursPtr(6)
+	done.WorkFinish = progressAt(6)
+	open := progressInstance()
+	open.AllocatedHours = hoursPtr(2)
+	cancelled := progressInstance()
+	cancelled.CancelCause = &cancelCause
+
+	tests := []struct {
+		name   string
+		task   models.AvExeTask
+		want   float64
+		wantOk bool
+	}{
+		{"cancelled", models.AvExeTask{Objstate: "Cancelled"}, 0, false},
+		{"completed state", models.AvExeTask{Objstate: "Completed"}, 1, true},
+		{"actual finish", models.AvExeTask{Objstate: "Started", ActualFinish: progressAt(3)}, 1, true},
+		{"instances weighted by allocated hours", models.AvExeTask{
+			Objstate:                 "Started",
+			JtExecutionInstanceArray: []models.JtExecutionInstance{done, open},
+		}, 0.75, true},
+		{"cancelled instance ignored", models.AvExeTask{
+			Objstate:                 "Started",
+			JtExecutionInstanceArray: []models.JtExecutionInstance{done, cancelled},
+		}, maxUnfinishedProgress, true},
+		{"started without instances", models.AvExeTask{
+			Objstate:      "Started",
+			ActualStart:   progressAt(0),
+			PlannedStart:  progressAt(0),
+			PlannedFinish: progressAt(8),
+		}, 0.5, true},
+		{"not started", models.AvExeTask{Objstate: "Planned", PlannedStart: progressAt(0), PlannedFinish: progressAt(8)}, 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := testProgress.TaskProgress(tt.task)
+		if ok != tt.wantOk || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestWorkPackageProgress(t *testing.T) {
+	completed := models.AvExeTask{Objstate: "Completed", PlannedStart: progressAt(0), PlannedFinish: progressAt(6)}
+	planned := models.AvExeTask{Objstate: "Planned", PlannedStart: progressAt(6), PlannedFinish: progressAt(8)}
+	cancelled := models.AvExeTask{Objstate: "Cancelled", PlannedStart: progressAt(0), PlannedFinish: progressAt(8)}
+	duration := 6
+	undated := models.AvExeTask{Objstate: "Completed", Duration: &duration}
+
+	tests := []struct {
+		name string
+		wp   models.AircraftWorkPackage
+		want float64
+	}{
+		{"completed state", models.AircraftWorkPackage{Objstate: "Completed", Avexetask: []models.AvExeTask{planned}}, 1},
+		{"tasks weighted by planned span", models.AircraftWorkPackage{Objstate: "Started", Avexetask: []models.AvExeTask{completed, planned}}, 0.75},
+		{"cancelled task ignored", models.AircraftWorkPackage{Objstate: "Started", Avexetask: []models.AvExeTask{completed, cancelled}}, 1},
+		{"duration without dates", models.AircraftWorkPackage{Objstate: "Started", Avexetask: []models.AvExeTask{undated, planned}}, 0.75},
+		{"no tasks", models.AircraftWorkPackage{Objstate: "Planned"}, 0},
+	}
+	for _, tt := range tests {
+		if got := testProgress.WorkPackageProgress(tt.wp); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The clock only matters for started work without reported hours
+	started := models.AvExeTask{Objstate: "Started", ActualStart: progressAt(0), PlannedStart: progressAt(0), PlannedFinish: progressAt(8)}
+	wp := models.AircraftWorkPackage{Objstate: "Started", Avexetask: []models.AvExeTask{started}}
+	later := NewProgressCalculator(func() time.Time { return *progressAt(6) })
+	if got := later.WorkPackageProgress(wp); math.Abs(got-0.75) > 1e-9 {
+		t.Errorf("started work 6 hours in: got %v, want 0.75", got)
+	}
+}
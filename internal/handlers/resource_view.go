@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/stationtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUtilisationBuckets limits the histogram size; larger ranges must be narrowed with startDate/endDate
+const maxUtilisationBuckets = 24 * 62
+
+// ResourceAllocation is an execution instance allocated to a crew or resource
+type ResourceAllocation struct {
+	ID                    string    `json:"id"` // Gantt task ID of the execution instance
+	AircraftWorkPackageId int       `json:"aircraftWorkPackageId"`
+	AircraftId            int       `json:"aircraftId,omitempty"`
+	LocationCode          string    `json:"locationCode,omitempty"`
+	TaskSeq               int       `json:"taskSeq"`
+	ExecutionInstanceSeq  int       `json:"executionInstanceSeq"`
+	TaskResourceSeq       int       `json:"taskResourceSeq,omitempty"`
+	TaskLeader            bool      `json:"taskLeader,omitempty"`
+	Description           string    `json:"description,omitempty"`
+	Start                 time.Time `json:"start"`
+	End                   time.Time `json:"end"`
+	Hours                 float64   `json:"hours"`
+}
+
+// UtilisationBucket is one bar of the utilisation histogram.
+// Load is the allocated hours divided by the bucket length, so 1.0 means one person fully busy
+type UtilisationBucket struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Hours float64   `json:"hours"`
+	Load  float64   `json:"load"`
+}
+
+// ResourceRow is one row of the resource view: a crew or an individual resource
+type ResourceRow struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Type        string               `json:"type"` // "crew" or "resource"
+	CrewCode    string               `json:"crewCode,omitempty"`
+	ResourceSeq int                  `json:"resourceSeq,omitempty"`
+	TotalHours  float64              `json:"totalHours"`
+	Allocations []ResourceAllocation `json:"allocations"`
+	Histogram   []UtilisationBucket  `json:"histogram"`
+}
+
+// ResourceViewResponse is the response of the resource view endpoint
+type ResourceViewResponse struct {
+	Bucket string        `json:"bucket"` // "hour" or "shift"
+	Start  *time.Time    `json:"start,omitempty"`
+	End    *time.Time    `json:"end,omitempty"`
+	Rows   []ResourceRow `json:"rows"`
+}
+
+// histogramBuckets describes how the time axis is divided
+type histogramBuckets struct {
+	length   time.Duration
+	offset   time.Duration  // bucket boundaries are aligned to midnight plus offset
+	location *time.Location // zone whose midnight is used, UTC if nil
+}
+
+// GetResourceView returns rows per crew and per resource with their allocated execution instances
+// across all matching work packages, plus a utilisation histogram per hour or per shift.
+// It accepts the same filters as GetGanttTasks and additionally:
+//   - bucket: "hour" (default) or "shift"
+//   - shiftHours: shift length in hours (default 8)
+//   - shiftStart: hour of day the first shift starts (default 6)
+func (s *Server) GetResourceView(c *gin.Context) {
+	buckets, bucketName, err := parseHistogramBuckets(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workPackages, window, tz, ok := s.loadGanttWorkPackages(c)
+	if !ok {
+		return
+	}
+
+	rows := BuildResourceRows(workPackages, window)
+
+	// Allocations are shown in the zone of their work package, the histogram in the zone of the
+	// requested stations so buckets start at local midnight
+	zones := workPackageZones(tz, workPackages)
+	for i := range rows {
+		for j := range rows[i].Allocations {
+			if zone, found := zones[rows[i].Allocations[j].AircraftWorkPackageId]; found {
+				stationtime.Convert(&rows[i].Allocations[j], zone)
+			}
+		}
+	}
+	buckets.location = tz.DayZone(queryStringList(c, "locationCode"))
+
+	// Histogram range: the requested window, otherwise the span of all allocations
+	rangeStart, rangeEnd := window.Start, window.End
+	for _, row := range rows {
+		for _, allocation := range row.Allocations {
+			if window.Start == nil && (rangeStart == nil || allocation.Start.Before(*rangeStart)) {
+				start := allocation.Start
+				rangeStart = &start
+			}
+			if window.End == nil && (rangeEnd == nil || allocation.End.After(*rangeEnd)) {
+				end := allocation.End
+				rangeEnd = &end
+			}
+		}
+	}
+
+	if rangeStart != nil && rangeEnd != nil {
+		stationtime.Convert(rangeStart, buckets.location)
+		stationtime.Convert(rangeEnd, buckets.location)
+		if rangeEnd.Sub(*rangeStart) > time.Duration(maxUtilisationBuckets)*buckets.length {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Date range too large for the histogram, narrow it with startDate/endDate"})
+			return
+		}
+		for i := range rows {
+			rows[i].Histogram = buildUtilisationHistogram(rows[i].Allocations, *rangeStart, *rangeEnd, buckets)
+		}
+	}
+
+	log.Printf("Built resource view with %d rows from %d work packages", len(rows), len(workPackages))
+
+	c.JSON(http.StatusOK, ResourceViewResponse{
+		Bucket: bucketName,
+		Start:  rangeStart,
+		End:    rangeEnd,
+		Rows:   rows,
+	})
+}
+
+// parseHistogramBuckets reads the bucket, shiftHours and shiftStart query parameters
+func parseHistogramBuckets(c *gin.Context) (histogramBuckets, string, error) {
+	bucket := c.DefaultQuery("bucket", "hour")
+	switch bucket {
+	case "hour":
+		return histogramBuckets{length: time.Hour}, bucket, nil
+	case "shift":
+		shiftHours, err := strconv.Atoi(c.DefaultQuery("shiftHours", "8"))
+		if err != nil || shiftHours <= 0 || 24%shiftHours != 0 {
+			return histogramBuckets{}, "", fmt.Errorf("shiftHours must divide 24")
+		}
+		shiftStart, err := strconv.Atoi(c.DefaultQuery("shiftStart", "6"))
+		if err != nil || shiftStart < 0 || shiftStart > 23 {
+			return histogramBuckets{}, "", fmt.Errorf("shiftStart must be an hour between 0 and 23")
+		}
+		return histogramBuckets{
+			length: time.Duration(shiftHours) * time.Hour,
+			offset: time.Duration(shiftStart%shiftHours) * time.Hour,
+		}, bucket, nil
+	}
+	return histogramBuckets{}, "", fmt.Errorf("bucket must be hour or shift")
+}
+
+// BuildResourceRows groups the execution instances of the work packages by crew (CrewCode of the task)
+// and by resource (ResourceSeq of the instance). Instances are clipped to the window
+func BuildResourceRows(workPackages []models.AircraftWorkPackage, window ganttWindow) []ResourceRow {
+	rows := map[string]*ResourceRow{}
+	getRow := func(id string, create func() ResourceRow) *ResourceRow {
+		row, found := rows[id]
+		if !found {
+			newRow := create()
+			newRow.Allocations = []ResourceAllocation{}
+			newRow.Histogram = []UtilisationBucket{}
+			row = &newRow
+			rows[id] = row
+		}
+		return row
+	}
+
+	for _, wp := range workPackages {
+		for _, task := range wp.Avexetask {
+			for _, instance := range task.JtExecutionInstanceArray {
+				if instance.AllocatedStart == nil || instance.AllocatedFinish == nil {
+					continue
+				}
+
+				// Clip to the window the same way the Gantt does
+				clipped := GanttTask{Start: *instance.AllocatedStart, End: *instance.AllocatedFinish}
+				if !window.clip(&clipped) {
+					continue
+				}
+
+				allocation := ResourceAllocation{
+					ID:                    ExecutionInstanceGanttID(wp.AircraftWorkPackageId, task.TaskSeq, instance.ExecutionInstanceSeq),
+					AircraftWorkPackageId: wp.AircraftWorkPackageId,
+					AircraftId:            wp.AircraftId,
+					LocationCode:          wp.LocationCode,
+					TaskSeq:               task.TaskSeq,
+					ExecutionInstanceSeq:  instance.ExecutionInstanceSeq,
+					TaskResourceSeq:       instance.TaskResourceSeq,
+					TaskLeader:            instance.TaskLeader,
+					Description:           task.Description,
+					Start:                 clipped.Start,
+					End:                   clipped.End,
+					Hours:                 clipped.End.Sub(clipped.Start).Hours(),
+				}
+
+				if task.CrewCode != nil && *task.CrewCode != "" {
+					crewCode := *task.CrewCode
+					row := getRow("crew:"+crewCode, func() ResourceRow {
+						name := crewCode
+						if task.CrewName != nil && *task.CrewName != "" {
+							name = *task.CrewName
+						}
+						return ResourceRow{ID: "crew:" + crewCode, Name: name, Type: "crew", CrewCode: crewCode}
+					})
+					row.Allocations = append(row.Allocations, allocation)
+					row.TotalHours += allocation.Hours
+				}
+
+				if instance.ResourceSeq != 0 {
+					resourceSeq := instance.ResourceSeq
+					resourceID := fmt.Sprintf("resource:%d", resourceSeq)
+					row := getRow(resourceID, func() ResourceRow {
+						return ResourceRow{ID: resourceID, Name: fmt.Sprintf("Resource %d", resourceSeq), Type: "resource", ResourceSeq: resourceSeq}
+					})
+					row.Allocations = append(row.Allocations, allocation)
+					row.TotalHours += allocation.Hours
+				}
+			}
+		}
+	}
+
+	result := make([]ResourceRow, 0, len(rows))
+	for _, row := range rows {
+		sort.Slice(row.Allocations, func(i, j int) bool {
+			return row.Allocations[i].Start.Before(row.Allocations[j].Start)
+		})
+		result = append(result, *row)
+	}
+
+	// Crews first, then resources, each sorted by ID
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type == "crew"
+		}
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
+
+// buildUtilisationHistogram sums the allocated hours per bucket between start and end
+func buildUtilisationHistogram(allocations []ResourceAllocation, start, end time.Time, buckets histogramBuckets) []UtilisationBucket {
+	// Align the first bucket to the bucket grid
+	location := buckets.location
+	if location == nil {
+		location = time.UTC
+	}
+	start = start.In(location)
+
+	// Step by wall-clock hours, so buckets keep starting at the same local hour across
+	// daylight saving changes; such buckets are an hour shorter or longer
+	step := int(buckets.length / time.Hour)
+	wallClock := func(t time.Time, hours int) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+hours, t.Minute(), 0, 0, location)
+	}
+	midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location)
+	first := wallClock(midnight, int(buckets.offset/time.Hour)-step)
+	for !wallClock(first, step).After(start) {
+		first = wallClock(first, step)
+	}
+
+	histogram := []UtilisationBucket{}
+	for bucketStart := first; bucketStart.Before(end); bucketStart = wallClock(bucketStart, step) {
+		bucketEnd := wallClock(bucketStart, step)
+
+		hours := 0.0
+		for _, allocation := range allocations {
+			overlapStart, overlapEnd := allocation.Start, allocation.End
+			if overlapStart.Before(bucketStart) {
+				overlapStart = bucketStart
+			}
+			if overlapEnd.After(bucketEnd) {
+				overlapEnd = bucketEnd
+			}
+			if overlapEnd.After(overlapStart) {
+				hours += overlapEnd.Sub(overlapStart).Hours()
+			}
+		}
+
+		histogram = append(histogram, UtilisationBucket{
+			Start: bucketStart,
+			End:   bucketEnd,
+			Hours: hours,
+			Load:  hours / bucketEnd.Sub(bucketStart).Hours(),
+		})
+	}
+
+	return histogram
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/oidc"
+	"stationMonitor/internal/repository"
+	"stationMonitor/internal/stationtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server holds the configuration and the repositories used by the handlers.
+// Routes are registered on its methods; tests construct it with in-memory repositories
+type Server struct {
+	// Config is the configuration the server was started with; it must be set
+	Config *config.Config
+
+	WorkPackages repository.WorkPackageRepository
+	Users        repository.UserRepository
+
+	// Baselines stores schedule baselines; nil disables baselines
+	Baselines repository.BaselineRepository
+
+	// Dependencies stores user-defined Gantt dependencies; nil leaves the chart with the
+	// dependencies derived from the task data only
+	Dependencies repository.DependencyRepository
+
+	// WriteBacks queues chart edits for IFS; nil does not send edits to IFS
+	WriteBacks repository.WriteBackRepository
+
+	// Stations maps LocationCodes to time zones for the tz parameter; nil shows times in UTC
+	// unless a request names a zone
+	Stations *stationtime.Registry
+
+	// Tokens stores refresh tokens and the revocation list; nil issues access tokens only
+	Tokens repository.TokenRepository
+
+	// APIKeys stores the API keys of machine clients; nil disables API keys
+	APIKeys repository.APIKeyRepository
+
+	// OIDC keeps the discovered OpenID Connect providers; nil disables OIDC login
+	OIDC *oidc.Registry
+}
+
+// NewServer returns a Server using the given configuration and repositories
+func NewServer(cfg *config.Config, workPackages repository.WorkPackageRepository, users repository.UserRepository) *Server {
+	return &Server{Config: cfg, WorkPackages: workPackages, Users: users}
+}
+
+// workPackages returns the work package repository limited to the scope of the user making the
+// request. Handlers read work packages through it rather than through WorkPackages
+func (s *Server) workPackages(c *gin.Context) repository.WorkPackageRepository {
+	scope, _ := c.Get("scope")
+	if scope, ok := scope.(models.Scope); ok {
+		return repository.Scoped(s.WorkPackages, scope)
+	}
+	return s.WorkPackages
+}
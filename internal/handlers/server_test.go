@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testConfig returns the configuration handler tests run with
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.JWT.Secret = "test-secret"
+	cfg.JWT.AccessTTL = "15m"
+	cfg.JWT.RefreshTTL = "24h"
+	return cfg
+}
+
+// testWorkPackage returns a work package with two consecutive tasks of 8 hours
+func testWorkPackage(wpId int) models.AircraftWorkPackage {
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	mid := start.Add(8 * time.Hour)
+	end := mid.Add(8 * time.Hour)
+	return models.AircraftWorkPackage{
+		AircraftWorkPackageId: wpId,
+		AircraftId:            100 + wpId,
+		LocationCode:          "AMS",
+		Objstate:              "Planned",
+		SchedStartDateTime:    &start,
+		SchedEndDateTime:      &end,
+		Avexetask: []models.AvExeTask{
+			{TaskSeq: 1, Objstate: "Planned", PlannedStart: &start, PlannedFinish: &mid},
+			{TaskSeq: 2, Objstate: "Planned", PlannedStart: &mid, PlannedFinish: &end},
+		},
+	}
+}
+
+// serve runs one request against handler as the user username, with scope when it is not nil
+func serve(handler gin.HandlerFunc, method, path, route string, body any, username string, scope *models.Scope) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, route, func(c *gin.Context) {
+		c.Set("username", username)
+		if scope != nil {
+			c.Set("scope", *scope)
+		}
+		c.Next()
+	}, handler)
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestScheduleBaselines(t *testing.T) {
+	s := NewServer(testConfig(), repository.NewMemoryWorkPackages(testWorkPackage(1)), repository.NewMemoryUsers())
+
+	w := serve(s.CreateScheduleBaseline, http.MethodPost, "/baselines/1", "/baselines/:aircraftWorkPackageId", nil, "planner", nil)
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("without a baseline repository: got %d, want 501", w.Code)
+	}
+
+	s.Baselines = repository.NewMemoryBaselines()
+	w = serve(s.CreateScheduleBaseline, http.MethodPost, "/baselines/1", "/baselines/:aircraftWorkPackageId", map[string]string{"name": "Plan A"}, "planner", nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", w.Code, w.Body)
+	}
+	var created models.ScheduleBaseline
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Name != "Plan A" || created.CreatedBy != "planner" || len(created.Tasks) != 2 {
+		t.Errorf("created baseline = %+v", created)
+	}
+
+	w = serve(s.GetScheduleBaselines, http.MethodGet, "/baselines/1", "/baselines/:aircraftWorkPackageId", nil, "planner", nil)
+	var list struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || list.Count != 1 {
+		t.Errorf("list: got %d %s", w.Code, w.Body)
+	}
+
+	w = serve(s.GetScheduleVariance, http.MethodGet, "/baselines/1/variance", "/baselines/:aircraftWorkPackageId/variance", nil, "planner", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("variance: got %d: %s", w.Code, w.Body)
+	}
+
+	w = serve(s.GetScheduleVariance, http.MethodGet, "/baselines/2/variance", "/baselines/:aircraftWorkPackageId/variance", nil, "planner", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("variance of an unknown work package: got %d, want 404", w.Code)
+	}
+}
+
+func TestGanttDependencies(t *testing.T) {
+	jfk := testWorkPackage(2)
+	jfk.LocationCode = "JFK"
+	s := NewServer(testConfig(), repository.NewMemoryWorkPackages(testWorkPackage(1), jfk), repository.NewMemoryUsers())
+	s.Dependencies = repository.NewMemoryDependencies()
+
+	ams := models.Scope{LocationCodes: []string{"AMS"}}
+	tests := []struct {
+		name  string
+		dep   models.GanttDependency
+		scope *models.Scope
+		want  int
+	}{
+		{"within a package", models.GanttDependency{SourceAircraftWpId: 1, SourceTaskSeq: 1, TargetAircraftWpId: 1, TargetTaskSeq: 2}, nil, http.StatusCreated},
+		{"across packages", models.GanttDependency{SourceAircraftWpId: 1, SourceTaskSeq: 2, TargetAircraftWpId: 2, TargetTaskSeq: 1, Type: "s2s"}, nil, http.StatusCreated},
+		{"self", models.GanttDependency{SourceAircraftWpId: 1, SourceTaskSeq: 1, TargetAircraftWpId: 1, TargetTaskSeq: 1}, nil, http.StatusBadRequest},
+		{"invalid type", models.GanttDependency{SourceAircraftWpId: 1, SourceTaskSeq: 1, TargetAircraftWpId: 2, TargetTaskSeq: 2, Type: "x"}, nil, http.StatusBadRequest},
+		{"outside the scope", models.GanttDependency{SourceAircraftWpId: 1, SourceTaskSeq: 1, TargetAircraftWpId: 2, TargetTaskSeq: 2}, &ams, http.StatusNotFound},
+	}
+	var ids []string
+	for _, tt := range tests {
+		w := serve(s.CreateGanttDependency, http.MethodPost, "/dependencies", "/dependencies", tt.dep, "planner", tt.scope)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+			continue
+		}
+		if w.Code == http.StatusCreated {
+			var created models.GanttDependency
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, created.ID.Hex())
+		}
+	}
+
+	// Package 2 only sees the dependency that crosses into it
+	w := serve(s.GetGanttDependencies, http.MethodGet, "/dependencies?aircraftWorkPackageId=2", "/dependencies", nil, "planner", nil)
+	var list struct {
+		Data []models.GanttDependency `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].Type != "s2s" {
+		t.Errorf("dependencies of package 2 = %+v", list.Data)
+	}
+
+	// A user scoped to AMS cannot remove the dependency into the JFK package
+	w = serve(s.DeleteGanttDependency, http.MethodDelete, "/dependencies/"+ids[1], "/dependencies/:id", nil, "technician", &ams)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("scoped delete: got %d, want 404", w.Code)
+	}
+
+	w = serve(s.DeleteGanttDependency, http.MethodDelete, "/dependencies/"+ids[1], "/dependencies/:id", nil, "planner", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("delete: got %d: %s", w.Code, w.Body)
+	}
+	w = serve(s.DeleteGanttDependency, http.MethodDelete, "/dependencies/"+ids[1], "/dependencies/:id", nil, "planner", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second delete: got %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/stationtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseTZ reads the tz query parameter: "UTC", "station" or an IANA zone name.
+// On failure the error response has already been written and ok is false
+func (s *Server) parseTZ(c *gin.Context) (tz stationtime.TZ, ok bool) {
+	tz, err := s.Stations.Parse(c.Query("tz"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return tz, false
+	}
+	return tz, true
+}
+
+// workPackageZones returns the zone every work package is shown in, keyed by AircraftWorkPackageId
+func workPackageZones(tz stationtime.TZ, workPackages []models.AircraftWorkPackage) map[int]*time.Location {
+	zones := map[int]*time.Location{}
+	for _, wp := range workPackages {
+		zones[wp.AircraftWorkPackageId] = tz.For(wp.LocationCode, stationtime.WorkPackageOffset(wp))
+	}
+	return zones
+}
+
+// localizeGanttTasks shows the dates of every task in the zone of its work package
+func localizeGanttTasks(tasks []GanttTask, zones map[int]*time.Location) {
+	for i := range tasks {
+		if zone, found := zones[tasks[i].AircraftWorkPackageId]; found {
+			stationtime.Convert(&tasks[i], zone)
+		}
+	}
+}
+
+// localizeWorkPackages shows the dates of every work package in its zone
+func localizeWorkPackages(tz stationtime.TZ, workPackages []models.AircraftWorkPackage) {
+	for i := range workPackages {
+		stationtime.Convert(&workPackages[i], tz.For(workPackages[i].LocationCode, stationtime.WorkPackageOffset(workPackages[i])))
+	}
+}
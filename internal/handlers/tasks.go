@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"stationMonitor/internal/repository"
+	"stationMonitor/internal/stationtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// closedTaskStates are the Objstate values excluded by open=true. They are the states
+// isCompletedState and isCancelledState recognise, spelled the way IFS stores them
+var closedTaskStates = []string{"Completed", "Closed", "Released", "Finished", "Cancelled", "Canceled"}
+
+// GetTasks queries AvExeTasks across work packages.
+// List parameters accept comma-separated or repeated values:
+//   - objstate, crewCode, location (task Location), classCode, priorityId
+//   - aircraftId, aircraftWorkPackageId, locationCode (work package LocationCode)
+//   - open: true leaves out completed and cancelled tasks
+//   - flightSafetyImpact, isHistoric: true or false
+//   - startDate/endDate: tasks whose planned window overlaps the range
+//   - dueFrom/dueTo: tasks whose LatestFinish lies in the range; a plain dueTo date includes the whole day
+//   - tz: UTC (default), station or an IANA zone; also sets where plain dates start and end
+//   - page, limit: pagination (default limit 100, max 1000)
+func (s *Server) GetTasks(c *gin.Context) {
+	filter := repository.TaskFilter{
+		LocationCodes: queryStringList(c, "locationCode"),
+		Objstates:     queryStringList(c, "objstate"),
+		CrewCodes:     queryStringList(c, "crewCode"),
+		Locations:     queryStringList(c, "location"),
+		ClassCodes:    queryStringList(c, "classCode"),
+	}
+
+	var err error
+	if filter.AircraftIds, err = queryIntList(c, "aircraftId"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.WorkPackageIds, err = queryIntList(c, "aircraftWorkPackageId"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.PriorityIds, err = queryIntList(c, "priorityId"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.IsHistoric, err = queryBool(c, "isHistoric"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.FlightSafetyImpact, err = queryBool(c, "flightSafetyImpact"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	open, err := queryBool(c, "open")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if open != nil && *open {
+		filter.ExcludeObjstates = closedTaskStates
+	}
+
+	tz, ok := s.parseTZ(c)
+	if !ok {
+		return
+	}
+	dayZone := tz.DayZone(filter.LocationCodes)
+
+	window, err := parseGanttWindow(c.Query("startDate"), c.Query("endDate"), dayZone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.From, filter.To = window.Start, window.End
+
+	if dueFromStr := c.Query("dueFrom"); dueFromStr != "" {
+		dueFrom, _, err := parseDateParam(dueFromStr, dayZone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dueFrom: " + dueFromStr})
+			return
+		}
+		filter.DueFrom = &dueFrom
+	}
+	if dueToStr := c.Query("dueTo"); dueToStr != "" {
+		dueTo, dateOnly, err := parseDateParam(dueToStr, dayZone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dueTo: " + dueToStr})
+			return
+		}
+		if dateOnly {
+			dueTo = endOfDay(dueTo)
+		}
+		filter.DueTo = &dueTo
+	}
+
+	// Pagination parameters
+	page := 1
+	limit := 100
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+	}
+
+	tasks, totalCount, err := s.workPackages(c).FindTasks(c.Request.Context(), filter,
+		repository.FindOptions{Skip: int64((page - 1) * limit), Limit: int64(limit)})
+	if err != nil {
+		log.Printf("Error querying tasks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database: " + err.Error()})
+		return
+	}
+	for i := range tasks {
+		stationtime.Convert(&tasks[i], tz.For(tasks[i].LocationCode, stationtime.TaskOffset(tasks[i].Task)))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       tasks,
+		"total":      totalCount,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (int(totalCount) + limit - 1) / limit,
+	})
+}
+
+// GetTask returns a single task by AircraftWorkPackageId and TaskSeq
+func (s *Server) GetTask(c *gin.Context) {
+	wpId, err := strconv.Atoi(c.Param("aircraftWorkPackageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work package ID"})
+		return
+	}
+	taskSeq, err := strconv.Atoi(c.Param("taskSeq"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task sequence"})
+		return
+	}
+
+	tz, ok := s.parseTZ(c)
+	if !ok {
+		return
+	}
+
+	task, err := s.workPackages(c).FindTask(c.Request.Context(), wpId, taskSeq)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+		return
+	}
+
+	stationtime.Convert(&task, tz.For(task.LocationCode, stationtime.TaskOffset(task.Task)))
+	c.JSON(http.StatusOK, task)
+}
+
+// queryBool parses an optional boolean query parameter; nil means it was not given
+func queryBool(c *gin.Context, key string) (*bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", key, value)
+	}
+	return &parsed, nil
+}
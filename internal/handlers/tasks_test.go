@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+)
+
+// taskTestServer returns a server with package 1 at AMS, whose task 2 is completed, and package 2 at JFK
+func taskTestServer() *Server {
+	ams := testWorkPackage(1)
+	mech, avi, priority := "MECH", "AVI", 1
+	due := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	ams.Avexetask[0].CrewCode = &mech
+	ams.Avexetask[0].LatestFinish = &due
+	ams.Avexetask[1].CrewCode = &avi
+	ams.Avexetask[1].Objstate = "Completed"
+	ams.Avexetask[1].PriorityId = &priority
+	ams.Avexetask[1].FlightSafetyImpact = true
+
+	jfk := testWorkPackage(2)
+	jfk.LocationCode = "JFK"
+	return NewServer(testConfig(), repository.NewMemoryWorkPackages(ams, jfk), repository.NewMemoryUsers())
+}
+
+// taskKeys returns the tasks as AircraftWorkPackageId/TaskSeq in order
+func taskKeys(tasks []models.WorkPackageTask) []string {
+	keys := []string{}
+	for _, task := range tasks {
+		keys = append(keys, fmt.Sprintf("%d/%d", task.AircraftWorkPackageId, task.Task.TaskSeq))
+	}
+	return keys
+}
+
+func TestGetTasks(t *testing.T) {
+	s := taskTestServer()
+
+	tests := []struct {
+		query string
+		want  []string
+		total int
+	}{
+		{"", []string{"1/1", "2/1", "1/2", "2/2"}, 4},
+		{"?objstate=Completed", []string{"1/2"}, 1},
+		{"?open=true", []string{"1/1", "2/1", "2/2"}, 3},
+		{"?crewCode=MECH,AVI", []string{"1/1", "1/2"}, 2},
+		{"?crewCode=MECH&crewCode=AVI", []string{"1/1", "1/2"}, 2},
+		{"?locationCode=JFK", []string{"2/1", "2/2"}, 2},
+		{"?aircraftId=101&aircraftWorkPackageId=1", []string{"1/1", "1/2"}, 2},
+		{"?priorityId=1", []string{"1/2"}, 1},
+		{"?flightSafetyImpact=true", []string{"1/2"}, 1},
+		{"?startDate=2026-03-02T17:00:00Z&endDate=2026-03-03", []string{"1/2", "2/2"}, 2},
+		{"?dueTo=2026-03-04", []string{"1/1"}, 1},
+		{"?dueFrom=2026-03-05", []string{}, 0},
+		{"?limit=3&page=2", []string{"2/2"}, 4},
+	}
+	for _, tt := range tests {
+		w := serve(s.GetTasks, http.MethodGet, "/tasks"+tt.query, "/tasks", nil, "planner", nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%q: got %d: %s", tt.query, w.Code, w.Body)
+			continue
+		}
+		var page struct {
+			Data  []models.WorkPackageTask `json:"data"`
+			Total int                      `json:"total"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		got := taskKeys(page.Data)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) || page.Total != tt.total {
+			t.Errorf("%q: got %v of %d, want %v of %d", tt.query, got, page.Total, tt.want, tt.total)
+		}
+	}
+
+	for _, query := range []string{"?aircraftId=x", "?open=maybe", "?dueTo=soon", "?startDate=2026-03-03&endDate=2026-03-02"} {
+		if w := serve(s.GetTasks, http.MethodGet, "/tasks"+query, "/tasks", nil, "planner", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: got %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestGetTask(t *testing.T) {
+	s := taskTestServer()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/tasks/1/2", http.StatusOK},
+		{"/tasks/2/1", http.StatusOK},
+		{"/tasks/1/9", http.StatusNotFound},
+		{"/tasks/9/1", http.StatusNotFound},
+		{"/tasks/x/1", http.StatusBadRequest},
+		{"/tasks/1/x", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := serve(s.GetTask, http.MethodGet, tt.path, "/tasks/:aircraftWorkPackageId/:taskSeq", nil, "planner", nil)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d: %s", tt.path, w.Code, tt.want, w.Body)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var task models.WorkPackageTask
+		if err := json.Unmarshal(w.Body.Bytes(), &task); err != nil {
+			t.Fatal(err)
+		}
+		if got := "/tasks/" + taskKeys([]models.WorkPackageTask{task})[0]; got != tt.path || task.LocationCode == "" {
+			t.Errorf("%s: got %s at %q", tt.path, got, task.LocationCode)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// loginCodeTTL is how long the frontend has to exchange the code of a browser login
+const loginCodeTTL = time.Minute
+
+// tokenLifetimes parses the access and refresh token lifetimes of the config
+func tokenLifetimes(cfg *config.Config) (time.Duration, time.Duration, error) {
+	accessTTL, err := time.ParseDuration(cfg.JWT.AccessTTL)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid jwt.access_ttl: %w", err)
+	}
+	refreshTTL, err := time.ParseDuration(cfg.JWT.RefreshTTL)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid jwt.refresh_ttl: %w", err)
+	}
+	return accessTTL, refreshTTL, nil
+}
+
+// randomToken returns n random bytes, base64url encoded
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the ID a refresh token is stored under
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenUsername is the username put in the JWT: the username, or the email of email-only users
+func tokenUsername(user models.User) string {
+	if user.Username != "" {
+		return user.Username
+	}
+	return user.Email
+}
+
+// issueTokens signs an access token carrying the roles of the user and, if the server stores
+// tokens, a refresh token in family. An empty family starts a new one, as a login does
+func (s *Server) issueTokens(ctx context.Context, user models.User, family string) (models.TokenResponse, error) {
+	accessTTL, refreshTTL, err := tokenLifetimes(s.Config)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	now := time.Now()
+
+	if family == "" {
+		if family, err = randomToken(16); err != nil {
+			return models.TokenResponse{}, err
+		}
+	}
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	username := tokenUsername(user)
+	claims := &Claims{
+		Username: username,
+		Roles:    user.Roles,
+		Family:   family,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if !user.Scope.IsZero() {
+		scope := user.Scope
+		claims.Scope = &scope
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.Config.JWT.Secret))
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	response := models.TokenResponse{Token: accessToken, ExpiresIn: int(accessTTL.Seconds())}
+
+	if s.Tokens == nil {
+		return response, nil
+	}
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	err = s.Tokens.CreateRefreshToken(ctx, models.RefreshToken{
+		ID:        hashToken(refreshToken),
+		Family:    family,
+		UserID:    user.ID,
+		Username:  username,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(refreshTTL),
+	})
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	response.RefreshToken = refreshToken
+	return response, nil
+}
+
+// issueLoginCode stores a one-time code for user. Browser logins redirect to the frontend with
+// it instead of the tokens, which would end up in the browser history, proxy logs and Referer
+// headers
+func (s *Server) issueLoginCode(ctx context.Context, user models.User) (string, error) {
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.Tokens.CreateLoginCode(ctx, models.LoginCode{
+		ID:        hashToken(code),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(loginCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ExchangeLoginCode exchanges the one-time code of a browser login for the tokens and the user.
+// A code can be exchanged once, within loginCodeTTL
+func (s *Server) ExchangeLoginCode(c *gin.Context) {
+	var req models.LoginCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if s.Tokens == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Login codes are not enabled"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	code, err := s.Tokens.RedeemLoginCode(ctx, hashToken(req.Code), time.Now())
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	user, err := s.Users.FindByID(ctx, code.UserID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	tokens, err := s.issueTokens(ctx, user, "")
+	if err != nil {
+		log.Printf("Failed to generate token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, models.LoginResponse{
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	})
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token.
+// Every refresh token can be used once; presenting a used one again means it was stolen,
+// so the whole family is revoked and the user has to log in again
+func (s *Server) RefreshToken(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if s.Tokens == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Refresh tokens are not enabled"})
+		return
+	}
+
+	accessTTL, _, err := tokenLifetimes(s.Config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration error"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	now := time.Now()
+	token, err := s.Tokens.FindRefreshToken(ctx, hashToken(req.RefreshToken))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if token.RevokedAt != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token revoked"})
+		return
+	}
+	if token.UsedAt != nil {
+		s.revokeReusedFamily(c, token, now, accessTTL)
+		return
+	}
+	if !now.Before(token.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
+		return
+	}
+
+	// Roles and scope are read again, so changes to them apply from the next refresh
+	user, err := s.Users.FindByID(ctx, token.UserID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	response, err := s.issueTokens(ctx, user, token.Family)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	// Another refresh with the same token may have won the race since it was read
+	if err := s.Tokens.UseRefreshToken(ctx, token.ID, hashToken(response.RefreshToken), now); err != nil {
+		if err == repository.ErrConflict {
+			s.revokeReusedFamily(c, token, now, accessTTL)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// revokeReusedFamily answers the reuse of a refresh token by revoking its family, including the
+// access tokens already issued with it
+func (s *Server) revokeReusedFamily(c *gin.Context, token models.RefreshToken, now time.Time, accessTTL time.Duration) {
+	log.Printf("Refresh token reuse detected for %s, revoking token family %s", token.Username, token.Family)
+	if err := s.Tokens.RevokeFamily(c.Request.Context(), token.Family, now, now.Add(accessTTL)); err != nil {
+		log.Printf("Failed to revoke token family %s: %v", token.Family, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please log in again"})
+}
+
+// Logout revokes the access token of the request and the refresh tokens of its session.
+// With {"all": true} every session of the user is ended, e.g. after a device was lost
+func (s *Server) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+	}
+	if s.Tokens == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Token revocation is not enabled"})
+		return
+	}
+	if c.GetString("apiKeyID") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API keys have no session; revoke the key instead"})
+		return
+	}
+
+	accessTTL, _, err := tokenLifetimes(s.Config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration error"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	now := time.Now()
+	tokenID := c.GetString("tokenID")
+	family := c.GetString("tokenFamily")
+	expiresAt := now.Add(accessTTL)
+	if value, exists := c.Get("tokenExpiresAt"); exists {
+		expiresAt = value.(time.Time)
+	}
+
+	if tokenID != "" {
+		if err := s.Tokens.Revoke(ctx, tokenID, now, expiresAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+			return
+		}
+	}
+	if family != "" {
+		if err := s.Tokens.RevokeFamily(ctx, family, now, now.Add(accessTTL)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+			return
+		}
+	}
+
+	if req.All {
+		user, err := s.Users.FindByLogin(ctx, c.GetString("username"))
+		if err != nil {
+			if err == repository.ErrNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if err := s.Tokens.RevokeUser(ctx, user.ID, now, now.Add(accessTTL)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+			return
+		}
+		log.Printf("Revoked all sessions of %s", c.GetString("username"))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
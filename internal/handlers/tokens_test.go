@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/middleware"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tokenTestServer returns a router with the login, refresh and logout routes, a protected
+// /dashboard and /work-packages counting the visible work packages, for a server storing tokens
+// in memory with one work package and the user planner, password secret
+func tokenTestServer(t *testing.T) (*gin.Engine, *Server) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(testConfig(), repository.NewMemoryWorkPackages(testWorkPackage(1)), repository.NewMemoryUsers(models.User{
+		Username: "planner",
+		Email:    "planner@example.com",
+		Password: string(hash),
+		Roles:    []string{"planner"},
+	}))
+	s.Tokens = repository.NewMemoryTokens()
+
+	router := gin.New()
+	router.POST("/api/login", s.Login)
+	router.POST("/api/register", s.Register)
+	router.POST("/api/token/refresh", s.RefreshToken)
+	router.POST("/api/auth/exchange", s.ExchangeLoginCode)
+	protected := router.Group("/api")
+	protected.Use(middleware.AuthMiddleware(s.Config.JWT.Secret, s.Tokens, nil))
+	protected.POST("/logout", s.Logout)
+	protected.GET("/dashboard", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user": c.GetString("username")})
+	})
+	protected.GET("/work-packages", func(c *gin.Context) {
+		workPackages, err := s.workPackages(c).Find(c.Request.Context(), repository.WorkPackageFilter{}, repository.FindOptions{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"count": len(workPackages)})
+	})
+	return router, s
+}
+
+// postJSON posts body to path, with token as the bearer token if it is not empty
+func postJSON(router *gin.Engine, path string, body any, token string) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeTokens decodes the tokens of a login or refresh response, failing unless it is a 200
+func decodeTokens(t *testing.T, w *httptest.ResponseRecorder) models.TokenResponse {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	var tokens models.TokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil {
+		t.Fatal(err)
+	}
+	if tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Fatalf("response without tokens: %s", w.Body)
+	}
+	return tokens
+}
+
+// dashboard requests the protected /dashboard with the access token and returns the status
+func dashboard(router *gin.Engine, token string) int {
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	router, _ := tokenTestServer(t)
+
+	login := decodeTokens(t, postJSON(router, "/api/login", models.LoginRequest{Username: "planner", Password: "secret"}, ""))
+	refreshed := decodeTokens(t, postJSON(router, "/api/token/refresh", models.RefreshRequest{RefreshToken: login.RefreshToken}, ""))
+	if refreshed.RefreshToken == login.RefreshToken || refreshed.Token == login.Token {
+		t.Errorf("refresh returned the same tokens")
+	}
+	if got := dashboard(router, refreshed.Token); got != http.StatusOK {
+		t.Errorf("refreshed access token: got %d, want 200", got)
+	}
+	second := decodeTokens(t, postJSON(router, "/api/token/refresh", models.RefreshRequest{RefreshToken: refreshed.RefreshToken}, ""))
+
+	// The first refresh token was used already, so presenting it again ends the session
+	w := postJSON(router, "/api/token/refresh", models.RefreshRequest{RefreshToken: login.RefreshToken}, "")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: got %d, want 401", w.Code)
+	}
+	if w := postJSON(router, "/api/token/refresh", models.RefreshRequest{RefreshToken: second.RefreshToken}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("latest refresh token of the revoked family: got %d, want 401", w.Code)
+	}
+	for name, token := range map[string]string{"login": login.Token, "refreshed": refreshed.Token, "second": second.Token} {
+		if got := dashboard(router, token); got != http.StatusUnauthorized {
+			t.Errorf("%s access token of the revoked family: got %d, want 401", name, got)
+		}
+	}
+
+	// Other sessions of the user are not affected
+	other := decodeTokens(t, postJSON(router, "/api/login", models.LoginRequest{Username: "planner", Password: "secret"}, ""))
+	if got := dashboard(router, other.Token); got != http.StatusOK {
+		t.Errorf("access token of another session: got %d, want 200", got)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	router, _ := tokenTestServer(t)
+
+	login := decodeTokens(t, postJSON(router, "/api/login", models.LoginRequest{Username: "planner", Password: "secret"}, ""))
+	if got := dashboard(router, login.Token); got != http.StatusOK {
+		t.Fatalf("before logout: got %d, want 200", got)
+	}
+	if w := postJSON(router, "/api/logout", nil, login.Token); w.Code != http.StatusOK {
+		t.Fatalf("logout: got %d: %s", w.Code, w.Body)
+	}
+
+	if got := dashboard(router, login.Token); got != http.StatusUnauthorized {
+		t.Errorf("logged out access token: got %d, want 401", got)
+	}
+	if w := postJSON(router, "/api/token/refresh", models.RefreshRequest{RefreshToken: login.RefreshToken}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token of the logged out session: got %d, want 401", w.Code)
+	}
+}
+
+func TestExchangeLoginCode(t *testing.T) {
+	router, s := tokenTestServer(t)
+	ctx := context.Background()
+	user, err := s.Users.FindByLogin(ctx, "planner")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := s.issueLoginCode(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := postJSON(router, "/api/auth/exchange", models.LoginCodeRequest{Code: code}, "")
+	tokens := decodeTokens(t, w)
+	if got := dashboard(router, tokens.Token); got != http.StatusOK {
+		t.Errorf("access token of the exchanged code: got %d, want 200", got)
+	}
+	var response models.LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.User.Username != "planner" {
+		t.Errorf("exchange response = %s", w.Body)
+	}
+
+	if w := postJSON(router, "/api/auth/exchange", models.LoginCodeRequest{Code: code}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("code exchanged twice: got %d, want 401", w.Code)
+	}
+
+	expired, err := randomToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Tokens.CreateLoginCode(ctx, models.LoginCode{ID: hashToken(expired), UserID: user.ID, ExpiresAt: time.Now().Add(-time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := postJSON(router, "/api/auth/exchange", models.LoginCodeRequest{Code: expired}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expired code: got %d, want 401", w.Code)
+	}
+}
+
+func TestRegisteredUserSeesNoData(t *testing.T) {
+	router, s := tokenTestServer(t)
+
+	visible := func(token string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/work-packages", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var body struct {
+			Count int `json:"count"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("work packages: got %d: %s", w.Code, w.Body)
+		}
+		return body.Count
+	}
+
+	login := decodeTokens(t, postJSON(router, "/api/login", models.LoginRequest{Username: "planner", Password: "secret"}, ""))
+	if got := visible(login.Token); got != 1 {
+		t.Errorf("user without a scope sees %d work packages, want 1", got)
+	}
+
+	w := postJSON(router, "/api/register", models.RegisterRequest{Email: "new@example.com", Password: "secret1"}, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: got %d: %s", w.Code, w.Body)
+	}
+	var registered models.RegisterResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &registered); err != nil {
+		t.Fatal(err)
+	}
+	if got := visible(registered.Token); got != 0 {
+		t.Errorf("registered user sees %d work packages, want 0", got)
+	}
+
+	// Once an admin assigns a scope, the next refresh sees its data
+	user, err := s.Users.FindByEmail(context.Background(), "new@example.com")
+	if err != nil || !user.Scope.DenyAll {
+		t.Fatalf("registered user = %+v, %v", user, err)
+	}
+	user.Scope = models.Scope{LocationCodes: []string{"AMS"}}
+	if err := s.Users.Update(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	refreshed := decodeTokens(t, postJSON(router, "/api/token/refresh", models.RefreshRequest{RefreshToken: registered.RefreshToken}, ""))
+	if got := visible(refreshed.Token); got != 1 {
+		t.Errorf("user with a scope sees %d work packages, want 1", got)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/rbac"
+	"stationMonitor/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// googleRoles returns the roles of a user provisioned by Google sign-in: those configured for
+// the domain of the email, else the default roles
+func googleRoles(cfg *config.Config, email string) []string {
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		if roles, ok := cfg.GoogleOAuth.DomainRoles[strings.ToLower(email[at+1:])]; ok {
+			return roles
+		}
+	}
+	return cfg.GoogleOAuth.DefaultRoles
+}
+
+// GetRoles lists the roles and the permissions they grant
+func GetRoles(c *gin.Context) {
+	names := []string{}
+	for name := range rbac.Roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	roles := []gin.H{}
+	for _, name := range names {
+		roles = append(roles, gin.H{"role": name, "permissions": rbac.Roles[name]})
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GetUsers lists the users with their roles
+func (s *Server) GetUsers(c *gin.Context) {
+	users, err := s.Users.List(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users, "count": len(users)})
+}
+
+// UpdateUserRoles replaces the roles of a user. The change applies when the user next logs in
+// or refreshes the access token. Admins cannot take the admin role away from themselves
+func (s *Server) UpdateUserRoles(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.UpdateRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := rbac.ValidateRoles(req.Roles); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := s.Users.FindByID(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if tokenUsername(user) == c.GetString("username") && !rbac.Allowed(req.Roles, rbac.ManageUsers) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot remove your own permission to manage users"})
+		return
+	}
+
+	user.Roles = req.Roles
+	if err := s.Users.Update(ctx, user); err != nil {
+		log.Printf("Error updating roles of user %s: %v", user.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	log.Printf("%s set the roles of %s to %v", c.GetString("username"), tokenUsername(user), user.Roles)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUserScope replaces the scope of a user; an empty scope lets the user see all data, one
+// with denyAll none. Like roles, the scope applies when the user next logs in or refreshes the
+// access token. Admins with a scope can only assign scopes within their own, and no admin can
+// change their own scope
+func (s *Server) UpdateUserScope(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.UpdateScopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if scope, exists := c.Get("scope"); exists {
+		if scope, ok := scope.(models.Scope); !ok || !scope.Contains(req.Scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot assign a scope beyond your own", "scope": scope})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	user, err := s.Users.FindByID(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if tokenUsername(user) == c.GetString("username") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own scope"})
+		return
+	}
+
+	user.Scope = req.Scope
+	if err := s.Users.Update(ctx, user); err != nil {
+		log.Printf("Error updating scope of user %s: %v", user.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	log.Printf("%s set the scope of %s to %+v", c.GetString("username"), tokenUsername(user), user.Scope)
+
+	c.JSON(http.StatusOK, user)
+}
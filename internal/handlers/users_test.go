@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+)
+
+func TestUpdateUserScope(t *testing.T) {
+	ams := models.Scope{LocationCodes: []string{"AMS"}}
+	hangar := models.Scope{LocationCodes: []string{"AMS"}, Sites: []string{"HANGAR2"}}
+	users := repository.NewMemoryUsers(
+		models.User{Username: "admin", Email: "admin@example.com", Roles: []string{"admin"}, Scope: ams},
+		models.User{Username: "planner", Email: "planner@example.com", Roles: []string{"planner"}},
+	)
+	s := NewServer(testConfig(), repository.NewMemoryWorkPackages(), users)
+	ctx := context.Background()
+	admin, _ := users.FindByLogin(ctx, "admin")
+	planner, _ := users.FindByLogin(ctx, "planner")
+
+	tests := []struct {
+		name   string
+		caller string
+		scope  *models.Scope
+		user   models.User
+		set    models.Scope
+		want   int
+	}{
+		{"caller without a scope", "root", nil, planner, ams, http.StatusOK},
+		{"within the caller's scope", "admin", &ams, planner, hangar, http.StatusOK},
+		{"deny all", "admin", &ams, planner, models.DenyAllScope, http.StatusOK},
+		{"no scope", "admin", &ams, planner, models.Scope{}, http.StatusForbidden},
+		{"beyond the caller's scope", "admin", &hangar, planner, ams, http.StatusForbidden},
+		{"other location", "admin", &ams, planner, models.Scope{LocationCodes: []string{"JFK"}}, http.StatusForbidden},
+		{"own scope", "admin", &ams, admin, hangar, http.StatusBadRequest},
+		{"own scope without a scope", "planner", nil, planner, models.Scope{}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		before, _ := users.FindByID(ctx, tt.user.ID)
+		path := "/users/" + tt.user.ID.Hex() + "/scope"
+		w := serve(s.UpdateUserScope, http.MethodPut, path, "/users/:id/scope", models.UpdateScopeRequest{Scope: tt.set}, tt.caller, tt.scope)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		after, _ := users.FindByID(ctx, tt.user.ID)
+		if tt.want != http.StatusOK && !scopesEqual(before.Scope, after.Scope) {
+			t.Errorf("%s: scope changed to %+v", tt.name, after.Scope)
+		}
+	}
+}
+
+// scopesEqual reports whether a and b are within each other
+func scopesEqual(a, b models.Scope) bool {
+	return a.Contains(b) && b.Contains(a)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"stationMonitor/internal/repository"
+	"stationMonitor/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validationBatchSize is how many work packages GetValidationReport loads at a time
+const validationBatchSize = 200
+
+// GetValidationReport checks the matching work packages against the data quality rules.
+// Query parameters:
+//   - aircraftId, aircraftWorkPackageId, locationCode: comma-separated or repeated
+//   - isHistoric: true or false
+//   - severity: least severe level reported, error, warning or info (default)
+//   - rule: only report these rule IDs
+//   - page, limit: pagination of the issues (default limit 500, max 5000); counts cover all issues
+func (s *Server) GetValidationReport(c *gin.Context) {
+	filter := repository.WorkPackageFilter{
+		LocationCodes: queryStringList(c, "locationCode"),
+	}
+	var err error
+	if filter.AircraftIds, err = queryIntList(c, "aircraftId"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.WorkPackageIds, err = queryIntList(c, "aircraftWorkPackageId"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.IsHistoric, err = queryBool(c, "isHistoric"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	severity, err := validation.ParseSeverity(c.Query("severity"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rules := queryStringList(c, "rule")
+	for _, rule := range rules {
+		if !validation.KnownRule(rule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown rule: " + rule})
+			return
+		}
+	}
+
+	page := 1
+	limit := 500
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 5000 {
+			limit = l
+		}
+	}
+
+	// Check the packages a batch at a time, so only their issues are held in memory
+	report := validation.NewReport(severity)
+	report.Rules = rules
+	for skip := int64(0); ; skip += validationBatchSize {
+		workPackages, err := s.workPackages(c).Find(c.Request.Context(), filter,
+			repository.FindOptions{Skip: skip, Limit: validationBatchSize})
+		if err != nil {
+			log.Printf("Error loading work packages for validation: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database: " + err.Error()})
+			return
+		}
+		for _, wp := range workPackages {
+			report.Add(wp)
+		}
+		if len(workPackages) < validationBatchSize {
+			break
+		}
+	}
+	report.Sort()
+
+	total := len(report.Issues)
+	start := (page - 1) * limit
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	report.Issues = report.Issues[start:end]
+
+	log.Printf("Validated %d work packages: %d errors, %d warnings, %d info",
+		report.Checked, report.Counts[validation.Error], report.Counts[validation.Warning], report.Counts[validation.Info])
+
+	c.JSON(http.StatusOK, gin.H{
+		"report":     report,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (total + limit - 1) / limit,
+	})
+}
+
+// GetValidationRules lists the data quality rules and their severities
+func GetValidationRules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rules": validation.Rules})
+}
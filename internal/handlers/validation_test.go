@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
+	"stationMonitor/internal/validation"
+)
+
+func TestGetValidationReportChecksEveryBatch(t *testing.T) {
+	workPackages := []models.AircraftWorkPackage{}
+	for wpId := 1; wpId <= validationBatchSize+5; wpId++ {
+		wp := testWorkPackage(wpId)
+		if wpId%100 == 0 {
+			// The package ends before it starts
+			wp.SchedStartDateTime, wp.SchedEndDateTime = wp.SchedEndDateTime, wp.SchedStartDateTime
+		}
+		workPackages = append(workPackages, wp)
+	}
+	s := NewServer(testConfig(), repository.NewMemoryWorkPackages(workPackages...), repository.NewMemoryUsers())
+
+	w := serve(s.GetValidationReport, http.MethodGet, "/validation?rule="+validation.EndBeforeStart, "/validation", nil, "planner", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		Report validation.Report `json:"report"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	report := response.Report
+	if report.Checked != len(workPackages) || report.WorkPackagesWithIssues != 2 {
+		t.Errorf("checked %d packages, %d with issues, want %d and 2", report.Checked, report.WorkPackagesWithIssues, len(workPackages))
+	}
+	if len(report.Issues) != 2 || report.Issues[0].AircraftWorkPackageId != 100 || report.Issues[1].AircraftWorkPackageId != 200 {
+		t.Errorf("issues = %+v", report.Issues)
+	}
+}
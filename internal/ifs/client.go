@@ -0,0 +1,223 @@
+package ifs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"stationMonitor/internal/models"
+
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// workPackageExpand loads the tasks and execution instances together with the work package.
+// The navigation names are the ones the documents are stored under (see models.AircraftWorkPackage)
+const workPackageExpand = "AvTaskArray($expand=JtExecutionInstanceArray)"
+
+// Client reads from and writes to an IFS Cloud OData projection
+type Client struct {
+	BaseURL   string // projection service root, e.g. https://ifs.example.com/main/ifsapplications/projection/v1/AvAircraftWorkPackageHandling.svc
+	EntitySet string // e.g. AvAircraftWorkPackageSet
+	PageSize  int    // requested with Prefer: odata.maxpagesize; 0 leaves paging to the server
+	HTTP      *http.Client
+}
+
+// ClientConfig holds the connection settings of the IFS OData service
+type ClientConfig struct {
+	BaseURL      string
+	EntitySet    string
+	PageSize     int
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Timeout      time.Duration
+}
+
+// NewClient creates a client. If a token URL is configured, requests are authenticated
+// with the OAuth2 client credentials flow used by IFS Cloud
+func NewClient(cfg ClientConfig) *Client {
+	httpClient := &http.Client{}
+	if cfg.TokenURL != "" {
+		credentials := clientcredentials.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			TokenURL:     cfg.TokenURL,
+		}
+		httpClient = credentials.Client(context.Background())
+	}
+	httpClient.Timeout = cfg.Timeout
+	if httpClient.Timeout == 0 {
+		httpClient.Timeout = 60 * time.Second
+	}
+
+	return &Client{
+		BaseURL:   strings.TrimRight(cfg.BaseURL, "/"),
+		EntitySet: cfg.EntitySet,
+		PageSize:  cfg.PageSize,
+		HTTP:      httpClient,
+	}
+}
+
+// ODataError is an error response of the OData service
+type ODataError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *ODataError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("OData request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("OData request failed with status %d: %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+// readODataError decodes the error body of a failed response
+func readODataError(resp *http.Response) error {
+	odataErr := &ODataError{StatusCode: resp.StatusCode}
+	var body struct {
+		Error *ODataError `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if json.Unmarshal(data, &body) == nil && body.Error != nil {
+		odataErr.Code = body.Error.Code
+		odataErr.Message = body.Error.Message
+	} else {
+		odataErr.Message = strings.TrimSpace(string(data))
+	}
+	return odataErr
+}
+
+// workPackagePage is one page of an OData collection response
+type workPackagePage struct {
+	Value    []models.AircraftWorkPackage `json:"value"`
+	NextLink string                       `json:"@odata.nextLink"`
+}
+
+// FormatDateTime formats a time as an OData Edm.DateTimeOffset literal
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// WorkPackagesChangedSince fetches every work package changed after the watermark, with its
+// tasks and execution instances, oldest change first. A zero watermark fetches everything.
+// Server-driven paging (@odata.nextLink) is followed until the last page
+func (c *Client) WorkPackagesChangedSince(ctx context.Context, watermark time.Time) ([]models.AircraftWorkPackage, error) {
+	query := url.Values{}
+	if !watermark.IsZero() {
+		query.Set("$filter", "ChangedDate gt "+FormatDateTime(watermark))
+	}
+	query.Set("$expand", workPackageExpand)
+	query.Set("$orderby", "ChangedDate asc")
+
+	// Keep the OData system query options readable ($ unescaped) and encode spaces as %20,
+	// which every OData server accepts
+	next := c.BaseURL + "/" + c.EntitySet + "?" + strings.NewReplacer("%24", "$", "+", "%20").Replace(query.Encode())
+
+	workPackages := []models.AircraftWorkPackage{}
+	for next != "" {
+		page, err := c.getWorkPackagePage(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		workPackages = append(workPackages, page.Value...)
+
+		next = page.NextLink
+		if next != "" && !strings.HasPrefix(next, "http") {
+			next = c.BaseURL + "/" + strings.TrimLeft(next, "/")
+		}
+	}
+
+	return workPackages, nil
+}
+
+// getWorkPackagePage requests one page of work packages
+func (c *Client) getWorkPackagePage(ctx context.Context, pageURL string) (workPackagePage, error) {
+	var page workPackagePage
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return page, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.PageSize > 0 {
+		req.Header.Set("Prefer", fmt.Sprintf("odata.maxpagesize=%d", c.PageSize))
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return page, readODataError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return page, fmt.Errorf("failed to decode OData response: %w", err)
+	}
+	return page, nil
+}
+
+// AvExeTaskPath returns the resource path of an AvExeTask below its work package
+func (c *Client) AvExeTaskPath(aircraftWorkPackageId, taskSeq int) string {
+	return fmt.Sprintf("%s(AircraftWorkPackageId=%d)/AvTaskArray(TaskSeq=%d)", c.EntitySet, aircraftWorkPackageId, taskSeq)
+}
+
+// ExecutionInstancePath returns the resource path of a JtExecutionInstance below its task
+func (c *Client) ExecutionInstancePath(aircraftWorkPackageId, taskSeq, executionInstanceSeq int) string {
+	return fmt.Sprintf("%s/JtExecutionInstanceArray(TaskSeq=%d,ExecutionInstanceSeq=%d)",
+		c.AvExeTaskPath(aircraftWorkPackageId, taskSeq), taskSeq, executionInstanceSeq)
+}
+
+// Patch updates an entity with If-Match set to the given etag and returns the new etag
+// reported by IFS. An empty etag returns ErrNoETag; changes are never sent with If-Match: *.
+// The correlation ID is sent so IFS logs can be matched with the write-back queue
+func (c *Client) Patch(ctx context.Context, path, etag, correlationID string, fields map[string]interface{}) (string, error) {
+	if etag == "" {
+		return "", ErrNoETag
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, c.BaseURL+"/"+path, strings.NewReader(string(body)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("If-Match", etag)
+	if correlationID != "" {
+		req.Header.Set("X-Correlation-ID", correlationID)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return "", readODataError(resp)
+	}
+
+	// The new etag comes in the ETag header, or in the body when IFS returns the representation
+	newETag := resp.Header.Get("ETag")
+	if newETag == "" && resp.StatusCode == http.StatusOK {
+		var entity struct {
+			ETag string `json:"@odata.etag"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&entity) == nil {
+			newETag = entity.ETag
+		}
+	}
+	return newETag, nil
+}
@@ -0,0 +1,293 @@
+// Package ifstest provides a local stand-in for the IFS Cloud OData service,
+// for tests and local development without an IFS instance
+package ifstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stationMonitor/internal/models"
+)
+
+// Server serves work packages from memory the way the IFS projection does:
+// $filter=ChangedDate gt <date>, $expand of the task arrays, server-driven
+// paging honouring Prefer: odata.maxpagesize, and PATCH of tasks and execution
+// instances checked against If-Match
+type Server struct {
+	*httptest.Server
+	EntitySet string
+
+	mu           sync.Mutex
+	workPackages map[int]models.AircraftWorkPackage
+	requests     []string
+	patches      []Patch
+	failures     []failure
+	etagSeq      int
+}
+
+// Patch is a PATCH request received by the stand-in
+type Patch struct {
+	Path          string
+	IfMatch       string
+	CorrelationID string
+	Fields        map[string]interface{}
+}
+
+// failure is an error response queued with FailNext
+type failure struct {
+	status  int
+	code    string
+	message string
+}
+
+// patchPath matches the task and execution instance resources below a work package
+var patchPath = regexp.MustCompile(`^(\w+)\(AircraftWorkPackageId=(\d+)\)/AvTaskArray\(TaskSeq=(\d+)\)(?:/JtExecutionInstanceArray\(TaskSeq=\d+,ExecutionInstanceSeq=(\d+)\))?$`)
+
+// NewServer starts a stand-in serving the given work packages under /<entitySet>.
+// Close it when done
+func NewServer(entitySet string, workPackages ...models.AircraftWorkPackage) *Server {
+	s := &Server{
+		EntitySet:    entitySet,
+		workPackages: map[int]models.AircraftWorkPackage{},
+	}
+	for _, wp := range workPackages {
+		s.workPackages[wp.AircraftWorkPackageId] = wp
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Put adds or replaces a work package
+func (s *Server) Put(wp models.AircraftWorkPackage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workPackages[wp.AircraftWorkPackageId] = wp
+}
+
+// WorkPackage returns the stored copy of a work package
+func (s *Server) WorkPackage(aircraftWorkPackageId int) (models.AircraftWorkPackage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wp, found := s.workPackages[aircraftWorkPackageId]
+	return wp, found
+}
+
+// Requests returns the method and request URI of every request received so far
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// FailNext makes the next PATCH requests fail with the given status, one per call
+func (s *Server) FailNext(status int, code, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{status, code, message})
+}
+
+// Patches returns the PATCH requests received so far, including failed ones
+func (s *Server) Patches() []Patch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Patch(nil), s.patches...)
+}
+
+// writeError writes an OData error response
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"code": code, "message": message},
+	})
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if r.Method == http.MethodGet && path == s.EntitySet {
+		s.list(w, r)
+		return
+	}
+	if r.Method == http.MethodPatch {
+		if match := patchPath.FindStringSubmatch(path); match != nil && match[1] == s.EntitySet {
+			s.patch(w, r, path, match)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "Resource not found: "+r.URL.Path)
+}
+
+// parseChangedSince parses the only filter the stand-in supports: ChangedDate gt <date>
+func parseChangedSince(filter string) (*time.Time, error) {
+	if filter == "" {
+		return nil, nil
+	}
+	fields := strings.Fields(filter)
+	if len(fields) != 3 || fields[0] != "ChangedDate" || fields[1] != "gt" {
+		return nil, fmt.Errorf("unsupported filter: %s", filter)
+	}
+	since, err := time.Parse(time.RFC3339, fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid date in filter: %s", fields[2])
+	}
+	return &since, nil
+}
+
+// list serves the entity set
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	since, err := parseChangedSince(query.Get("$filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	matching := []models.AircraftWorkPackage{}
+	for _, wp := range s.workPackages {
+		if since != nil && (wp.ChangedDate == nil || !wp.ChangedDate.After(*since)) {
+			continue
+		}
+		if query.Get("$expand") == "" {
+			wp.Avexetask = nil
+		}
+		matching = append(matching, wp)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(matching, func(i, j int) bool {
+		a, b := matching[i].ChangedDate, matching[j].ChangedDate
+		if a == nil || b == nil || a.Equal(*b) {
+			return matching[i].AircraftWorkPackageId < matching[j].AircraftWorkPackageId
+		}
+		return a.Before(*b)
+	})
+
+	// Server-driven paging with a $skiptoken holding the offset
+	skip, _ := strconv.Atoi(query.Get("$skiptoken"))
+	if skip > len(matching) {
+		skip = len(matching)
+	}
+	page := matching[skip:]
+
+	response := map[string]interface{}{}
+	var pageSize int
+	fmt.Sscanf(r.Header.Get("Prefer"), "odata.maxpagesize=%d", &pageSize)
+	if pageSize > 0 && len(page) > pageSize {
+		page = page[:pageSize]
+		next := r.URL.Query()
+		next.Set("$skiptoken", strconv.Itoa(skip+pageSize))
+		response["@odata.nextLink"] = s.URL + r.URL.Path + "?" + next.Encode()
+	}
+	response["value"] = page
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// patch applies a PATCH to a task or execution instance. The body is merged into the stored
+// entity, which gets a new etag and ChangedDate
+func (s *Server) patch(w http.ResponseWriter, r *http.Request, path string, match []string) {
+	var fields map[string]interface{}
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &fields)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "Invalid request body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.patches = append(s.patches, Patch{
+		Path:          path,
+		IfMatch:       r.Header.Get("If-Match"),
+		CorrelationID: r.Header.Get("X-Correlation-ID"),
+		Fields:        fields,
+	})
+
+	if len(s.failures) > 0 {
+		next := s.failures[0]
+		s.failures = s.failures[1:]
+		writeError(w, next.status, next.code, next.message)
+		return
+	}
+
+	wpId, _ := strconv.Atoi(match[2])
+	taskSeq, _ := strconv.Atoi(match[3])
+	wp, found := s.workPackages[wpId]
+	if !found {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Work package not found")
+		return
+	}
+
+	var task *models.AvExeTask
+	for i := range wp.Avexetask {
+		if wp.Avexetask[i].TaskSeq == taskSeq {
+			task = &wp.Avexetask[i]
+		}
+	}
+	if task == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Task not found")
+		return
+	}
+
+	// Point at the etag and ChangedDate of the patched entity and decode the body into it
+	etag, changedDate := &task.ODataEtag, &task.ChangedDate
+	var target interface{} = task
+	if match[4] != "" {
+		instanceSeq, _ := strconv.Atoi(match[4])
+		var instance *models.JtExecutionInstance
+		for i := range task.JtExecutionInstanceArray {
+			if task.JtExecutionInstanceArray[i].ExecutionInstanceSeq == instanceSeq {
+				instance = &task.JtExecutionInstanceArray[i]
+			}
+		}
+		if instance == nil {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Execution instance not found")
+			return
+		}
+		etag, changedDate, target = &instance.ODataEtag, &instance.ChangedDate, instance
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		writeError(w, http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", "If-Match header is required")
+		return
+	}
+	if ifMatch != "*" && ifMatch != *etag {
+		writeError(w, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "The record has been modified by another user")
+		return
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+
+	s.etagSeq++
+	*etag = fmt.Sprintf("W/\"%d\"", s.etagSeq)
+	now := time.Now().UTC()
+	*changedDate = &now
+	wp.ChangedDate = &now
+	s.workPackages[wpId] = wp
+
+	w.Header().Set("ETag", *etag)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,306 @@
+package ifs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WatermarkCollection holds one watermark document per synchronised IFS entity
+const WatermarkCollection = "syncWatermarks"
+
+// Watermark records how far an IFS entity has been synchronised
+type Watermark struct {
+	Entity      string     `bson:"_id" json:"entity"`
+	ChangedDate time.Time  `bson:"ChangedDate" json:"changedDate"` // latest ChangedDate stored
+	LastRun     *time.Time `bson:"LastRun,omitempty" json:"lastRun,omitempty"`
+	LastSuccess *time.Time `bson:"LastSuccess,omitempty" json:"lastSuccess,omitempty"`
+	LastError   string     `bson:"LastError,omitempty" json:"lastError,omitempty"`
+	LastCount   int        `bson:"LastCount" json:"lastCount"`
+}
+
+// SyncResult summarises one sync run
+type SyncResult struct {
+	Fetched   int       `json:"fetched"`
+	Upserted  int       `json:"upserted"`
+	Updated   int       `json:"updated"`
+	Watermark time.Time `json:"watermark"`
+}
+
+// SyncStore keeps the synchronised work packages and the watermarks
+type SyncStore interface {
+	// LoadWatermark returns the watermark of an entity, or a zero watermark if it was never synchronised
+	LoadWatermark(ctx context.Context, entity string) (Watermark, error)
+	SaveWatermark(ctx context.Context, watermark Watermark) error
+	// MergeWorkPackage stores the IFS version of a work package, see MergeWorkPackage.
+	// Returns true if the work package was new
+	MergeWorkPackage(ctx context.Context, wp models.AircraftWorkPackage) (bool, error)
+}
+
+// Syncer copies changed work packages from IFS into the store
+type Syncer struct {
+	Client   *Client
+	Store    SyncStore
+	Interval time.Duration
+}
+
+// Run synchronises every Interval until the context is cancelled
+func (s *Syncer) Run(ctx context.Context) {
+	log.Printf("IFS sync started for %s (interval %s)", s.Client.EntitySet, s.Interval)
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if result, err := s.SyncOnce(ctx); err != nil {
+			log.Printf("IFS sync of %s failed: %v", s.Client.EntitySet, err)
+		} else if result.Fetched > 0 {
+			log.Printf("IFS sync of %s: fetched %d, inserted %d, updated %d, watermark %s",
+				s.Client.EntitySet, result.Fetched, result.Upserted, result.Updated, FormatDateTime(result.Watermark))
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("IFS sync stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncOnce fetches the work packages changed since the stored watermark and merges them by
+// AircraftWorkPackageId. The watermark only advances once every fetched document is stored,
+// so a failed run is retried from the same point
+func (s *Syncer) SyncOnce(ctx context.Context) (SyncResult, error) {
+	entity := s.Client.EntitySet
+	started := time.Now().UTC()
+
+	watermark, err := s.Store.LoadWatermark(ctx, entity)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	result := SyncResult{Watermark: watermark.ChangedDate}
+
+	workPackages, err := s.Client.WorkPackagesChangedSince(ctx, watermark.ChangedDate)
+	if err != nil {
+		s.recordRun(ctx, watermark, started, result, err)
+		return result, err
+	}
+	result.Fetched = len(workPackages)
+
+	for _, wp := range workPackages {
+		if wp.AircraftWorkPackageId == 0 {
+			log.Printf("IFS sync: skipping work package without AircraftWorkPackageId (keyref %q)", wp.Keyref)
+			continue
+		}
+
+		upserted, err := s.Store.MergeWorkPackage(ctx, wp)
+		if err != nil {
+			s.recordRun(ctx, watermark, started, result, err)
+			return result, err
+		}
+		if upserted {
+			result.Upserted++
+		} else {
+			result.Updated++
+		}
+
+		if wp.ChangedDate != nil && wp.ChangedDate.After(result.Watermark) {
+			result.Watermark = *wp.ChangedDate
+		}
+	}
+
+	s.recordRun(ctx, watermark, started, result, nil)
+	return result, nil
+}
+
+// recordRun stores the outcome of a run and, on success, the new watermark
+func (s *Syncer) recordRun(ctx context.Context, watermark Watermark, started time.Time, result SyncResult, runErr error) {
+	watermark.LastRun = &started
+	watermark.LastCount = result.Fetched
+	if runErr != nil {
+		watermark.LastError = runErr.Error()
+	} else {
+		watermark.LastError = ""
+		watermark.LastSuccess = &started
+		watermark.ChangedDate = result.Watermark
+	}
+
+	if err := s.Store.SaveWatermark(ctx, watermark); err != nil {
+		log.Printf("IFS sync: failed to store watermark of %s: %v", watermark.Entity, err)
+	}
+}
+
+// MergeWorkPackage returns the IFS version of a work package merged into the stored one.
+// IFS owns the document, except for what this service records on it: the request state of the
+// last write-back of a task is kept, and so are the dates of tasks and execution instances
+// with a change in unsent, which the chart shows until IFS has it. unsent holds the pending
+// and processing write-backs of the work package
+func MergeWorkPackage(stored, incoming models.AircraftWorkPackage, unsent []models.IFSWriteBack) models.AircraftWorkPackage {
+	merged := incoming
+	merged.ID = stored.ID
+	merged.Avexetask = append([]models.AvExeTask(nil), incoming.Avexetask...)
+
+	storedTasks := map[int]models.AvExeTask{}
+	for _, task := range stored.Avexetask {
+		storedTasks[task.TaskSeq] = task
+	}
+
+	for i := range merged.Avexetask {
+		task := &merged.Avexetask[i]
+		old, found := storedTasks[task.TaskSeq]
+		if !found {
+			continue
+		}
+
+		if old.RequestStateCd != nil {
+			task.RequestStateCd = old.RequestStateCd
+			task.RequestCorrelationId = old.RequestCorrelationId
+			task.RequestErrorMessage = old.RequestErrorMessage
+			task.RequestOperation = old.RequestOperation
+		}
+
+		task.JtExecutionInstanceArray = append([]models.JtExecutionInstance(nil), task.JtExecutionInstanceArray...)
+		for _, item := range unsent {
+			if item.TaskSeq != task.TaskSeq {
+				continue
+			}
+			if item.ExecutionInstanceSeq == nil {
+				task.PlannedStart, task.PlannedFinish, task.Duration = old.PlannedStart, old.PlannedFinish, old.Duration
+				continue
+			}
+			oldInstance := findInstance(old.JtExecutionInstanceArray, *item.ExecutionInstanceSeq)
+			instance := findInstance(task.JtExecutionInstanceArray, *item.ExecutionInstanceSeq)
+			if oldInstance != nil && instance != nil {
+				instance.AllocatedStart, instance.AllocatedFinish = oldInstance.AllocatedStart, oldInstance.AllocatedFinish
+			}
+		}
+	}
+	return merged
+}
+
+// findInstance returns the execution instance with the given sequence or nil
+func findInstance(instances []models.JtExecutionInstance, executionInstanceSeq int) *models.JtExecutionInstance {
+	for i := range instances {
+		if instances[i].ExecutionInstanceSeq == executionInstanceSeq {
+			return &instances[i]
+		}
+	}
+	return nil
+}
+
+// MongoSyncStore keeps the synchronised work packages and watermarks in MongoDB. Queue is the
+// write-back queue whose unsent changes are kept by the merge
+type MongoSyncStore struct {
+	WorkPackages *mongo.Collection
+	Watermarks   *mongo.Collection
+	Queue        *mongo.Collection
+}
+
+// LoadWatermark returns the watermark of an entity, or a zero watermark if it was never synchronised
+func (s *MongoSyncStore) LoadWatermark(ctx context.Context, entity string) (Watermark, error) {
+	watermark := Watermark{Entity: entity}
+	err := s.Watermarks.FindOne(ctx, bson.M{"_id": entity}).Decode(&watermark)
+	if err == mongo.ErrNoDocuments {
+		return watermark, nil
+	}
+	return watermark, err
+}
+
+// SaveWatermark stores the watermark of an entity
+func (s *MongoSyncStore) SaveWatermark(ctx context.Context, watermark Watermark) error {
+	_, err := s.Watermarks.ReplaceOne(ctx, bson.M{"_id": watermark.Entity}, watermark, options.Replace().SetUpsert(true))
+	return err
+}
+
+// MergeWorkPackage inserts a new work package, or $sets the merged IFS version on the stored
+// one, so fields IFS does not send are kept. The update is guarded by the ChangedDate of every
+// task and execution instance, chart edits made meanwhile are merged on the next attempt
+func (s *MongoSyncStore) MergeWorkPackage(ctx context.Context, wp models.AircraftWorkPackage) (bool, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		var stored models.AircraftWorkPackage
+		err := s.WorkPackages.FindOne(ctx, bson.M{"AircraftWorkPackageId": wp.AircraftWorkPackageId}).Decode(&stored)
+		if err == mongo.ErrNoDocuments {
+			wp.ID = primitive.NilObjectID
+			_, err := s.WorkPackages.InsertOne(ctx, wp)
+			if mongo.IsDuplicateKeyError(err) {
+				// Inserted concurrently, merge into it
+				continue
+			}
+			return err == nil, err
+		}
+		if err != nil {
+			return false, err
+		}
+
+		unsent, err := s.unsentWriteBacks(ctx, wp.AircraftWorkPackageId)
+		if err != nil {
+			return false, err
+		}
+
+		set, err := toBsonM(MergeWorkPackage(stored, wp, unsent))
+		if err != nil {
+			return false, err
+		}
+		delete(set, "_id")
+
+		guards := []bson.M{{"_id": stored.ID}}
+		for _, task := range stored.Avexetask {
+			guards = append(guards, bson.M{"AvTaskArray": bson.M{"$elemMatch": bson.M{"TaskSeq": task.TaskSeq, "ChangedDate": task.ChangedDate}}})
+			for _, instance := range task.JtExecutionInstanceArray {
+				guards = append(guards, bson.M{"AvTaskArray": bson.M{"$elemMatch": bson.M{
+					"TaskSeq": task.TaskSeq,
+					"JtExecutionInstanceArray": bson.M{"$elemMatch": bson.M{
+						"ExecutionInstanceSeq": instance.ExecutionInstanceSeq,
+						"ChangedDate":          instance.ChangedDate,
+					}},
+				}}})
+			}
+		}
+
+		result, err := s.WorkPackages.UpdateOne(ctx, bson.M{"$and": guards}, bson.M{"$set": set})
+		if err != nil {
+			return false, err
+		}
+		if result.MatchedCount > 0 {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("work package %d changed concurrently", wp.AircraftWorkPackageId)
+}
+
+// unsentWriteBacks returns the queued changes of a work package that IFS does not have yet
+func (s *MongoSyncStore) unsentWriteBacks(ctx context.Context, aircraftWorkPackageId int) ([]models.IFSWriteBack, error) {
+	unsent := []models.IFSWriteBack{}
+	if s.Queue == nil {
+		return unsent, nil
+	}
+	cursor, err := s.Queue.Find(ctx, bson.M{
+		"AircraftWorkPackageId": aircraftWorkPackageId,
+		"Status":                bson.M{"$in": []string{WriteBackPending, WriteBackProcessing}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &unsent)
+	return unsent, err
+}
+
+// toBsonM converts a document to a map of its top-level fields
+func toBsonM(doc interface{}) (bson.M, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var m bson.M
+	err = bson.Unmarshal(data, &m)
+	return m, err
+}
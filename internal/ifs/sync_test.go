@@ -0,0 +1,209 @@
+package ifs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/ifs/ifstest"
+	"stationMonitor/internal/models"
+)
+
+// memorySyncStore keeps synchronised work packages in memory. Merging fails for the work
+// package failWpId
+type memorySyncStore struct {
+	workPackages map[int]models.AircraftWorkPackage
+	watermarks   map[string]Watermark
+	unsent       []models.IFSWriteBack
+	failWpId     int
+}
+
+func newMemorySyncStore() *memorySyncStore {
+	return &memorySyncStore{workPackages: map[int]models.AircraftWorkPackage{}, watermarks: map[string]Watermark{}}
+}
+
+func (s *memorySyncStore) LoadWatermark(ctx context.Context, entity string) (Watermark, error) {
+	if watermark, found := s.watermarks[entity]; found {
+		return watermark, nil
+	}
+	return Watermark{Entity: entity}, nil
+}
+
+func (s *memorySyncStore) SaveWatermark(ctx context.Context, watermark Watermark) error {
+	s.watermarks[watermark.Entity] = watermark
+	return nil
+}
+
+func (s *memorySyncStore) MergeWorkPackage(ctx context.Context, wp models.AircraftWorkPackage) (bool, error) {
+	if wp.AircraftWorkPackageId == s.failWpId {
+		return false, errors.New("store unavailable")
+	}
+	stored, found := s.workPackages[wp.AircraftWorkPackageId]
+	if !found {
+		s.workPackages[wp.AircraftWorkPackageId] = wp
+		return true, nil
+	}
+	s.workPackages[wp.AircraftWorkPackageId] = MergeWorkPackage(stored, wp, s.unsent)
+	return false, nil
+}
+
+// syncTestWorkPackage returns a work package changed at changed with one task that has an
+// execution instance
+func syncTestWorkPackage(wpId int, changed time.Time) models.AircraftWorkPackage {
+	start := changed.Add(24 * time.Hour)
+	finish := start.Add(8 * time.Hour)
+	return models.AircraftWorkPackage{
+		AircraftWorkPackageId: wpId,
+		ChangedDate:           &changed,
+		Avexetask: []models.AvExeTask{{
+			TaskSeq:       1,
+			ODataEtag:     fmt.Sprintf(`W/"%d"`, wpId),
+			PlannedStart:  &start,
+			PlannedFinish: &finish,
+			JtExecutionInstanceArray: []models.JtExecutionInstance{
+				{ExecutionInstanceSeq: 1, AllocatedStart: &start, AllocatedFinish: &finish},
+			},
+		}},
+	}
+}
+
+func countListRequests(requests []string) int {
+	count := 0
+	for _, request := range requests {
+		if strings.HasPrefix(request, http.MethodGet+" ") {
+			count++
+		}
+	}
+	return count
+}
+
+// requestQuery returns the query parameters of a request recorded by the stand-in
+func requestQuery(t *testing.T, request string) url.Values {
+	t.Helper()
+	_, uri, _ := strings.Cut(request, " ")
+	parsed, err := url.ParseRequestURI(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Query()
+}
+
+func TestSyncOncePagesAndAdvancesWatermark(t *testing.T) {
+	base := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	var workPackages []models.AircraftWorkPackage
+	for i := 1; i <= 5; i++ {
+		workPackages = append(workPackages, syncTestWorkPackage(i, base.Add(time.Duration(i)*time.Hour)))
+	}
+	server := ifstest.NewServer("AvAircraftWorkPackageSet", workPackages...)
+	defer server.Close()
+
+	store := newMemorySyncStore()
+	syncer := &Syncer{
+		Client: &Client{BaseURL: server.URL, EntitySet: server.EntitySet, PageSize: 2, HTTP: server.Client()},
+		Store:  store,
+	}
+
+	result, err := syncer.SyncOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Fetched != 5 || result.Upserted != 5 || !result.Watermark.Equal(base.Add(5*time.Hour)) {
+		t.Errorf("first run = %+v", result)
+	}
+	requests := server.Requests()
+	if got := countListRequests(requests); got != 3 {
+		t.Errorf("pages requested = %d, want 3: %v", got, requests)
+	}
+	if query := requestQuery(t, requests[0]); query.Get("$expand") != "AvTaskArray($expand=JtExecutionInstanceArray)" || query.Has("$filter") {
+		t.Errorf("first request = %s", requests[0])
+	}
+	if tasks := store.workPackages[3].Avexetask; len(tasks) != 1 || len(tasks[0].JtExecutionInstanceArray) != 1 {
+		t.Errorf("expanded tasks of work package 3 = %+v", tasks)
+	}
+	if watermark := store.watermarks[server.EntitySet]; watermark.LastSuccess == nil || watermark.LastCount != 5 {
+		t.Errorf("stored watermark = %+v", watermark)
+	}
+
+	// Only packages changed after the watermark are fetched
+	changed := syncTestWorkPackage(2, base.Add(6*time.Hour))
+	server.Put(changed)
+	result, err = syncer.SyncOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Fetched != 1 || result.Updated != 1 || !result.Watermark.Equal(base.Add(6*time.Hour)) {
+		t.Errorf("second run = %+v", result)
+	}
+	requests = server.Requests()
+	if last := requests[len(requests)-1]; requestQuery(t, last).Get("$filter") != "ChangedDate gt 2026-05-01T13:00:00Z" {
+		t.Errorf("second run request = %s", last)
+	}
+
+	// A failed run keeps the watermark, so the next run fetches the same changes again
+	server.Put(syncTestWorkPackage(4, base.Add(7*time.Hour)))
+	store.failWpId = 4
+	if _, err := syncer.SyncOnce(context.Background()); err == nil {
+		t.Fatal("run with a failing store succeeded")
+	}
+	watermark := store.watermarks[server.EntitySet]
+	if !watermark.ChangedDate.Equal(base.Add(6*time.Hour)) || watermark.LastError == "" {
+		t.Errorf("watermark after a failed run = %+v", watermark)
+	}
+
+	store.failWpId = 0
+	if result, err = syncer.SyncOnce(context.Background()); err != nil || result.Fetched != 1 {
+		t.Errorf("run after the failure = %+v, %v", result, err)
+	}
+}
+
+func TestMergeWorkPackageKeepsLocalFields(t *testing.T) {
+	changed := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	stored := syncTestWorkPackage(1, changed)
+	state, correlationId := RequestStateError, "abc"
+	stored.Avexetask[0].RequestStateCd = &state
+	stored.Avexetask[0].RequestCorrelationId = &correlationId
+	edited := changed.Add(48 * time.Hour)
+	stored.Avexetask[0].PlannedStart = &edited
+	stored.Avexetask[0].JtExecutionInstanceArray[0].AllocatedStart = &edited
+
+	incoming := syncTestWorkPackage(1, changed.Add(time.Hour))
+	incoming.Avexetask[0].ODataEtag = `W/"2"`
+	instanceSeq := 1
+
+	tests := []struct {
+		name          string
+		unsent        []models.IFSWriteBack
+		plannedStart  time.Time
+		instanceStart time.Time
+	}{
+		{"nothing queued", nil, *incoming.Avexetask[0].PlannedStart, *incoming.Avexetask[0].PlannedStart},
+		{"task queued", []models.IFSWriteBack{{AircraftWorkPackageId: 1, TaskSeq: 1}}, edited, *incoming.Avexetask[0].PlannedStart},
+		{"instance queued", []models.IFSWriteBack{{AircraftWorkPackageId: 1, TaskSeq: 1, ExecutionInstanceSeq: &instanceSeq}}, *incoming.Avexetask[0].PlannedStart, edited},
+	}
+	for _, tt := range tests {
+		merged := MergeWorkPackage(stored, incoming, tt.unsent)
+		task := merged.Avexetask[0]
+		if task.RequestStateCd == nil || *task.RequestStateCd != state || task.RequestCorrelationId == nil || *task.RequestCorrelationId != correlationId {
+			t.Errorf("%s: request state not kept: %+v", tt.name, task)
+		}
+		if task.ODataEtag != `W/"2"` {
+			t.Errorf("%s: etag = %s, want the IFS one", tt.name, task.ODataEtag)
+		}
+		if !task.PlannedStart.Equal(tt.plannedStart) {
+			t.Errorf("%s: PlannedStart = %s, want %s", tt.name, task.PlannedStart, tt.plannedStart)
+		}
+		if start := task.JtExecutionInstanceArray[0].AllocatedStart; !start.Equal(tt.instanceStart) {
+			t.Errorf("%s: AllocatedStart = %s, want %s", tt.name, start, tt.instanceStart)
+		}
+	}
+
+	// The incoming document is not changed by a merge
+	if !incoming.Avexetask[0].PlannedStart.Equal(changed.Add(25 * time.Hour)) {
+		t.Errorf("incoming document was modified")
+	}
+}
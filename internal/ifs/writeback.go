@@ -0,0 +1,518 @@
+package ifs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WriteBackCollection holds the queue of planning changes to send to IFS
+const WriteBackCollection = "ifsWriteBackQueue"
+
+// Write-back queue states
+const (
+	WriteBackPending    = "pending"
+	WriteBackProcessing = "processing"
+	WriteBackDone       = "done"
+	WriteBackFailed     = "failed"     // rejected by IFS or out of attempts
+	WriteBackConflict   = "conflict"   // the etag no longer matched; the next sync brings the IFS version
+	WriteBackSuperseded = "superseded" // replaced by a later change of the same target before it was sent
+)
+
+var (
+	// ErrNoETag is returned for a change queued without the etag of its target. It is treated as
+	// a conflict, since the version the change was made on is unknown
+	ErrNoETag = errors.New("the change has no etag of the version it was made on")
+	// ErrWriteBackSuperseded is returned when a queue item is retried while a later change of
+	// the same target is pending
+	ErrWriteBackSuperseded = errors.New("a later change of the same target is queued")
+)
+
+// RequestStateCd values recorded on the AvExeTask after a write-back
+const (
+	RequestStateSuccess = "Success"
+	RequestStateError   = "Error"
+)
+
+// NewCorrelationID returns a random ID to trace one write-back through IFS. Should the system
+// random source fail, it falls back to an ObjectID, which is still unique
+func NewCorrelationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return primitive.NewObjectID().Hex()
+	}
+	return hex.EncodeToString(b)
+}
+
+// targetFilter selects the queue items changing the same task or execution instance as item
+func targetFilter(item models.IFSWriteBack) bson.M {
+	filter := bson.M{
+		"AircraftWorkPackageId": item.AircraftWorkPackageId,
+		"TaskSeq":               item.TaskSeq,
+		"ExecutionInstanceSeq":  bson.M{"$exists": false},
+	}
+	if item.ExecutionInstanceSeq != nil {
+		filter["ExecutionInstanceSeq"] = *item.ExecutionInstanceSeq
+	}
+	return filter
+}
+
+// WriteBackStore keeps the write-back queue drained by the WriteBackWorker and the work packages
+// the outcome of each change is recorded on
+type WriteBackStore interface {
+	// Claim marks the oldest item due at now, or left processing by a crashed worker, as
+	// processing until lockedUntil and returns it. Returns ErrNoWriteBackDue if there is none
+	Claim(ctx context.Context, now, lockedUntil time.Time) (models.IFSWriteBack, error)
+	// Finish releases the claim on an item with the outcome of sending it. An item put back in
+	// the queue while a later change of its target was enqueued is superseded by that change
+	Finish(ctx context.Context, item models.IFSWriteBack, outcome WriteBackOutcome, at time.Time) error
+	// AdvanceETag moves pending changes of the target of item made on the version item was made
+	// on to newETag, the version item created
+	AdvanceETag(ctx context.Context, item models.IFSWriteBack, newETag string) error
+	// RecordRequestState stores the request state of item on its AvExeTask and, if newETag is
+	// not empty, the new etag of the updated task or execution instance, see applyRequestState
+	RecordRequestState(ctx context.Context, item models.IFSWriteBack, state, errorMessage, newETag string) error
+}
+
+// ErrNoWriteBackDue is returned by WriteBackStore.Claim when no queue item is due
+var ErrNoWriteBackDue = errors.New("no write-back is due")
+
+// WriteBackOutcome is the state a queue item is left in after an attempt to send it
+type WriteBackOutcome struct {
+	Status      string
+	LastError   string
+	Attempts    int
+	NextAttempt time.Time // when a pending item is tried again
+}
+
+// MongoWriteBackStore keeps the write-back queue in MongoDB and records outcomes on the work
+// packages collection. A unique index keeps one pending item per target
+type MongoWriteBackStore struct {
+	WorkPackages *mongo.Collection
+	Queue        *mongo.Collection
+}
+
+// supersede marks the items of the target of item in one of the states as superseded
+func (s *MongoWriteBackStore) supersede(ctx context.Context, item models.IFSWriteBack, states []string, at time.Time) error {
+	filter := targetFilter(item)
+	filter["Status"] = bson.M{"$in": states}
+	if !item.ID.IsZero() {
+		filter["_id"] = bson.M{"$ne": item.ID}
+	}
+	_, err := s.Queue.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"Status": WriteBackSuperseded, "UpdatedDate": at}})
+	return err
+}
+
+// Enqueue queues a planning change. A change still pending for the same task or execution
+// instance is replaced, so only the latest dates are sent, and failed or conflicting changes of
+// it are superseded
+func (s *MongoWriteBackStore) Enqueue(ctx context.Context, item models.IFSWriteBack) error {
+	now := time.Now().UTC()
+
+	if err := s.supersede(ctx, item, []string{WriteBackFailed, WriteBackConflict}, now); err != nil {
+		return err
+	}
+
+	filter := targetFilter(item)
+	filter["Status"] = WriteBackPending
+	set := bson.M{
+		"ETag":        item.ETag,
+		"Attempts":    0,
+		"NextAttempt": now,
+		"UpdatedDate": now,
+		"LastError":   "",
+	}
+	if item.ExecutionInstanceSeq == nil {
+		set["PlannedStart"] = item.PlannedStart
+		set["PlannedFinish"] = item.PlannedFinish
+		set["Duration"] = item.Duration
+	} else {
+		set["AllocatedStart"] = item.AllocatedStart
+		set["AllocatedFinish"] = item.AllocatedFinish
+	}
+	if item.CreatedBy != "" {
+		set["CreatedBy"] = item.CreatedBy
+	}
+
+	update := bson.M{
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"CorrelationId": NewCorrelationID(),
+			"CreatedDate":   now,
+		},
+	}
+	_, err := s.Queue.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent enqueue inserted the pending item first, update it instead
+		_, err = s.Queue.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	}
+	return err
+}
+
+// Retry puts a failed or conflicting item back in the queue and supersedes the other failed or
+// conflicting items of its target. It returns mongo.ErrNoDocuments if there is no item with the
+// ID in either state, and ErrWriteBackSuperseded, after superseding the item, if a later change
+// of the target is pending
+func (s *MongoWriteBackStore) Retry(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	retryable := bson.M{"_id": id, "Status": bson.M{"$in": []string{WriteBackFailed, WriteBackConflict}}}
+
+	var item models.IFSWriteBack
+	if err := s.Queue.FindOne(ctx, retryable).Decode(&item); err != nil {
+		return err
+	}
+
+	pending := targetFilter(item)
+	pending["Status"] = WriteBackPending
+	count, err := s.Queue.CountDocuments(ctx, pending)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		if err := s.supersede(ctx, item, []string{WriteBackFailed, WriteBackConflict}, at); err != nil {
+			return err
+		}
+		result, err := s.Queue.UpdateOne(ctx, retryable,
+			bson.M{"$set": bson.M{"Status": WriteBackPending, "Attempts": 0, "NextAttempt": at, "UpdatedDate": at}})
+		if err == nil && result.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		// A change was enqueued concurrently
+	}
+
+	if _, err := s.Queue.UpdateOne(ctx, retryable, bson.M{"$set": bson.M{"Status": WriteBackSuperseded, "UpdatedDate": at}}); err != nil {
+		return err
+	}
+	return ErrWriteBackSuperseded
+}
+
+// Claim marks the oldest due item as processing and returns it
+func (s *MongoWriteBackStore) Claim(ctx context.Context, now, lockedUntil time.Time) (models.IFSWriteBack, error) {
+	var item models.IFSWriteBack
+	err := s.Queue.FindOneAndUpdate(ctx,
+		bson.M{"$or": []bson.M{
+			{"Status": WriteBackPending, "NextAttempt": bson.M{"$lte": now}},
+			{"Status": WriteBackProcessing, "LockedUntil": bson.M{"$lte": now}},
+		}},
+		bson.M{"$set": bson.M{"Status": WriteBackProcessing, "LockedUntil": lockedUntil, "UpdatedDate": now}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "NextAttempt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return item, ErrNoWriteBackDue
+	}
+	return item, err
+}
+
+// Finish releases the claim on a queue item with the given outcome
+func (s *MongoWriteBackStore) Finish(ctx context.Context, item models.IFSWriteBack, outcome WriteBackOutcome, at time.Time) error {
+	set := bson.M{
+		"Status":      outcome.Status,
+		"LastError":   outcome.LastError,
+		"Attempts":    outcome.Attempts,
+		"UpdatedDate": at,
+	}
+	if outcome.Status == WriteBackPending {
+		set["NextAttempt"] = outcome.NextAttempt
+	}
+	update := bson.M{
+		"$set":   set,
+		"$unset": bson.M{"LockedUntil": ""},
+	}
+	_, err := s.Queue.UpdateOne(ctx, bson.M{"_id": item.ID}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		set["Status"] = WriteBackSuperseded
+		_, err = s.Queue.UpdateOne(ctx, bson.M{"_id": item.ID}, update)
+	}
+	return err
+}
+
+// AdvanceETag moves the pending changes of the target of item to the version it created
+func (s *MongoWriteBackStore) AdvanceETag(ctx context.Context, item models.IFSWriteBack, newETag string) error {
+	filter := targetFilter(item)
+	filter["Status"] = WriteBackPending
+	filter["ETag"] = item.ETag
+	_, err := s.Queue.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"ETag": newETag}})
+	return err
+}
+
+// RecordRequestState stores the request state on the AvExeTask.
+// The etag key contains a dot and cannot be addressed by an update path, so the whole task
+// element is replaced; the ChangedDate guards make sure a concurrent chart edit is not lost
+func (s *MongoWriteBackStore) RecordRequestState(ctx context.Context, item models.IFSWriteBack, state, errorMessage, newETag string) error {
+	for attempt := 0; attempt < 3; attempt++ {
+		var wp models.AircraftWorkPackage
+		if err := s.WorkPackages.FindOne(ctx, bson.M{"AircraftWorkPackageId": item.AircraftWorkPackageId}).Decode(&wp); err != nil {
+			return err
+		}
+
+		var task *models.AvExeTask
+		for i := range wp.Avexetask {
+			if wp.Avexetask[i].TaskSeq == item.TaskSeq {
+				task = &wp.Avexetask[i]
+			}
+		}
+		if task == nil {
+			return nil
+		}
+		changedDate := task.ChangedDate
+		applyRequestState(task, item, state, errorMessage, newETag)
+
+		// Guard the task and each of its instances, chart edits bump their own ChangedDate
+		guards := []bson.M{{"AvTaskArray": bson.M{"$elemMatch": bson.M{"TaskSeq": item.TaskSeq, "ChangedDate": changedDate}}}}
+		for _, instance := range task.JtExecutionInstanceArray {
+			guards = append(guards, bson.M{"AvTaskArray": bson.M{"$elemMatch": bson.M{
+				"TaskSeq": item.TaskSeq,
+				"JtExecutionInstanceArray": bson.M{"$elemMatch": bson.M{
+					"ExecutionInstanceSeq": instance.ExecutionInstanceSeq,
+					"ChangedDate":          instance.ChangedDate,
+				}},
+			}}})
+		}
+
+		result, err := s.WorkPackages.UpdateOne(ctx,
+			bson.M{"_id": wp.ID, "$and": guards},
+			bson.M{"$set": bson.M{"AvTaskArray.$[t]": task}},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"t.TaskSeq": item.TaskSeq}}}))
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount > 0 {
+			return nil
+		}
+	}
+	return errors.New("task changed concurrently, request state not recorded")
+}
+
+// applyRequestState sets the request fields of task (execution instances have none) and, on
+// success, the new etag of the entity item updated
+func applyRequestState(task *models.AvExeTask, item models.IFSWriteBack, state, errorMessage, newETag string) {
+	task.RequestStateCd = &state
+	correlationID := item.CorrelationId
+	task.RequestCorrelationId = &correlationID
+	task.RequestErrorMessage = nil
+	if errorMessage != "" {
+		task.RequestErrorMessage = &errorMessage
+	}
+	operation := "PATCH"
+	task.RequestOperation = &operation
+
+	if newETag == "" {
+		return
+	}
+	if item.ExecutionInstanceSeq == nil {
+		task.ODataEtag = newETag
+		return
+	}
+	for i := range task.JtExecutionInstanceArray {
+		if task.JtExecutionInstanceArray[i].ExecutionInstanceSeq == *item.ExecutionInstanceSeq {
+			task.JtExecutionInstanceArray[i].ODataEtag = newETag
+		}
+	}
+}
+
+// WriteBackWorker sends queued planning changes to IFS with OData PATCH requests
+type WriteBackWorker struct {
+	Client      *Client
+	Store       WriteBackStore
+	Interval    time.Duration
+	MaxAttempts int              // attempts before an item is marked failed (default 8)
+	Backoff     time.Duration    // delay after the first failure, doubled after every further one (default 30s)
+	LockTimeout time.Duration    // after this an item left processing by a crashed worker is picked up again (default 5m)
+	Now         func() time.Time // clock, time.Now if nil
+}
+
+func (w *WriteBackWorker) now() time.Time {
+	if w.Now != nil {
+		return w.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (w *WriteBackWorker) maxAttempts() int {
+	if w.MaxAttempts > 0 {
+		return w.MaxAttempts
+	}
+	return 8
+}
+
+func (w *WriteBackWorker) backoff(attempts int) time.Duration {
+	delay := w.Backoff
+	if delay <= 0 {
+		delay = 30 * time.Second
+	}
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
+
+func (w *WriteBackWorker) lockTimeout() time.Duration {
+	if w.LockTimeout > 0 {
+		return w.LockTimeout
+	}
+	return 5 * time.Minute
+}
+
+// Run drains the queue every Interval until the context is cancelled
+func (w *WriteBackWorker) Run(ctx context.Context) {
+	log.Printf("IFS write-back started (interval %s)", w.Interval)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		if sent, err := w.ProcessDue(ctx); err != nil {
+			log.Printf("IFS write-back error: %v", err)
+		} else if sent > 0 {
+			log.Printf("IFS write-back processed %d changes", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("IFS write-back stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue sends every queued change that is due and returns how many were processed
+func (w *WriteBackWorker) ProcessDue(ctx context.Context) (int, error) {
+	processed := 0
+	for {
+		now := w.now()
+		item, err := w.Store.Claim(ctx, now, now.Add(w.lockTimeout()))
+		if err == ErrNoWriteBackDue {
+			return processed, nil
+		}
+		if err != nil {
+			return processed, err
+		}
+
+		w.process(ctx, item)
+		processed++
+	}
+}
+
+// process sends one change and records the outcome on the queue item and the work package
+func (w *WriteBackWorker) process(ctx context.Context, item models.IFSWriteBack) {
+	newETag, err := w.send(ctx, item)
+	now := w.now()
+
+	if err == nil {
+		w.finish(ctx, item, WriteBackOutcome{Status: WriteBackDone, Attempts: item.Attempts}, now)
+		w.recordRequestState(ctx, item, RequestStateSuccess, "", newETag)
+		w.advanceETag(ctx, item, newETag)
+		log.Printf("IFS write-back %s: updated %s", item.CorrelationId, w.describe(item))
+		return
+	}
+
+	outcome := WriteBackOutcome{LastError: err.Error(), Attempts: item.Attempts + 1}
+	var odataErr *ODataError
+	switch {
+	case errors.Is(err, ErrNoETag):
+		outcome.Status = WriteBackConflict
+	case errors.As(err, &odataErr) && odataErr.StatusCode == http.StatusPreconditionFailed:
+		outcome.Status = WriteBackConflict
+	case errors.As(err, &odataErr) && !isRetryableStatus(odataErr.StatusCode):
+		outcome.Status = WriteBackFailed
+	case item.Attempts+1 >= w.maxAttempts():
+		outcome.Status = WriteBackFailed
+	default:
+		outcome.Status = WriteBackPending
+		outcome.NextAttempt = now.Add(w.backoff(item.Attempts + 1))
+	}
+
+	w.finish(ctx, item, outcome, now)
+	w.recordRequestState(ctx, item, RequestStateError, err.Error(), "")
+	log.Printf("IFS write-back %s: %s failed (%s): %v", item.CorrelationId, w.describe(item), outcome.Status, err)
+}
+
+// isRetryableStatus reports whether a failed request may succeed when repeated
+func isRetryableStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// describe names the target of a queue item for logging
+func (w *WriteBackWorker) describe(item models.IFSWriteBack) string {
+	if item.ExecutionInstanceSeq != nil {
+		return fmt.Sprintf("execution instance %d of task %d (WP %d)", *item.ExecutionInstanceSeq, item.TaskSeq, item.AircraftWorkPackageId)
+	}
+	return fmt.Sprintf("task %d (WP %d)", item.TaskSeq, item.AircraftWorkPackageId)
+}
+
+// advanceETag moves a change of the target queued on the version item was made on to the
+// version item created, since IFS now holds that version
+func (w *WriteBackWorker) advanceETag(ctx context.Context, item models.IFSWriteBack, newETag string) {
+	if newETag == "" {
+		return
+	}
+	if err := w.Store.AdvanceETag(ctx, item, newETag); err != nil {
+		log.Printf("IFS write-back %s: failed to update the etag of queued changes: %v", item.CorrelationId, err)
+	}
+}
+
+// send PATCHes the change with the etag of the version it was made on
+func (w *WriteBackWorker) send(ctx context.Context, item models.IFSWriteBack) (string, error) {
+	if item.ETag == "" {
+		return "", ErrNoETag
+	}
+
+	if item.ExecutionInstanceSeq == nil {
+		fields := map[string]interface{}{}
+		if item.PlannedStart != nil {
+			fields["PlannedStart"] = FormatDateTime(*item.PlannedStart)
+		}
+		if item.PlannedFinish != nil {
+			fields["PlannedFinish"] = FormatDateTime(*item.PlannedFinish)
+		}
+		if item.Duration != nil {
+			fields["Duration"] = *item.Duration
+		}
+		return w.Client.Patch(ctx, w.Client.AvExeTaskPath(item.AircraftWorkPackageId, item.TaskSeq),
+			item.ETag, item.CorrelationId, fields)
+	}
+
+	fields := map[string]interface{}{}
+	if item.AllocatedStart != nil {
+		fields["AllocatedStart"] = FormatDateTime(*item.AllocatedStart)
+	}
+	if item.AllocatedFinish != nil {
+		fields["AllocatedFinish"] = FormatDateTime(*item.AllocatedFinish)
+	}
+	return w.Client.Patch(ctx, w.Client.ExecutionInstancePath(item.AircraftWorkPackageId, item.TaskSeq, *item.ExecutionInstanceSeq),
+		item.ETag, item.CorrelationId, fields)
+}
+
+// finish releases the claim on a queue item with the given outcome
+func (w *WriteBackWorker) finish(ctx context.Context, item models.IFSWriteBack, outcome WriteBackOutcome, at time.Time) {
+	if err := w.Store.Finish(ctx, item, outcome, at); err != nil {
+		log.Printf("IFS write-back %s: failed to update queue item: %v", item.CorrelationId, err)
+	}
+}
+
+// recordRequestState stores the outcome on the AvExeTask of the change
+func (w *WriteBackWorker) recordRequestState(ctx context.Context, item models.IFSWriteBack, state, errorMessage, newETag string) {
+	if err := w.Store.RecordRequestState(ctx, item, state, errorMessage, newETag); err != nil {
+		log.Printf("IFS write-back %s: failed to record request state: %v", item.CorrelationId, err)
+	}
+}
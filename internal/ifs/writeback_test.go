@@ -0,0 +1,270 @@
+package ifs
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/ifs/ifstest"
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryWriteBackStore keeps the write-back queue and the work packages in memory
+type memoryWriteBackStore struct {
+	items        []models.IFSWriteBack
+	workPackages map[int]models.AircraftWorkPackage
+}
+
+func newMemoryWriteBackStore(workPackages ...models.AircraftWorkPackage) *memoryWriteBackStore {
+	s := &memoryWriteBackStore{workPackages: map[int]models.AircraftWorkPackage{}}
+	for _, wp := range workPackages {
+		s.workPackages[wp.AircraftWorkPackageId] = wp
+	}
+	return s
+}
+
+// add queues a pending item due at nextAttempt and returns it
+func (s *memoryWriteBackStore) add(item models.IFSWriteBack, nextAttempt time.Time) models.IFSWriteBack {
+	item.ID = primitive.NewObjectID()
+	item.CorrelationId = NewCorrelationID()
+	item.Status = WriteBackPending
+	item.NextAttempt = nextAttempt
+	s.items = append(s.items, item)
+	return item
+}
+
+func (s *memoryWriteBackStore) item(id primitive.ObjectID) *models.IFSWriteBack {
+	for i := range s.items {
+		if s.items[i].ID == id {
+			return &s.items[i]
+		}
+	}
+	return nil
+}
+
+func sameWriteBackTarget(a, b models.IFSWriteBack) bool {
+	if a.AircraftWorkPackageId != b.AircraftWorkPackageId || a.TaskSeq != b.TaskSeq {
+		return false
+	}
+	if a.ExecutionInstanceSeq == nil || b.ExecutionInstanceSeq == nil {
+		return a.ExecutionInstanceSeq == nil && b.ExecutionInstanceSeq == nil
+	}
+	return *a.ExecutionInstanceSeq == *b.ExecutionInstanceSeq
+}
+
+func (s *memoryWriteBackStore) Claim(ctx context.Context, now, lockedUntil time.Time) (models.IFSWriteBack, error) {
+	due := []*models.IFSWriteBack{}
+	for i := range s.items {
+		item := &s.items[i]
+		if (item.Status == WriteBackPending && !item.NextAttempt.After(now)) ||
+			(item.Status == WriteBackProcessing && item.LockedUntil != nil && !item.LockedUntil.After(now)) {
+			due = append(due, item)
+		}
+	}
+	if len(due) == 0 {
+		return models.IFSWriteBack{}, ErrNoWriteBackDue
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttempt.Before(due[j].NextAttempt) })
+	due[0].Status, due[0].LockedUntil, due[0].UpdatedDate = WriteBackProcessing, &lockedUntil, now
+	return *due[0], nil
+}
+
+func (s *memoryWriteBackStore) Finish(ctx context.Context, item models.IFSWriteBack, outcome WriteBackOutcome, at time.Time) error {
+	stored := s.item(item.ID)
+	stored.Status, stored.LastError, stored.Attempts, stored.UpdatedDate = outcome.Status, outcome.LastError, outcome.Attempts, at
+	stored.LockedUntil = nil
+	if outcome.Status != WriteBackPending {
+		return nil
+	}
+	stored.NextAttempt = outcome.NextAttempt
+	for _, other := range s.items {
+		if other.ID != item.ID && other.Status == WriteBackPending && sameWriteBackTarget(other, item) {
+			stored.Status = WriteBackSuperseded
+		}
+	}
+	return nil
+}
+
+func (s *memoryWriteBackStore) AdvanceETag(ctx context.Context, item models.IFSWriteBack, newETag string) error {
+	for i := range s.items {
+		if s.items[i].Status == WriteBackPending && s.items[i].ETag == item.ETag && sameWriteBackTarget(s.items[i], item) {
+			s.items[i].ETag = newETag
+		}
+	}
+	return nil
+}
+
+func (s *memoryWriteBackStore) RecordRequestState(ctx context.Context, item models.IFSWriteBack, state, errorMessage, newETag string) error {
+	wp, found := s.workPackages[item.AircraftWorkPackageId]
+	if !found {
+		return nil
+	}
+	for i := range wp.Avexetask {
+		if wp.Avexetask[i].TaskSeq == item.TaskSeq {
+			applyRequestState(&wp.Avexetask[i], item, state, errorMessage, newETag)
+		}
+	}
+	s.workPackages[item.AircraftWorkPackageId] = wp
+	return nil
+}
+
+// writeBackTestWorker returns a worker sending to a stand-in that holds wp, with its clock at *now
+func writeBackTestWorker(t *testing.T, wp models.AircraftWorkPackage, now *time.Time) (*WriteBackWorker, *memoryWriteBackStore, *ifstest.Server) {
+	t.Helper()
+	server := ifstest.NewServer("AvAircraftWorkPackageSet", wp)
+	t.Cleanup(server.Close)
+	store := newMemoryWriteBackStore(wp)
+	worker := &WriteBackWorker{
+		Client:      &Client{BaseURL: server.URL, EntitySet: server.EntitySet, HTTP: server.Client()},
+		Store:       store,
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		Now:         func() time.Time { return *now },
+	}
+	return worker, store, server
+}
+
+// storedTask returns a task of a work package in the store
+func storedTask(t *testing.T, store *memoryWriteBackStore, wpId, taskSeq int) models.AvExeTask {
+	t.Helper()
+	for _, task := range store.workPackages[wpId].Avexetask {
+		if task.TaskSeq == taskSeq {
+			return task
+		}
+	}
+	t.Fatalf("task %d of WP %d not found", taskSeq, wpId)
+	return models.AvExeTask{}
+}
+
+func TestWriteBackSendsEditETagAndAdvancesIt(t *testing.T) {
+	now := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	wp := syncTestWorkPackage(1, now.Add(-time.Hour))
+	wp.Avexetask[0].ODataEtag = `W/"7"`
+	worker, store, server := writeBackTestWorker(t, wp, &now)
+
+	start := now.Add(48 * time.Hour)
+	finish := start.Add(4 * time.Hour)
+	first := store.add(models.IFSWriteBack{AircraftWorkPackageId: 1, TaskSeq: 1, ETag: `W/"7"`, PlannedStart: &start}, now.Add(-time.Minute))
+	// A change of the same task made on the same version, enqueued while the first was sent
+	second := store.add(models.IFSWriteBack{AircraftWorkPackageId: 1, TaskSeq: 1, ETag: `W/"7"`, PlannedFinish: &finish}, now)
+
+	processed, err := worker.ProcessDue(context.Background())
+	if err != nil || processed != 2 {
+		t.Fatalf("ProcessDue = %d, %v", processed, err)
+	}
+
+	patches := server.Patches()
+	if len(patches) != 2 {
+		t.Fatalf("patches = %+v", patches)
+	}
+	if patches[0].IfMatch != `W/"7"` || patches[0].CorrelationID != first.CorrelationId {
+		t.Errorf("first patch = %+v, want If-Match of the edited version", patches[0])
+	}
+	ifsTask := func() models.AvExeTask {
+		stored, _ := server.WorkPackage(1)
+		return stored.Avexetask[0]
+	}
+	// The second change was moved to the version the first one created, so it was not a conflict
+	if patches[1].IfMatch == `W/"7"` || patches[1].CorrelationID != second.CorrelationId {
+		t.Errorf("second patch = %+v, want If-Match of the version the first patch created", patches[1])
+	}
+	for _, item := range []models.IFSWriteBack{first, second} {
+		if got := store.item(item.ID); got.Status != WriteBackDone {
+			t.Errorf("item %s: status %q, want done (%s)", item.CorrelationId, got.Status, got.LastError)
+		}
+	}
+
+	task := storedTask(t, store, 1, 1)
+	if task.ODataEtag != ifsTask().ODataEtag {
+		t.Errorf("stored etag %q, want %q of IFS", task.ODataEtag, ifsTask().ODataEtag)
+	}
+	if task.RequestStateCd == nil || *task.RequestStateCd != RequestStateSuccess ||
+		task.RequestCorrelationId == nil || *task.RequestCorrelationId != second.CorrelationId ||
+		task.RequestErrorMessage != nil {
+		t.Errorf("request state of the task = %v, %v, %v", task.RequestStateCd, task.RequestCorrelationId, task.RequestErrorMessage)
+	}
+}
+
+func TestWriteBackOutcomes(t *testing.T) {
+	now := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	start := now.Add(48 * time.Hour)
+	instanceSeq := 1
+
+	tests := []struct {
+		name   string
+		item   models.IFSWriteBack
+		fail   int // status of the stand-in's next response, 0 for none
+		status string
+		state  string
+	}{
+		{"stale etag", models.IFSWriteBack{TaskSeq: 1, ETag: `W/"0"`, PlannedStart: &start}, 0, WriteBackConflict, RequestStateError},
+		{"precondition failed", models.IFSWriteBack{TaskSeq: 1, ETag: `W/"1"`, PlannedStart: &start}, http.StatusPreconditionFailed, WriteBackConflict, RequestStateError},
+		{"no etag", models.IFSWriteBack{TaskSeq: 1, PlannedStart: &start}, 0, WriteBackConflict, RequestStateError},
+		{"rejected", models.IFSWriteBack{TaskSeq: 1, ETag: `W/"1"`, PlannedStart: &start}, http.StatusBadRequest, WriteBackFailed, RequestStateError},
+		{"server error", models.IFSWriteBack{TaskSeq: 1, ETag: `W/"1"`, PlannedStart: &start}, http.StatusServiceUnavailable, WriteBackPending, RequestStateError},
+		{"execution instance", models.IFSWriteBack{TaskSeq: 1, ExecutionInstanceSeq: &instanceSeq, ETag: "*", AllocatedStart: &start}, 0, WriteBackDone, RequestStateSuccess},
+	}
+	for _, tt := range tests {
+		worker, store, server := writeBackTestWorker(t, syncTestWorkPackage(1, now.Add(-time.Hour)), &now)
+		if tt.fail != 0 {
+			server.FailNext(tt.fail, "ERROR", "failed for the test")
+		}
+		tt.item.AircraftWorkPackageId = 1
+		item := store.add(tt.item, now)
+
+		if _, err := worker.ProcessDue(context.Background()); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := store.item(item.ID)
+		if got.Status != tt.status || got.Attempts != 1 && tt.status != WriteBackDone {
+			t.Errorf("%s: status %q after %d attempts, want %q", tt.name, got.Status, got.Attempts, tt.status)
+		}
+		task := storedTask(t, store, 1, 1)
+		if task.RequestStateCd == nil || *task.RequestStateCd != tt.state ||
+			task.RequestCorrelationId == nil || *task.RequestCorrelationId != item.CorrelationId {
+			t.Errorf("%s: request state of the task = %v, %v", tt.name, task.RequestStateCd, task.RequestCorrelationId)
+		}
+		if (tt.state == RequestStateError) != (task.RequestErrorMessage != nil) {
+			t.Errorf("%s: request error message = %v", tt.name, task.RequestErrorMessage)
+		}
+	}
+}
+
+func TestWriteBackRetriesWithBackoff(t *testing.T) {
+	now := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	worker, store, server := writeBackTestWorker(t, syncTestWorkPackage(1, now.Add(-time.Hour)), &now)
+	for i := 0; i < worker.MaxAttempts; i++ {
+		server.FailNext(http.StatusBadGateway, "BAD_GATEWAY", "upstream unavailable")
+	}
+	start := now.Add(48 * time.Hour)
+	item := store.add(models.IFSWriteBack{AircraftWorkPackageId: 1, TaskSeq: 1, ETag: `W/"1"`, PlannedStart: &start}, now)
+
+	// The delay doubles after every failure until the last attempt fails the item
+	for attempt, delay := range []time.Duration{time.Minute, 2 * time.Minute} {
+		if processed, _ := worker.ProcessDue(context.Background()); processed != 1 {
+			t.Fatalf("attempt %d: processed %d", attempt+1, processed)
+		}
+		got := store.item(item.ID)
+		if got.Status != WriteBackPending || got.Attempts != attempt+1 || !got.NextAttempt.Equal(now.Add(delay)) {
+			t.Fatalf("after attempt %d = %+v, want pending until %s", attempt+1, got, now.Add(delay))
+		}
+		if processed, _ := worker.ProcessDue(context.Background()); processed != 0 {
+			t.Fatalf("after attempt %d: item retried before its backoff", attempt+1)
+		}
+		now = now.Add(delay)
+	}
+
+	if processed, _ := worker.ProcessDue(context.Background()); processed != 1 {
+		t.Fatal("last attempt not made")
+	}
+	if got := store.item(item.ID); got.Status != WriteBackFailed || got.Attempts != worker.MaxAttempts || got.LastError == "" {
+		t.Errorf("after the last attempt = %+v, want failed", got)
+	}
+	if patches := server.Patches(); len(patches) != worker.MaxAttempts {
+		t.Errorf("patches = %d, want %d", len(patches), worker.MaxAttempts)
+	}
+}
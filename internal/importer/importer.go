@@ -0,0 +1,216 @@
+// Package importer reads work package exports and loads them into Mongo
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/validation"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Supported input formats
+const (
+	FormatAuto   = "auto"
+	FormatJSON   = "json"   // a single work package or an array of them
+	FormatNDJSON = "ndjson" // one work package per line
+	FormatOData  = "odata"  // an OData collection response: {"value": [...]}
+)
+
+// Record is one work package read from an export, or the error that prevented reading it
+type Record struct {
+	Index       int // position in the input, starting at 1
+	Line        int // line number for NDJSON input, otherwise 0
+	WorkPackage models.AircraftWorkPackage
+	Err         error
+}
+
+// Location describes where the record came from, for error reports
+func (r Record) Location() string {
+	location := fmt.Sprintf("record %d", r.Index)
+	if r.Line > 0 {
+		location += fmt.Sprintf(" (line %d)", r.Line)
+	}
+	if r.WorkPackage.AircraftWorkPackageId != 0 {
+		location += fmt.Sprintf(" [WP %d]", r.WorkPackage.AircraftWorkPackageId)
+	}
+	return location
+}
+
+// Read parses an export in the given format. Records that cannot be decoded are returned with
+// Err set, so one bad record does not stop the others. With strict set, fields that do not
+// exist in models.AircraftWorkPackage are errors
+func Read(r io.Reader, format string, strict bool) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return []Record{}, nil
+	}
+
+	if format == FormatAuto || format == "" {
+		format = detectFormat(data)
+	}
+
+	switch format {
+	case FormatNDJSON:
+		return readNDJSON(data, strict), nil
+	case FormatOData:
+		var page struct {
+			Value []json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("invalid OData response: %w", err)
+		}
+		if page.Value == nil {
+			return nil, fmt.Errorf("invalid OData response: no value array")
+		}
+		return decodeAll(page.Value, strict), nil
+	case FormatJSON:
+		if data[0] == '[' {
+			var values []json.RawMessage
+			if err := json.Unmarshal(data, &values); err != nil {
+				return nil, fmt.Errorf("invalid JSON array: %w", err)
+			}
+			return decodeAll(values, strict), nil
+		}
+		return decodeAll([]json.RawMessage{data}, strict), nil
+	}
+	return nil, fmt.Errorf("unknown format: %s", format)
+}
+
+// detectFormat guesses the format from the content: an array is JSON, an object with a
+// value array is OData, several objects are NDJSON and a single object is JSON
+func detectFormat(data []byte) string {
+	if data[0] == '[' {
+		return FormatJSON
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var first map[string]json.RawMessage
+	if err := decoder.Decode(&first); err != nil {
+		// Let the NDJSON reader report the broken lines
+		return FormatNDJSON
+	}
+	if decoder.More() {
+		return FormatNDJSON
+	}
+	if value, found := first["value"]; found && bytes.HasPrefix(bytes.TrimSpace(value), []byte("[")) {
+		return FormatOData
+	}
+	return FormatJSON
+}
+
+// readNDJSON decodes one record per non-empty line
+func readNDJSON(data []byte, strict bool) []Record {
+	records := []Record{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		record := decodeRecord(text, strict)
+		record.Index = len(records) + 1
+		record.Line = line
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		records = append(records, Record{Index: len(records) + 1, Line: line + 1, Err: err})
+	}
+	return records
+}
+
+// decodeAll decodes each raw value into a record
+func decodeAll(values []json.RawMessage, strict bool) []Record {
+	records := make([]Record, 0, len(values))
+	for i, value := range values {
+		record := decodeRecord(value, strict)
+		record.Index = i + 1
+		records = append(records, record)
+	}
+	return records
+}
+
+// decodeRecord decodes and validates one work package
+func decodeRecord(data []byte, strict bool) Record {
+	var record Record
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(&record.WorkPackage); err != nil {
+		// Keep the ID for the error report if it can be read at all
+		var id struct {
+			AircraftWorkPackageId int `json:"AircraftWorkPackageId"`
+		}
+		json.Unmarshal(data, &id)
+		record.WorkPackage = models.AircraftWorkPackage{AircraftWorkPackageId: id.AircraftWorkPackageId}
+		record.Err = fmt.Errorf("does not match AircraftWorkPackage: %w", err)
+		return record
+	}
+
+	if problems := Validate(record.WorkPackage); len(problems) > 0 {
+		record.Err = fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return record
+}
+
+// Validate checks the keys and dates of a work package and returns the problems that make it
+// unfit for import: the issues of error severity (see the validation package)
+func Validate(wp models.AircraftWorkPackage) []string {
+	problems := []string{}
+	for _, issue := range validation.Check(wp) {
+		if issue.Severity == validation.Error {
+			problems = append(problems, issue.String())
+		}
+	}
+	return problems
+}
+
+// Outcome of an upsert
+const (
+	Inserted  = "inserted"
+	Updated   = "updated"
+	Unchanged = "unchanged"
+)
+
+// Upsert stores a work package by AircraftWorkPackageId, replacing any existing copy.
+// Importing the same export twice leaves the collection unchanged
+func Upsert(ctx context.Context, collection *mongo.Collection, wp models.AircraftWorkPackage) (string, error) {
+	// Keep the Mongo _id of an existing document
+	wp.ID = primitive.NilObjectID
+
+	result, err := collection.ReplaceOne(ctx,
+		bson.M{"AircraftWorkPackageId": wp.AircraftWorkPackageId},
+		wp,
+		options.Replace().SetUpsert(true))
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case result.UpsertedCount > 0:
+		return Inserted, nil
+	case result.ModifiedCount > 0:
+		return Updated, nil
+	}
+	return Unchanged, nil
+}
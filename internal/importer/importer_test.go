@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordSummary describes a record as "index/line:WP id" with "!" appended if it has an error
+func recordSummary(record Record) string {
+	summary := fmt.Sprintf("%d/%d:%d", record.Index, record.Line, record.WorkPackage.AircraftWorkPackageId)
+	if record.Err != nil {
+		summary += "!"
+	}
+	return summary
+}
+
+func TestRead(t *testing.T) {
+	const (
+		wp1 = `{"AircraftWorkPackageId": 1, "AvTaskArray": [{"TaskSeq": 1}]}`
+		wp2 = `{"AircraftWorkPackageId": 2}`
+		// Task 1 ends before it starts
+		invalid = `{"AircraftWorkPackageId": 3, "AvTaskArray": [{"TaskSeq": 1, "PlannedStart": "2026-03-02T10:00:00Z", "PlannedFinish": "2026-03-02T08:00:00Z"}]}`
+		// AircraftId is a number in the model
+		mistyped = `{"AircraftWorkPackageId": 4, "AircraftId": "A320"}`
+		unknown  = `{"AircraftWorkPackageId": 5, "Remark": "from a newer projection"}`
+	)
+
+	tests := []struct {
+		name   string
+		input  string
+		format string
+		strict bool
+		want   []string
+		err    bool
+	}{
+		{"single object", wp1, FormatAuto, false, []string{"1/0:1"}, false},
+		{"array", "[" + wp1 + "," + wp2 + "]", FormatAuto, false, []string{"1/0:1", "2/0:2"}, false},
+		{"array as JSON", "[" + wp1 + "," + wp2 + "]", FormatJSON, false, []string{"1/0:1", "2/0:2"}, false},
+		{"NDJSON", wp1 + "\n\n" + wp2 + "\n", FormatAuto, false, []string{"1/1:1", "2/3:2"}, false},
+		{"NDJSON as NDJSON", wp1 + "\n" + wp2, FormatNDJSON, false, []string{"1/1:1", "2/2:2"}, false},
+		{"single line NDJSON", wp1, FormatNDJSON, false, []string{"1/1:1"}, false},
+		{"OData", `{"@odata.context": "x", "value": [` + wp1 + "," + wp2 + `]}`, FormatAuto, false, []string{"1/0:1", "2/0:2"}, false},
+		{"OData as OData", `{"value": [` + wp2 + `]}`, FormatOData, false, []string{"1/0:2"}, false},
+		{"empty", "  \n", FormatAuto, false, []string{}, false},
+		{"invalid record in an array", "[" + wp1 + "," + invalid + "," + wp2 + "]", FormatAuto, false, []string{"1/0:1", "2/0:3!", "3/0:2"}, false},
+		{"mistyped record in NDJSON", wp1 + "\n" + mistyped + "\n" + wp2, FormatAuto, false, []string{"1/1:1", "2/2:4!", "3/3:2"}, false},
+		{"broken line in NDJSON", wp1 + "\n{\"AircraftWorkPackageId\": 6,\n" + wp2, FormatAuto, false, []string{"1/1:1", "2/2:0!", "3/3:2"}, false},
+		{"invalid record in OData", `{"value": [` + invalid + "," + wp1 + `]}`, FormatAuto, false, []string{"1/0:3!", "2/0:1"}, false},
+		{"unknown field", unknown, FormatAuto, false, []string{"1/0:5"}, false},
+		{"unknown field in strict mode", unknown, FormatAuto, true, []string{"1/0:5!"}, false},
+		{"unknown field in strict NDJSON", wp1 + "\n" + unknown, FormatAuto, true, []string{"1/1:1", "2/2:5!"}, false},
+		{"OData without value", `{"value": null}`, FormatOData, false, nil, true},
+		{"broken array", "[" + wp1, FormatAuto, false, nil, true},
+		{"unknown format", wp1, "xml", false, nil, true},
+	}
+	for _, tt := range tests {
+		records, err := Read(strings.NewReader(tt.input), tt.format, tt.strict)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		got := []string{}
+		for _, record := range records {
+			got = append(got, recordSummary(record))
+		}
+		if !tt.err && fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`[{"AircraftWorkPackageId": 1}]`, FormatJSON},
+		{`{"AircraftWorkPackageId": 1}`, FormatJSON},
+		{`{"value": []}`, FormatOData},
+		{`{"value": "not an array"}`, FormatJSON},
+		{"{\"AircraftWorkPackageId\": 1}\n{\"AircraftWorkPackageId\": 2}", FormatNDJSON},
+		{`{"AircraftWorkPackageId": `, FormatNDJSON},
+	}
+	for _, tt := range tests {
+		if got := detectFormat([]byte(tt.input)); got != tt.want {
+			t.Errorf("detectFormat(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRecordLocation(t *testing.T) {
+	records, err := Read(strings.NewReader("{\"AircraftWorkPackageId\": 1}\n{\"AircraftWorkPackageId\": 7, \"AircraftId\": \"x\"}"), FormatAuto, false)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Read = %v, %v", records, err)
+	}
+	if got := records[1].Location(); got != "record 2 (line 2) [WP 7]" {
+		t.Errorf("location = %q", got)
+	}
+	if records[1].Err == nil || !strings.Contains(records[1].Err.Error(), "AircraftWorkPackage") {
+		t.Errorf("error = %v", records[1].Err)
+	}
+}
@@ -1,25 +1,31 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 
-	"stationMonitor/internal/config"
+	"stationMonitor/internal/models"
+	"stationMonitor/internal/repository"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type Claims struct {
-	Username string `json:"username"`
+	Username string        `json:"username"`
+	Roles    []string      `json:"roles,omitempty"`
+	Scope    *models.Scope `json:"scope,omitempty"`
+	Family   string        `json:"fam,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware accepts requests with a valid access token signed with secret that is not on the
+// revocation list of tokens, or with a valid API key of keys. A nil tokens skips the revocation
+// check, a nil keys rejects API keys
+func AuthMiddleware(secret string, tokens repository.TokenRepository, keys repository.APIKeyRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		cfg, err := config.LoadConfig("config/config.yaml")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration error"})
-			c.Abort()
+		if key := requestAPIKey(c); key != "" {
+			authenticateAPIKey(c, keys, key)
 			return
 		}
 
@@ -37,7 +43,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		claims := &Claims{}
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWT.Secret), nil
+			return []byte(secret), nil
 		})
 
 		if err != nil || !token.Valid {
@@ -46,7 +52,39 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// A logout revokes the token itself, a logout or refresh token reuse its whole family
+		if tokens != nil {
+			ids := []string{}
+			if claims.ID != "" {
+				ids = append(ids, claims.ID)
+			}
+			if claims.Family != "" {
+				ids = append(ids, claims.Family)
+			}
+			revoked, err := tokens.IsRevoked(c.Request.Context(), ids...)
+			if err != nil {
+				log.Printf("Failed to check token revocation: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				c.Abort()
+				return
+			}
+			if revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Set("username", claims.Username)
+		c.Set("roles", claims.Roles)
+		if claims.Scope != nil {
+			c.Set("scope", *claims.Scope)
+		}
+		c.Set("tokenID", claims.ID)
+		c.Set("tokenFamily", claims.Family)
+		if claims.ExpiresAt != nil {
+			c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"stationMonitor/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission accepts requests whose token carries a role granting permission, or whose
+// API key was granted it. It must run after AuthMiddleware
+func RequirePermission(permission rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Permitted(c, permission) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":      "Insufficient permissions",
+				"permission": permission,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -130,6 +130,7 @@ type AvExeTask struct {
 
 // JtExecutionInstance represents an execution instance within a task
 type JtExecutionInstance struct {
+	ODataEtag              string     `bson:"@odata.etag,omitempty" json:"@odata.etag,omitempty"`
 	TaskSeq                int        `bson:"TaskSeq,omitempty" json:"TaskSeq,omitempty"`
 	ExecutionInstanceSeq   int        `bson:"ExecutionInstanceSeq,omitempty" json:"ExecutionInstanceSeq,omitempty"`
 	CreatedDate            *time.Time `bson:"CreatedDate,omitempty" json:"CreatedDate,omitempty"`
@@ -213,6 +214,7 @@ type AircraftWorkPackage struct {
 	RequestOperation       *string            `bson:"RequestOperation,omitempty" json:"RequestOperation,omitempty"`
 	ExternalId             string             `bson:"ExternalId,omitempty" json:"ExternalId,omitempty"`
 	MessageDate            *time.Time         `bson:"MessageDate,omitempty" json:"MessageDate,omitempty"`
+	ChangedDate            *time.Time         `bson:"ChangedDate,omitempty" json:"ChangedDate,omitempty"`
 	StatusBeforeStart      *string            `bson:"StatusBeforeStart,omitempty" json:"StatusBeforeStart,omitempty"`
 	IsHeavyMaintenance     bool               `bson:"IsHeavyMaintenance,omitempty" json:"IsHeavyMaintenance,omitempty"`
 	HmContractId           *string            `bson:"HmContractId,omitempty" json:"HmContractId,omitempty"`
@@ -220,4 +222,7 @@ type AircraftWorkPackage struct {
 	LastClearWorkscopeDate *time.Time         `bson:"LastClearWorkscopeDate,omitempty" json:"LastClearWorkscopeDate,omitempty"`
 	BindSignatureId        string             `bson:"BindSignatureId,omitempty" json:"BindSignatureId,omitempty"`
 	Avexetask              []AvExeTask        `bson:"AvTaskArray,omitempty" json:"AvTaskArray,omitempty"`
+
+	// ArchivedDate is set on work packages read from the archive collection
+	ArchivedDate *time.Time `bson:"-" json:"ArchivedDate,omitempty"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyPrefix starts every API key, so the middleware can tell keys from access tokens
+const APIKeyPrefix = "smk_"
+
+// APIKey lets a machine client, such as a hangar display board or an integration script, call
+// the API without borrowing a user's token. Only the SHA-256 hash of the key is stored; the key
+// itself is shown once, when it is created. The key is granted Permissions directly instead of
+// roles, and sees only the data within Scope
+type APIKey struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"Name" json:"name"`
+	Hash        string             `bson:"Hash" json:"-"`        // SHA-256 of the key, hex
+	Prefix      string             `bson:"Prefix" json:"prefix"` // start of the key, to recognise it by
+	Permissions []string           `bson:"Permissions" json:"permissions"`
+	Scope       Scope              `bson:"Scope,omitempty" json:"scope"`
+	CreatedBy   string             `bson:"CreatedBy" json:"createdBy"`
+	CreatedAt   time.Time          `bson:"CreatedAt" json:"createdAt"`
+	ExpiresAt   *time.Time         `bson:"ExpiresAt,omitempty" json:"expiresAt,omitempty"` // nil never expires
+	LastUsedAt  *time.Time         `bson:"LastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	RevokedAt   *time.Time         `bson:"RevokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// CreateAPIKeyRequest creates an API key
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name" binding:"required"`
+	Permissions []string   `json:"permissions" binding:"required"`
+	Scope       Scope      `json:"scope"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
+
+// CreateAPIKeyResponse holds the new key. It cannot be retrieved again
+type CreateAPIKeyResponse struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"apiKey"`
+}
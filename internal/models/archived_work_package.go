@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ArchivedWorkPackage is a work package moved to the archive collection. The fields the work
+// package filters use are kept at the top level under their usual names, so the same queries
+// select archived packages. The package itself is embedded, or stored as gzipped BSON when compressed
+type ArchivedWorkPackage struct {
+	ID                    primitive.ObjectID   `bson:"_id,omitempty" json:"_id,omitempty"`
+	AircraftWorkPackageId int                  `bson:"AircraftWorkPackageId,omitempty" json:"AircraftWorkPackageId,omitempty"`
+	AircraftId            int                  `bson:"AircraftId,omitempty" json:"AircraftId,omitempty"`
+	LocationCode          string               `bson:"LocationCode,omitempty" json:"LocationCode,omitempty"`
+	Objstate              string               `bson:"Objstate,omitempty" json:"Objstate,omitempty"`
+	IsHistoric            bool                 `bson:"IsHistoric,omitempty" json:"IsHistoric,omitempty"`
+	CustomerNo            *string              `bson:"CustomerNo,omitempty" json:"CustomerNo,omitempty"`
+	Sites                 []ArchivedSite       `bson:"Sites" json:"Sites"` // distinct Site and Company pairs of the tasks, for user scopes
+	SchedStartDateTime    *time.Time           `bson:"SchedStartDateTime,omitempty" json:"SchedStartDateTime,omitempty"`
+	SchedEndDateTime      *time.Time           `bson:"SchedEndDateTime,omitempty" json:"SchedEndDateTime,omitempty"`
+	EndDateTime           time.Time            `bson:"EndDateTime" json:"EndDateTime"` // ActualEndDateTime, else SchedEndDateTime; retention counts from it
+	ArchivedDate          time.Time            `bson:"ArchivedDate" json:"ArchivedDate"`
+	WorkPackage           *AircraftWorkPackage `bson:"WorkPackage,omitempty" json:"WorkPackage,omitempty"`
+	Compressed            []byte               `bson:"Compressed,omitempty" json:"-"`
+}
+
+// ArchivedSite is a Site and Company at which an archived work package has tasks
+type ArchivedSite struct {
+	Site    string `bson:"Site" json:"Site"`
+	Company string `bson:"Company" json:"Company"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GanttDependency represents a user-defined dependency between two AvExeTasks.
+// Tasks are referenced by work package and task sequence so the dependency
+// survives a re-sync of the work package documents.
+type GanttDependency struct {
+	ID                 primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SourceAircraftWpId int                `bson:"SourceAircraftWpId" json:"sourceAircraftWpId" binding:"required"`
+	SourceTaskSeq      int                `bson:"SourceTaskSeq" json:"sourceTaskSeq" binding:"required"`
+	TargetAircraftWpId int                `bson:"TargetAircraftWpId" json:"targetAircraftWpId" binding:"required"`
+	TargetTaskSeq      int                `bson:"TargetTaskSeq" json:"targetTaskSeq" binding:"required"`
+	Type               string             `bson:"Type" json:"type"` // 's2s', 's2e', 'e2s', 'e2e'
+	CreatedBy          string             `bson:"CreatedBy,omitempty" json:"createdBy,omitempty"`
+	CreatedDate        time.Time          `bson:"CreatedDate" json:"createdDate"`
+}
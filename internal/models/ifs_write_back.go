@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IFSWriteBack is a planning change waiting to be sent to IFS.
+// It targets an AvExeTask, or one of its execution instances when ExecutionInstanceSeq is set.
+// Only the date fields of the target kind are sent
+type IFSWriteBack struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AircraftWorkPackageId int                `bson:"AircraftWorkPackageId" json:"aircraftWorkPackageId"`
+	TaskSeq               int                `bson:"TaskSeq" json:"taskSeq"`
+	ExecutionInstanceSeq  *int               `bson:"ExecutionInstanceSeq,omitempty" json:"executionInstanceSeq,omitempty"`
+
+	// AvExeTask fields
+	PlannedStart  *time.Time `bson:"PlannedStart,omitempty" json:"plannedStart,omitempty"`
+	PlannedFinish *time.Time `bson:"PlannedFinish,omitempty" json:"plannedFinish,omitempty"`
+	Duration      *int       `bson:"Duration,omitempty" json:"duration,omitempty"`
+
+	// JtExecutionInstance fields
+	AllocatedStart  *time.Time `bson:"AllocatedStart,omitempty" json:"allocatedStart,omitempty"`
+	AllocatedFinish *time.Time `bson:"AllocatedFinish,omitempty" json:"allocatedFinish,omitempty"`
+
+	// ETag is the @odata.etag of the target when the change was made, sent as If-Match.
+	// IFS rejects the change if the target was changed there since
+	ETag string `bson:"ETag,omitempty" json:"etag,omitempty"`
+
+	Status        string     `bson:"Status" json:"status"` // "pending", "processing", "done", "failed", "conflict" or "superseded"
+	Attempts      int        `bson:"Attempts" json:"attempts"`
+	NextAttempt   time.Time  `bson:"NextAttempt" json:"nextAttempt"`
+	LockedUntil   *time.Time `bson:"LockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	LastError     string     `bson:"LastError,omitempty" json:"lastError,omitempty"`
+	CorrelationId string     `bson:"CorrelationId" json:"correlationId"`
+	CreatedBy     string     `bson:"CreatedBy,omitempty" json:"createdBy,omitempty"`
+	CreatedDate   time.Time  `bson:"CreatedDate" json:"createdDate"`
+	UpdatedDate   time.Time  `bson:"UpdatedDate" json:"updatedDate"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ScheduleBaseline is a frozen copy of the planned dates of a work package
+type ScheduleBaseline struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AircraftWorkPackageId int                `bson:"AircraftWorkPackageId" json:"aircraftWorkPackageId"`
+	Name                  string             `bson:"Name,omitempty" json:"name,omitempty"`
+	Trigger               string             `bson:"Trigger" json:"trigger"` // "manual" or "scheduled"
+	Objstate              string             `bson:"Objstate,omitempty" json:"objstate,omitempty"`
+	CreatedBy             string             `bson:"CreatedBy,omitempty" json:"createdBy,omitempty"`
+	CreatedDate           time.Time          `bson:"CreatedDate" json:"createdDate"`
+	SchedStartDateTime    *time.Time         `bson:"SchedStartDateTime,omitempty" json:"schedStartDateTime,omitempty"`
+	SchedEndDateTime      *time.Time         `bson:"SchedEndDateTime,omitempty" json:"schedEndDateTime,omitempty"`
+	Tasks                 []BaselineTask     `bson:"Tasks" json:"tasks"`
+}
+
+// BaselineTask holds the planned dates of an AvExeTask at baseline time
+type BaselineTask struct {
+	TaskSeq       int                `bson:"TaskSeq" json:"taskSeq"`
+	Description   string             `bson:"Description,omitempty" json:"description,omitempty"`
+	PlannedStart  *time.Time         `bson:"PlannedStart,omitempty" json:"plannedStart,omitempty"`
+	PlannedFinish *time.Time         `bson:"PlannedFinish,omitempty" json:"plannedFinish,omitempty"`
+	Duration      *int               `bson:"Duration,omitempty" json:"duration,omitempty"`
+	Instances     []BaselineInstance `bson:"Instances,omitempty" json:"instances,omitempty"`
+}
+
+// BaselineInstance holds the allocated dates of a JtExecutionInstance at baseline time
+type BaselineInstance struct {
+	ExecutionInstanceSeq int        `bson:"ExecutionInstanceSeq" json:"executionInstanceSeq"`
+	AllocatedStart       *time.Time `bson:"AllocatedStart,omitempty" json:"allocatedStart,omitempty"`
+	AllocatedFinish      *time.Time `bson:"AllocatedFinish,omitempty" json:"allocatedFinish,omitempty"`
+}
@@ -0,0 +1,107 @@
+package models
+
+// Scope limits the data a user sees. A work package is visible if its LocationCode and
+// CustomerNo are in the non-empty lists, and, when Sites or Companies are set, it has a task
+// at one of the sites and companies; only those tasks are shown. An empty scope sees everything,
+// a scope with DenyAll nothing
+type Scope struct {
+	LocationCodes []string `bson:"locationCodes,omitempty" json:"locationCodes,omitempty"`
+	Sites         []string `bson:"sites,omitempty" json:"sites,omitempty"`
+	Companies     []string `bson:"companies,omitempty" json:"companies,omitempty"`
+	CustomerNos   []string `bson:"customerNos,omitempty" json:"customerNos,omitempty"`
+	// DenyAll hides all data. Users who sign themselves up get it until an admin assigns a scope
+	DenyAll bool `bson:"denyAll,omitempty" json:"denyAll,omitempty"`
+}
+
+// DenyAllScope is the scope of users who signed themselves up
+var DenyAllScope = Scope{DenyAll: true}
+
+// IsZero reports whether the scope restricts nothing. It also keeps empty scopes out of BSON
+func (s Scope) IsZero() bool {
+	return len(s.LocationCodes) == 0 && len(s.Sites) == 0 && len(s.Companies) == 0 && len(s.CustomerNos) == 0 && !s.DenyAll
+}
+
+// Contains reports whether everything visible within other is also visible within s
+func (s Scope) Contains(other Scope) bool {
+	if other.DenyAll {
+		return true
+	}
+	return !s.DenyAll &&
+		scopeSubset(s.LocationCodes, other.LocationCodes) &&
+		scopeSubset(s.Sites, other.Sites) &&
+		scopeSubset(s.Companies, other.Companies) &&
+		scopeSubset(s.CustomerNos, other.CustomerNos)
+}
+
+// RestrictsTasks reports whether the scope selects tasks by Site or Company
+func (s Scope) RestrictsTasks() bool {
+	return len(s.Sites) > 0 || len(s.Companies) > 0
+}
+
+// AllowsTask reports whether a task is within the Sites and Companies of the scope
+func (s Scope) AllowsTask(task AvExeTask) bool {
+	return !s.DenyAll && scopeContains(s.Sites, task.Site) && scopeContains(s.Companies, task.Company)
+}
+
+// AllowsWorkPackage reports whether a work package is visible within the scope
+func (s Scope) AllowsWorkPackage(wp AircraftWorkPackage) bool {
+	if s.DenyAll || !scopeContains(s.LocationCodes, wp.LocationCode) {
+		return false
+	}
+	if len(s.CustomerNos) > 0 && (wp.CustomerNo == nil || !scopeContains(s.CustomerNos, *wp.CustomerNo)) {
+		return false
+	}
+	if !s.RestrictsTasks() {
+		return true
+	}
+	for _, task := range wp.Avexetask {
+		if s.AllowsTask(task) {
+			return true
+		}
+	}
+	return false
+}
+
+// Restrict returns the work package with only the tasks within the scope
+func (s Scope) Restrict(wp AircraftWorkPackage) AircraftWorkPackage {
+	if !s.RestrictsTasks() || wp.Avexetask == nil {
+		return wp
+	}
+	tasks := []AvExeTask{}
+	for _, task := range wp.Avexetask {
+		if s.AllowsTask(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	wp.Avexetask = tasks
+	return wp
+}
+
+// scopeContains reports whether value is in values; an empty list allows every value
+func scopeContains(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeSubset reports whether the values allowed by other are all allowed by values
+func scopeSubset(values, other []string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	if len(other) == 0 {
+		return false
+	}
+	for _, value := range other {
+		if !scopeContains(values, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is a server-side refresh token. Only the SHA-256 hash of the token is stored.
+// Every refresh uses the token up and issues a new one in the same Family; a family starts at
+// login and ends at logout, or when a used token is presented again
+type RefreshToken struct {
+	ID         string             `bson:"_id" json:"-"` // SHA-256 of the token, hex
+	Family     string             `bson:"Family" json:"family"`
+	UserID     primitive.ObjectID `bson:"UserID" json:"userId"`
+	Username   string             `bson:"Username" json:"username"`
+	IssuedAt   time.Time          `bson:"IssuedAt" json:"issuedAt"`
+	ExpiresAt  time.Time          `bson:"ExpiresAt" json:"expiresAt"`
+	UsedAt     *time.Time         `bson:"UsedAt,omitempty" json:"usedAt,omitempty"`
+	ReplacedBy string             `bson:"ReplacedBy,omitempty" json:"-"` // hash of the token issued in exchange
+	RevokedAt  *time.Time         `bson:"RevokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// RevokedToken is an entry of the revocation list: the ID of an access token, or a refresh token
+// family whose access tokens are all revoked. It is kept until the last access token it covers expires
+type RevokedToken struct {
+	ID        string    `bson:"_id" json:"id"`
+	RevokedAt time.Time `bson:"RevokedAt" json:"revokedAt"`
+	ExpiresAt time.Time `bson:"ExpiresAt" json:"expiresAt"`
+}
+
+// LoginCode is a one-time code a browser login redirects to the frontend with instead of the
+// tokens, which the frontend exchanges for them. Only the SHA-256 hash of the code is stored
+type LoginCode struct {
+	ID        string             `bson:"_id" json:"-"` // SHA-256 of the code, hex
+	UserID    primitive.ObjectID `bson:"UserID" json:"userId"`
+	ExpiresAt time.Time          `bson:"ExpiresAt" json:"expiresAt"`
+}
+
+// LoginCodeRequest exchanges a login code for an access and refresh token
+type LoginCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RefreshRequest exchanges a refresh token for a new access and refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse is the result of a refresh
+type TokenResponse struct {
+	Token        string `json:"token"` // access token
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"` // seconds until the access token expires
+}
+
+// LogoutRequest ends the session of the access token. All ends every session of the user
+type LogoutRequest struct {
+	All bool `json:"all"`
+}
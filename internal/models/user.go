@@ -8,9 +8,12 @@ type User struct {
 	Username    string             `bson:"username,omitempty" json:"username,omitempty"`
 	Password    string             `bson:"password,omitempty" json:"-"` // Don't expose password in JSON
 	GoogleID    string             `bson:"google_id,omitempty" json:"-"` // Google OAuth ID
+	ExternalID  string             `bson:"external_id,omitempty" json:"-"` // "<provider>:<subject>" of an OIDC login
 	Provider    string             `bson:"provider,omitempty" json:"provider,omitempty"` // "local" or "google"
 	Name        string             `bson:"name,omitempty" json:"name,omitempty"` // Full name from Google
 	Picture     string             `bson:"picture,omitempty" json:"picture,omitempty"` // Profile picture URL
+	Roles       []string           `bson:"roles,omitempty" json:"roles,omitempty"`     // see package rbac
+	Scope       Scope              `bson:"scope,omitempty" json:"scope"`               // data the user may see
 }
 
 type LoginRequest struct {
@@ -24,14 +27,26 @@ type RegisterRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"` // access token
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"` // seconds until the access token expires
+	User         User   `json:"user"`
 }
 
 type RegisterResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
-	Message string `json:"message"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+	User         User   `json:"user"`
+	Message      string `json:"message"`
+}
+
+type UpdateRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+type UpdateScopeRequest struct {
+	Scope Scope `json:"scope"`
 }
 
 type GoogleOAuthRequest struct {
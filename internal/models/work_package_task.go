@@ -0,0 +1,11 @@
+package models
+
+// WorkPackageTask is an AvExeTask together with the work package it belongs to,
+// as returned by the task queries across work packages
+type WorkPackageTask struct {
+	AircraftWorkPackageId int       `bson:"AircraftWorkPackageId" json:"AircraftWorkPackageId"`
+	AircraftId            int       `bson:"AircraftId,omitempty" json:"AircraftId,omitempty"`
+	LocationCode          string    `bson:"LocationCode,omitempty" json:"LocationCode,omitempty"`
+	WorkPackageName       string    `bson:"WorkPackageName,omitempty" json:"WorkPackageName,omitempty"`
+	Task                  AvExeTask `bson:"Task" json:"Task"`
+}
@@ -0,0 +1,276 @@
+// Package oidc signs users in with OpenID Connect identity providers such as Azure AD, Okta or
+// Keycloak. Provider endpoints are discovered from the issuer, ID tokens are verified against the
+// provider's JSON Web Key Set, and the roles of a user are mapped from a claim of the ID token
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/rbac"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// signingMethods are the ID token algorithms accepted. HMAC is left out, it would make the
+// client secret a signing key
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// clockSkew is the leeway allowed on the time claims of ID tokens
+const clockSkew = time.Minute
+
+// Validate checks the provider configuration: names must be set and unique, the issuer and
+// client ID must be set, and every mapped role must exist
+func Validate(providers []config.OIDCProvider) error {
+	names := map[string]bool{}
+	for _, provider := range providers {
+		if provider.Name == "" {
+			return errors.New("provider without a name")
+		}
+		if strings.ContainsAny(provider.Name, "/?#:") {
+			return fmt.Errorf("provider %s: name must not contain / ? # or :", provider.Name)
+		}
+		if names[provider.Name] {
+			return fmt.Errorf("provider %s is configured twice", provider.Name)
+		}
+		names[provider.Name] = true
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return fmt.Errorf("provider %s: issuer and client_id are required", provider.Name)
+		}
+		if err := rbac.ValidateRoles(provider.DefaultRoles); err != nil {
+			return fmt.Errorf("provider %s: default_roles: %w", provider.Name, err)
+		}
+		for value, roles := range provider.RoleMapping {
+			if err := rbac.ValidateRoles(roles); err != nil {
+				return fmt.Errorf("provider %s: role_mapping for %s: %w", provider.Name, value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Discovery holds the fields of the provider's openid-configuration document used here
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is a discovered identity provider
+type Provider struct {
+	Config    config.OIDCProvider
+	Discovery Discovery
+
+	client *http.Client
+	keys   *keySet
+}
+
+// Identity is the user an ID token was issued for
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+	Claims        jwt.MapClaims
+}
+
+// Registry discovers providers on first use and keeps them, with their cached signing keys,
+// until their configuration changes. It is safe for concurrent use
+type Registry struct {
+	client *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*Provider
+}
+
+// NewRegistry returns a registry talking to the providers with client
+func NewRegistry(client *http.Client) *Registry {
+	return &Registry{client: client, providers: map[string]*Provider{}}
+}
+
+// Provider returns the discovered provider of cfg
+func (r *Registry) Provider(ctx context.Context, cfg config.OIDCProvider) (*Provider, error) {
+	r.mu.Lock()
+	provider, ok := r.providers[cfg.Name]
+	r.mu.Unlock()
+	if ok && reflect.DeepEqual(provider.Config, cfg) {
+		return provider, nil
+	}
+
+	provider, err := discover(ctx, r.client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.providers[cfg.Name] = provider
+	r.mu.Unlock()
+	return provider, nil
+}
+
+// discover reads the openid-configuration document of a provider
+func discover(ctx context.Context, client *http.Client, cfg config.OIDCProvider) (*Provider, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.DiscoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discovery of %s: %w", cfg.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery of %s: %s returned %s", cfg.Name, cfg.DiscoveryURL, resp.Status)
+	}
+
+	var discovery Discovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, fmt.Errorf("discovery of %s: %w", cfg.Name, err)
+	}
+	// The issuer must be the one configured, or tokens of another issuer would be accepted
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(cfg.Issuer, "/") {
+		return nil, fmt.Errorf("discovery of %s: issuer %q does not match the configured %q", cfg.Name, discovery.Issuer, cfg.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery of %s: authorization_endpoint, token_endpoint and jwks_uri are required", cfg.Name)
+	}
+
+	return &Provider{
+		Config:    cfg,
+		Discovery: discovery,
+		client:    client,
+		keys:      newKeySet(client, discovery.JWKSURI),
+	}, nil
+}
+
+// oauth2Config returns the authorization code flow configuration for redirectURL
+func (p *Provider) oauth2Config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.Config.ClientID,
+		ClientSecret: p.Config.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       p.Config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.Discovery.AuthorizationEndpoint,
+			TokenURL: p.Discovery.TokenEndpoint,
+		},
+	}
+}
+
+// AuthCodeURL returns the URL of the provider's login page. The ID token will carry nonce
+func (p *Provider) AuthCodeURL(redirectURL, state, nonce string) string {
+	return p.oauth2Config(redirectURL).AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce))
+}
+
+// Exchange redeems an authorization code and returns the verified identity of its ID token
+func (p *Provider) Exchange(ctx context.Context, code, redirectURL, nonce string) (Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth2Config(redirectURL).Exchange(ctx, code)
+	if err != nil {
+		return Identity{}, fmt.Errorf("token exchange: %w", err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return Identity{}, errors.New("token response holds no id_token")
+	}
+	return p.Verify(ctx, rawIDToken, nonce)
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.keys.key(ctx, kid)
+		},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(p.Discovery.Issuer),
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return Identity{}, errors.New("invalid ID token: nonce does not match")
+	}
+
+	identity := Identity{Claims: claims}
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Subject == "" {
+		return Identity{}, errors.New("invalid ID token: no subject")
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Picture, _ = claims["picture"].(string)
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	return identity, nil
+}
+
+// Roles maps the role claim of an identity to roles through the role mapping. Without a
+// mapped value the user gets the default roles
+func (p *Provider) Roles(identity Identity) []string {
+	roles := []string{}
+	seen := map[string]bool{}
+	for _, value := range claimValues(identity.Claims, p.Config.RoleClaim) {
+		for _, role := range p.Config.RoleMapping[value] {
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	if len(roles) == 0 {
+		return p.Config.DefaultRoles
+	}
+	return roles
+}
+
+// claimValues returns the string values of the claim at path, where dots separate the names of
+// nested claims. A single string is one value
+func claimValues(claims map[string]interface{}, path string) []string {
+	if path == "" {
+		return nil
+	}
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := []string{}
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
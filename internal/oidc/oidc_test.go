@@ -0,0 +1,138 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/config"
+	"stationMonitor/internal/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testProvider starts a stand-in provider and returns it discovered
+func testProvider(t *testing.T) (*oidctest.Server, *Provider) {
+	t.Helper()
+	idp := oidctest.NewServer("station-monitor", "secret")
+	t.Cleanup(idp.Close)
+
+	provider, err := NewRegistry(http.DefaultClient).Provider(context.Background(), config.OIDCProvider{
+		Name:         "test",
+		Issuer:       idp.Issuer,
+		DiscoveryURL: idp.Issuer + "/.well-known/openid-configuration",
+		ClientID:     "station-monitor",
+		ClientSecret: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idp, provider
+}
+
+// countRequests counts the requests of the stand-in to path
+func countRequests(idp *oidctest.Server, path string) int {
+	count := 0
+	for _, request := range idp.Requests() {
+		if strings.HasSuffix(request, " "+path) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestVerify(t *testing.T) {
+	idp, provider := testProvider(t)
+
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": idp.Issuer, "aud": "station-monitor", "sub": "user-1", "nonce": "n", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"iss": idp.Issuer, "aud": "station-monitor", "sub": "user-1", "nonce": "n", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		raw     string // used instead of a token signed by the provider
+		nonce   string
+		wantErr string
+	}{
+		{"valid", map[string]interface{}{"sub": "user-1", "nonce": "n", "email": "user@example.com"}, "", "n", ""},
+		{"wrong audience", map[string]interface{}{"sub": "user-1", "nonce": "n", "aud": "other-client"}, "", "n", "audience"},
+		{"wrong issuer", map[string]interface{}{"sub": "user-1", "nonce": "n", "iss": "https://evil.example.com"}, "", "n", "issuer"},
+		{"expired", map[string]interface{}{"sub": "user-1", "nonce": "n", "exp": time.Now().Add(-time.Hour).Unix()}, "", "n", "expired"},
+		{"nonce mismatch", map[string]interface{}{"sub": "user-1", "nonce": "other"}, "", "n", "nonce"},
+		{"no nonce", map[string]interface{}{"sub": "user-1"}, "", "n", "nonce"},
+		{"no subject", map[string]interface{}{"nonce": "n"}, "", "n", "subject"},
+		{"HS256", nil, hs256, "n", "signing method"},
+		{"alg none", nil, none, "n", "signing method"},
+	}
+	for _, tt := range tests {
+		raw := tt.raw
+		if raw == "" {
+			if raw, err = idp.IDToken(tt.claims); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		identity, err := provider.Verify(context.Background(), raw, tt.nonce)
+		if tt.wantErr == "" {
+			if err != nil || identity.Subject != "user-1" || identity.Email != "user@example.com" {
+				t.Errorf("%s: got %+v, %v", tt.name, identity, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error %v, want one about %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyRefetchesKeysForUnknownKeyID(t *testing.T) {
+	idp, provider := testProvider(t)
+
+	raw, err := idp.IDToken(map[string]interface{}{"sub": "user-1", "nonce": "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Verify(context.Background(), raw, "n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := countRequests(idp, "/jwks"); got != 1 {
+		t.Fatalf("key set fetched %d times, want 1", got)
+	}
+
+	// A token signed with a new key is rejected until the refresh interval has passed
+	if err := idp.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	raw, err = idp.IDToken(map[string]interface{}{"sub": "user-1", "nonce": "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Verify(context.Background(), raw, "n"); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("token with a new key within the refresh interval: %v", err)
+	}
+	if got := countRequests(idp, "/jwks"); got != 1 {
+		t.Fatalf("key set fetched %d times within the refresh interval, want 1", got)
+	}
+
+	provider.keys.mu.Lock()
+	provider.keys.fetched = time.Now().Add(-keyRefreshInterval)
+	provider.keys.mu.Unlock()
+	if _, err := provider.Verify(context.Background(), raw, "n"); err != nil {
+		t.Fatalf("token with a new key after the refresh interval: %v", err)
+	}
+	if got := countRequests(idp, "/jwks"); got != 2 {
+		t.Errorf("key set fetched %d times, want 2", got)
+	}
+}
@@ -0,0 +1,306 @@
+// Package oidctest provides a local stand-in for an OpenID Connect provider, for tests and
+// local development without Azure AD, Okta or Keycloak. It has no login page: the
+// authorization endpoint signs in the configured user straight away
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IdP serves discovery, authorization, token and key set endpoints for one client. ID tokens
+// are signed with RS256 under the current key, and the key set serves all keys ever used
+type IdP struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+
+	mu       sync.Mutex
+	keys     []signingKey
+	user     map[string]interface{}
+	codes    map[string]authorization
+	requests []string
+}
+
+// signingKey is an RSA key with its key ID
+type signingKey struct {
+	id  string
+	key *rsa.PrivateKey
+}
+
+// authorization is an issued authorization code
+type authorization struct {
+	redirectURI string
+	nonce       string
+	claims      map[string]interface{}
+	expires     time.Time
+}
+
+// New returns a provider for issuer, which must be the URL it is served at
+func New(issuer, clientID, clientSecret string) (*IdP, error) {
+	p := &IdP{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		user: map[string]interface{}{
+			"sub":            "user-1",
+			"email":          "user@example.com",
+			"email_verified": true,
+			"name":           "Test User",
+		},
+		codes: map[string]authorization{},
+	}
+	if err := p.RotateKey(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Server is an IdP listening on a local port
+type Server struct {
+	*httptest.Server
+	*IdP
+}
+
+// NewServer starts a provider on a local port. Close it when done
+func NewServer(clientID, clientSecret string) *Server {
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Start()
+	idp, err := New(ts.URL, clientID, clientSecret)
+	if err != nil {
+		ts.Close()
+		panic(err)
+	}
+	ts.Config.Handler = idp
+	return &Server{Server: ts, IdP: idp}
+}
+
+// SetUser replaces the claims of the user signed in by the authorization endpoint. Claims
+// such as groups or roles are copied into the ID token as given
+func (p *IdP) SetUser(claims map[string]interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = claims
+}
+
+// RotateKey signs future tokens with a new key. Earlier keys stay in the key set
+func (p *IdP) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = append(p.keys, signingKey{id: fmt.Sprintf("key-%d", len(p.keys)+1), key: key})
+	return nil
+}
+
+// IDToken signs an ID token for the client with the current key. The standard claims are
+// added unless claims sets them
+func (p *IdP) IDToken(claims map[string]interface{}) (string, error) {
+	p.mu.Lock()
+	key := p.keys[len(p.keys)-1]
+	p.mu.Unlock()
+
+	now := time.Now()
+	token := jwt.MapClaims{
+		"iss": p.Issuer,
+		"aud": p.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		token[name] = value
+	}
+	signed := jwt.NewWithClaims(jwt.SigningMethodRS256, token)
+	signed.Header["kid"] = key.id
+	return signed.SignedString(key.key)
+}
+
+// Requests returns the method and path of every request received so far
+func (p *IdP) Requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.requests...)
+}
+
+// ServeHTTP serves the provider endpoints
+func (p *IdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.requests = append(p.requests, r.Method+" "+r.URL.Path)
+	p.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		p.discovery(w)
+	case "/authorize":
+		p.authorize(w, r)
+	case "/token":
+		p.token(w, r)
+	case "/jwks":
+		p.jwks(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *IdP) discovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+// authorize signs in the configured user and redirects back with a code
+func (p *IdP) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != p.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" {
+		http.Error(w, "unsupported response_type", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirect.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code, err := randomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	claims := map[string]interface{}{}
+	for name, value := range p.user {
+		claims[name] = value
+	}
+	p.codes[code] = authorization{
+		redirectURI: query.Get("redirect_uri"),
+		nonce:       query.Get("nonce"),
+		claims:      claims,
+		expires:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token redeems an authorization code once, for the client authenticated with Basic auth or
+// form parameters
+func (p *IdP) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || clientSecret != p.ClientSecret {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	p.mu.Lock()
+	auth, found := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if !found || time.Now().After(auth.expires) || auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	claims := auth.claims
+	if auth.nonce != "" {
+		claims["nonce"] = auth.nonce
+	}
+	idToken, err := p.IDToken(claims)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	accessToken, err := randomString()
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (p *IdP) jwks(w http.ResponseWriter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := []map[string]string{}
+	for _, key := range p.keys {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": key.id,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.key.E)).Bytes()),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// tokenError writes an OAuth 2.0 error response
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,101 @@
+// Package rbac defines the roles users can have and the permissions they grant. Users carry
+// role names; what a role may do is decided here, so changing the mapping takes effect
+// without reissuing tokens
+package rbac
+
+import "fmt"
+
+// Permission is the right to use a group of endpoints
+type Permission string
+
+const (
+	// ReadWorkPackages allows reading work packages, tasks, Gantt data and reports
+	ReadWorkPackages Permission = "work-packages:read"
+	// WriteSchedule allows changing schedules, dependencies and baselines
+	WriteSchedule Permission = "schedule:write"
+	// SignOffTasks allows signing off completed work
+	SignOffTasks Permission = "tasks:sign-off"
+	// ManageIFS allows inspecting and retrying the IFS write-back queue
+	ManageIFS Permission = "ifs:manage"
+	// ManageUsers allows listing users and changing their roles and scopes
+	ManageUsers Permission = "users:manage"
+	// ManageAPIKeys allows creating, listing and revoking API keys
+	ManageAPIKeys Permission = "api-keys:manage"
+)
+
+// Permissions lists every permission
+var Permissions = []Permission{ReadWorkPackages, WriteSchedule, SignOffTasks, ManageIFS, ManageUsers, ManageAPIKeys}
+
+// Role names
+const (
+	Admin      = "admin"
+	Planner    = "planner"
+	Certifying = "certifying"
+	Technician = "technician"
+	Viewer     = "viewer"
+)
+
+// Roles maps every role to the permissions it grants. A user with several roles has the
+// permissions of all of them, e.g. a technician who is also certifying staff
+var Roles = map[string][]Permission{
+	Admin:      {ReadWorkPackages, WriteSchedule, SignOffTasks, ManageIFS, ManageUsers, ManageAPIKeys},
+	Planner:    {ReadWorkPackages, WriteSchedule, ManageIFS},
+	Certifying: {ReadWorkPackages, SignOffTasks},
+	Technician: {ReadWorkPackages},
+	Viewer:     {ReadWorkPackages},
+}
+
+// KnownRole reports whether role is defined
+func KnownRole(role string) bool {
+	_, ok := Roles[role]
+	return ok
+}
+
+// ValidateRoles returns an error naming the first unknown role
+func ValidateRoles(roles []string) error {
+	for _, role := range roles {
+		if !KnownRole(role) {
+			return fmt.Errorf("unknown role: %s", role)
+		}
+	}
+	return nil
+}
+
+// Allowed reports whether any of the roles grants permission
+func Allowed(roles []string, permission Permission) bool {
+	for _, role := range roles {
+		for _, granted := range Roles[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidatePermissions returns an error naming the first unknown permission
+func ValidatePermissions(permissions []string) error {
+	for _, name := range permissions {
+		known := false
+		for _, permission := range Permissions {
+			if Permission(name) == permission {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown permission: %s", name)
+		}
+	}
+	return nil
+}
+
+// Granted reports whether permission is in the list of permissions, as held by an API key
+func Granted(permissions []string, permission Permission) bool {
+	for _, name := range permissions {
+		if Permission(name) == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1060 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"stationMonitor/internal/ifs"
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// clone copies a document through BSON, so callers never share slices with the store
+// and times are truncated to milliseconds the way MongoDB stores them
+func clone[T any](document T) T {
+	var copied T
+	data, err := bson.Marshal(document)
+	if err != nil {
+		panic(err)
+	}
+	if err := bson.Unmarshal(data, &copied); err != nil {
+		panic(err)
+	}
+	return copied
+}
+
+// sameTime compares optional times the way an equality match on a possibly missing field does
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+var (
+	_ WorkPackageRepository = (*MemoryWorkPackages)(nil)
+	_ UserRepository        = (*MemoryUsers)(nil)
+	_ TokenRepository       = (*MemoryTokens)(nil)
+	_ APIKeyRepository      = (*MemoryAPIKeys)(nil)
+	_ BaselineRepository    = (*MemoryBaselines)(nil)
+	_ DependencyRepository  = (*MemoryDependencies)(nil)
+	_ WriteBackRepository   = (*MemoryWriteBacks)(nil)
+)
+
+// MemoryWorkPackages keeps work packages in memory. It is safe for concurrent use
+type MemoryWorkPackages struct {
+	mu           sync.RWMutex
+	workPackages []models.AircraftWorkPackage
+	archived     []models.AircraftWorkPackage
+}
+
+// NewMemoryWorkPackages returns an in-memory work package repository holding the given work packages
+func NewMemoryWorkPackages(workPackages ...models.AircraftWorkPackage) *MemoryWorkPackages {
+	r := &MemoryWorkPackages{}
+	for _, wp := range workPackages {
+		r.Put(wp)
+	}
+	return r
+}
+
+// Put inserts the work package or replaces the one with the same AircraftWorkPackageId
+func (r *MemoryWorkPackages) Put(wp models.AircraftWorkPackage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.workPackages {
+		if r.workPackages[i].AircraftWorkPackageId == wp.AircraftWorkPackageId {
+			wp.ID = r.workPackages[i].ID
+			r.workPackages[i] = clone(wp)
+			return
+		}
+	}
+	if wp.ID.IsZero() {
+		wp.ID = primitive.NewObjectID()
+	}
+	r.workPackages = append(r.workPackages, clone(wp))
+}
+
+// PutArchived stores the work package in the archive, as archived at archivedDate
+func (r *MemoryWorkPackages) PutArchived(wp models.AircraftWorkPackage, archivedDate time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if wp.ID.IsZero() {
+		wp.ID = primitive.NewObjectID()
+	}
+	wp = clone(wp)
+	wp.ArchivedDate = &archivedDate
+	for i := range r.archived {
+		if r.archived[i].AircraftWorkPackageId == wp.AircraftWorkPackageId {
+			r.archived[i] = wp
+			return
+		}
+	}
+	r.archived = append(r.archived, wp)
+}
+
+// archivedCopy clones an archived package, keeping its ArchivedDate
+func archivedCopy(wp models.AircraftWorkPackage) models.AircraftWorkPackage {
+	copied := clone(wp)
+	copied.ArchivedDate = wp.ArchivedDate
+	return copied
+}
+
+// matching returns copies of the work packages matching the filter, unordered.
+// Archived packages that are also in the work package collection are left out, as in MongoDB
+func (r *MemoryWorkPackages) matching(filter WorkPackageFilter) []models.AircraftWorkPackage {
+	workPackages := []models.AircraftWorkPackage{}
+	current := map[int]bool{}
+	for _, wp := range r.workPackages {
+		current[wp.AircraftWorkPackageId] = true
+		if filter.matches(wp) {
+			workPackages = append(workPackages, clone(wp))
+		}
+	}
+	if filter.IncludeArchived {
+		for _, wp := range r.archived {
+			if !current[wp.AircraftWorkPackageId] && filter.matches(wp) {
+				workPackages = append(workPackages, archivedCopy(wp))
+			}
+		}
+	}
+	return workPackages
+}
+
+// matches reports whether wp is selected by the filter
+func (filter WorkPackageFilter) matches(wp models.AircraftWorkPackage) bool {
+	if len(filter.AircraftIds) > 0 && !containsInt(filter.AircraftIds, wp.AircraftId) {
+		return false
+	}
+	if len(filter.WorkPackageIds) > 0 && !containsInt(filter.WorkPackageIds, wp.AircraftWorkPackageId) {
+		return false
+	}
+	if len(filter.LocationCodes) > 0 && !containsString(filter.LocationCodes, wp.LocationCode) {
+		return false
+	}
+	if filter.IsHistoric != nil && wp.IsHistoric != *filter.IsHistoric {
+		return false
+	}
+	if filter.From != nil && (wp.SchedEndDateTime == nil || wp.SchedEndDateTime.Before(*filter.From)) {
+		return false
+	}
+	if filter.To != nil && (wp.SchedStartDateTime == nil || wp.SchedStartDateTime.After(*filter.To)) {
+		return false
+	}
+	if filter.Scope != nil && !filter.Scope.AllowsWorkPackage(wp) {
+		return false
+	}
+	return true
+}
+
+// Find returns the work packages matching the filter
+func (r *MemoryWorkPackages) Find(ctx context.Context, filter WorkPackageFilter, opts FindOptions) ([]models.AircraftWorkPackage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	workPackages := r.matching(filter)
+
+	// Missing dates sort first, then AircraftWorkPackageId breaks ties, as in MongoDB
+	sort.SliceStable(workPackages, func(i, j int) bool {
+		a, b := workPackages[i].SchedStartDateTime, workPackages[j].SchedStartDateTime
+		idA, idB := workPackages[i].AircraftWorkPackageId, workPackages[j].AircraftWorkPackageId
+		if opts.Descending {
+			a, b = b, a
+			idA, idB = idB, idA
+		}
+		if !sameTime(a, b) {
+			if a == nil || b == nil {
+				return a == nil
+			}
+			return a.Before(*b)
+		}
+		return idA < idB
+	})
+
+	if opts.Skip > 0 {
+		if opts.Skip >= int64(len(workPackages)) {
+			return []models.AircraftWorkPackage{}, nil
+		}
+		workPackages = workPackages[opts.Skip:]
+	}
+	if opts.Limit > 0 && opts.Limit < int64(len(workPackages)) {
+		workPackages = workPackages[:opts.Limit]
+	}
+	return workPackages, nil
+}
+
+// Count returns the number of work packages matching the filter
+func (r *MemoryWorkPackages) Count(ctx context.Context, filter WorkPackageFilter) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.matching(filter))), nil
+}
+
+// FindByObjectID returns the work package with the given document ID
+func (r *MemoryWorkPackages) FindByObjectID(ctx context.Context, id primitive.ObjectID) (models.AircraftWorkPackage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, wp := range r.workPackages {
+		if wp.ID == id {
+			return clone(wp), nil
+		}
+	}
+	return models.AircraftWorkPackage{}, ErrNotFound
+}
+
+// FindByWorkPackageID returns the work package with the given AircraftWorkPackageId
+func (r *MemoryWorkPackages) FindByWorkPackageID(ctx context.Context, aircraftWorkPackageId int) (models.AircraftWorkPackage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, wp := range r.workPackages {
+		if wp.AircraftWorkPackageId == aircraftWorkPackageId {
+			return clone(wp), nil
+		}
+	}
+	return models.AircraftWorkPackage{}, ErrNotFound
+}
+
+// FindArchivedByObjectID returns the archived work package with the given document ID
+func (r *MemoryWorkPackages) FindArchivedByObjectID(ctx context.Context, id primitive.ObjectID) (models.AircraftWorkPackage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, wp := range r.archived {
+		if wp.ID == id {
+			return archivedCopy(wp), nil
+		}
+	}
+	return models.AircraftWorkPackage{}, ErrNotFound
+}
+
+// FindArchivedByWorkPackageID returns the archived work package with the given AircraftWorkPackageId
+func (r *MemoryWorkPackages) FindArchivedByWorkPackageID(ctx context.Context, aircraftWorkPackageId int) (models.AircraftWorkPackage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, wp := range r.archived {
+		if wp.AircraftWorkPackageId == aircraftWorkPackageId {
+			return archivedCopy(wp), nil
+		}
+	}
+	return models.AircraftWorkPackage{}, ErrNotFound
+}
+
+// matchesTask reports whether a task is selected by the task fields of the filter
+func (filter TaskFilter) matchesTask(task models.AvExeTask) bool {
+	if len(filter.Objstates) > 0 && !containsString(filter.Objstates, task.Objstate) {
+		return false
+	}
+	if len(filter.ExcludeObjstates) > 0 && containsString(filter.ExcludeObjstates, task.Objstate) {
+		return false
+	}
+	if len(filter.CrewCodes) > 0 && (task.CrewCode == nil || !containsString(filter.CrewCodes, *task.CrewCode)) {
+		return false
+	}
+	if len(filter.Locations) > 0 && (task.Location == nil || !containsString(filter.Locations, *task.Location)) {
+		return false
+	}
+	if len(filter.ClassCodes) > 0 && !containsString(filter.ClassCodes, task.ClassCode) {
+		return false
+	}
+	if len(filter.PriorityIds) > 0 && (task.PriorityId == nil || !containsInt(filter.PriorityIds, *task.PriorityId)) {
+		return false
+	}
+	if filter.FlightSafetyImpact != nil && task.FlightSafetyImpact != *filter.FlightSafetyImpact {
+		return false
+	}
+	if filter.From != nil && (task.PlannedFinish == nil || task.PlannedFinish.Before(*filter.From)) {
+		return false
+	}
+	if filter.To != nil && (task.PlannedStart == nil || task.PlannedStart.After(*filter.To)) {
+		return false
+	}
+	if filter.DueFrom != nil && (task.LatestFinish == nil || task.LatestFinish.Before(*filter.DueFrom)) {
+		return false
+	}
+	if filter.DueTo != nil && (task.LatestFinish == nil || task.LatestFinish.After(*filter.DueTo)) {
+		return false
+	}
+	if filter.Scope != nil && !filter.Scope.AllowsTask(task) {
+		return false
+	}
+	return true
+}
+
+// FindTasks returns one page of the matching tasks ordered by PlannedStart
+func (r *MemoryWorkPackages) FindTasks(ctx context.Context, filter TaskFilter, opts FindOptions) ([]models.WorkPackageTask, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	workPackageFilter := WorkPackageFilter{
+		AircraftIds:    filter.AircraftIds,
+		WorkPackageIds: filter.WorkPackageIds,
+		LocationCodes:  filter.LocationCodes,
+		IsHistoric:     filter.IsHistoric,
+		Scope:          filter.Scope,
+	}
+
+	tasks := []models.WorkPackageTask{}
+	for _, wp := range r.workPackages {
+		if !workPackageFilter.matches(wp) {
+			continue
+		}
+		for _, task := range wp.Avexetask {
+			if filter.matchesTask(task) {
+				tasks = append(tasks, clone(workPackageTask(wp, task)))
+			}
+		}
+	}
+
+	// Same order as the aggregation: PlannedStart (missing first), then work package and task
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i].Task.PlannedStart, tasks[j].Task.PlannedStart
+		if opts.Descending {
+			a, b = b, a
+		}
+		if !sameTime(a, b) {
+			if a == nil || b == nil {
+				return a == nil
+			}
+			return a.Before(*b)
+		}
+		if tasks[i].AircraftWorkPackageId != tasks[j].AircraftWorkPackageId {
+			return tasks[i].AircraftWorkPackageId < tasks[j].AircraftWorkPackageId
+		}
+		return tasks[i].Task.TaskSeq < tasks[j].Task.TaskSeq
+	})
+
+	total := int64(len(tasks))
+	if opts.Skip > 0 {
+		if opts.Skip >= total {
+			return []models.WorkPackageTask{}, total, nil
+		}
+		tasks = tasks[opts.Skip:]
+	}
+	if opts.Limit > 0 && opts.Limit < int64(len(tasks)) {
+		tasks = tasks[:opts.Limit]
+	}
+	return tasks, total, nil
+}
+
+// FindTask returns the task with the given TaskSeq of a work package
+func (r *MemoryWorkPackages) FindTask(ctx context.Context, aircraftWorkPackageId int, taskSeq int) (models.WorkPackageTask, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, wp := range r.workPackages {
+		if wp.AircraftWorkPackageId != aircraftWorkPackageId {
+			continue
+		}
+		for _, task := range wp.Avexetask {
+			if task.TaskSeq == taskSeq {
+				return clone(workPackageTask(wp, task)), nil
+			}
+		}
+	}
+	return models.WorkPackageTask{}, ErrNotFound
+}
+
+// UpdateSchedule applies the edits to a copy and only stores it if every guard holds
+func (r *MemoryWorkPackages) UpdateSchedule(ctx context.Context, aircraftWorkPackageId int, edits []ScheduleEdit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	position := -1
+	for i := range r.workPackages {
+		if r.workPackages[i].AircraftWorkPackageId == aircraftWorkPackageId {
+			position = i
+			break
+		}
+	}
+	if position < 0 {
+		return ErrConflict
+	}
+
+	wp := clone(r.workPackages[position])
+	for _, edit := range edits {
+		var task *models.AvExeTask
+		for i := range wp.Avexetask {
+			if wp.Avexetask[i].TaskSeq == edit.TaskSeq {
+				task = &wp.Avexetask[i]
+				break
+			}
+		}
+		if task == nil {
+			return ErrConflict
+		}
+
+		changedDate := edit.ChangedDate
+		start, end := edit.Start.UTC(), edit.End.UTC()
+
+		if edit.ExecutionInstanceSeq == nil {
+			if !sameTime(task.ChangedDate, edit.ExpectedChangedDate) {
+				return ErrConflict
+			}
+			duration := edit.Duration
+			task.PlannedStart = &start
+			task.PlannedFinish = &end
+			task.Duration = &duration
+			task.ScheduledManually = true
+			task.ChangedDate = &changedDate
+			continue
+		}
+
+		var instance *models.JtExecutionInstance
+		for i := range task.JtExecutionInstanceArray {
+			if task.JtExecutionInstanceArray[i].ExecutionInstanceSeq == *edit.ExecutionInstanceSeq {
+				instance = &task.JtExecutionInstanceArray[i]
+				break
+			}
+		}
+		if instance == nil || !sameTime(instance.ChangedDate, edit.ExpectedChangedDate) {
+			return ErrConflict
+		}
+		instance.AllocatedStart = &start
+		instance.AllocatedFinish = &end
+		instance.ChangedDate = &changedDate
+	}
+
+	r.workPackages[position] = clone(wp)
+	return nil
+}
+
+// MemoryUsers keeps users in memory. It is safe for concurrent use
+type MemoryUsers struct {
+	mu    sync.RWMutex
+	users []models.User
+}
+
+// NewMemoryUsers returns an in-memory user repository holding the given users
+func NewMemoryUsers(users ...models.User) *MemoryUsers {
+	r := &MemoryUsers{}
+	for _, user := range users {
+		if user.ID.IsZero() {
+			user.ID = primitive.NewObjectID()
+		}
+		r.users = append(r.users, user)
+	}
+	return r
+}
+
+func (r *MemoryUsers) find(match func(models.User) bool) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// List returns all users ordered by email
+func (r *MemoryUsers) List(ctx context.Context) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := append([]models.User{}, r.users...)
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+	return users, nil
+}
+
+// FindByID returns the user with the given ID
+func (r *MemoryUsers) FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	return r.find(func(user models.User) bool { return user.ID == id })
+}
+
+// FindByLogin returns the user whose username or email equals login
+func (r *MemoryUsers) FindByLogin(ctx context.Context, login string) (models.User, error) {
+	return r.find(func(user models.User) bool {
+		return (user.Username != "" && user.Username == login) || user.Email == login
+	})
+}
+
+// FindByEmail returns the user with the given email
+func (r *MemoryUsers) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.find(func(user models.User) bool { return user.Email == email })
+}
+
+// FindByGoogleID returns the user linked to the given Google account
+func (r *MemoryUsers) FindByGoogleID(ctx context.Context, googleID string) (models.User, error) {
+	return r.find(func(user models.User) bool { return user.GoogleID != "" && user.GoogleID == googleID })
+}
+
+// FindByExternalID returns the user linked to the given OIDC account
+func (r *MemoryUsers) FindByExternalID(ctx context.Context, externalID string) (models.User, error) {
+	return r.find(func(user models.User) bool { return user.ExternalID != "" && user.ExternalID == externalID })
+}
+
+// conflicts reports whether another user already has the email or username of user,
+// mirroring the unique indexes of the users collection
+func (r *MemoryUsers) conflicts(user models.User) bool {
+	for _, existing := range r.users {
+		if existing.ID == user.ID {
+			continue
+		}
+		if existing.Email == user.Email || (user.Username != "" && existing.Username == user.Username) {
+			return true
+		}
+	}
+	return false
+}
+
+// Create inserts a new user
+func (r *MemoryUsers) Create(ctx context.Context, user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	if r.conflicts(user) {
+		return ErrDuplicate
+	}
+	r.users = append(r.users, user)
+	return nil
+}
+
+// Update replaces the stored user with the same ID
+func (r *MemoryUsers) Update(ctx context.Context, user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.users {
+		if r.users[i].ID == user.ID {
+			if r.conflicts(user) {
+				return ErrDuplicate
+			}
+			r.users[i] = user
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// MemoryTokens keeps refresh tokens, login codes and the revocation list in memory. It is safe
+// for concurrent use
+type MemoryTokens struct {
+	mu            sync.Mutex
+	refreshTokens map[string]models.RefreshToken
+	revoked       map[string]models.RevokedToken
+	loginCodes    map[string]models.LoginCode
+}
+
+// NewMemoryTokens returns an empty in-memory token repository
+func NewMemoryTokens() *MemoryTokens {
+	return &MemoryTokens{
+		refreshTokens: map[string]models.RefreshToken{},
+		revoked:       map[string]models.RevokedToken{},
+		loginCodes:    map[string]models.LoginCode{},
+	}
+}
+
+// CreateRefreshToken inserts a new refresh token
+func (r *MemoryTokens) CreateRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.refreshTokens[token.ID]; exists {
+		return ErrDuplicate
+	}
+	r.refreshTokens[token.ID] = token
+	return nil
+}
+
+// FindRefreshToken finds a refresh token by the hash of its value
+func (r *MemoryTokens) FindRefreshToken(ctx context.Context, id string) (models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, exists := r.refreshTokens[id]
+	if !exists {
+		return token, ErrNotFound
+	}
+	return token, nil
+}
+
+// UseRefreshToken marks an unused, unrevoked refresh token as used
+func (r *MemoryTokens) UseRefreshToken(ctx context.Context, id string, replacedBy string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, exists := r.refreshTokens[id]
+	if !exists || token.UsedAt != nil || token.RevokedAt != nil {
+		return ErrConflict
+	}
+	token.UsedAt = &at
+	token.ReplacedBy = replacedBy
+	r.refreshTokens[id] = token
+	return nil
+}
+
+// RevokeFamily revokes the refresh tokens of a family and puts the family on the revocation list
+func (r *MemoryTokens) RevokeFamily(ctx context.Context, family string, at time.Time, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revokeFamily(family, at, until)
+	return nil
+}
+
+func (r *MemoryTokens) revokeFamily(family string, at time.Time, until time.Time) {
+	for id, token := range r.refreshTokens {
+		if token.Family == family && token.RevokedAt == nil {
+			token.RevokedAt = &at
+			r.refreshTokens[id] = token
+		}
+	}
+	r.revoke(family, at, until)
+}
+
+// RevokeUser revokes every family of a user that still has a valid refresh token
+func (r *MemoryTokens) RevokeUser(ctx context.Context, userID primitive.ObjectID, at time.Time, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	families := map[string]bool{}
+	for _, token := range r.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			families[token.Family] = true
+		}
+	}
+	for family := range families {
+		r.revokeFamily(family, at, until)
+	}
+	return nil
+}
+
+// Revoke adds an ID to the revocation list
+func (r *MemoryTokens) Revoke(ctx context.Context, id string, at time.Time, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revoke(id, at, until)
+	return nil
+}
+
+func (r *MemoryTokens) revoke(id string, at time.Time, until time.Time) {
+	entry, exists := r.revoked[id]
+	if !exists {
+		entry = models.RevokedToken{ID: id, RevokedAt: at}
+	}
+	if until.After(entry.ExpiresAt) {
+		entry.ExpiresAt = until
+	}
+	r.revoked[id] = entry
+}
+
+// IsRevoked reports whether any of the IDs is on the revocation list and not yet expired
+func (r *MemoryTokens) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		if entry, exists := r.revoked[id]; exists && entry.ExpiresAt.After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CreateLoginCode inserts a new login code
+func (r *MemoryTokens) CreateLoginCode(ctx context.Context, code models.LoginCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.loginCodes[code.ID]; exists {
+		return ErrDuplicate
+	}
+	r.loginCodes[code.ID] = code
+	return nil
+}
+
+// RedeemLoginCode removes a login code and returns it if it has not expired
+func (r *MemoryTokens) RedeemLoginCode(ctx context.Context, id string, at time.Time) (models.LoginCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, exists := r.loginCodes[id]
+	if !exists {
+		return code, ErrNotFound
+	}
+	delete(r.loginCodes, id)
+	if !at.Before(code.ExpiresAt) {
+		return models.LoginCode{}, ErrNotFound
+	}
+	return code, nil
+}
+
+// MemoryAPIKeys keeps API keys in memory. It is safe for concurrent use
+type MemoryAPIKeys struct {
+	mu   sync.Mutex
+	keys []models.APIKey
+}
+
+// NewMemoryAPIKeys returns an in-memory API key repository holding the given keys
+func NewMemoryAPIKeys(keys ...models.APIKey) *MemoryAPIKeys {
+	return &MemoryAPIKeys{keys: append([]models.APIKey{}, keys...)}
+}
+
+// Create inserts a new API key
+func (r *MemoryAPIKeys) Create(ctx context.Context, key models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.keys {
+		if existing.ID == key.ID || existing.Hash == key.Hash {
+			return ErrDuplicate
+		}
+	}
+	r.keys = append(r.keys, key)
+	return nil
+}
+
+// List returns every API key, newest first
+func (r *MemoryAPIKeys) List(ctx context.Context) ([]models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := append([]models.APIKey{}, r.keys...)
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// FindByHash finds an API key by the hash of its value
+func (r *MemoryAPIKeys) FindByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, ErrNotFound
+}
+
+// Revoke marks an API key as revoked
+func (r *MemoryAPIKeys) Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.keys {
+		if r.keys[i].ID == id {
+			if r.keys[i].RevokedAt == nil {
+				r.keys[i].RevokedAt = &at
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Touch sets the LastUsedAt of an API key
+func (r *MemoryAPIKeys) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.keys {
+		if r.keys[i].ID == id {
+			r.keys[i].LastUsedAt = &at
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// MemoryBaselines keeps schedule baselines in memory. It is safe for concurrent use
+type MemoryBaselines struct {
+	mu        sync.Mutex
+	baselines []models.ScheduleBaseline
+}
+
+// NewMemoryBaselines returns an in-memory baseline repository holding the given baselines
+func NewMemoryBaselines(baselines ...models.ScheduleBaseline) *MemoryBaselines {
+	r := &MemoryBaselines{}
+	for _, snapshot := range baselines {
+		r.baselines = append(r.baselines, clone(snapshot))
+	}
+	return r
+}
+
+// Create inserts a new baseline
+func (r *MemoryBaselines) Create(ctx context.Context, snapshot models.ScheduleBaseline) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.baselines = append(r.baselines, clone(snapshot))
+	return nil
+}
+
+// List returns the baselines of a work package, newest first
+func (r *MemoryBaselines) List(ctx context.Context, aircraftWorkPackageId int) ([]models.ScheduleBaseline, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	baselines := []models.ScheduleBaseline{}
+	for _, snapshot := range r.baselines {
+		if snapshot.AircraftWorkPackageId == aircraftWorkPackageId {
+			baselines = append(baselines, clone(snapshot))
+		}
+	}
+	sort.SliceStable(baselines, func(i, j int) bool { return baselines[i].CreatedDate.After(baselines[j].CreatedDate) })
+	return baselines, nil
+}
+
+// Find returns a baseline of a work package
+func (r *MemoryBaselines) Find(ctx context.Context, aircraftWorkPackageId int, id primitive.ObjectID) (models.ScheduleBaseline, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, snapshot := range r.baselines {
+		if snapshot.ID == id && snapshot.AircraftWorkPackageId == aircraftWorkPackageId {
+			return clone(snapshot), nil
+		}
+	}
+	return models.ScheduleBaseline{}, ErrNotFound
+}
+
+// Latest returns the most recent baseline of each of the work packages that has one
+func (r *MemoryBaselines) Latest(ctx context.Context, aircraftWorkPackageIds []int) (map[int]models.ScheduleBaseline, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	latest := map[int]models.ScheduleBaseline{}
+	for _, snapshot := range r.baselines {
+		if !containsInt(aircraftWorkPackageIds, snapshot.AircraftWorkPackageId) {
+			continue
+		}
+		if current, found := latest[snapshot.AircraftWorkPackageId]; !found || snapshot.CreatedDate.After(current.CreatedDate) {
+			latest[snapshot.AircraftWorkPackageId] = clone(snapshot)
+		}
+	}
+	return latest, nil
+}
+
+// MemoryDependencies keeps user-defined Gantt dependencies in memory. It is safe for concurrent use
+type MemoryDependencies struct {
+	mu           sync.Mutex
+	dependencies []models.GanttDependency
+}
+
+// NewMemoryDependencies returns an in-memory dependency repository holding the given dependencies
+func NewMemoryDependencies(dependencies ...models.GanttDependency) *MemoryDependencies {
+	return &MemoryDependencies{dependencies: append([]models.GanttDependency{}, dependencies...)}
+}
+
+// Find returns the dependencies with an end in any of the work packages, oldest first
+func (r *MemoryDependencies) Find(ctx context.Context, aircraftWorkPackageIds []int) ([]models.GanttDependency, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dependencies := []models.GanttDependency{}
+	for _, dependency := range r.dependencies {
+		if len(aircraftWorkPackageIds) == 0 ||
+			containsInt(aircraftWorkPackageIds, dependency.SourceAircraftWpId) ||
+			containsInt(aircraftWorkPackageIds, dependency.TargetAircraftWpId) {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	sort.SliceStable(dependencies, func(i, j int) bool { return dependencies[i].CreatedDate.Before(dependencies[j].CreatedDate) })
+	return dependencies, nil
+}
+
+// FindByID returns the dependency with the given ID
+func (r *MemoryDependencies) FindByID(ctx context.Context, id primitive.ObjectID) (models.GanttDependency, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, dependency := range r.dependencies {
+		if dependency.ID == id {
+			return dependency, nil
+		}
+	}
+	return models.GanttDependency{}, ErrNotFound
+}
+
+// Create inserts a new dependency
+func (r *MemoryDependencies) Create(ctx context.Context, dependency models.GanttDependency) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.dependencies {
+		if existing.ID == dependency.ID {
+			return ErrDuplicate
+		}
+	}
+	r.dependencies = append(r.dependencies, dependency)
+	return nil
+}
+
+// Delete removes a dependency
+func (r *MemoryDependencies) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, dependency := range r.dependencies {
+		if dependency.ID == id {
+			r.dependencies = append(r.dependencies[:i], r.dependencies[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// MemoryWriteBacks keeps the IFS write-back queue in memory. Nothing drains it; tests inspect
+// it with List. It is safe for concurrent use
+type MemoryWriteBacks struct {
+	mu    sync.Mutex
+	items []models.IFSWriteBack
+}
+
+// NewMemoryWriteBacks returns an in-memory write-back queue holding the given items
+func NewMemoryWriteBacks(items ...models.IFSWriteBack) *MemoryWriteBacks {
+	r := &MemoryWriteBacks{}
+	for _, item := range items {
+		r.items = append(r.items, clone(item))
+	}
+	return r
+}
+
+// sameTarget reports whether two queue items change the same task or execution instance
+func sameTarget(a, b models.IFSWriteBack) bool {
+	if a.AircraftWorkPackageId != b.AircraftWorkPackageId || a.TaskSeq != b.TaskSeq {
+		return false
+	}
+	if a.ExecutionInstanceSeq == nil || b.ExecutionInstanceSeq == nil {
+		return a.ExecutionInstanceSeq == nil && b.ExecutionInstanceSeq == nil
+	}
+	return *a.ExecutionInstanceSeq == *b.ExecutionInstanceSeq
+}
+
+// supersede marks the other items of the target of item in one of the states as superseded
+func (r *MemoryWriteBacks) supersede(item models.IFSWriteBack, states []string, at time.Time) {
+	for i := range r.items {
+		existing := &r.items[i]
+		if existing.ID != item.ID && sameTarget(*existing, item) && containsString(states, existing.Status) {
+			existing.Status, existing.UpdatedDate = ifs.WriteBackSuperseded, at
+		}
+	}
+}
+
+// Enqueue queues a planning change. A change still pending for the same target is replaced,
+// failed or conflicting changes of it are superseded
+func (r *MemoryWriteBacks) Enqueue(ctx context.Context, item models.IFSWriteBack) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	item.ID = primitive.NilObjectID
+	r.supersede(item, []string{ifs.WriteBackFailed, ifs.WriteBackConflict}, now)
+	for i := range r.items {
+		existing := &r.items[i]
+		if existing.Status != ifs.WriteBackPending || !sameTarget(*existing, item) {
+			continue
+		}
+		existing.ETag = item.ETag
+		existing.PlannedStart, existing.PlannedFinish, existing.Duration = item.PlannedStart, item.PlannedFinish, item.Duration
+		existing.AllocatedStart, existing.AllocatedFinish = item.AllocatedStart, item.AllocatedFinish
+		existing.Attempts, existing.NextAttempt, existing.UpdatedDate, existing.LastError = 0, now, now, ""
+		if item.CreatedBy != "" {
+			existing.CreatedBy = item.CreatedBy
+		}
+		*existing = clone(*existing)
+		return nil
+	}
+
+	item.ID = primitive.NewObjectID()
+	item.Status = ifs.WriteBackPending
+	item.Attempts, item.NextAttempt, item.LastError = 0, now, ""
+	item.CorrelationId = ifs.NewCorrelationID()
+	item.CreatedDate, item.UpdatedDate = now, now
+	r.items = append(r.items, clone(item))
+	return nil
+}
+
+// List returns up to limit items, most recently updated first
+func (r *MemoryWriteBacks) List(ctx context.Context, filter WriteBackFilter, limit int64) ([]models.IFSWriteBack, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	items := []models.IFSWriteBack{}
+	for _, item := range r.items {
+		if len(filter.Statuses) > 0 && !containsString(filter.Statuses, item.Status) {
+			continue
+		}
+		if len(filter.WorkPackageIds) > 0 && !containsInt(filter.WorkPackageIds, item.AircraftWorkPackageId) {
+			continue
+		}
+		items = append(items, clone(item))
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].UpdatedDate.After(items[j].UpdatedDate) })
+	if limit > 0 && int64(len(items)) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// FindByID returns the queue item with the given ID
+func (r *MemoryWriteBacks) FindByID(ctx context.Context, id primitive.ObjectID) (models.IFSWriteBack, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, item := range r.items {
+		if item.ID == id {
+			return clone(item), nil
+		}
+	}
+	return models.IFSWriteBack{}, ErrNotFound
+}
+
+// Retry puts a failed or conflicting item back in the queue, see ifs.MongoWriteBackStore.Retry
+func (r *MemoryWriteBacks) Retry(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.items {
+		item := &r.items[i]
+		if item.ID != id || (item.Status != ifs.WriteBackFailed && item.Status != ifs.WriteBackConflict) {
+			continue
+		}
+		for _, other := range r.items {
+			if other.Status == ifs.WriteBackPending && sameTarget(other, *item) {
+				item.Status, item.UpdatedDate = ifs.WriteBackSuperseded, at
+				return ifs.ErrWriteBackSuperseded
+			}
+		}
+		r.supersede(*item, []string{ifs.WriteBackFailed, ifs.WriteBackConflict}, at)
+		item.Status, item.Attempts, item.NextAttempt, item.UpdatedDate = ifs.WriteBackPending, 0, at, at
+		*item = clone(*item)
+		return nil
+	}
+	return ErrNotFound
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
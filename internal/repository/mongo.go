@@ -0,0 +1,984 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"stationMonitor/internal/archive"
+	"stationMonitor/internal/baseline"
+	"stationMonitor/internal/database"
+	"stationMonitor/internal/ifs"
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	_ WorkPackageRepository = (*MongoWorkPackages)(nil)
+	_ UserRepository        = (*MongoUsers)(nil)
+	_ TokenRepository       = (*MongoTokens)(nil)
+	_ APIKeyRepository      = (*MongoAPIKeys)(nil)
+	_ BaselineRepository    = (*MongoBaselines)(nil)
+	_ DependencyRepository  = (*MongoDependencies)(nil)
+	_ WriteBackRepository   = (*MongoWriteBacks)(nil)
+)
+
+// MongoWorkPackages stores work packages in the work package collection
+type MongoWorkPackages struct {
+	Collection *mongo.Collection
+	Archive    *mongo.Collection // packages moved out of Collection by the archiver; nil if not used
+}
+
+// NewMongoWorkPackages returns a work package repository backed by db
+func NewMongoWorkPackages(db *mongo.Database) *MongoWorkPackages {
+	return &MongoWorkPackages{
+		Collection: db.Collection(database.WorkPackageCollection),
+		Archive:    db.Collection(database.ArchiveCollection),
+	}
+}
+
+// workPackageQuery converts a filter into a MongoDB query on the work package collection
+func workPackageQuery(filter WorkPackageFilter) bson.M {
+	return scopeQuery(filterQuery(filter), filter.Scope, "AvTaskArray")
+}
+
+// archiveQuery converts a filter into a MongoDB query on the archive collection, where the
+// Site and Company of the tasks are kept in Sites
+func archiveQuery(filter WorkPackageFilter) bson.M {
+	return scopeQuery(filterQuery(filter), filter.Scope, "Sites")
+}
+
+// scopeQuery adds the conditions of a scope to query. sites names the array holding the
+// Site and Company of the tasks
+func scopeQuery(query bson.M, scope *models.Scope, sites string) bson.M {
+	if scope == nil || scope.IsZero() {
+		return query
+	}
+	conditions := bson.A{query}
+	if scope.DenyAll {
+		conditions = append(conditions, bson.M{"_id": bson.M{"$in": bson.A{}}})
+	}
+	if len(scope.LocationCodes) > 0 {
+		conditions = append(conditions, bson.M{"LocationCode": bson.M{"$in": scope.LocationCodes}})
+	}
+	if len(scope.CustomerNos) > 0 {
+		conditions = append(conditions, bson.M{"CustomerNo": bson.M{"$in": scope.CustomerNos}})
+	}
+	if scope.RestrictsTasks() {
+		conditions = append(conditions, bson.M{sites: bson.M{"$elemMatch": siteConditions(scope, "")}})
+	}
+	return bson.M{"$and": conditions}
+}
+
+// siteConditions converts the Sites and Companies of a scope into conditions on fields under prefix
+func siteConditions(scope *models.Scope, prefix string) bson.M {
+	conditions := bson.M{}
+	if len(scope.Sites) > 0 {
+		conditions[prefix+"Site"] = bson.M{"$in": scope.Sites}
+	}
+	if len(scope.Companies) > 0 {
+		conditions[prefix+"Company"] = bson.M{"$in": scope.Companies}
+	}
+	return conditions
+}
+
+// filterQuery converts the fields of a filter, except the scope, into a MongoDB query
+func filterQuery(filter WorkPackageFilter) bson.M {
+	query := bson.M{}
+	if len(filter.AircraftIds) > 0 {
+		query["AircraftId"] = bson.M{"$in": filter.AircraftIds}
+	}
+	if len(filter.WorkPackageIds) > 0 {
+		query["AircraftWorkPackageId"] = bson.M{"$in": filter.WorkPackageIds}
+	}
+	if len(filter.LocationCodes) > 0 {
+		query["LocationCode"] = bson.M{"$in": filter.LocationCodes}
+	}
+	if filter.IsHistoric != nil {
+		query["IsHistoric"] = *filter.IsHistoric
+	}
+	if filter.From != nil {
+		query["SchedEndDateTime"] = bson.M{"$gte": *filter.From}
+	}
+	if filter.To != nil {
+		query["SchedStartDateTime"] = bson.M{"$lte": *filter.To}
+	}
+	return query
+}
+
+// archivePipeline selects the archived packages matching query, built by archiveQuery. Packages that are back in the
+// work package collection, because IFS changed them after they were archived, are left out
+func (r *MongoWorkPackages) archivePipeline(query bson.M) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: query}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         r.Collection.Name(),
+			"localField":   "AircraftWorkPackageId",
+			"foreignField": "AircraftWorkPackageId",
+			"pipeline":     bson.A{bson.M{"$project": bson.M{"_id": 1}}},
+			"as":           "current",
+		}}},
+		{{Key: "$match", Value: bson.M{"current": bson.M{"$size": 0}}}},
+		{{Key: "$project", Value: bson.M{"current": 0}}},
+	}
+}
+
+// withArchive reports whether the archive is searched for the filter
+func (r *MongoWorkPackages) withArchive(filter WorkPackageFilter) bool {
+	return filter.IncludeArchived && r.Archive != nil
+}
+
+// Find returns the work packages matching the filter
+func (r *MongoWorkPackages) Find(ctx context.Context, filter WorkPackageFilter, opts FindOptions) ([]models.AircraftWorkPackage, error) {
+	if r.withArchive(filter) {
+		return r.findWithArchive(ctx, filter, opts)
+	}
+
+	order := 1
+	if opts.Descending {
+		order = -1
+	}
+	// AircraftWorkPackageId breaks ties, so pages do not overlap
+	findOptions := options.Find().SetSort(bson.D{{Key: "SchedStartDateTime", Value: order}, {Key: "AircraftWorkPackageId", Value: order}})
+	if opts.Skip > 0 {
+		findOptions.SetSkip(opts.Skip)
+	}
+	if opts.Limit > 0 {
+		findOptions.SetLimit(opts.Limit)
+	}
+
+	cursor, err := r.Collection.Find(ctx, workPackageQuery(filter), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	workPackages := []models.AircraftWorkPackage{}
+	if err := cursor.All(ctx, &workPackages); err != nil {
+		return nil, err
+	}
+	return workPackages, nil
+}
+
+// findWithArchive pages through the matching packages of both collections
+func (r *MongoWorkPackages) findWithArchive(ctx context.Context, filter WorkPackageFilter, opts FindOptions) ([]models.AircraftWorkPackage, error) {
+	order := 1
+	if opts.Descending {
+		order = -1
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: workPackageQuery(filter)}},
+		{{Key: "$unionWith", Value: bson.M{"coll": r.Archive.Name(), "pipeline": r.archivePipeline(archiveQuery(filter))}}},
+		{{Key: "$sort", Value: bson.D{{Key: "SchedStartDateTime", Value: order}, {Key: "AircraftWorkPackageId", Value: order}}}},
+	}
+	if opts.Skip > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: opts.Skip}})
+	}
+	if opts.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: opts.Limit}})
+	}
+
+	cursor, err := r.Collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	workPackages := []models.AircraftWorkPackage{}
+	for cursor.Next(ctx) {
+		// Only archive documents have an ArchivedDate
+		if _, err := cursor.Current.LookupErr("ArchivedDate"); err != nil {
+			var wp models.AircraftWorkPackage
+			if err := cursor.Decode(&wp); err != nil {
+				return nil, err
+			}
+			workPackages = append(workPackages, wp)
+			continue
+		}
+		var doc models.ArchivedWorkPackage
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		wp, err := archive.Unpack(doc)
+		if err != nil {
+			return nil, err
+		}
+		workPackages = append(workPackages, wp)
+	}
+	return workPackages, cursor.Err()
+}
+
+// Count returns the number of work packages matching the filter
+func (r *MongoWorkPackages) Count(ctx context.Context, filter WorkPackageFilter) (int64, error) {
+	count, err := r.Collection.CountDocuments(ctx, workPackageQuery(filter))
+	if err != nil || !r.withArchive(filter) {
+		return count, err
+	}
+
+	cursor, err := r.Archive.Aggregate(ctx, append(r.archivePipeline(archiveQuery(filter)), bson.D{{Key: "$count", Value: "count"}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) > 0 {
+		count += rows[0].Count
+	}
+	return count, nil
+}
+
+// FindByObjectID returns the work package with the given document ID
+func (r *MongoWorkPackages) FindByObjectID(ctx context.Context, id primitive.ObjectID) (models.AircraftWorkPackage, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+// FindByWorkPackageID returns the work package with the given AircraftWorkPackageId
+func (r *MongoWorkPackages) FindByWorkPackageID(ctx context.Context, aircraftWorkPackageId int) (models.AircraftWorkPackage, error) {
+	return r.findOne(ctx, bson.M{"AircraftWorkPackageId": aircraftWorkPackageId})
+}
+
+// FindArchivedByObjectID returns the archived work package with the given document ID
+func (r *MongoWorkPackages) FindArchivedByObjectID(ctx context.Context, id primitive.ObjectID) (models.AircraftWorkPackage, error) {
+	return r.findArchived(ctx, bson.M{"_id": id})
+}
+
+// FindArchivedByWorkPackageID returns the archived work package with the given AircraftWorkPackageId
+func (r *MongoWorkPackages) FindArchivedByWorkPackageID(ctx context.Context, aircraftWorkPackageId int) (models.AircraftWorkPackage, error) {
+	return r.findArchived(ctx, bson.M{"AircraftWorkPackageId": aircraftWorkPackageId})
+}
+
+func (r *MongoWorkPackages) findArchived(ctx context.Context, filter bson.M) (models.AircraftWorkPackage, error) {
+	if r.Archive == nil {
+		return models.AircraftWorkPackage{}, ErrNotFound
+	}
+	var doc models.ArchivedWorkPackage
+	if err := r.Archive.FindOne(ctx, filter).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.AircraftWorkPackage{}, ErrNotFound
+		}
+		return models.AircraftWorkPackage{}, err
+	}
+	return archive.Unpack(doc)
+}
+
+func (r *MongoWorkPackages) findOne(ctx context.Context, filter bson.M) (models.AircraftWorkPackage, error) {
+	var wp models.AircraftWorkPackage
+	if err := r.Collection.FindOne(ctx, filter).Decode(&wp); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return wp, ErrNotFound
+		}
+		return wp, err
+	}
+	return wp, nil
+}
+
+// taskConditions converts the task fields of a filter into conditions on fields under prefix
+func taskConditions(filter TaskFilter, prefix string) bson.M {
+	conditions := bson.M{}
+	if len(filter.Objstates) > 0 || len(filter.ExcludeObjstates) > 0 {
+		objstate := bson.M{}
+		if len(filter.Objstates) > 0 {
+			objstate["$in"] = filter.Objstates
+		}
+		if len(filter.ExcludeObjstates) > 0 {
+			objstate["$nin"] = filter.ExcludeObjstates
+		}
+		conditions[prefix+"Objstate"] = objstate
+	}
+	if len(filter.CrewCodes) > 0 {
+		conditions[prefix+"CrewCode"] = bson.M{"$in": filter.CrewCodes}
+	}
+	if len(filter.Locations) > 0 {
+		conditions[prefix+"Location"] = bson.M{"$in": filter.Locations}
+	}
+	if len(filter.ClassCodes) > 0 {
+		conditions[prefix+"ClassCode"] = bson.M{"$in": filter.ClassCodes}
+	}
+	if len(filter.PriorityIds) > 0 {
+		conditions[prefix+"PriorityId"] = bson.M{"$in": filter.PriorityIds}
+	}
+	if filter.FlightSafetyImpact != nil {
+		if *filter.FlightSafetyImpact {
+			conditions[prefix+"FlightSafetyImpact"] = true
+		} else {
+			conditions[prefix+"FlightSafetyImpact"] = bson.M{"$ne": true}
+		}
+	}
+	if filter.From != nil {
+		conditions[prefix+"PlannedFinish"] = bson.M{"$gte": *filter.From}
+	}
+	if filter.To != nil {
+		conditions[prefix+"PlannedStart"] = bson.M{"$lte": *filter.To}
+	}
+	if filter.DueFrom != nil || filter.DueTo != nil {
+		due := bson.M{}
+		if filter.DueFrom != nil {
+			due["$gte"] = *filter.DueFrom
+		}
+		if filter.DueTo != nil {
+			due["$lte"] = *filter.DueTo
+		}
+		conditions[prefix+"LatestFinish"] = due
+	}
+	if filter.Scope != nil {
+		for field, condition := range siteConditions(filter.Scope, prefix) {
+			conditions[field] = condition
+		}
+	}
+	return conditions
+}
+
+// FindTasks unwinds the task array of the matching work packages and pages through the matching tasks.
+// Work packages without a matching task are skipped before unwinding, so the task indexes are used
+func (r *MongoWorkPackages) FindTasks(ctx context.Context, filter TaskFilter, opts FindOptions) ([]models.WorkPackageTask, int64, error) {
+	match := workPackageQuery(WorkPackageFilter{
+		AircraftIds:    filter.AircraftIds,
+		WorkPackageIds: filter.WorkPackageIds,
+		LocationCodes:  filter.LocationCodes,
+		IsHistoric:     filter.IsHistoric,
+		Scope:          filter.Scope,
+	})
+	conditions := taskConditions(filter, "")
+	if len(conditions) > 0 {
+		match["AvTaskArray"] = bson.M{"$elemMatch": conditions}
+	}
+
+	order := 1
+	if opts.Descending {
+		order = -1
+	}
+	page := bson.A{
+		bson.M{"$sort": bson.D{
+			{Key: "AvTaskArray.PlannedStart", Value: order},
+			{Key: "AircraftWorkPackageId", Value: 1},
+			{Key: "AvTaskArray.TaskSeq", Value: 1},
+		}},
+	}
+	if opts.Skip > 0 {
+		page = append(page, bson.M{"$skip": opts.Skip})
+	}
+	if opts.Limit > 0 {
+		page = append(page, bson.M{"$limit": opts.Limit})
+	}
+	page = append(page, bson.M{"$project": bson.M{
+		"_id":                   0,
+		"AircraftWorkPackageId": 1,
+		"AircraftId":            1,
+		"LocationCode":          1,
+		"WorkPackageName":       1,
+		"Task":                  "$AvTaskArray",
+	}})
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$AvTaskArray"}},
+		{{Key: "$match", Value: taskConditions(filter, "AvTaskArray.")}},
+		{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"tasks": page,
+		}}},
+	}
+
+	cursor, err := r.Collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Tasks []models.WorkPackageTask `bson:"tasks"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+
+	tasks := []models.WorkPackageTask{}
+	total := int64(0)
+	if len(results) > 0 {
+		if results[0].Tasks != nil {
+			tasks = results[0].Tasks
+		}
+		if len(results[0].Total) > 0 {
+			total = results[0].Total[0].Count
+		}
+	}
+	return tasks, total, nil
+}
+
+// FindTask returns the task with the given TaskSeq of a work package
+func (r *MongoWorkPackages) FindTask(ctx context.Context, aircraftWorkPackageId int, taskSeq int) (models.WorkPackageTask, error) {
+	projection := bson.M{
+		"AircraftWorkPackageId": 1,
+		"AircraftId":            1,
+		"LocationCode":          1,
+		"WorkPackageName":       1,
+		"AvTaskArray.$":         1,
+	}
+	filter := bson.M{"AircraftWorkPackageId": aircraftWorkPackageId, "AvTaskArray.TaskSeq": taskSeq}
+
+	var wp models.AircraftWorkPackage
+	if err := r.Collection.FindOne(ctx, filter, options.FindOne().SetProjection(projection)).Decode(&wp); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.WorkPackageTask{}, ErrNotFound
+		}
+		return models.WorkPackageTask{}, err
+	}
+	if len(wp.Avexetask) == 0 {
+		return models.WorkPackageTask{}, ErrNotFound
+	}
+	return workPackageTask(wp, wp.Avexetask[0]), nil
+}
+
+// UpdateSchedule writes the edits in a single update. Every touched task and execution instance
+// is guarded on its expected ChangedDate, so either all edits are stored or none are
+func (r *MongoWorkPackages) UpdateSchedule(ctx context.Context, aircraftWorkPackageId int, edits []ScheduleEdit) error {
+	if len(edits) == 0 {
+		return nil
+	}
+
+	set := bson.M{}
+	guards := []bson.M{}
+	arrayFilters := []interface{}{}
+	identifiers := map[string]bool{}
+
+	addArrayFilter := func(identifier string, field string, value int) {
+		if !identifiers[identifier] {
+			identifiers[identifier] = true
+			arrayFilters = append(arrayFilters, bson.M{identifier + "." + field: value})
+		}
+	}
+
+	for _, edit := range edits {
+		taskPath := fmt.Sprintf("AvTaskArray.$[t%d]", edit.TaskSeq)
+		addArrayFilter(fmt.Sprintf("t%d", edit.TaskSeq), "TaskSeq", edit.TaskSeq)
+
+		if edit.ExecutionInstanceSeq == nil {
+			guards = append(guards, bson.M{"AvTaskArray": bson.M{"$elemMatch": bson.M{
+				"TaskSeq":     edit.TaskSeq,
+				"ChangedDate": edit.ExpectedChangedDate,
+			}}})
+
+			set[taskPath+".PlannedStart"] = edit.Start.UTC()
+			set[taskPath+".PlannedFinish"] = edit.End.UTC()
+			set[taskPath+".Duration"] = edit.Duration
+			set[taskPath+".ScheduledManually"] = true
+			set[taskPath+".ChangedDate"] = edit.ChangedDate
+			continue
+		}
+
+		instanceSeq := *edit.ExecutionInstanceSeq
+		guards = append(guards, bson.M{"AvTaskArray": bson.M{"$elemMatch": bson.M{
+			"TaskSeq": edit.TaskSeq,
+			"JtExecutionInstanceArray": bson.M{"$elemMatch": bson.M{
+				"ExecutionInstanceSeq": instanceSeq,
+				"ChangedDate":          edit.ExpectedChangedDate,
+			}},
+		}}})
+
+		identifier := fmt.Sprintf("i%dx%d", edit.TaskSeq, instanceSeq)
+		addArrayFilter(identifier, "ExecutionInstanceSeq", instanceSeq)
+		instancePath := taskPath + ".JtExecutionInstanceArray.$[" + identifier + "]"
+
+		set[instancePath+".AllocatedStart"] = edit.Start.UTC()
+		set[instancePath+".AllocatedFinish"] = edit.End.UTC()
+		set[instancePath+".ChangedDate"] = edit.ChangedDate
+	}
+
+	filter := bson.M{"AircraftWorkPackageId": aircraftWorkPackageId, "$and": guards}
+	updateOptions := options.Update().SetArrayFilters(options.ArrayFilters{Filters: arrayFilters})
+
+	result, err := r.Collection.UpdateOne(ctx, filter, bson.M{"$set": set}, updateOptions)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// MongoUsers stores users in the users collection
+type MongoUsers struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoUsers returns a user repository backed by db
+func NewMongoUsers(db *mongo.Database) *MongoUsers {
+	return &MongoUsers{Collection: db.Collection(database.UserCollection)}
+}
+
+// List returns all users ordered by email
+func (r *MongoUsers) List(ctx context.Context) ([]models.User, error) {
+	cursor, err := r.Collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "email", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// FindByID returns the user with the given ID
+func (r *MongoUsers) FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+// FindByLogin returns the user whose username or email equals login
+func (r *MongoUsers) FindByLogin(ctx context.Context, login string) (models.User, error) {
+	return r.findOne(ctx, bson.M{
+		"$or": []bson.M{
+			{"username": login},
+			{"email": login},
+		},
+	})
+}
+
+// FindByEmail returns the user with the given email
+func (r *MongoUsers) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+// FindByGoogleID returns the user linked to the given Google account
+func (r *MongoUsers) FindByGoogleID(ctx context.Context, googleID string) (models.User, error) {
+	return r.findOne(ctx, bson.M{"google_id": googleID})
+}
+
+// FindByExternalID returns the user linked to the given OIDC account
+func (r *MongoUsers) FindByExternalID(ctx context.Context, externalID string) (models.User, error) {
+	return r.findOne(ctx, bson.M{"external_id": externalID})
+}
+
+func (r *MongoUsers) findOne(ctx context.Context, filter bson.M) (models.User, error) {
+	var user models.User
+	if err := r.Collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return user, ErrNotFound
+		}
+		return user, err
+	}
+	return user, nil
+}
+
+// Create inserts a new user
+func (r *MongoUsers) Create(ctx context.Context, user models.User) error {
+	if _, err := r.Collection.InsertOne(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+// Update replaces the stored user with the same ID
+func (r *MongoUsers) Update(ctx context.Context, user models.User) error {
+	result, err := r.Collection.ReplaceOne(ctx, bson.M{"_id": user.ID}, user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MongoTokens stores refresh tokens, login codes and the revocation list
+type MongoTokens struct {
+	RefreshTokens *mongo.Collection
+	Revoked       *mongo.Collection
+	LoginCodes    *mongo.Collection
+}
+
+// NewMongoTokens returns a token repository backed by db
+func NewMongoTokens(db *mongo.Database) *MongoTokens {
+	return &MongoTokens{
+		RefreshTokens: db.Collection(database.RefreshTokenCollection),
+		Revoked:       db.Collection(database.RevokedTokenCollection),
+		LoginCodes:    db.Collection(database.LoginCodeCollection),
+	}
+}
+
+// CreateRefreshToken inserts a new refresh token
+func (r *MongoTokens) CreateRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	if _, err := r.RefreshTokens.InsertOne(ctx, token); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+// FindRefreshToken finds a refresh token by the hash of its value
+func (r *MongoTokens) FindRefreshToken(ctx context.Context, id string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.RefreshTokens.FindOne(ctx, bson.M{"_id": id}).Decode(&token); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return token, ErrNotFound
+		}
+		return token, err
+	}
+	return token, nil
+}
+
+// UseRefreshToken marks an unused, unrevoked refresh token as used. Two concurrent refreshes
+// with the same token cannot both succeed
+func (r *MongoTokens) UseRefreshToken(ctx context.Context, id string, replacedBy string, at time.Time) error {
+	result, err := r.RefreshTokens.UpdateOne(ctx,
+		bson.M{"_id": id, "UsedAt": nil, "RevokedAt": nil},
+		bson.M{"$set": bson.M{"UsedAt": at, "ReplacedBy": replacedBy}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// RevokeFamily revokes the refresh tokens of a family and puts the family on the revocation list
+func (r *MongoTokens) RevokeFamily(ctx context.Context, family string, at time.Time, until time.Time) error {
+	if _, err := r.RefreshTokens.UpdateMany(ctx,
+		bson.M{"Family": family, "RevokedAt": nil},
+		bson.M{"$set": bson.M{"RevokedAt": at}}); err != nil {
+		return err
+	}
+	return r.Revoke(ctx, family, at, until)
+}
+
+// RevokeUser revokes every family of a user that still has a valid refresh token
+func (r *MongoTokens) RevokeUser(ctx context.Context, userID primitive.ObjectID, at time.Time, until time.Time) error {
+	families, err := r.RefreshTokens.Distinct(ctx, "Family", bson.M{"UserID": userID, "RevokedAt": nil})
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		name, ok := family.(string)
+		if !ok {
+			continue
+		}
+		if err := r.RevokeFamily(ctx, name, at, until); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Revoke adds an ID to the revocation list. Revoking it again extends the entry if needed
+func (r *MongoTokens) Revoke(ctx context.Context, id string, at time.Time, until time.Time) error {
+	_, err := r.Revoked.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$setOnInsert": bson.M{"RevokedAt": at},
+			"$max":         bson.M{"ExpiresAt": until},
+		},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// IsRevoked reports whether any of the IDs is on the revocation list. Entries past ExpiresAt
+// are ignored, since the TTL index only removes them periodically
+func (r *MongoTokens) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	if len(ids) == 0 {
+		return false, nil
+	}
+	count, err := r.Revoked.CountDocuments(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "ExpiresAt": bson.M{"$gt": time.Now()}},
+		options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreateLoginCode inserts a new login code
+func (r *MongoTokens) CreateLoginCode(ctx context.Context, code models.LoginCode) error {
+	if _, err := r.LoginCodes.InsertOne(ctx, code); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+// RedeemLoginCode deletes a login code that has not expired and returns it. Deleting makes sure
+// two requests with the same code cannot both succeed
+func (r *MongoTokens) RedeemLoginCode(ctx context.Context, id string, at time.Time) (models.LoginCode, error) {
+	var code models.LoginCode
+	err := r.LoginCodes.FindOneAndDelete(ctx, bson.M{"_id": id, "ExpiresAt": bson.M{"$gt": at}}).Decode(&code)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return code, ErrNotFound
+		}
+		return code, err
+	}
+	return code, nil
+}
+
+// MongoAPIKeys stores API keys in the API key collection
+type MongoAPIKeys struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoAPIKeys returns an API key repository backed by db
+func NewMongoAPIKeys(db *mongo.Database) *MongoAPIKeys {
+	return &MongoAPIKeys{Collection: db.Collection(database.APIKeyCollection)}
+}
+
+// Create inserts a new API key
+func (r *MongoAPIKeys) Create(ctx context.Context, key models.APIKey) error {
+	if _, err := r.Collection.InsertOne(ctx, key); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+// List returns every API key, newest first
+func (r *MongoAPIKeys) List(ctx context.Context) ([]models.APIKey, error) {
+	cursor, err := r.Collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// FindByHash finds an API key by the hash of its value
+func (r *MongoAPIKeys) FindByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	var key models.APIKey
+	if err := r.Collection.FindOne(ctx, bson.M{"Hash": hash}).Decode(&key); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return key, ErrNotFound
+		}
+		return key, err
+	}
+	return key, nil
+}
+
+// Revoke marks an API key as revoked
+func (r *MongoAPIKeys) Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	result, err := r.Collection.UpdateOne(ctx,
+		bson.M{"_id": id, "RevokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"RevokedAt": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+	// Already revoked, or no such key
+	count, err := r.Collection.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Touch sets the LastUsedAt of an API key
+func (r *MongoAPIKeys) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"LastUsedAt": at}})
+	return err
+}
+
+// MongoBaselines stores schedule baselines in the baseline collection
+type MongoBaselines struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoBaselines returns a baseline repository backed by db
+func NewMongoBaselines(db *mongo.Database) *MongoBaselines {
+	return &MongoBaselines{Collection: db.Collection(baseline.CollectionName)}
+}
+
+// Create inserts a new baseline
+func (r *MongoBaselines) Create(ctx context.Context, snapshot models.ScheduleBaseline) error {
+	_, err := r.Collection.InsertOne(ctx, snapshot)
+	return err
+}
+
+// List returns the baselines of a work package, newest first
+func (r *MongoBaselines) List(ctx context.Context, aircraftWorkPackageId int) ([]models.ScheduleBaseline, error) {
+	cursor, err := r.Collection.Find(ctx,
+		bson.M{"AircraftWorkPackageId": aircraftWorkPackageId},
+		options.Find().SetSort(bson.D{{Key: "CreatedDate", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	baselines := []models.ScheduleBaseline{}
+	if err := cursor.All(ctx, &baselines); err != nil {
+		return nil, err
+	}
+	return baselines, nil
+}
+
+// Find returns a baseline of a work package
+func (r *MongoBaselines) Find(ctx context.Context, aircraftWorkPackageId int, id primitive.ObjectID) (models.ScheduleBaseline, error) {
+	var snapshot models.ScheduleBaseline
+	err := r.Collection.FindOne(ctx, bson.M{"_id": id, "AircraftWorkPackageId": aircraftWorkPackageId}).Decode(&snapshot)
+	if err == mongo.ErrNoDocuments {
+		return snapshot, ErrNotFound
+	}
+	return snapshot, err
+}
+
+// Latest returns the most recent baseline of each of the work packages that has one
+func (r *MongoBaselines) Latest(ctx context.Context, aircraftWorkPackageIds []int) (map[int]models.ScheduleBaseline, error) {
+	return baseline.Latest(ctx, r.Collection, aircraftWorkPackageIds)
+}
+
+// MongoDependencies stores user-defined Gantt dependencies in the dependency collection
+type MongoDependencies struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoDependencies returns a dependency repository backed by db
+func NewMongoDependencies(db *mongo.Database) *MongoDependencies {
+	return &MongoDependencies{Collection: db.Collection(database.GanttDependencyCollection)}
+}
+
+// Find returns the dependencies with an end in any of the work packages, oldest first
+func (r *MongoDependencies) Find(ctx context.Context, aircraftWorkPackageIds []int) ([]models.GanttDependency, error) {
+	filter := bson.M{}
+	if len(aircraftWorkPackageIds) > 0 {
+		filter["$or"] = []bson.M{
+			{"SourceAircraftWpId": bson.M{"$in": aircraftWorkPackageIds}},
+			{"TargetAircraftWpId": bson.M{"$in": aircraftWorkPackageIds}},
+		}
+	}
+	cursor, err := r.Collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "CreatedDate", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	dependencies := []models.GanttDependency{}
+	if err := cursor.All(ctx, &dependencies); err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
+
+// FindByID returns the dependency with the given ID
+func (r *MongoDependencies) FindByID(ctx context.Context, id primitive.ObjectID) (models.GanttDependency, error) {
+	var dependency models.GanttDependency
+	err := r.Collection.FindOne(ctx, bson.M{"_id": id}).Decode(&dependency)
+	if err == mongo.ErrNoDocuments {
+		return dependency, ErrNotFound
+	}
+	return dependency, err
+}
+
+// Create inserts a new dependency
+func (r *MongoDependencies) Create(ctx context.Context, dependency models.GanttDependency) error {
+	_, err := r.Collection.InsertOne(ctx, dependency)
+	return err
+}
+
+// Delete removes a dependency
+func (r *MongoDependencies) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MongoWriteBacks stores the IFS write-back queue in the collection drained by ifs.WriteBackWorker
+type MongoWriteBacks struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoWriteBacks returns a write-back queue backed by db
+func NewMongoWriteBacks(db *mongo.Database) *MongoWriteBacks {
+	return &MongoWriteBacks{Collection: db.Collection(ifs.WriteBackCollection)}
+}
+
+// store returns the queue as the store of the ifs.WriteBackWorker, which implements the updates
+// shared with it
+func (r *MongoWriteBacks) store() *ifs.MongoWriteBackStore {
+	return &ifs.MongoWriteBackStore{Queue: r.Collection}
+}
+
+// Enqueue queues a planning change
+func (r *MongoWriteBacks) Enqueue(ctx context.Context, item models.IFSWriteBack) error {
+	return r.store().Enqueue(ctx, item)
+}
+
+// List returns up to limit items, most recently updated first
+func (r *MongoWriteBacks) List(ctx context.Context, filter WriteBackFilter, limit int64) ([]models.IFSWriteBack, error) {
+	query := bson.M{}
+	if len(filter.Statuses) > 0 {
+		query["Status"] = bson.M{"$in": filter.Statuses}
+	}
+	if len(filter.WorkPackageIds) > 0 {
+		query["AircraftWorkPackageId"] = bson.M{"$in": filter.WorkPackageIds}
+	}
+	cursor, err := r.Collection.Find(ctx, query,
+		options.Find().SetSort(bson.D{{Key: "UpdatedDate", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := []models.IFSWriteBack{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FindByID returns the queue item with the given ID
+func (r *MongoWriteBacks) FindByID(ctx context.Context, id primitive.ObjectID) (models.IFSWriteBack, error) {
+	var item models.IFSWriteBack
+	err := r.Collection.FindOne(ctx, bson.M{"_id": id}).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return item, ErrNotFound
+	}
+	return item, err
+}
+
+// Retry puts a failed or conflicting item back in the queue, see ifs.MongoWriteBackStore.Retry
+func (r *MongoWriteBacks) Retry(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	err := r.store().Retry(ctx, id, at)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,224 @@
+// Package repository hides how work packages, users and tokens are stored from the handlers.
+// MongoDB backs the server; the in-memory implementations back handler tests
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when the requested document does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a conditional update did not match because the document changed
+	ErrConflict = errors.New("document was changed concurrently")
+	// ErrDuplicate is returned when a document with the same unique key already exists
+	ErrDuplicate = errors.New("duplicate key")
+)
+
+// WorkPackageFilter selects work packages. Empty fields do not filter
+type WorkPackageFilter struct {
+	AircraftIds    []int
+	WorkPackageIds []int
+	LocationCodes  []string
+	IsHistoric     *bool
+
+	// Packages whose scheduled window overlaps [From, To]
+	From *time.Time
+	To   *time.Time
+
+	// IncludeArchived also selects packages moved to the archive. Find and Count only
+	IncludeArchived bool
+
+	// Scope selects only the packages visible to a user, see models.Scope. Find returns them
+	// with all their tasks; Scoped also leaves out the tasks outside the scope
+	Scope *models.Scope
+}
+
+// FindOptions pages and orders the result of a find. Results are ordered by SchedStartDateTime
+type FindOptions struct {
+	Skip       int64
+	Limit      int64 // 0 means no limit
+	Descending bool
+}
+
+// TaskFilter selects AvExeTasks across work packages. Empty fields do not filter
+type TaskFilter struct {
+	// Work package fields
+	AircraftIds    []int
+	WorkPackageIds []int
+	LocationCodes  []string
+	IsHistoric     *bool
+
+	// Task fields
+	Objstates          []string
+	ExcludeObjstates   []string
+	CrewCodes          []string
+	Locations          []string
+	ClassCodes         []string
+	PriorityIds        []int
+	FlightSafetyImpact *bool
+
+	// Tasks whose planned window overlaps [From, To]
+	From *time.Time
+	To   *time.Time
+
+	// Tasks whose LatestFinish lies in [DueFrom, DueTo]
+	DueFrom *time.Time
+	DueTo   *time.Time
+
+	// Scope selects only the tasks visible to a user
+	Scope *models.Scope
+}
+
+// ScheduleEdit is a date change of an AvExeTask or, when ExecutionInstanceSeq is set, of one of its
+// execution instances. ExpectedChangedDate is the ChangedDate the edit was validated against
+type ScheduleEdit struct {
+	TaskSeq              int
+	ExecutionInstanceSeq *int
+	ExpectedChangedDate  *time.Time
+	Start                time.Time
+	End                  time.Time
+	Duration             int // hours, tasks only
+	ChangedDate          time.Time
+}
+
+// WorkPackageRepository reads aircraft work packages and writes chart edits back to them
+type WorkPackageRepository interface {
+	Find(ctx context.Context, filter WorkPackageFilter, opts FindOptions) ([]models.AircraftWorkPackage, error)
+	Count(ctx context.Context, filter WorkPackageFilter) (int64, error)
+	FindByObjectID(ctx context.Context, id primitive.ObjectID) (models.AircraftWorkPackage, error)
+	FindByWorkPackageID(ctx context.Context, aircraftWorkPackageId int) (models.AircraftWorkPackage, error)
+
+	// FindArchivedByObjectID and FindArchivedByWorkPackageID look a package up in the archive only.
+	// The result has ArchivedDate set
+	FindArchivedByObjectID(ctx context.Context, id primitive.ObjectID) (models.AircraftWorkPackage, error)
+	FindArchivedByWorkPackageID(ctx context.Context, aircraftWorkPackageId int) (models.AircraftWorkPackage, error)
+
+	// FindTasks returns one page of the matching tasks ordered by PlannedStart, and the total number of matches
+	FindTasks(ctx context.Context, filter TaskFilter, opts FindOptions) ([]models.WorkPackageTask, int64, error)
+	FindTask(ctx context.Context, aircraftWorkPackageId int, taskSeq int) (models.WorkPackageTask, error)
+
+	// UpdateSchedule applies all edits of one work package at once. It returns ErrConflict
+	// without changing anything if a touched task or instance no longer has its expected ChangedDate
+	UpdateSchedule(ctx context.Context, aircraftWorkPackageId int, edits []ScheduleEdit) error
+}
+
+// UserRepository stores the accounts that can log in
+type UserRepository interface {
+	List(ctx context.Context) ([]models.User, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error)
+	// FindByLogin finds a user by username or email
+	FindByLogin(ctx context.Context, login string) (models.User, error)
+	FindByEmail(ctx context.Context, email string) (models.User, error)
+	FindByGoogleID(ctx context.Context, googleID string) (models.User, error)
+	// FindByExternalID finds the user linked to an OIDC account, see models.User.ExternalID
+	FindByExternalID(ctx context.Context, externalID string) (models.User, error)
+	Create(ctx context.Context, user models.User) error
+	Update(ctx context.Context, user models.User) error
+}
+
+// TokenRepository stores refresh tokens and the revocation list checked on every request
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token models.RefreshToken) error
+	// FindRefreshToken finds a refresh token by the hash of its value
+	FindRefreshToken(ctx context.Context, id string) (models.RefreshToken, error)
+	// UseRefreshToken marks a refresh token as used and replaced by the token replacedBy. It returns
+	// ErrConflict without changing anything if the token was already used or revoked
+	UseRefreshToken(ctx context.Context, id string, replacedBy string, at time.Time) error
+
+	// RevokeFamily revokes the refresh tokens of a family, and the access tokens issued with them
+	// until until
+	RevokeFamily(ctx context.Context, family string, at time.Time, until time.Time) error
+	// RevokeUser revokes every family of a user as RevokeFamily does
+	RevokeUser(ctx context.Context, userID primitive.ObjectID, at time.Time, until time.Time) error
+	// Revoke adds an access token ID to the revocation list until it expires
+	Revoke(ctx context.Context, id string, at time.Time, until time.Time) error
+	// IsRevoked reports whether any of the IDs, access token IDs or families, is on the revocation list
+	IsRevoked(ctx context.Context, ids ...string) (bool, error)
+
+	// CreateLoginCode stores a one-time login code
+	CreateLoginCode(ctx context.Context, code models.LoginCode) error
+	// RedeemLoginCode removes a login code by the hash of its value and returns it. It returns
+	// ErrNotFound if the code is unknown, was redeemed already or expired before at
+	RedeemLoginCode(ctx context.Context, id string, at time.Time) (models.LoginCode, error)
+}
+
+// APIKeyRepository stores the API keys of machine clients
+type APIKeyRepository interface {
+	Create(ctx context.Context, key models.APIKey) error
+	// List returns every key, revoked and expired ones included, newest first
+	List(ctx context.Context) ([]models.APIKey, error)
+	// FindByHash finds a key by the hash of its value, see HashAPIKey
+	FindByHash(ctx context.Context, hash string) (models.APIKey, error)
+	// Revoke marks a key as revoked. Revoking a revoked key keeps its first RevokedAt
+	Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// Touch records that a key was used at
+	Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+// BaselineRepository stores the schedule baselines of work packages
+type BaselineRepository interface {
+	Create(ctx context.Context, baseline models.ScheduleBaseline) error
+	// List returns the baselines of a work package, newest first
+	List(ctx context.Context, aircraftWorkPackageId int) ([]models.ScheduleBaseline, error)
+	// Find returns a baseline of a work package
+	Find(ctx context.Context, aircraftWorkPackageId int, id primitive.ObjectID) (models.ScheduleBaseline, error)
+	// Latest returns the most recent baseline of each of the work packages that has one
+	Latest(ctx context.Context, aircraftWorkPackageIds []int) (map[int]models.ScheduleBaseline, error)
+}
+
+// DependencyRepository stores the user-defined dependencies between Gantt tasks
+type DependencyRepository interface {
+	// Find returns the dependencies with an end in any of the work packages, or all of them
+	// when none are given, oldest first
+	Find(ctx context.Context, aircraftWorkPackageIds []int) ([]models.GanttDependency, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.GanttDependency, error)
+	Create(ctx context.Context, dependency models.GanttDependency) error
+	// Delete removes a dependency. It returns ErrNotFound if there is none with the ID
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// WriteBackFilter selects items of the IFS write-back queue. Empty fields do not filter
+type WriteBackFilter struct {
+	Statuses       []string
+	WorkPackageIds []int
+}
+
+// WriteBackRepository is the queue of planning changes sent to IFS by the ifs.WriteBackWorker
+type WriteBackRepository interface {
+	// Enqueue queues a planning change, replacing a pending change of the same target and
+	// superseding its failed or conflicting ones, see ifs.MongoWriteBackStore.Enqueue
+	Enqueue(ctx context.Context, item models.IFSWriteBack) error
+	// List returns up to limit items, most recently updated first
+	List(ctx context.Context, filter WriteBackFilter, limit int64) ([]models.IFSWriteBack, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.IFSWriteBack, error)
+	// Retry puts a failed or conflicting item back in the queue. It returns ErrNotFound if there
+	// is no item with the ID in either state, and ifs.ErrWriteBackSuperseded if a later change of
+	// the same target is pending; the item is superseded then
+	Retry(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up by
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// workPackageTask pairs a task with the keys of its work package
+func workPackageTask(wp models.AircraftWorkPackage, task models.AvExeTask) models.WorkPackageTask {
+	return models.WorkPackageTask{
+		AircraftWorkPackageId: wp.AircraftWorkPackageId,
+		AircraftId:            wp.AircraftId,
+		LocationCode:          wp.LocationCode,
+		WorkPackageName:       wp.WorkPackageName,
+		Task:                  task,
+	}
+}
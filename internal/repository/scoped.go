@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+
+	"stationMonitor/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ScopedWorkPackages limits a WorkPackageRepository to the data within a user scope. Work
+// packages outside the scope are not found, and work packages are returned with only the
+// tasks within the scope
+type ScopedWorkPackages struct {
+	Repo  WorkPackageRepository
+	Scope models.Scope
+}
+
+var _ WorkPackageRepository = (*ScopedWorkPackages)(nil)
+
+// Scoped returns repo limited to scope, or repo itself if the scope restricts nothing
+func Scoped(repo WorkPackageRepository, scope models.Scope) WorkPackageRepository {
+	if scope.IsZero() {
+		return repo
+	}
+	return &ScopedWorkPackages{Repo: repo, Scope: scope}
+}
+
+// Find returns the matching work packages within the scope
+func (r *ScopedWorkPackages) Find(ctx context.Context, filter WorkPackageFilter, opts FindOptions) ([]models.AircraftWorkPackage, error) {
+	filter.Scope = &r.Scope
+	workPackages, err := r.Repo.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range workPackages {
+		workPackages[i] = r.Scope.Restrict(workPackages[i])
+	}
+	return workPackages, nil
+}
+
+// Count returns the number of matching work packages within the scope
+func (r *ScopedWorkPackages) Count(ctx context.Context, filter WorkPackageFilter) (int64, error) {
+	filter.Scope = &r.Scope
+	return r.Repo.Count(ctx, filter)
+}
+
+// FindByObjectID returns the work package with the given document ID if it is within the scope
+func (r *ScopedWorkPackages) FindByObjectID(ctx context.Context, id primitive.ObjectID) (models.AircraftWorkPackage, error) {
+	return r.restrict(r.Repo.FindByObjectID(ctx, id))
+}
+
+// FindByWorkPackageID returns the work package with the given AircraftWorkPackageId if it is within the scope
+func (r *ScopedWorkPackages) FindByWorkPackageID(ctx context.Context, aircraftWorkPackageId int) (models.AircraftWorkPackage, error) {
+	return r.restrict(r.Repo.FindByWorkPackageID(ctx, aircraftWorkPackageId))
+}
+
+// FindArchivedByObjectID returns the archived work package with the given document ID if it is within the scope
+func (r *ScopedWorkPackages) FindArchivedByObjectID(ctx context.Context, id primitive.ObjectID) (models.AircraftWorkPackage, error) {
+	return r.restrict(r.Repo.FindArchivedByObjectID(ctx, id))
+}
+
+// FindArchivedByWorkPackageID returns the archived work package with the given AircraftWorkPackageId if it is within the scope
+func (r *ScopedWorkPackages) FindArchivedByWorkPackageID(ctx context.Context, aircraftWorkPackageId int) (models.AircraftWorkPackage, error) {
+	return r.restrict(r.Repo.FindArchivedByWorkPackageID(ctx, aircraftWorkPackageId))
+}
+
+// restrict passes on a work package found by ID with only the tasks within the scope, and
+// reports packages outside the scope as not found
+func (r *ScopedWorkPackages) restrict(wp models.AircraftWorkPackage, err error) (models.AircraftWorkPackage, error) {
+	if err != nil {
+		return wp, err
+	}
+	if !r.Scope.AllowsWorkPackage(wp) {
+		return models.AircraftWorkPackage{}, ErrNotFound
+	}
+	return r.Scope.Restrict(wp), nil
+}
+
+// FindTasks returns one page of the matching tasks within the scope
+func (r *ScopedWorkPackages) FindTasks(ctx context.Context, filter TaskFilter, opts FindOptions) ([]models.WorkPackageTask, int64, error) {
+	filter.Scope = &r.Scope
+	return r.Repo.FindTasks(ctx, filter, opts)
+}
+
+// FindTask returns a task if it and its work package are within the scope
+func (r *ScopedWorkPackages) FindTask(ctx context.Context, aircraftWorkPackageId int, taskSeq int) (models.WorkPackageTask, error) {
+	task, err := r.Repo.FindTask(ctx, aircraftWorkPackageId, taskSeq)
+	if err != nil {
+		return task, err
+	}
+	if !r.Scope.AllowsTask(task.Task) {
+		return models.WorkPackageTask{}, ErrNotFound
+	}
+	// The task does not carry the CustomerNo of its work package
+	count, err := r.Count(ctx, WorkPackageFilter{WorkPackageIds: []int{aircraftWorkPackageId}})
+	if err != nil {
+		return models.WorkPackageTask{}, err
+	}
+	if count == 0 {
+		return models.WorkPackageTask{}, ErrNotFound
+	}
+	return task, nil
+}
+
+// UpdateSchedule applies the edits if the work package and every edited task are within the
+// scope. It returns ErrNotFound otherwise
+func (r *ScopedWorkPackages) UpdateSchedule(ctx context.Context, aircraftWorkPackageId int, edits []ScheduleEdit) error {
+	wp, err := r.FindByWorkPackageID(ctx, aircraftWorkPackageId)
+	if err != nil {
+		return err
+	}
+	visible := map[int]bool{}
+	for _, task := range wp.Avexetask {
+		visible[task.TaskSeq] = true
+	}
+	for _, edit := range edits {
+		if !visible[edit.TaskSeq] {
+			return ErrNotFound
+		}
+	}
+	return r.Repo.UpdateSchedule(ctx, aircraftWorkPackageId, edits)
+}
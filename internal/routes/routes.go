@@ -1,19 +1,69 @@
 package routes
 
 import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"stationMonitor/internal/config"
 	"stationMonitor/internal/handlers"
 	"stationMonitor/internal/middleware"
+	"stationMonitor/internal/oidc"
+	"stationMonitor/internal/rbac"
+	"stationMonitor/internal/repository"
+	"stationMonitor/internal/stationtime"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupRoutes(router *gin.Engine, db *mongo.Database) {
+func SetupRoutes(router *gin.Engine, db *mongo.Database, cfg *config.Config, stations *stationtime.Registry) {
+	server := handlers.NewServer(cfg, repository.NewMongoWorkPackages(db), repository.NewMongoUsers(db))
+	server.Stations = stations
+	server.Baselines = repository.NewMongoBaselines(db)
+	server.Dependencies = repository.NewMongoDependencies(db)
+	// Chart edits are only queued for IFS when the write-back worker sends them
+	if cfg.IFS.BaseURL != "" {
+		server.WriteBacks = repository.NewMongoWriteBacks(db)
+	}
+	server.Tokens = repository.NewMongoTokens(db)
+	server.APIKeys = repository.NewMongoAPIKeys(db)
+	server.OIDC = oidc.NewRegistry(&http.Client{Timeout: 10 * time.Second})
+	RegisterRoutes(router, server)
+}
+
+// frontendOrigins returns the origins of the frontend pages OIDC logins return to. Only they
+// may send the login cookie with cross-origin requests
+func frontendOrigins(cfg *config.Config) map[string]bool {
+	urls := []string{cfg.OIDC.CallbackURL}
+	for _, provider := range cfg.OIDC.Providers {
+		urls = append(urls, provider.RedirectURL)
+		urls = append(urls, provider.RedirectURLs...)
+	}
+
+	origins := map[string]bool{}
+	for _, raw := range urls {
+		if parsed, err := url.Parse(raw); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+			origins[parsed.Scheme+"://"+parsed.Host] = true
+		}
+	}
+	return origins
+}
+
+// RegisterRoutes registers all routes on the router using the handlers of server
+func RegisterRoutes(router *gin.Engine, server *handlers.Server) {
+	origins := frontendOrigins(server.Config)
+
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		if origin := c.GetHeader("Origin"); origins[origin] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Add("Vary", "Origin")
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-API-Key")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -27,25 +77,71 @@ func SetupRoutes(router *gin.Engine, db *mongo.Database) {
 	// Public routes
 	api := router.Group("/api")
 	{
-		api.POST("/login", handlers.Login)
-		api.POST("/register", handlers.Register)
-		api.GET("/auth/google", handlers.GoogleOAuthLogin)
-		api.GET("/auth/google/callback", handlers.GoogleOAuthCallback)
+		api.POST("/login", server.Login)
+		api.POST("/register", server.Register)
+		api.POST("/token/refresh", server.RefreshToken)
+		api.POST("/auth/exchange", server.ExchangeLoginCode)
+		api.GET("/auth/google", server.GoogleOAuthLogin)
+		api.GET("/auth/google/callback", server.GoogleOAuthCallback)
+		api.GET("/auth/providers", server.GetAuthProviders)
+		api.GET("/auth/oidc/:provider", server.OIDCLogin)
+		api.GET("/auth/oidc/:provider/callback", server.OIDCCallback)
 	}
 
+	// Permissions checked per route, see package rbac
+	canRead := middleware.RequirePermission(rbac.ReadWorkPackages)
+	canSchedule := middleware.RequirePermission(rbac.WriteSchedule)
+	canManageIFS := middleware.RequirePermission(rbac.ManageIFS)
+	canManageUsers := middleware.RequirePermission(rbac.ManageUsers)
+	canManageAPIKeys := middleware.RequirePermission(rbac.ManageAPIKeys)
+
 	// Protected routes
 	protected := api.Group("/")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(server.Config.JWT.Secret, server.Tokens, server.APIKeys))
 	{
 		protected.GET("/dashboard", handlers.Dashboard)
+		protected.POST("/logout", server.Logout)
 		
 		// Gantt Chart routes
-		protected.GET("/gantt/tasks", handlers.GetGanttTasks)
+		protected.GET("/gantt/tasks", canRead, server.GetGanttTasks)
+		protected.GET("/gantt/tasks/:id", canRead, server.GetGanttTaskSource)
+		protected.POST("/gantt/tasks/resolve", canRead, server.ResolveGanttTaskSources)
+		protected.GET("/gantt/project", canRead, server.GetGanttProject)
+		protected.POST("/gantt/sync", canSchedule, server.SyncGantt)
+		protected.GET("/gantt/critical-path/:aircraftWorkPackageId", canRead, server.GetCriticalPath)
+		protected.GET("/gantt/resources", canRead, server.GetResourceView)
+		protected.GET("/gantt/dependencies", canRead, server.GetGanttDependencies)
+		protected.POST("/gantt/dependencies", canSchedule, server.CreateGanttDependency)
+		protected.DELETE("/gantt/dependencies/:id", canSchedule, server.DeleteGanttDependency)
+		protected.GET("/gantt/baselines/:aircraftWorkPackageId", canRead, server.GetScheduleBaselines)
+		protected.POST("/gantt/baselines/:aircraftWorkPackageId", canSchedule, server.CreateScheduleBaseline)
+		protected.GET("/gantt/baselines/:aircraftWorkPackageId/variance", canRead, server.GetScheduleVariance)
+		protected.GET("/ifs/write-back", canManageIFS, server.GetIFSWriteBackQueue)
+		protected.POST("/ifs/write-back/:id/retry", canManageIFS, server.RetryIFSWriteBack)
 		
 		// Aircraft Work Package routes
-		protected.GET("/aircraft-work-packages", handlers.GetAircraftWorkPackages)
-		protected.GET("/aircraft-work-packages/:id", handlers.GetAircraftWorkPackageByID)
-		protected.GET("/aircraft/:aircraftId/work-packages", handlers.GetAircraftWorkPackagesByAircraftId)
+		protected.GET("/aircraft-work-packages", canRead, server.GetAircraftWorkPackages)
+		protected.GET("/aircraft-work-packages/:id", canRead, server.GetAircraftWorkPackageByID)
+		protected.GET("/aircraft/:aircraftId/work-packages", canRead, server.GetAircraftWorkPackagesByAircraftId)
+
+		// Task routes, across work packages
+		protected.GET("/tasks", canRead, server.GetTasks)
+		protected.GET("/tasks/:aircraftWorkPackageId/:taskSeq", canRead, server.GetTask)
+
+		// Data quality report
+		protected.GET("/validation", canRead, server.GetValidationReport)
+		protected.GET("/validation/rules", canRead, handlers.GetValidationRules)
+
+		// User administration
+		protected.GET("/roles", handlers.GetRoles)
+		protected.GET("/users", canManageUsers, server.GetUsers)
+		protected.PUT("/users/:id/roles", canManageUsers, server.UpdateUserRoles)
+		protected.PUT("/users/:id/scope", canManageUsers, server.UpdateUserScope)
+
+		// API keys of machine clients
+		protected.GET("/api-keys", canManageAPIKeys, server.GetAPIKeys)
+		protected.POST("/api-keys", canManageAPIKeys, server.CreateAPIKey)
+		protected.DELETE("/api-keys/:id", canManageAPIKeys, server.RevokeAPIKey)
 	}
 }
 
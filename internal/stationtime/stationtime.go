@@ -0,0 +1,196 @@
+// Package stationtime shows UTC timestamps in the local time of the station a work package is at.
+// Stations are looked up by LocationCode in a registry of IANA zones; stations that are not
+// registered fall back to the UTC offset IFS stores next to every timestamp (the *Ofs fields)
+package stationtime
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"stationMonitor/internal/models"
+)
+
+// Station is the tz value that shows every work package in the zone of its own station
+const Station = "station"
+
+// Registry maps station LocationCodes to IANA time zones
+type Registry struct {
+	zones     map[string]*time.Location
+	defaultTZ string
+}
+
+// NewRegistry loads the zones of the stations. defaultTZ is used when a request names no tz
+// and is UTC when empty
+func NewRegistry(zones map[string]string, defaultTZ string) (*Registry, error) {
+	r := &Registry{zones: map[string]*time.Location{}, defaultTZ: defaultTZ}
+	for locationCode, name := range zones {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("station %s: unknown time zone %q", locationCode, name)
+		}
+		r.zones[locationCode] = location
+	}
+	if _, err := r.Parse(""); err != nil {
+		return nil, fmt.Errorf("default tz: %w", err)
+	}
+	return r, nil
+}
+
+// Zone returns the registered zone of a station, or nil if it has none
+func (r *Registry) Zone(locationCode string) *time.Location {
+	if r == nil {
+		return nil
+	}
+	return r.zones[locationCode]
+}
+
+// Parse reads a tz query parameter: "UTC", "station" or an IANA zone name.
+// An empty value means the registry default
+func (r *Registry) Parse(value string) (TZ, error) {
+	if value == "" && r != nil {
+		value = r.defaultTZ
+	}
+	switch {
+	case value == "" || strings.EqualFold(value, "UTC"):
+		return TZ{registry: r, location: time.UTC}, nil
+	case strings.EqualFold(value, Station):
+		return TZ{registry: r, station: true}, nil
+	}
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return TZ{}, fmt.Errorf("invalid tz: %s", value)
+	}
+	return TZ{registry: r, location: location}, nil
+}
+
+// TZ is the time zone a response is shown in
+type TZ struct {
+	registry *Registry
+	station  bool
+	location *time.Location
+}
+
+// String returns the zone name, or "station"
+func (tz TZ) String() string {
+	if tz.station {
+		return Station
+	}
+	if tz.location == nil {
+		return "UTC"
+	}
+	return tz.location.String()
+}
+
+// For returns the zone to show a work package's times in. For tz=station that is the registered
+// zone of the station, else the fixed offset (in hours) IFS stored, else UTC
+func (tz TZ) For(locationCode string, offset *float64) *time.Location {
+	if !tz.station {
+		if tz.location == nil {
+			return time.UTC
+		}
+		return tz.location
+	}
+	if location := tz.registry.Zone(locationCode); location != nil {
+		return location
+	}
+	if offset != nil {
+		return OffsetZone(*offset)
+	}
+	return time.UTC
+}
+
+// DayZone returns the zone whose midnight starts a day for date filters limited to the given
+// stations. For tz=station all stations must share a registered zone, otherwise days are UTC
+func (tz TZ) DayZone(locationCodes []string) *time.Location {
+	if !tz.station {
+		return tz.For("", nil)
+	}
+	var zone *time.Location
+	for _, locationCode := range locationCodes {
+		location := tz.registry.Zone(locationCode)
+		if location == nil || (zone != nil && location.String() != zone.String()) {
+			return time.UTC
+		}
+		zone = location
+	}
+	if zone == nil {
+		return time.UTC
+	}
+	return zone
+}
+
+// OffsetZone returns a fixed zone for an IFS offset in hours east of UTC
+func OffsetZone(hours float64) *time.Location {
+	seconds := int(math.Round(hours * 3600))
+	if seconds == 0 {
+		return time.UTC
+	}
+	sign, abs := '+', seconds
+	if seconds < 0 {
+		sign, abs = '-', -seconds
+	}
+	return time.FixedZone(fmt.Sprintf("UTC%c%02d:%02d", sign, abs/3600, abs%3600/60), seconds)
+}
+
+// WorkPackageOffset returns the first offset IFS stored on a task or execution instance of the
+// work package, or nil. Work packages carry no offset fields themselves
+func WorkPackageOffset(wp models.AircraftWorkPackage) *float64 {
+	for _, task := range wp.Avexetask {
+		if offset := TaskOffset(task); offset != nil {
+			return offset
+		}
+	}
+	return nil
+}
+
+// TaskOffset returns the offset IFS stored with the planned dates of a task or its instances, or nil
+func TaskOffset(task models.AvExeTask) *float64 {
+	if task.PlannedStartOfs != nil {
+		return task.PlannedStartOfs
+	}
+	if task.PlannedFinishOfs != nil {
+		return task.PlannedFinishOfs
+	}
+	for _, instance := range task.JtExecutionInstanceArray {
+		if instance.AllocatedStartOfs != nil {
+			return instance.AllocatedStartOfs
+		}
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Convert moves every time.Time and *time.Time reachable from v, which must be a pointer,
+// into location. The instants do not change, only the offset they are written with
+func Convert(v interface{}, location *time.Location) {
+	convertValue(reflect.ValueOf(v), location)
+}
+
+func convertValue(v reflect.Value, location *time.Location) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			convertValue(v.Elem(), location)
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(v.Interface().(time.Time).In(location)))
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				convertValue(v.Field(i), location)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			convertValue(v.Index(i), location)
+		}
+	}
+}
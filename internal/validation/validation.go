@@ -0,0 +1,305 @@
+// Package validation checks work packages against data quality rules, so problems in the
+// IFS data are reported and can be fixed at the source instead of being skipped silently
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"stationMonitor/internal/models"
+)
+
+// Severity of an issue
+type Severity string
+
+const (
+	// Error: the data contradicts itself, e.g. an end before its start. Imports reject it
+	Error Severity = "error"
+	// Warning: the data is inconsistent or incomplete; the Gantt skips or clips it
+	Warning Severity = "warning"
+	// Info: worth knowing, the data is still usable
+	Info Severity = "info"
+)
+
+// rank orders the severities, most severe first
+var rank = map[Severity]int{Error: 0, Warning: 1, Info: 2}
+
+// ParseSeverity reads a severity name; the empty string means Info, so every issue is included
+func ParseSeverity(value string) (Severity, error) {
+	if value == "" {
+		return Info, nil
+	}
+	severity := Severity(strings.ToLower(value))
+	if _, found := rank[severity]; !found {
+		return "", fmt.Errorf("severity must be error, warning or info")
+	}
+	return severity, nil
+}
+
+// AtLeast reports whether s is as severe as min or more
+func (s Severity) AtLeast(min Severity) bool {
+	return rank[s] <= rank[min]
+}
+
+// Rule is a data quality check
+type Rule struct {
+	ID          string   `json:"id"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+}
+
+// Rule IDs
+const (
+	MissingKey           = "missing-key"
+	DuplicateKey         = "duplicate-key"
+	MismatchedKey        = "mismatched-key"
+	EndBeforeStart       = "end-before-start"
+	ActualEndBeforeStart = "actual-end-before-start"
+	NegativeDuration     = "negative-duration"
+	MissingSchedDates    = "missing-sched-dates"
+	MissingTaskDates     = "missing-task-dates"
+	TaskOutsidePackage   = "task-outside-package"
+	InstanceOutsideTask  = "instance-outside-task"
+)
+
+// Rules lists every rule Check applies
+var Rules = []Rule{
+	{MissingKey, Error, "AircraftWorkPackageId, TaskSeq or ExecutionInstanceSeq is missing"},
+	{DuplicateKey, Error, "TaskSeq or ExecutionInstanceSeq is used twice in the same parent"},
+	{MismatchedKey, Error, "AircraftWpId of a task or TaskSeq of an execution instance names another parent"},
+	{EndBeforeStart, Error, "a scheduled, planned or allocated end lies before its start"},
+	{ActualEndBeforeStart, Error, "an actual end lies before the actual start"},
+	{NegativeDuration, Error, "a duration or hours field is negative"},
+	{MissingSchedDates, Warning, "the work package has no SchedStartDateTime or SchedEndDateTime and is left out of the Gantt"},
+	{MissingTaskDates, Info, "the task has neither PlannedStart/PlannedFinish nor EarliestStart/LatestFinish and is left out of the Gantt"},
+	{TaskOutsidePackage, Warning, "the planned dates of a task lie outside the scheduled window of its work package"},
+	{InstanceOutsideTask, Warning, "the allocated dates of an execution instance lie outside the planned dates of its task"},
+}
+
+// severityOf returns the severity of a rule
+func severityOf(ruleID string) Severity {
+	for _, rule := range Rules {
+		if rule.ID == ruleID {
+			return rule.Severity
+		}
+	}
+	return Info
+}
+
+// KnownRule reports whether ruleID names one of the Rules
+func KnownRule(ruleID string) bool {
+	for _, rule := range Rules {
+		if rule.ID == ruleID {
+			return true
+		}
+	}
+	return false
+}
+
+// Issue is one rule violation
+type Issue struct {
+	Rule                  string   `json:"rule"`
+	Severity              Severity `json:"severity"`
+	AircraftWorkPackageId int      `json:"aircraftWorkPackageId"`
+	TaskSeq               int      `json:"taskSeq,omitempty"`
+	ExecutionInstanceSeq  int      `json:"executionInstanceSeq,omitempty"`
+	Message               string   `json:"message"`
+}
+
+// String formats the issue with the task and execution instance it concerns
+func (i Issue) String() string {
+	message := i.Message
+	if i.ExecutionInstanceSeq != 0 {
+		message = fmt.Sprintf("execution instance %d: %s", i.ExecutionInstanceSeq, message)
+	}
+	if i.TaskSeq != 0 {
+		message = fmt.Sprintf("task %d: %s", i.TaskSeq, message)
+	}
+	return message
+}
+
+// checker collects the issues of one work package
+type checker struct {
+	wpId   int
+	issues []Issue
+}
+
+func (c *checker) add(rule string, taskSeq, instanceSeq int, format string, args ...interface{}) {
+	c.issues = append(c.issues, Issue{
+		Rule:                  rule,
+		Severity:              severityOf(rule),
+		AircraftWorkPackageId: c.wpId,
+		TaskSeq:               taskSeq,
+		ExecutionInstanceSeq:  instanceSeq,
+		Message:               fmt.Sprintf(format, args...),
+	})
+}
+
+// endBeforeStart adds an issue if both dates are set and end lies before start
+func (c *checker) endBeforeStart(rule string, taskSeq, instanceSeq int, start, end *time.Time, startName, endName string) {
+	if start != nil && end != nil && end.Before(*start) {
+		c.add(rule, taskSeq, instanceSeq, "%s is before %s", endName, startName)
+	}
+}
+
+// outside adds an issue if [start, end] is not within [windowStart, windowEnd]. Missing dates are not checked
+func (c *checker) outside(rule string, taskSeq, instanceSeq int, start, end, windowStart, windowEnd *time.Time, what, window string) {
+	if start != nil && windowStart != nil && start.Before(*windowStart) {
+		c.add(rule, taskSeq, instanceSeq, "%s start %s is before the %s", what, start.Format(time.RFC3339), window)
+	}
+	if end != nil && windowEnd != nil && end.After(*windowEnd) {
+		c.add(rule, taskSeq, instanceSeq, "%s end %s is after the %s", what, end.Format(time.RFC3339), window)
+	}
+}
+
+// Check applies every rule to the work package, its tasks and their execution instances
+func Check(wp models.AircraftWorkPackage) []Issue {
+	c := &checker{wpId: wp.AircraftWorkPackageId}
+
+	if wp.AircraftWorkPackageId == 0 {
+		c.add(MissingKey, 0, 0, "AircraftWorkPackageId is required")
+	}
+	if wp.SchedStartDateTime == nil || wp.SchedEndDateTime == nil {
+		c.add(MissingSchedDates, 0, 0, "SchedStartDateTime and SchedEndDateTime are required for the Gantt")
+	}
+	c.endBeforeStart(EndBeforeStart, 0, 0, wp.SchedStartDateTime, wp.SchedEndDateTime, "SchedStartDateTime", "SchedEndDateTime")
+	c.endBeforeStart(ActualEndBeforeStart, 0, 0, wp.ActualStartDateTime, wp.ActualEndDateTime, "ActualStartDateTime", "ActualEndDateTime")
+	if wp.Duration < 0 {
+		c.add(NegativeDuration, 0, 0, "Duration is %g", wp.Duration)
+	}
+
+	taskSeqs := map[int]bool{}
+	for i, task := range wp.Avexetask {
+		if task.TaskSeq == 0 {
+			c.add(MissingKey, 0, 0, "AvTaskArray[%d]: TaskSeq is required", i)
+			continue
+		}
+		if taskSeqs[task.TaskSeq] {
+			c.add(DuplicateKey, 0, 0, "AvTaskArray[%d]: duplicate TaskSeq %d", i, task.TaskSeq)
+		}
+		taskSeqs[task.TaskSeq] = true
+		checkTask(c, wp, task)
+	}
+
+	return c.issues
+}
+
+// checkTask applies the task rules and checks the execution instances of the task
+func checkTask(c *checker, wp models.AircraftWorkPackage, task models.AvExeTask) {
+	seq := task.TaskSeq
+
+	if task.AircraftWpId != 0 && task.AircraftWpId != wp.AircraftWorkPackageId {
+		c.add(MismatchedKey, seq, 0, "AircraftWpId %d does not match the work package", task.AircraftWpId)
+	}
+	c.endBeforeStart(EndBeforeStart, seq, 0, task.PlannedStart, task.PlannedFinish, "PlannedStart", "PlannedFinish")
+	c.endBeforeStart(ActualEndBeforeStart, seq, 0, task.ActualStart, task.ActualFinish, "ActualStart", "ActualFinish")
+	if task.Duration != nil && *task.Duration < 0 {
+		c.add(NegativeDuration, seq, 0, "Duration is %d", *task.Duration)
+	}
+	if task.MinVisitDuration != nil && *task.MinVisitDuration < 0 {
+		c.add(NegativeDuration, seq, 0, "MinVisitDuration is %d", *task.MinVisitDuration)
+	}
+
+	planned := task.PlannedStart != nil && task.PlannedFinish != nil
+	if !planned && (task.EarliestStart == nil || task.LatestFinish == nil) {
+		c.add(MissingTaskDates, seq, 0, "no PlannedStart/PlannedFinish or EarliestStart/LatestFinish")
+	}
+	c.outside(TaskOutsidePackage, seq, 0, task.PlannedStart, task.PlannedFinish,
+		wp.SchedStartDateTime, wp.SchedEndDateTime, "planned", "work package window")
+
+	instanceSeqs := map[int]bool{}
+	for j, instance := range task.JtExecutionInstanceArray {
+		if instance.ExecutionInstanceSeq == 0 {
+			c.add(MissingKey, seq, 0, "JtExecutionInstanceArray[%d]: ExecutionInstanceSeq is required", j)
+			continue
+		}
+		instanceSeq := instance.ExecutionInstanceSeq
+		if instanceSeqs[instanceSeq] {
+			c.add(DuplicateKey, seq, 0, "duplicate ExecutionInstanceSeq %d", instanceSeq)
+		}
+		instanceSeqs[instanceSeq] = true
+
+		if instance.TaskSeq != 0 && instance.TaskSeq != seq {
+			c.add(MismatchedKey, seq, instanceSeq, "TaskSeq is %d", instance.TaskSeq)
+		}
+		c.endBeforeStart(EndBeforeStart, seq, instanceSeq, instance.AllocatedStart, instance.AllocatedFinish, "AllocatedStart", "AllocatedFinish")
+		c.endBeforeStart(ActualEndBeforeStart, seq, instanceSeq, instance.WorkStart, instance.WorkFinish, "WorkStart", "WorkFinish")
+		if instance.AllocatedHours != nil && *instance.AllocatedHours < 0 {
+			c.add(NegativeDuration, seq, instanceSeq, "AllocatedHours is %g", *instance.AllocatedHours)
+		}
+		if instance.ActualWorkedHours != nil && *instance.ActualWorkedHours < 0 {
+			c.add(NegativeDuration, seq, instanceSeq, "ActualWorkedHours is %g", *instance.ActualWorkedHours)
+		}
+		if planned {
+			c.outside(InstanceOutsideTask, seq, instanceSeq, instance.AllocatedStart, instance.AllocatedFinish,
+				task.PlannedStart, task.PlannedFinish, "allocated", "planned task window")
+		}
+	}
+}
+
+// Report summarises the issues of many work packages
+type Report struct {
+	Severity               Severity         `json:"severity"`        // least severe level included
+	Rules                  []string         `json:"rules,omitempty"` // rules included, all if empty
+	Checked                int              `json:"checked"`
+	WorkPackagesWithIssues int              `json:"workPackagesWithIssues"`
+	Counts                 map[Severity]int `json:"counts"`
+	RuleCounts             map[string]int   `json:"ruleCounts"`
+	Issues                 []Issue          `json:"issues"`
+}
+
+// NewReport returns an empty report that keeps issues of at least the given severity
+func NewReport(min Severity) *Report {
+	return &Report{
+		Severity:   min,
+		Counts:     map[Severity]int{Error: 0, Warning: 0, Info: 0},
+		RuleCounts: map[string]int{},
+		Issues:     []Issue{},
+	}
+}
+
+// Add checks a work package and records its issues
+func (r *Report) Add(wp models.AircraftWorkPackage) {
+	r.Checked++
+	found := false
+	for _, issue := range Check(wp) {
+		if !issue.Severity.AtLeast(r.Severity) || (len(r.Rules) > 0 && !containsString(r.Rules, issue.Rule)) {
+			continue
+		}
+		found = true
+		r.Counts[issue.Severity]++
+		r.RuleCounts[issue.Rule]++
+		r.Issues = append(r.Issues, issue)
+	}
+	if found {
+		r.WorkPackagesWithIssues++
+	}
+}
+
+// Sort orders the issues by severity, then work package, task and execution instance
+func (r *Report) Sort() {
+	sort.SliceStable(r.Issues, func(i, j int) bool {
+		a, b := r.Issues[i], r.Issues[j]
+		if a.Severity != b.Severity {
+			return rank[a.Severity] < rank[b.Severity]
+		}
+		if a.AircraftWorkPackageId != b.AircraftWorkPackageId {
+			return a.AircraftWorkPackageId < b.AircraftWorkPackageId
+		}
+		if a.TaskSeq != b.TaskSeq {
+			return a.TaskSeq < b.TaskSeq
+		}
+		return a.ExecutionInstanceSeq < b.ExecutionInstanceSeq
+	})
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package validation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"stationMonitor/internal/models"
+)
+
+// at returns 2026-03-02 at the given hour, UTC
+func at(hour int) *time.Time {
+	t := time.Date(2026, 3, 2, hour, 0, 0, 0, time.UTC)
+	return &t
+}
+
+// validWorkPackage returns a work package from 8:00 to 24:00 without issues. Task 1 runs
+// 8:00-16:00 with execution instance 1 allocated 9:00-12:00, task 2 runs 16:00-24:00
+func validWorkPackage() models.AircraftWorkPackage {
+	return models.AircraftWorkPackage{
+		AircraftWorkPackageId: 1,
+		SchedStartDateTime:    at(8),
+		SchedEndDateTime:      at(24),
+		Avexetask: []models.AvExeTask{
+			{
+				TaskSeq:       1,
+				PlannedStart:  at(8),
+				PlannedFinish: at(16),
+				JtExecutionInstanceArray: []models.JtExecutionInstance{
+					{ExecutionInstanceSeq: 1, TaskSeq: 1, AllocatedStart: at(9), AllocatedFinish: at(12)},
+				},
+			},
+			{TaskSeq: 2, PlannedStart: at(16), PlannedFinish: at(24)},
+		},
+	}
+}
+
+// issueKey identifies an issue by rule, task and execution instance
+func issueKey(rule string, taskSeq, instanceSeq int) string {
+	return fmt.Sprintf("%s %d/%d", rule, taskSeq, instanceSeq)
+}
+
+// reportKey identifies an issue of a report by work package, rule, task and execution instance
+func reportKey(wpId int, rule string, taskSeq, instanceSeq int) string {
+	return fmt.Sprintf("WP %d: %s", wpId, issueKey(rule, taskSeq, instanceSeq))
+}
+
+func TestCheck(t *testing.T) {
+	negative, negativeHours := -1, -1.5
+
+	tests := []struct {
+		name   string
+		change func(wp *models.AircraftWorkPackage)
+		want   []string
+	}{
+		{"valid", func(wp *models.AircraftWorkPackage) {}, nil},
+		{"work package without key", func(wp *models.AircraftWorkPackage) { wp.AircraftWorkPackageId = 0 }, []string{issueKey(MissingKey, 0, 0)}},
+		{"task without key", func(wp *models.AircraftWorkPackage) { wp.Avexetask[1].TaskSeq = 0 }, []string{issueKey(MissingKey, 0, 0)}},
+		{"instance without key", func(wp *models.AircraftWorkPackage) {
+			wp.Avexetask[0].JtExecutionInstanceArray[0].ExecutionInstanceSeq = 0
+		}, []string{issueKey(MissingKey, 1, 0)}},
+		{"duplicate task", func(wp *models.AircraftWorkPackage) { wp.Avexetask[1].TaskSeq = 1 }, []string{issueKey(DuplicateKey, 0, 0)}},
+		{"duplicate instance", func(wp *models.AircraftWorkPackage) {
+			task := &wp.Avexetask[0]
+			task.JtExecutionInstanceArray = append(task.JtExecutionInstanceArray, task.JtExecutionInstanceArray[0])
+		}, []string{issueKey(DuplicateKey, 1, 0)}},
+		{"task of another package", func(wp *models.AircraftWorkPackage) { wp.Avexetask[0].AircraftWpId = 2 }, []string{issueKey(MismatchedKey, 1, 0)}},
+		{"instance of another task", func(wp *models.AircraftWorkPackage) {
+			wp.Avexetask[0].JtExecutionInstanceArray[0].TaskSeq = 2
+		}, []string{issueKey(MismatchedKey, 1, 1)}},
+		{"package ends before it starts", func(wp *models.AircraftWorkPackage) {
+			wp.SchedEndDateTime = at(6)
+			wp.Avexetask = nil
+		}, []string{issueKey(EndBeforeStart, 0, 0)}},
+		{"task ends before it starts", func(wp *models.AircraftWorkPackage) { wp.Avexetask[1].PlannedFinish = at(12) }, []string{issueKey(EndBeforeStart, 2, 0)}},
+		{"instance ends before it starts", func(wp *models.AircraftWorkPackage) {
+			wp.Avexetask[0].JtExecutionInstanceArray[0].AllocatedFinish = at(8)
+		}, []string{issueKey(EndBeforeStart, 1, 1)}},
+		{"package actually ends before it starts", func(wp *models.AircraftWorkPackage) {
+			wp.ActualStartDateTime, wp.ActualEndDateTime = at(10), at(9)
+		}, []string{issueKey(ActualEndBeforeStart, 0, 0)}},
+		{"task actually ends before it starts", func(wp *models.AircraftWorkPackage) {
+			wp.Avexetask[0].ActualStart, wp.Avexetask[0].ActualFinish = at(10), at(9)
+		}, []string{issueKey(ActualEndBeforeStart, 1, 0)}},
+		{"work ends before it starts", func(wp *models.AircraftWorkPackage) {
+			instance := &wp.Avexetask[0].JtExecutionInstanceArray[0]
+			instance.WorkStart, instance.WorkFinish = at(10), at(9)
+		}, []string{issueKey(ActualEndBeforeStart, 1, 1)}},
+		{"negative package duration", func(wp *models.AircraftWorkPackage) { wp.Duration = -2 }, []string{issueKey(NegativeDuration, 0, 0)}},
+		{"negative task durations", func(wp *models.AircraftWorkPackage) {
+			wp.Avexetask[0].Duration = &negative
+			wp.Avexetask[1].MinVisitDuration = &negative
+		}, []string{issueKey(NegativeDuration, 1, 0), issueKey(NegativeDuration, 2, 0)}},
+		{"negative instance hours", func(wp *models.AircraftWorkPackage) {
+			instance := &wp.Avexetask[0].JtExecutionInstanceArray[0]
+			instance.AllocatedHours, instance.ActualWorkedHours = &negativeHours, &negativeHours
+		}, []string{issueKey(NegativeDuration, 1, 1), issueKey(NegativeDuration, 1, 1)}},
+		{"package without end", func(wp *models.AircraftWorkPackage) { wp.SchedEndDateTime = nil }, []string{issueKey(MissingSchedDates, 0, 0)}},
+		{"task without dates", func(wp *models.AircraftWorkPackage) { wp.Avexetask[1].PlannedStart = nil }, []string{issueKey(MissingTaskDates, 2, 0)}},
+		{"task with only the earliest and latest dates", func(wp *models.AircraftWorkPackage) {
+			wp.Avexetask[1].PlannedStart, wp.Avexetask[1].PlannedFinish = nil, nil
+			wp.Avexetask[1].EarliestStart, wp.Avexetask[1].LatestFinish = at(16), at(24)
+		}, nil},
+		{"task after the package", func(wp *models.AircraftWorkPackage) { wp.Avexetask[1].PlannedFinish = at(26) }, []string{issueKey(TaskOutsidePackage, 2, 0)}},
+		{"task around the package", func(wp *models.AircraftWorkPackage) {
+			wp.Avexetask[1].PlannedStart, wp.Avexetask[1].PlannedFinish = at(7), at(26)
+		}, []string{issueKey(TaskOutsidePackage, 2, 0), issueKey(TaskOutsidePackage, 2, 0)}},
+		{"instance after the task", func(wp *models.AircraftWorkPackage) {
+			wp.Avexetask[0].JtExecutionInstanceArray[0].AllocatedFinish = at(17)
+		}, []string{issueKey(InstanceOutsideTask, 1, 1)}},
+	}
+
+	covered := map[string]bool{}
+	for _, tt := range tests {
+		wp := validWorkPackage()
+		tt.change(&wp)
+
+		got := []string{}
+		for _, issue := range Check(wp) {
+			got = append(got, issueKey(issue.Rule, issue.TaskSeq, issue.ExecutionInstanceSeq))
+			covered[issue.Rule] = true
+			if issue.Severity != severityOf(issue.Rule) || issue.AircraftWorkPackageId != wp.AircraftWorkPackageId || issue.Message == "" {
+				t.Errorf("%s: issue %+v", tt.name, issue)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, rule := range Rules {
+		if !covered[rule.ID] {
+			t.Errorf("no case for rule %s", rule.ID)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Severity
+		ok    bool
+	}{
+		{"", Info, true},
+		{"error", Error, true},
+		{"Warning", Warning, true},
+		{"INFO", Info, true},
+		{"fatal", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSeverity(tt.value)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseSeverity(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestAtLeast(t *testing.T) {
+	tests := []struct {
+		severity, min Severity
+		want          bool
+	}{
+		{Error, Error, true},
+		{Error, Info, true},
+		{Warning, Error, false},
+		{Warning, Warning, true},
+		{Warning, Info, true},
+		{Info, Warning, false},
+		{Info, Info, true},
+	}
+	for _, tt := range tests {
+		if got := tt.severity.AtLeast(tt.min); got != tt.want {
+			t.Errorf("%s.AtLeast(%s) = %v, want %v", tt.severity, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	// Package 2 has an error and a warning, package 1 a warning and an info, package 3 none
+	second := validWorkPackage()
+	second.AircraftWorkPackageId = 2
+	second.Avexetask[1].PlannedFinish = at(12)
+	second.Avexetask[0].JtExecutionInstanceArray[0].AllocatedFinish = at(17)
+	first := validWorkPackage()
+	first.Avexetask[1].PlannedStart = nil
+	first.Avexetask[0].PlannedFinish = at(26)
+	clean := validWorkPackage()
+	clean.AircraftWorkPackageId = 3
+
+	tests := []struct {
+		name       string
+		severity   Severity
+		rules      []string
+		want       []string
+		counts     map[Severity]int
+		withIssues int
+	}{
+		{"all", Info, nil,
+			[]string{reportKey(2, EndBeforeStart, 2, 0), reportKey(1, TaskOutsidePackage, 1, 0), reportKey(2, InstanceOutsideTask, 1, 1), reportKey(1, MissingTaskDates, 2, 0)},
+			map[Severity]int{Error: 1, Warning: 2, Info: 1}, 2},
+		{"warnings", Warning, nil,
+			[]string{reportKey(2, EndBeforeStart, 2, 0), reportKey(1, TaskOutsidePackage, 1, 0), reportKey(2, InstanceOutsideTask, 1, 1)},
+			map[Severity]int{Error: 1, Warning: 2, Info: 0}, 2},
+		{"errors", Error, nil, []string{reportKey(2, EndBeforeStart, 2, 0)}, map[Severity]int{Error: 1, Warning: 0, Info: 0}, 1},
+		{"one rule", Info, []string{MissingTaskDates}, []string{reportKey(1, MissingTaskDates, 2, 0)}, map[Severity]int{Error: 0, Warning: 0, Info: 1}, 1},
+	}
+	for _, tt := range tests {
+		report := NewReport(tt.severity)
+		report.Rules = tt.rules
+		for _, wp := range []models.AircraftWorkPackage{second, first, clean} {
+			report.Add(wp)
+		}
+		report.Sort()
+
+		got := []string{}
+		for _, issue := range report.Issues {
+			got = append(got, reportKey(issue.AircraftWorkPackageId, issue.Rule, issue.TaskSeq, issue.ExecutionInstanceSeq))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: issues %v, want %v", tt.name, got, tt.want)
+		}
+		if fmt.Sprint(report.Counts) != fmt.Sprint(tt.counts) || report.Checked != 3 || report.WorkPackagesWithIssues != tt.withIssues {
+			t.Errorf("%s: counts %v, %d of %d packages with issues, want %v and %d of 3", tt.name, report.Counts, report.WorkPackagesWithIssues, report.Checked, tt.counts, tt.withIssues)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -21,9 +22,10 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Get username and password from command line or use defaults
+	// Get username, password and comma-separated roles from command line or use defaults
 	username := "admin"
 	password := "password123"
+	roles := []string{"admin"}
 
 	if len(os.Args) > 1 {
 		username = os.Args[1]
@@ -31,6 +33,9 @@ func main() {
 	if len(os.Args) > 2 {
 		password = os.Args[2]
 	}
+	if len(os.Args) > 3 {
+		roles = strings.Split(os.Args[3], ",")
+	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -64,12 +69,12 @@ func main() {
 	var existingUser bson.M
 	err = collection.FindOne(ctx, bson.M{"username": username}).Decode(&existingUser)
 	if err == nil {
-		fmt.Printf("User '%s' already exists. Updating password...\n", username)
+		fmt.Printf("User '%s' already exists. Updating password and roles...\n", username)
 		// Update existing user
 		_, err = collection.UpdateOne(
 			ctx,
 			bson.M{"username": username},
-			bson.M{"$set": bson.M{"password": string(hashedPassword)}},
+			bson.M{"$set": bson.M{"password": string(hashedPassword), "roles": roles}},
 		)
 		if err != nil {
 			fmt.Printf("Error updating user: %v\n", err)
@@ -81,6 +86,7 @@ func main() {
 		_, err = collection.InsertOne(ctx, bson.M{
 			"username": username,
 			"password": string(hashedPassword),
+			"roles":    roles,
 		})
 		if err != nil {
 			fmt.Printf("Error creating user: %v\n", err)
@@ -95,5 +101,6 @@ func main() {
 	fmt.Printf("\nLogin credentials:\n")
 	fmt.Printf("Username: %s\n", username)
 	fmt.Printf("Password: %s\n", password)
+	fmt.Printf("Roles: %s\n", strings.Join(roles, ","))
 }
 